@@ -0,0 +1,179 @@
+// Package anim resamples, reduces and smooths the keyframes of bone animations
+package anim
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/xackery/quail/helper"
+)
+
+// Key is where a bone is at a time. raw.AniBoneFrame and wce.AniBoneFrame convert to and from it
+type Key struct {
+	Milliseconds uint32
+	Translation  [3]float32
+	Rotation     [4]float32 // x, y, z, w
+	Scale        [3]float32
+}
+
+// Options are the steps Process takes. A step with its zero value is skipped
+type Options struct {
+	LoopBlend uint32  // milliseconds at the end blended into the first key, so a looping animation has no seam
+	Rate      float64 // frames per second to resample at
+	Angle     float64 // radians a removed key may be off by
+	Distance  float32 // units the translation or scale of a removed key may be off by
+}
+
+// Validate checks the options are in range
+func (opts Options) Validate() error {
+	if opts.Rate < 0 || opts.Rate > 1000 {
+		return fmt.Errorf("rate %g must be between 0 and 1000", opts.Rate)
+	}
+	if opts.Angle < 0 || opts.Distance < 0 {
+		return fmt.Errorf("tolerance must not be negative")
+	}
+	return nil
+}
+
+// IsZero returns true if Process would not change keys
+func (opts Options) IsZero() bool {
+	return opts == Options{}
+}
+
+// Process smooths the loop seam, resamples and then reduces keys, as the options ask
+func (opts Options) Process(keys []Key) []Key {
+	if opts.LoopBlend > 0 {
+		keys = SmoothLoop(keys, opts.LoopBlend)
+	}
+	if opts.Rate > 0 {
+		keys = Resample(keys, uint32(math.Max(1, math.Round(1000/opts.Rate))))
+	}
+	if opts.Angle > 0 || opts.Distance > 0 {
+		keys = Reduce(keys, opts.Angle, opts.Distance)
+	}
+	return keys
+}
+
+// Sample returns where a bone is at ms, between the keys around it. Rotations are spherically
+// interpolated. keys are in time order, and times outside them hold the first or last key
+func Sample(keys []Key, ms uint32) Key {
+	if len(keys) == 0 {
+		return Key{Milliseconds: ms, Rotation: helper.QuatIdentity, Scale: [3]float32{1, 1, 1}}
+	}
+	next := sort.Search(len(keys), func(i int) bool { return keys[i].Milliseconds >= ms })
+	if next == 0 {
+		key := keys[0]
+		key.Milliseconds = ms
+		return key
+	}
+	if next == len(keys) {
+		key := keys[len(keys)-1]
+		key.Milliseconds = ms
+		return key
+	}
+	a := keys[next-1]
+	b := keys[next]
+	return interpolate(a, b, float32(ms-a.Milliseconds)/float32(b.Milliseconds-a.Milliseconds), ms)
+}
+
+func interpolate(a Key, b Key, t float32, ms uint32) Key {
+	out := Key{Milliseconds: ms}
+	for i := 0; i < 3; i++ {
+		out.Translation[i] = a.Translation[i] + (b.Translation[i]-a.Translation[i])*t
+		out.Scale[i] = a.Scale[i] + (b.Scale[i]-a.Scale[i])*t
+	}
+	out.Rotation = helper.QuatSlerp(a.Rotation, b.Rotation, t)
+	return out
+}
+
+// Resample returns keys evenly spread from the first key to the last, about interval ms apart.
+// The spacing is stretched so the last key keeps its time
+func Resample(keys []Key, interval uint32) []Key {
+	if len(keys) < 2 || interval == 0 {
+		return append([]Key{}, keys...)
+	}
+	start := keys[0].Milliseconds
+	duration := keys[len(keys)-1].Milliseconds - start
+	count := int(math.Max(1, math.Round(float64(duration)/float64(interval))))
+	out := []Key{}
+	for i := 0; i <= count; i++ {
+		ms := start + uint32(math.Round(float64(i)*float64(duration)/float64(count)))
+		out = append(out, Sample(keys, ms))
+	}
+	return out
+}
+
+// Reduce returns keys without those the keys kept around them reproduce, within angle radians and
+// distance units. The first and last keys are always kept
+func Reduce(keys []Key, angle float64, distance float32) []Key {
+	if len(keys) < 3 {
+		return append([]Key{}, keys...)
+	}
+	out := []Key{keys[0]}
+	anchor := 0
+	for i := 2; i < len(keys); i++ {
+		if reduceFits(keys, anchor, i, angle, distance) {
+			continue
+		}
+		anchor = i - 1
+		out = append(out, keys[anchor])
+	}
+	return append(out, keys[len(keys)-1])
+}
+
+// reduceFits returns true if interpolating from keys[from] to keys[to] reproduces every key between
+func reduceFits(keys []Key, from int, to int, angle float64, distance float32) bool {
+	a := keys[from]
+	b := keys[to]
+	for i := from + 1; i < to; i++ {
+		key := keys[i]
+		t := float32(0)
+		if b.Milliseconds > a.Milliseconds {
+			t = float32(key.Milliseconds-a.Milliseconds) / float32(b.Milliseconds-a.Milliseconds)
+		}
+		guess := interpolate(a, b, t, key.Milliseconds)
+		if helper.QuatAngle(guess.Rotation, key.Rotation) > angle {
+			return false
+		}
+		if vecDistance(guess.Translation, key.Translation) > distance || vecDistance(guess.Scale, key.Scale) > distance {
+			return false
+		}
+	}
+	return true
+}
+
+// SmoothLoop returns keys with those in the last blend ms eased toward the first key, so the last
+// key matches the first and a looping animation plays without a jump
+func SmoothLoop(keys []Key, blend uint32) []Key {
+	out := append([]Key{}, keys...)
+	if len(out) < 2 || blend == 0 {
+		return out
+	}
+	first := out[0]
+	end := out[len(out)-1].Milliseconds
+	start := first.Milliseconds
+	if end-start > blend {
+		start = end - blend
+	}
+	for i := 1; i < len(out); i++ {
+		if out[i].Milliseconds < start {
+			continue
+		}
+		t := float32(1)
+		if end > start {
+			t = float32(out[i].Milliseconds-start) / float32(end-start)
+		}
+		// smoothstep, so the blend starts and ends gently
+		t = t * t * (3 - 2*t)
+		out[i] = interpolate(out[i], first, t, out[i].Milliseconds)
+	}
+	return out
+}
+
+func vecDistance(a [3]float32, b [3]float32) float32 {
+	x := a[0] - b[0]
+	y := a[1] - b[1]
+	z := a[2] - b[2]
+	return float32(math.Sqrt(float64(x*x + y*y + z*z)))
+}
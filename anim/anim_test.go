@@ -0,0 +1,117 @@
+package anim
+
+import (
+	"math"
+	"testing"
+)
+
+// spin returns keys turning about z at a constant speed, every interval ms until duration
+func spin(interval uint32, duration uint32) []Key {
+	keys := []Key{}
+	for ms := uint32(0); ms <= duration; ms += interval {
+		angle := float64(ms) / 1000 * math.Pi / 2 // 90 degrees a second
+		keys = append(keys, Key{
+			Milliseconds: ms,
+			Translation:  [3]float32{float32(ms) / 100, 0, 0},
+			Rotation:     [4]float32{0, 0, float32(math.Sin(angle / 2)), float32(math.Cos(angle / 2))},
+			Scale:        [3]float32{1, 1, 1},
+		})
+	}
+	return keys
+}
+
+func TestSample(t *testing.T) {
+	keys := spin(1000, 1000)
+	key := Sample(keys, 500)
+	want := spin(500, 500)[1]
+	if math.Abs(float64(key.Translation[0]-want.Translation[0])) > 1e-4 {
+		t.Fatalf("translation: got %v, want %v", key.Translation, want.Translation)
+	}
+	// slerp keeps a constant speed, where nlerp would not
+	for i := 0; i < 4; i++ {
+		if math.Abs(float64(key.Rotation[i]-want.Rotation[i])) > 1e-4 {
+			t.Fatalf("rotation: got %v, want %v", key.Rotation, want.Rotation)
+		}
+	}
+	if key := Sample(keys, 2000); key.Milliseconds != 2000 || key.Translation != keys[1].Translation {
+		t.Fatalf("past the end: got %+v, want the last key", key)
+	}
+}
+
+func TestResample(t *testing.T) {
+	keys := spin(10, 1000)
+	out := Resample(keys, 100)
+	if len(out) != 11 {
+		t.Fatalf("keys: got %d, want 11", len(out))
+	}
+	for i, key := range out {
+		if key.Milliseconds != uint32(i*100) {
+			t.Fatalf("key %d: got %d ms, want %d", i, key.Milliseconds, i*100)
+		}
+	}
+
+	// a spacing that does not divide the duration is stretched to keep the last key
+	out = Resample(keys, 300)
+	if len(out) != 4 || out[3].Milliseconds != 1000 {
+		t.Fatalf("stretched: got %d keys ending at %d ms, want 4 ending at 1000", len(out), out[len(out)-1].Milliseconds)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	keys := spin(10, 1000)
+	out := Reduce(keys, 0.001, 0.001)
+	if len(out) != 2 {
+		t.Fatalf("constant motion: got %d keys, want 2", len(out))
+	}
+
+	// a key that jumps away from the motion stays
+	keys[50].Translation[1] = 5
+	out = Reduce(keys, 0.001, 0.001)
+	isKept := false
+	for _, key := range out {
+		if key.Milliseconds == 500 {
+			isKept = true
+		}
+	}
+	if !isKept || len(out) > 5 {
+		t.Fatalf("jump: got %d keys, want the key at 500 ms kept", len(out))
+	}
+	for _, key := range keys {
+		got := Sample(out, key.Milliseconds)
+		if vecDistance(got.Translation, key.Translation) > 0.001 {
+			t.Fatalf("%d ms: got %v, want %v", key.Milliseconds, got.Translation, key.Translation)
+		}
+	}
+}
+
+func TestSmoothLoop(t *testing.T) {
+	keys := spin(100, 1000)
+	out := SmoothLoop(keys, 300)
+	if len(out) != len(keys) {
+		t.Fatalf("keys: got %d, want %d", len(out), len(keys))
+	}
+	last := out[len(out)-1]
+	if vecDistance(last.Translation, keys[0].Translation) > 1e-4 {
+		t.Fatalf("last translation: got %v, want %v", last.Translation, keys[0].Translation)
+	}
+	for i := 0; i < 7; i++ {
+		if out[i] != keys[i] {
+			t.Fatalf("key %d before the blend changed", i)
+		}
+	}
+	// eased, so each key in the blend moves toward the start
+	if !(out[8].Translation[0] < keys[8].Translation[0] && out[9].Translation[0] < out[8].Translation[0]) {
+		t.Fatalf("blend: got %v %v", out[8].Translation, out[9].Translation)
+	}
+}
+
+func TestOptionsProcess(t *testing.T) {
+	opts := Options{Rate: 10, Angle: 0.001, Distance: 0.001}
+	out := opts.Process(spin(10, 1000))
+	if len(out) != 2 || out[1].Milliseconds != 1000 {
+		t.Fatalf("keys: got %+v", out)
+	}
+	if err := (Options{Rate: -1}).Validate(); err == nil {
+		t.Fatalf("negative rate: want error")
+	}
+}
@@ -0,0 +1,131 @@
+// Package bake computes per-vertex lighting from point lights, for zones without baked lighting
+package bake
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Light is a point light
+type Light struct {
+	Position [3]float32
+	Color    [3]float32 // 0 to 1 per channel
+	Radius   float32    // distance the light fades out at, lights with no radius are skipped
+}
+
+// Baker lights vertices with point lights and an ambient color
+type Baker struct {
+	Lights  []Light
+	Ambient [3]float32 // added to every vertex, 0 to 1 per channel
+	Scene   *Scene     // triangles that cast shadows, nil for no shadows
+	Bias    float32    // distance shadow rays start off a vertex along its normal, so it does not shadow itself
+}
+
+// Color returns the light reaching a point with a normal, 0 to 1 per channel. A zero normal is lit from every side.
+// Lights fade out linearly to their radius and by the angle they hit the surface at
+func (b *Baker) Color(position, normal [3]float32) [3]float32 {
+	color := b.Ambient
+	normal = normalize(normal)
+	origin := position
+	for i := range origin {
+		origin[i] += normal[i] * b.Bias
+	}
+	for _, light := range b.Lights {
+		if light.Radius <= 0 {
+			continue
+		}
+		toLight := sub(light.Position, position)
+		distance := float32(math.Sqrt(float64(dot(toLight, toLight))))
+		if distance >= light.Radius {
+			continue
+		}
+		intensity := 1 - distance/light.Radius
+		if distance > 0 && normal != [3]float32{} {
+			intensity *= dot(normal, toLight) / distance
+		}
+		if intensity <= 0 {
+			continue
+		}
+		if b.Scene.IsOccluded(origin, light.Position) {
+			continue
+		}
+		for i := range color {
+			color[i] += light.Color[i] * intensity
+		}
+	}
+	return color
+}
+
+// Bake returns the rgba color of each vertex, with alpha 255. normals may be nil or shorter than positions
+func (b *Baker) Bake(positions [][3]float32, normals [][3]float32) [][4]uint8 {
+	colors := make([][4]uint8, len(positions))
+	workers := runtime.NumCPU()
+	chunk := (len(positions) + workers - 1) / workers
+	wg := sync.WaitGroup{}
+	for start := 0; start < len(positions); start += chunk {
+		end := min(start+chunk, len(positions))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				normal := [3]float32{}
+				if i < len(normals) {
+					normal = normals[i]
+				}
+				colors[i] = Quantize(b.Color(positions[i], normal))
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return colors
+}
+
+// Validate returns an error if the baker has a light that can not be baked
+func (b *Baker) Validate() error {
+	for i, light := range b.Lights {
+		for _, v := range append(append([]float32{}, light.Position[:]...), light.Color[:]...) {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				return fmt.Errorf("light %d: position or color is not a number", i)
+			}
+		}
+		if light.Radius < 0 {
+			return fmt.Errorf("light %d: radius %0.2f is negative", i, light.Radius)
+		}
+	}
+	if b.Bias < 0 {
+		return fmt.Errorf("bias %0.2f is negative", b.Bias)
+	}
+	return nil
+}
+
+// Quantize converts a 0 to 1 color to rgba bytes with alpha 255, clamping each channel
+func Quantize(color [3]float32) [4]uint8 {
+	rgba := [4]uint8{0, 0, 0, 255}
+	for i, v := range color {
+		v = float32(math.Round(float64(v * 255)))
+		rgba[i] = uint8(max(0, min(255, v)))
+	}
+	return rgba
+}
+
+func normalize(v [3]float32) [3]float32 {
+	length := float32(math.Sqrt(float64(dot(v, v))))
+	if length == 0 {
+		return v
+	}
+	return [3]float32{v[0] / length, v[1] / length, v[2] / length}
+}
+
+func sub(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func cross(a, b [3]float32) [3]float32 {
+	return [3]float32{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
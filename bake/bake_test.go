@@ -0,0 +1,95 @@
+package bake
+
+import (
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	b := &Baker{
+		Lights:  []Light{{Position: [3]float32{0, 0, 10}, Color: [3]float32{1, 0, 0}, Radius: 20}},
+		Ambient: [3]float32{0.1, 0.1, 0.1},
+	}
+	color := b.Color([3]float32{}, [3]float32{0, 0, 2})
+	if color != [3]float32{0.6, 0.1, 0.1} {
+		t.Fatalf("facing: got %v, want half red over ambient", color)
+	}
+	color = b.Color([3]float32{}, [3]float32{0, 0, -1})
+	if color != b.Ambient {
+		t.Fatalf("facing away: got %v, want ambient", color)
+	}
+	color = b.Color([3]float32{0, 0, -15}, [3]float32{})
+	if color != b.Ambient {
+		t.Fatalf("past radius: got %v, want ambient", color)
+	}
+	// a vertex without a normal takes the light from any side
+	color = b.Color([3]float32{0, 0, 20}, [3]float32{})
+	if color != [3]float32{0.6, 0.1, 0.1} {
+		t.Fatalf("no normal: got %v", color)
+	}
+}
+
+func TestShadows(t *testing.T) {
+	positions := [][3]float32{}
+	indices := []uint32{}
+	// a roof of small quads at z 5 with a one unit hole around x 5.5, y 0.5
+	for x := 0; x < 10; x++ {
+		for y := -5; y < 5; y++ {
+			if x == 5 && y == 0 {
+				continue
+			}
+			base := uint32(len(positions))
+			positions = append(positions,
+				[3]float32{float32(x), float32(y), 5}, [3]float32{float32(x + 1), float32(y), 5},
+				[3]float32{float32(x + 1), float32(y + 1), 5}, [3]float32{float32(x), float32(y + 1), 5})
+			indices = append(indices, base, base+1, base+2, base, base+2, base+3)
+		}
+	}
+	// the floor the vertices sit on
+	positions = append(positions, [3]float32{-50, -50, 0}, [3]float32{50, -50, 0}, [3]float32{0, 50, 0})
+	floor := uint32(len(positions))
+	indices = append(indices, floor-3, floor-2, floor-1)
+
+	scene := NewScene(positions, indices)
+	if scene.Len() != 199 {
+		t.Fatalf("scene: got %d triangles, want 199", scene.Len())
+	}
+	b := &Baker{
+		Lights: []Light{{Position: [3]float32{5.5, 0.5, 10}, Color: [3]float32{1, 1, 1}, Radius: 100}},
+		Scene:  scene,
+		Bias:   0.01,
+	}
+	up := [3]float32{0, 0, 1}
+	if b.Color([3]float32{5.5, 0.5, 0}, up)[0] == 0 {
+		t.Fatalf("under the hole: want lit")
+	}
+	if b.Color([3]float32{2.5, 0.5, 0}, up)[0] != 0 {
+		t.Fatalf("under the roof: want shadowed")
+	}
+	b.Scene = nil
+	if b.Color([3]float32{2.5, 0.5, 0}, up)[0] == 0 {
+		t.Fatalf("no scene: want lit")
+	}
+}
+
+func TestBake(t *testing.T) {
+	b := &Baker{
+		Lights:  []Light{{Position: [3]float32{0, 0, 1}, Color: [3]float32{2, 1, 0}, Radius: 2}},
+		Ambient: [3]float32{0, 0, 0.2},
+	}
+	colors := b.Bake([][3]float32{{0, 0, 0}, {0, 0, 10}}, nil)
+	if len(colors) != 2 {
+		t.Fatalf("colors: got %d, want 2", len(colors))
+	}
+	// the first vertex is half way to the radius, red clamps at 255
+	if colors[0] != [4]uint8{255, 128, 51, 255} {
+		t.Fatalf("lit: got %v", colors[0])
+	}
+	if colors[1] != [4]uint8{0, 0, 51, 255} {
+		t.Fatalf("unlit: got %v", colors[1])
+	}
+
+	b.Lights[0].Radius = -1
+	if b.Validate() == nil {
+		t.Fatalf("negative radius: want error")
+	}
+}
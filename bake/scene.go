@@ -0,0 +1,189 @@
+package bake
+
+import (
+	"math"
+	"sort"
+)
+
+// sceneLeafSize is the most triangles a bvh leaf holds
+const sceneLeafSize = 4
+
+// Scene is triangles that cast shadows, kept in a bounding volume hierarchy for ray casts
+type Scene struct {
+	tris  [][3][3]float32
+	nodes []sceneNode
+}
+
+// sceneNode is a bvh node. Leaves have no children and own count triangles from start
+type sceneNode struct {
+	min   [3]float32
+	max   [3]float32
+	left  int
+	right int
+	start int
+	count int
+}
+
+// NewScene builds a scene from triangles, 3 indices per face. Indices out of range are skipped
+func NewScene(positions [][3]float32, indices []uint32) *Scene {
+	s := &Scene{}
+	for i := 0; i+2 < len(indices); i += 3 {
+		if int(indices[i]) >= len(positions) || int(indices[i+1]) >= len(positions) || int(indices[i+2]) >= len(positions) {
+			continue
+		}
+		s.tris = append(s.tris, [3][3]float32{positions[indices[i]], positions[indices[i+1]], positions[indices[i+2]]})
+	}
+	if len(s.tris) > 0 {
+		s.build(0, len(s.tris))
+	}
+	return s
+}
+
+// Len returns the number of triangles in the scene
+func (s *Scene) Len() int {
+	return len(s.tris)
+}
+
+// build adds the node for tris[start:end] and its children, returning its index
+func (s *Scene) build(start, end int) int {
+	node := sceneNode{left: -1, right: -1, start: start, count: end - start}
+	node.min, node.max = s.tris[start][0], s.tris[start][0]
+	centerMin := triCenter(s.tris[start])
+	centerMax := centerMin
+	for _, tri := range s.tris[start:end] {
+		for _, vert := range tri {
+			node.min, node.max = boundsAdd(node.min, node.max, vert)
+		}
+		centerMin, centerMax = boundsAdd(centerMin, centerMax, triCenter(tri))
+	}
+	index := len(s.nodes)
+	s.nodes = append(s.nodes, node)
+	if end-start <= sceneLeafSize {
+		return index
+	}
+
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if centerMax[i]-centerMin[i] > centerMax[axis]-centerMin[axis] {
+			axis = i
+		}
+	}
+	if centerMax[axis] == centerMin[axis] {
+		return index
+	}
+	tris := s.tris[start:end]
+	sort.Slice(tris, func(i, j int) bool {
+		return triCenter(tris[i])[axis] < triCenter(tris[j])[axis]
+	})
+	mid := start + (end-start)/2
+	left := s.build(start, mid)
+	right := s.build(mid, end)
+	s.nodes[index].left = left
+	s.nodes[index].right = right
+	s.nodes[index].count = 0
+	return index
+}
+
+// IsOccluded returns true if a triangle crosses the segment between from and to
+func (s *Scene) IsOccluded(from, to [3]float32) bool {
+	if s == nil || len(s.nodes) == 0 {
+		return false
+	}
+	dir := sub(to, from)
+	inv := [3]float32{}
+	for i := range dir {
+		inv[i] = float32(math.Inf(1))
+		if dir[i] != 0 {
+			inv[i] = 1 / dir[i]
+		}
+	}
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &s.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !boxHit(node.min, node.max, from, inv) {
+			continue
+		}
+		if node.left < 0 {
+			for _, tri := range s.tris[node.start : node.start+node.count] {
+				if segmentHit(tri, from, dir) {
+					return true
+				}
+			}
+			continue
+		}
+		stack = append(stack, node.left, node.right)
+	}
+	return false
+}
+
+// boxHit returns true if the segment from origin along 1/inv, for t from 0 to 1, touches a box
+func boxHit(min, max, origin, inv [3]float32) bool {
+	tMin, tMax := float32(0), float32(1)
+	for i := 0; i < 3; i++ {
+		if math.IsInf(float64(inv[i]), 0) {
+			if origin[i] < min[i] || origin[i] > max[i] {
+				return false
+			}
+			continue
+		}
+		t1 := (min[i] - origin[i]) * inv[i]
+		t2 := (max[i] - origin[i]) * inv[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = float32(math.Max(float64(tMin), float64(t1)))
+		tMax = float32(math.Min(float64(tMax), float64(t2)))
+		if tMin > tMax {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentHit returns true if the segment from origin to origin+dir crosses a triangle, either side.
+// Hits at the very ends do not count, so surfaces at the light or the vertex do not shadow
+func segmentHit(tri [3][3]float32, origin, dir [3]float32) bool {
+	const epsilon = 1e-6
+	edge1 := sub(tri[1], tri[0])
+	edge2 := sub(tri[2], tri[0])
+	p := cross(dir, edge2)
+	det := dot(edge1, p)
+	if det > -epsilon && det < epsilon {
+		return false
+	}
+	invDet := 1 / det
+	t := sub(origin, tri[0])
+	u := dot(t, p) * invDet
+	if u < 0 || u > 1 {
+		return false
+	}
+	q := cross(t, edge1)
+	v := dot(dir, q) * invDet
+	if v < 0 || u+v > 1 {
+		return false
+	}
+	distance := dot(edge2, q) * invDet
+	return distance > 1e-4 && distance < 1-1e-4
+}
+
+func triCenter(tri [3][3]float32) [3]float32 {
+	return [3]float32{
+		(tri[0][0] + tri[1][0] + tri[2][0]) / 3,
+		(tri[0][1] + tri[1][1] + tri[2][1]) / 3,
+		(tri[0][2] + tri[1][2] + tri[2][2]) / 3,
+	}
+}
+
+func boundsAdd(min, max, v [3]float32) ([3]float32, [3]float32) {
+	for i := 0; i < 3; i++ {
+		if v[i] < min[i] {
+			min[i] = v[i]
+		}
+		if v[i] > max[i] {
+			max[i] = v[i]
+		}
+	}
+	return min, max
+}
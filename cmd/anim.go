@@ -0,0 +1,445 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/wce"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	rootCmd.AddCommand(animCmd)
+	animCmd.AddCommand(animListCmd)
+	animCmd.AddCommand(animCopyCmd)
+	animCmd.AddCommand(animRemoveCmd)
+	animCmd.AddCommand(animRenameCmd)
+	animCmd.AddCommand(animSpeedCmd)
+	animCmd.AddCommand(animRetargetCmd)
+	animListCmd.Flags().String("skeleton", "", "only list animations of this skeleton, such as HUM_HS_DEF")
+	animCopyCmd.Flags().String("from", "", "skeleton the animations are made for, such as HUM_HS_DEF")
+	animCopyCmd.Flags().String("to", "", "skeleton to copy the animations to, such as ELF_HS_DEF")
+	animCopyCmd.Flags().String("bones", "", "yaml file mapping bones of the from skeleton to bones of the to skeleton")
+	animCopyCmd.Flags().StringSlice("code", nil, "animation codes to copy, such as C01 (default all)")
+	animRemoveCmd.Flags().String("skeleton", "", "skeleton to remove animations from (default the only one)")
+	animRemoveCmd.Flags().StringSlice("code", nil, "animation codes to remove, such as C01")
+	animRenameCmd.Flags().String("skeleton", "", "skeleton of the animation (default the only one)")
+	animSpeedCmd.Flags().String("skeleton", "", "skeleton of the animation (default the only one)")
+	animRetargetCmd.Flags().String("from", "", "skeleton the animations are made for, such as HUM_HS_DEF")
+	animRetargetCmd.Flags().String("to", "", "skeleton to move the animations to, such as ZZZ_HS_DEF")
+	animRetargetCmd.Flags().String("bones", "", "yaml file mapping bones of the from skeleton to bones of the to skeleton")
+	animRetargetCmd.Flags().StringSlice("code", nil, "animation codes to retarget, such as C01 (default all)")
+	animRetargetCmd.Flags().Bool("root-motion", false, "keep the movement of root bones instead of playing in place")
+}
+
+// animCmd represents the anim command
+var animCmd = &cobra.Command{
+	Use:   "anim",
+	Short: "Work with the animations of character models",
+	Long: `Work with the animations of s3d hierarchical sprites and eqg mds models
+Usage: quail anim <list|copy|remove|rename|speed|retarget> ...`,
+}
+
+// animListCmd represents the anim list command
+var animListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List animations",
+	Long: `List the code, name, frame count and duration of each animation of each skeleton
+Usage: quail anim list <src> [--skeleton <skeleton>]
+Example: quail anim list global_chr.s3d
+Example: quail anim list elf.eqg --skeleton elf`,
+	RunE: runAnimList,
+}
+
+func runAnimList(cmd *cobra.Command, args []string) error {
+	err := runAnimListE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimListE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	skelTag, err := cmd.Flags().GetString("skeleton")
+	if err != nil {
+		return fmt.Errorf("parse skeleton: %w", err)
+	}
+	q, err := animLoad(args[0])
+	if err != nil {
+		return err
+	}
+
+	skels, err := q.Wld.Skeletons()
+	if err != nil {
+		return err
+	}
+	if skelTag != "" {
+		skel, err := q.Wld.Skeleton(skelTag)
+		if err != nil {
+			return err
+		}
+		skels = []*wce.Skeleton{skel}
+	}
+	for _, skel := range skels {
+		anims := q.Wld.Animations(skel)
+		fmt.Printf("%s: %d bones, %d animations\n", skel.Tag, len(skel.Bones), len(anims))
+		for _, anim := range anims {
+			name := helper.AnimationName(anim.Code)
+			if name == "" {
+				name = "unknown"
+			}
+			fmt.Printf("  %s %s: %d frames, %dms, %d bones\n", anim.Code, name, anim.FrameCount(), anim.Duration(), len(anim.Bones))
+		}
+	}
+	return nil
+}
+
+// animCopyCmd represents the anim copy command
+var animCopyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy animations from another model",
+	Long: `Copy the animations of the from skeleton in src to the to skeleton in dst as they are, and save dst.
+An animation dst already has is replaced. Bones are matched by name, without the model code,
+unless the bones file maps them. Use retarget instead for skeletons of another size
+Usage: quail anim copy <src> <dst> --from <skeleton> --to <skeleton> [--bones bones.yaml] [--code C01]
+Example: quail anim copy global_chr.s3d global_chr.s3d --from HUM_HS_DEF --to ELF_HS_DEF --code C01
+Example: quail anim copy elf.eqg zzz.eqg --from elf --to zzz`,
+	RunE: runAnimCopy,
+}
+
+func runAnimCopy(cmd *cobra.Command, args []string) error {
+	err := runAnimCopyE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimCopyE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	return animTransfer(cmd, args, false)
+}
+
+// animRemoveCmd represents the anim remove command
+var animRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove animations",
+	Long: `Remove animations, with the track of every bone or their .ani file, and save to dst
+Usage: quail anim remove <src> <dst> --code <code> [--skeleton <skeleton>]
+Example: quail anim remove elf_chr.s3d elf_chr.s3d --code C01,C02
+Example: quail anim remove global_chr.s3d global_chr.s3d --skeleton HUM_HS_DEF --code S16`,
+	RunE: runAnimRemove,
+}
+
+func runAnimRemove(cmd *cobra.Command, args []string) error {
+	err := runAnimRemoveE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimRemoveE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	codes, err := cmd.Flags().GetStringSlice("code")
+	if err != nil {
+		return fmt.Errorf("parse code: %w", err)
+	}
+	if len(codes) == 0 {
+		return fmt.Errorf("--code is required")
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		count := q.Wld.AnimationRemove(skel, code)
+		if count == 0 {
+			return fmt.Errorf("animation %s not found for %s", code, skel.Tag)
+		}
+		fmt.Printf("Removed %s from %s (%d %s)\n", strings.ToUpper(code), skel.Tag, count, animUnit(skel, count))
+	}
+	return quailSave(q, args[1])
+}
+
+// animRenameCmd represents the anim rename command
+var animRenameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Change the code of an animation",
+	Long: `Change the code of an animation, renaming the track of every bone or its .ani file, and save to dst
+Usage: quail anim rename <src> <dst> <old> <new> [--skeleton <skeleton>]
+Example: quail anim rename elf_chr.s3d elf_chr.s3d C01 C11
+Example: quail anim rename elf.eqg elf.eqg L01 L06 --skeleton elf`,
+	RunE: runAnimRename,
+}
+
+func runAnimRename(cmd *cobra.Command, args []string) error {
+	err := runAnimRenameE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimRenameE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	count, err := q.Wld.AnimationRename(skel, args[2], args[3])
+	if err != nil {
+		return fmt.Errorf("%s: %w", skel.Tag, err)
+	}
+	fmt.Printf("Renamed %s to %s in %s (%d %s)\n", strings.ToUpper(args[2]), strings.ToUpper(args[3]), skel.Tag, count, animUnit(skel, count))
+	return quailSave(q, args[1])
+}
+
+// animSpeedCmd represents the anim speed command
+var animSpeedCmd = &cobra.Command{
+	Use:   "speed",
+	Short: "Change how fast an animation plays",
+	Long: `Retime an animation to play speed times as fast, and save to dst.
+Tracks keep their frames and change how long each is shown, .ani keyframes move
+Usage: quail anim speed <src> <dst> <code> <speed> [--skeleton <skeleton>]
+Example: quail anim speed elf_chr.s3d elf_chr.s3d L02 1.5
+Example: quail anim speed elf.eqg elf.eqg C01 0.5`,
+	RunE: runAnimSpeed,
+}
+
+func runAnimSpeed(cmd *cobra.Command, args []string) error {
+	err := runAnimSpeedE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimSpeedE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	speed, err := strconv.ParseFloat(args[3], 64)
+	if err != nil {
+		return fmt.Errorf("parse speed: %w", err)
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	count, err := q.Wld.AnimationSpeed(skel, args[2], speed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", skel.Tag, err)
+	}
+	fmt.Printf("Set %s of %s to %gx speed (%d %s)\n", strings.ToUpper(args[2]), skel.Tag, speed, count, animUnit(skel, count))
+	return quailSave(q, args[1])
+}
+
+// animRetargetCmd represents the anim retarget command
+var animRetargetCmd = &cobra.Command{
+	Use:   "retarget",
+	Short: "Move animations to another skeleton",
+	Long: `Move the animations of the from skeleton in src to the to skeleton in dst, and save dst.
+Bones are matched by name, without the model code, unless the bones file maps them, such as:
+  PE: PELVIS
+  CH: CHEST
+Translations are scaled by how much longer each bone of the to skeleton is
+Usage: quail anim retarget <src> <dst> --from <skeleton> --to <skeleton> [--bones bones.yaml] [--code C01] [--root-motion]
+Example: quail anim retarget global_chr.s3d zzz_chr.s3d --from HUM_HS_DEF --to ZZZ_HS_DEF
+Example: quail anim retarget global_chr.s3d zzz.eqg --from HUM --to zzz --bones hum_zzz.yaml --code C01,C02`,
+	RunE: runAnimRetarget,
+}
+
+func runAnimRetarget(cmd *cobra.Command, args []string) error {
+	err := runAnimRetargetE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runAnimRetargetE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	return animTransfer(cmd, args, true)
+}
+
+// animTransfer copies or retargets the animations of the from skeleton in src to the to skeleton in dst
+func animTransfer(cmd *cobra.Command, args []string, isRetarget bool) error {
+	fromTag, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return fmt.Errorf("parse from: %w", err)
+	}
+	toTag, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return fmt.Errorf("parse to: %w", err)
+	}
+	if fromTag == "" || toTag == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+	bonesPath, err := cmd.Flags().GetString("bones")
+	if err != nil {
+		return fmt.Errorf("parse bones: %w", err)
+	}
+	codes, err := cmd.Flags().GetStringSlice("code")
+	if err != nil {
+		return fmt.Errorf("parse code: %w", err)
+	}
+	isRootMotion := false
+	if isRetarget {
+		isRootMotion, err = cmd.Flags().GetBool("root-motion")
+		if err != nil {
+			return fmt.Errorf("parse root-motion: %w", err)
+		}
+	}
+
+	boneMap := make(map[string]string)
+	if bonesPath != "" {
+		data, err := os.ReadFile(bonesPath)
+		if err != nil {
+			return fmt.Errorf("read bones: %w", err)
+		}
+		err = yaml.Unmarshal(data, &boneMap)
+		if err != nil {
+			return fmt.Errorf("decode bones %s: %w", filepath.Base(bonesPath), err)
+		}
+	}
+
+	srcQ, err := animLoad(args[0])
+	if err != nil {
+		return err
+	}
+	dstQ := srcQ
+	if args[1] != args[0] {
+		dstQ, err = animLoad(args[1])
+		if err != nil {
+			return err
+		}
+	}
+	from, err := srcQ.Wld.Skeleton(fromTag)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(args[0]), err)
+	}
+	to, err := dstQ.Wld.Skeleton(toTag)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(args[1]), err)
+	}
+
+	count := 0
+	for _, anim := range srcQ.Wld.Animations(from) {
+		if len(codes) > 0 && !animCodeIsIn(anim.Code, codes) {
+			continue
+		}
+		var moved *wce.Animation
+		if isRetarget {
+			moved, err = wce.AnimationRetarget(anim, from, to, boneMap, isRootMotion)
+		} else {
+			moved, err = wce.AnimationCopy(anim, from, to, boneMap)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", anim.Code, err)
+		}
+		err = dstQ.Wld.AnimationSet(to, moved)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", anim.Code, err)
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no animations found for %s", from.Tag)
+	}
+
+	err = quailSave(dstQ, args[1])
+	if err != nil {
+		return err
+	}
+	verb := "Copied"
+	if isRetarget {
+		verb = "Retargeted"
+	}
+	fmt.Printf("%s %d animations from %s to %s in %s\n", verb, count, from.Tag, to.Tag, filepath.Base(args[1]))
+	return nil
+}
+
+func animCodeIsIn(code string, codes []string) bool {
+	for _, c := range codes {
+		if strings.EqualFold(c, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func animLoad(srcPath string) (*quail.Quail, error) {
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	q := quails[0]
+	if q.Wld == nil {
+		return nil, fmt.Errorf("no models found in %s", filepath.Base(srcPath))
+	}
+	return q, nil
+}
+
+// animLoadSkeleton loads src and the skeleton the skeleton flag names, or its only skeleton
+func animLoadSkeleton(cmd *cobra.Command, srcPath string) (*quail.Quail, *wce.Skeleton, error) {
+	skelTag, err := cmd.Flags().GetString("skeleton")
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse skeleton: %w", err)
+	}
+	q, err := animLoad(srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if skelTag != "" {
+		skel, err := q.Wld.Skeleton(skelTag)
+		if err != nil {
+			return nil, nil, err
+		}
+		return q, skel, nil
+	}
+	skels, err := q.Wld.Skeletons()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(skels) != 1 {
+		tags := []string{}
+		for _, skel := range skels {
+			tags = append(tags, skel.Tag)
+		}
+		return nil, nil, fmt.Errorf("%s has %d skeletons, pick one with --skeleton: %s", filepath.Base(srcPath), len(skels), strings.Join(tags, ", "))
+	}
+	return q, skels[0], nil
+}
+
+// animUnit names what an animation of skel is stored in
+func animUnit(skel *wce.Skeleton, count int) string {
+	if skel.IsEqg() {
+		return ".ani file" + helper.Pluralize(count)
+	}
+	return "track" + helper.Pluralize(count)
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/bake"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/quail"
+)
+
+func init() {
+	rootCmd.AddCommand(bakeCmd)
+	bakeCmd.Flags().Bool("shadows", false, "cast shadow rays against zone geometry")
+	bakeCmd.Flags().Float32("bias", 0.5, "distance shadow rays start off a vertex, along its normal")
+	bakeCmd.Flags().IntSlice("ambient", nil, "ambient red,green,blue from 0 to 255, instead of the zone's global ambient light")
+	bakeCmd.Flags().Float32("radius-scale", 1, "multiplies the radius of every light")
+}
+
+// bakeCmd represents the bake command
+var bakeCmd = &cobra.Command{
+	Use:   "bake",
+	Short: "Bake vertex lighting from a zone's lights",
+	Long: `Light every vertex of a zone from its point lights and global ambient light, fading each light to its radius.
+Region meshes keep the colors as vertex colors, placed s3d objects get an rgb track and eqg zon
+instances get lits, or a .lit file for v1 zones. Objects of a foo.s3d zone are read from foo_obj.s3d
+Usage: quail bake <src> <dst> [--shadows] [--bias <distance>] [--ambient <r,g,b>] [--radius-scale <scale>]
+Example: quail bake foo.s3d out/foo.s3d
+Example: quail bake foo.eqg out/foo.eqg --shadows --ambient 40,40,48`,
+	RunE: runBake,
+}
+
+func runBake(cmd *cobra.Command, args []string) error {
+	err := runBakeE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runBakeE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	isShadowed, err := cmd.Flags().GetBool("shadows")
+	if err != nil {
+		return fmt.Errorf("parse shadows: %w", err)
+	}
+	bias, err := cmd.Flags().GetFloat32("bias")
+	if err != nil {
+		return fmt.Errorf("parse bias: %w", err)
+	}
+	ambientFlag, err := cmd.Flags().GetIntSlice("ambient")
+	if err != nil {
+		return fmt.Errorf("parse ambient: %w", err)
+	}
+	if len(ambientFlag) != 0 && len(ambientFlag) != 3 {
+		return fmt.Errorf("ambient wants red,green,blue, got %d values", len(ambientFlag))
+	}
+	radiusScale, err := cmd.Flags().GetFloat32("radius-scale")
+	if err != nil {
+		return fmt.Errorf("parse radius-scale: %w", err)
+	}
+	if radiusScale <= 0 {
+		return fmt.Errorf("radius-scale %0.2f must be above 0", radiusScale)
+	}
+
+	quails, err := exportLoad(args[0])
+	if err != nil {
+		return err
+	}
+	q := quails[0]
+	if q.Wld == nil {
+		return fmt.Errorf("no zone found in %s", filepath.Base(args[0]))
+	}
+
+	baker, err := bakeBaker(q, ambientFlag, radiusScale)
+	if err != nil {
+		return err
+	}
+	baker.Bias = bias
+
+	meshes, err := q.LightMeshes(quails[1:]...)
+	if err != nil {
+		return fmt.Errorf("light meshes: %w", err)
+	}
+	if len(meshes) == 0 {
+		return fmt.Errorf("no meshes to bake found in %s", filepath.Base(args[0]))
+	}
+	if isShadowed {
+		baker.Scene = quail.LightScene(meshes)
+	}
+
+	err = q.LightBake(baker, meshes)
+	if err != nil {
+		return fmt.Errorf("light bake: %w", err)
+	}
+
+	err = quailSave(q, args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Baked %d model%s with %d light%s to %s\n", len(meshes), helper.Pluralize(len(meshes)), len(baker.Lights), helper.Pluralize(len(baker.Lights)), filepath.Base(args[1]))
+	return nil
+}
+
+// bakeBaker returns a baker with the lights of q, scaling their radius, and the ambient flag
+// or the global ambient light
+func bakeBaker(q *quail.Quail, ambientFlag []int, radiusScale float32) (*bake.Baker, error) {
+	lights, ambient, _ := q.Lights()
+	for i := range lights {
+		lights[i].Radius *= radiusScale
+	}
+	if len(ambientFlag) == 3 {
+		for i, v := range ambientFlag {
+			if v < 0 || v > 255 {
+				return nil, fmt.Errorf("ambient %d is not 0 to 255", v)
+			}
+			ambient[i] = float32(v) / 255
+		}
+	}
+	return &bake.Baker{Lights: lights, Ambient: ambient}, nil
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/gltf"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(charCmd)
+	charCmd.Flags().Int("head", 0, "head model, such as 1 for ELFHE01")
+	charCmd.Flags().Int("face", 0, "face texture, such as 1 for ELFHE0011")
+	charCmd.Flags().Int("texture", 0, "armor texture set, such as 2 for ELFCH0201")
+}
+
+// charCmd represents the char command
+var charCmd = &cobra.Command{
+	Use:   "char",
+	Short: "List or export playable race models",
+	Long: `List the heads, faces and armor texture sets of playable race models, or export one assembled
+with a chosen head, face and texture set as a glTF. Textures are written beside the glTF as they are
+stored in the archive
+Usage: quail char <src> [code] [dst] [--head <n>] [--face <n>] [--texture <n>]
+Example: quail char global_chr.s3d - Lists every race
+Example: quail char global_chr.s3d elf - Lists the heads, faces and texture sets of elves
+Example: quail char global_chr.s3d elf elf.gltf --head 1 --face 2 --texture 3`,
+	RunE: runChar,
+}
+
+func runChar(cmd *cobra.Command, args []string) error {
+	err := runCharE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runCharE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	look := wce.CharacterLook{}
+	var err error
+	look.Head, err = cmd.Flags().GetInt("head")
+	if err != nil {
+		return fmt.Errorf("parse head: %w", err)
+	}
+	look.Face, err = cmd.Flags().GetInt("face")
+	if err != nil {
+		return fmt.Errorf("parse face: %w", err)
+	}
+	look.TextureSet, err = cmd.Flags().GetInt("texture")
+	if err != nil {
+		return fmt.Errorf("parse texture: %w", err)
+	}
+
+	q, err := animLoad(args[0])
+	if err != nil {
+		return err
+	}
+
+	codes := []string{}
+	if len(args) > 1 {
+		codes = append(codes, args[1])
+	} else {
+		codes, err = q.Characters()
+		if err != nil {
+			return err
+		}
+	}
+	if len(args) < 3 {
+		for _, code := range codes {
+			char, err := q.Character(code)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d bones, heads %s, faces %s, texture sets %s\n", char.Code, len(char.Skeleton.Bones), charNumbers(char.Heads), charNumbers(char.Faces), charNumbers(char.TextureSets))
+		}
+		fmt.Printf("%d character%s\n", len(codes), helper.Pluralize(len(codes)))
+		return nil
+	}
+
+	char, err := q.Character(args[1])
+	if err != nil {
+		return err
+	}
+	doc, err := charGltf(char, look)
+	if err != nil {
+		return err
+	}
+	dstPath := args[2]
+	w, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+	err = doc.Write(w)
+	if err != nil {
+		return fmt.Errorf("gltf write: %w", err)
+	}
+
+	count := 0
+	for _, name := range doc.Images() {
+		data := charAsset(q, name)
+		if data == nil {
+			fmt.Printf("Texture %s not found\n", name)
+			continue
+		}
+		err = os.WriteFile(filepath.Join(filepath.Dir(dstPath), name), data, 0644)
+		if err != nil {
+			return fmt.Errorf("write texture: %w", err)
+		}
+		count++
+	}
+	fmt.Printf("Exported %s head %d face %d texture set %d to %s with %d texture%s\n", char.Code, look.Head, look.Face, look.TextureSet, filepath.Base(dstPath), count, helper.Pluralize(count))
+	return nil
+}
+
+// charGltf returns char assembled with look as a glTF doc
+func charGltf(char *wce.Character, look wce.CharacterLook) (*gltf.Doc, error) {
+	mesh, err := char.Mesh(look)
+	if err != nil {
+		return nil, err
+	}
+	src := &gltf.Mesh{
+		Name:      char.Code,
+		Positions: mesh.Vertices,
+		Normals:   mesh.Normals,
+		UVs:       mesh.UVs,
+	}
+	for _, group := range mesh.Groups {
+		src.Primitives = append(src.Primitives, &gltf.Primitive{
+			Material: group.Material,
+			Texture:  strings.ToLower(group.Texture),
+			Indices:  group.Indices,
+		})
+	}
+	doc := gltf.New()
+	err = doc.MeshAdd(src)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// charAsset returns the data of the asset named name, matched without case, or nil
+func charAsset(q *quail.Quail, name string) []byte {
+	for assetName, data := range q.Assets {
+		if strings.EqualFold(assetName, name) {
+			return data
+		}
+	}
+	return nil
+}
+
+func charNumbers(values []int) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	text := []string{}
+	for _, value := range values {
+		text = append(text, fmt.Sprintf("%d", value))
+	}
+	return strings.Join(text, ",")
+}
@@ -2,17 +2,24 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/xackery/quail/anim"
 	"github.com/xackery/quail/quail"
 	"github.com/xackery/quail/raw"
 )
 
 func init() {
 	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().Float64("anim-rate", 0, "resample animations to this many frames per second")
+	convertCmd.Flags().Float64("anim-angle", 0, "remove animation keyframes that are within this many degrees of their neighbours")
+	convertCmd.Flags().Float64("anim-distance", 0, "remove animation keyframes that are within this distance of their neighbours")
+	convertCmd.Flags().Uint32("anim-loop", 0, "blend the last milliseconds of each animation into its first frame")
 }
 
 // convertCmd represents the convert command
@@ -22,7 +29,10 @@ var convertCmd = &cobra.Command{
 	Long: `Supports eqg, s3d, and quail (wcemu) files
 Usage: quail convert <src> <dst>
 Example: quail convert foo.s3d foo.quail - Takes foo.s3d and creates a folder called foo.quail
-Example: quail convert foo.quail foo.s3d - Takes foo.quail folder and creates a foo.s3d file`,
+Example: quail convert foo.quail foo.s3d - Takes foo.quail folder and creates a foo.s3d file
+The --anim flags process animations converted to the other format, and every animation written to an eqg
+Example: quail convert --anim-angle 0.5 --anim-distance 0.01 foo.s3d foo.eqg - Drops keyframes that barely move
+Example: quail convert --anim-rate 15 --anim-loop 200 foo.eqg foo.s3d - Plays at 15 fps with smooth loops`,
 	RunE: runConvert,
 }
 
@@ -85,6 +95,14 @@ func runConvertE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	animOptions, err := convertAnimOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if q.Wld != nil {
+		q.Wld.SetAnimationOptions(animOptions)
+	}
+
 	dstExt := filepath.Ext(dstPath)
 	switch dstExt {
 	case ".quail":
@@ -127,6 +145,35 @@ func runConvertE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// convertAnimOptions returns how the --anim flags ask to process animations
+func convertAnimOptions(cmd *cobra.Command) (anim.Options, error) {
+	opts := anim.Options{}
+	var err error
+	opts.Rate, err = cmd.Flags().GetFloat64("anim-rate")
+	if err != nil {
+		return opts, fmt.Errorf("parse anim-rate: %w", err)
+	}
+	angle, err := cmd.Flags().GetFloat64("anim-angle")
+	if err != nil {
+		return opts, fmt.Errorf("parse anim-angle: %w", err)
+	}
+	opts.Angle = angle * math.Pi / 180
+	distance, err := cmd.Flags().GetFloat64("anim-distance")
+	if err != nil {
+		return opts, fmt.Errorf("parse anim-distance: %w", err)
+	}
+	opts.Distance = float32(distance)
+	opts.LoopBlend, err = cmd.Flags().GetUint32("anim-loop")
+	if err != nil {
+		return opts, fmt.Errorf("parse anim-loop: %w", err)
+	}
+	err = opts.Validate()
+	if err != nil {
+		return opts, fmt.Errorf("anim: %w", err)
+	}
+	return opts, nil
+}
+
 func quailLoadSideFile(q *quail.Quail, path string) error {
 	ext := filepath.Ext(path)
 	r, err := os.Open(path)
@@ -151,3 +198,25 @@ func quailLoadSideFile(q *quail.Quail, path string) error {
 	fmt.Printf("Loaded side file %s\n", filepath.Base(path))
 	return nil
 }
+
+// quailSave writes q to dstPath as a .quail folder, a .json file or an archive, by its extension
+func quailSave(q *quail.Quail, dstPath string) error {
+	switch strings.ToLower(filepath.Ext(dstPath)) {
+	case ".quail":
+		err := q.DirWrite(dstPath)
+		if err != nil {
+			return fmt.Errorf("dir write: %w", err)
+		}
+	case ".json":
+		err := q.JsonWrite(dstPath)
+		if err != nil {
+			return fmt.Errorf("json write: %w", err)
+		}
+	default:
+		err := q.PfsWrite(1, 1, dstPath)
+		if err != nil {
+			return fmt.Errorf("pfs write: %w", err)
+		}
+	}
+	return nil
+}
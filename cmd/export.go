@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/navmesh"
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportMapCmd)
+	exportCmd.AddCommand(exportWtrCmd)
+	exportWtrCmd.Flags().Float32("sheet-depth", 1000, "depth below an eqg watersheet surface that is considered water")
+	exportCmd.AddCommand(exportNavCmd)
+	navCfg := navmesh.DefaultConfig()
+	exportNavCmd.Flags().Float32("radius", navCfg.AgentRadius, "agent radius, distance kept from walls")
+	exportNavCmd.Flags().Float32("height", navCfg.AgentHeight, "agent height, minimum ceiling clearance")
+	exportNavCmd.Flags().Float32("step", navCfg.AgentMaxClimb, "max step height an agent can climb")
+	exportNavCmd.Flags().Float32("slope", navCfg.AgentMaxSlope, "max walkable slope in degrees")
+	exportNavCmd.Flags().Float32("cell-size", navCfg.CellSize, "horizontal voxel size")
+	exportNavCmd.Flags().Float32("cell-height", navCfg.CellHeight, "vertical voxel size")
+	exportNavCmd.Flags().Int("tile-size", navCfg.TileSize, "tile size in cells")
+	exportNavCmd.Flags().Float32("sheet-depth", 1000, "depth below an eqg watersheet surface that is considered water")
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export zone data to EQEmu server formats",
+	Long: `Export zone data to files used by EQEmu servers
+Usage: quail export <type> <src> <dst>`,
+}
+
+// exportMapCmd represents the export map command
+var exportMapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Export an EQEmu collision .map",
+	Long: `Export the collidable and non-collidable triangles and placeables of a zone as an EQEmu v2 map
+Usage: quail export map <src> <dst>
+Example: quail export map foo.eqg foo.map
+Example: quail export map foo.s3d foo.map - Also reads models from foo_obj.s3d if it exists`,
+	RunE: runExportMap,
+}
+
+func runExportMap(cmd *cobra.Command, args []string) error {
+	err := runExportMapE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runExportMapE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return err
+	}
+	dst, err := exportMap(srcPath, quails)
+	if err != nil {
+		return err
+	}
+
+	w, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+
+	err = dst.Write(w)
+	if err != nil {
+		return fmt.Errorf("map write: %w", err)
+	}
+
+	fmt.Printf("Exported %s with %d collidable and %d non-collidable faces, %d models, %d placeables, %d tiles\n", filepath.Base(dstPath), len(dst.Indices)/3, len(dst.NCIndices)/3, len(dst.Models), len(dst.Placeables), len(dst.Tiles))
+	return nil
+}
+
+// exportMap builds a zone's collision map, adding the terrain tiles and invisible walls of an eqg
+func exportMap(srcPath string, quails []*quail.Quail) (*raw.Map, error) {
+	dst := &raw.Map{}
+	for _, q := range quails {
+		err := q.MapExport(dst)
+		if err != nil {
+			return nil, fmt.Errorf("map export: %w", err)
+		}
+	}
+	if strings.ToLower(filepath.Ext(srcPath)) != ".eqg" {
+		return dst, nil
+	}
+
+	archive, err := pfs.NewFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filepath.Base(srcPath), err)
+	}
+	defer archive.Close()
+
+	zon, err := exportZonV4(srcPath, archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.Files() {
+		switch {
+		case strings.EqualFold(file.Name(), "invw.dat"):
+			walls := &raw.DatIw{}
+			err = walls.Read(bytes.NewReader(file.Data()))
+			if err != nil {
+				return nil, fmt.Errorf("read invw.dat: %w", err)
+			}
+			err = wce.WriteMapWallsRaw(dst, walls)
+			if err != nil {
+				return nil, fmt.Errorf("invw.dat: %w", err)
+			}
+		case zon != nil && strings.EqualFold(file.Name(), zon.V4Info.Name+".dat"):
+			dat := &raw.DatZon{QuadsPerTile: zon.V4Info.QuadsPerTile}
+			err = dat.Read(bytes.NewReader(file.Data()))
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", file.Name(), err)
+			}
+			err = wce.WriteMapTilesRaw(dst, &zon.V4Info, dat)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name(), err)
+			}
+		}
+	}
+	return dst, nil
+}
+
+// exportZonV4 returns the v4 zon of an eqg, from the side .zon file or the archive, or nil if it has none
+func exportZonV4(srcPath string, archive *pfs.Pfs) (*raw.Zon, error) {
+	data, err := os.ReadFile(strings.TrimSuffix(srcPath, filepath.Ext(srcPath)) + ".zon")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read side file .zon: %w", err)
+	}
+	datas := [][]byte{}
+	if err == nil {
+		datas = append(datas, data)
+	}
+	for _, file := range archive.Files() {
+		if strings.ToLower(filepath.Ext(file.Name())) == ".zon" {
+			datas = append(datas, file.Data())
+		}
+	}
+	for _, data := range datas {
+		if !bytes.HasPrefix(data, []byte("EQTZ")) {
+			continue
+		}
+		zon := &raw.Zon{}
+		err = zon.Read(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("read v4 zon: %w", err)
+		}
+		return zon, nil
+	}
+	return nil, nil
+}
+
+// exportWtrCmd represents the export wtr command
+var exportWtrCmd = &cobra.Command{
+	Use:   "wtr",
+	Short: "Export an EQEmu water .wtr",
+	Long: `Export the water, lava, pvp and zoneline regions of a zone as an EQEmu water map
+s3d zones use the bsp tree (version 1), eqg zones use zon areas and water.dat watersheets (version 2)
+Usage: quail export wtr <src> <dst>
+Example: quail export wtr foo.s3d foo.wtr
+Example: quail export wtr foo.eqg foo.wtr --sheet-depth 500`,
+	RunE: runExportWtr,
+}
+
+func runExportWtr(cmd *cobra.Command, args []string) error {
+	err := runExportWtrE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runExportWtrE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+
+	sheetDepth, err := cmd.Flags().GetFloat32("sheet-depth")
+	if err != nil {
+		return fmt.Errorf("parse sheet-depth: %w", err)
+	}
+
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return err
+	}
+	dst, err := exportWtr(srcPath, quails[0], sheetDepth)
+	if err != nil {
+		return err
+	}
+
+	w, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+
+	err = dst.Write(w)
+	if err != nil {
+		return fmt.Errorf("wtr write: %w", err)
+	}
+
+	fmt.Printf("Exported %s version %d\n%s", filepath.Base(dstPath), dst.Version, dst.String())
+	return nil
+}
+
+// exportWtr builds a zone's water map, adding eqg watersheets that are sheetDepth deep
+func exportWtr(srcPath string, q *quail.Quail, sheetDepth float32) (*raw.Wtr, error) {
+	if q.Wld == nil {
+		return nil, fmt.Errorf("no zone found in %s", filepath.Base(srcPath))
+	}
+
+	dst := &raw.Wtr{}
+	err := q.Wld.WriteWtrRaw(dst)
+	if err != nil {
+		return nil, fmt.Errorf("wtr export: %w", err)
+	}
+
+	if strings.ToLower(filepath.Ext(srcPath)) == ".eqg" {
+		sheets, err := exportWaterSheets(srcPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, sheet := range sheets {
+			dst.SheetAdd(sheet, sheetDepth)
+		}
+	}
+	return dst, nil
+}
+
+// exportWaterSheets returns the watersheets of an eqg's water.dat, if it has one
+func exportWaterSheets(srcPath string) ([]*raw.DatWtrSheet, error) {
+	archive, err := pfs.NewFile(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filepath.Base(srcPath), err)
+	}
+	defer archive.Close()
+
+	for _, file := range archive.Files() {
+		if !strings.EqualFold(file.Name(), "water.dat") {
+			continue
+		}
+		datWtr := &raw.DatWtr{}
+		err = datWtr.Read(bytes.NewReader(file.Data()))
+		if err != nil {
+			return nil, fmt.Errorf("read water.dat: %w", err)
+		}
+		return datWtr.Watersheets, nil
+	}
+	return nil, nil
+}
+
+// exportLoad loads a zone and, for s3d zones, the sibling _obj.s3d that holds its object models
+func exportLoad(srcPath string) ([]*quail.Quail, error) {
+	srcExt := strings.ToLower(filepath.Ext(srcPath))
+
+	q := quail.New()
+	switch srcExt {
+	case ".quail":
+		err := q.DirRead(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("quail read dir: %w", err)
+		}
+		return []*quail.Quail{q}, nil
+	case ".json":
+		err := q.JsonRead(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("json read: %w", err)
+		}
+		return []*quail.Quail{q}, nil
+	}
+
+	err := q.PfsRead(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(srcPath), err)
+	}
+	srcPathNoExt := srcPath[:len(srcPath)-len(srcExt)]
+	if srcExt == ".eqg" {
+		err = quailLoadSideFile(q, srcPathNoExt+".zon")
+		if err != nil {
+			return nil, fmt.Errorf("load side file .zon: %w", err)
+		}
+	}
+	quails := []*quail.Quail{q}
+
+	if srcExt != ".s3d" || strings.HasSuffix(strings.ToLower(srcPathNoExt), "_obj") {
+		return quails, nil
+	}
+	objPath := srcPathNoExt + "_obj.s3d"
+	_, err = os.Stat(objPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return quails, nil
+		}
+		return nil, fmt.Errorf("stat %s: %w", filepath.Base(objPath), err)
+	}
+	objQ := quail.New()
+	err = objQ.PfsRead(objPath)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(objPath), err)
+	}
+	return append(quails, objQ), nil
+}
+
+// exportNavCmd represents the export nav command
+var exportNavCmd = &cobra.Command{
+	Use:   "nav",
+	Short: "Export an EQEmu navmesh .nav",
+	Long: `Generate a navigation mesh from the collidable triangles of a zone and export it as an EQEmu navmesh
+Polygons in water, lava, pvp and zoneline regions are given the matching area
+Usage: quail export nav <src> <dst>
+Example: quail export nav foo.s3d foo.nav
+Example: quail export nav foo.eqg foo.nav --radius 2 --height 7 --step 4`,
+	RunE: runExportNav,
+}
+
+func runExportNav(cmd *cobra.Command, args []string) error {
+	err := runExportNavE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runExportNavE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+
+	cfg := navmesh.DefaultConfig()
+	for name, val := range map[string]*float32{
+		"radius":      &cfg.AgentRadius,
+		"height":      &cfg.AgentHeight,
+		"step":        &cfg.AgentMaxClimb,
+		"slope":       &cfg.AgentMaxSlope,
+		"cell-size":   &cfg.CellSize,
+		"cell-height": &cfg.CellHeight,
+	} {
+		v, err := cmd.Flags().GetFloat32(name)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		*val = v
+	}
+	tileSize, err := cmd.Flags().GetInt("tile-size")
+	if err != nil {
+		return fmt.Errorf("parse tile-size: %w", err)
+	}
+	cfg.TileSize = tileSize
+	sheetDepth, err := cmd.Flags().GetFloat32("sheet-depth")
+	if err != nil {
+		return fmt.Errorf("parse sheet-depth: %w", err)
+	}
+
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return err
+	}
+
+	zoneMap, err := exportMap(srcPath, quails)
+	if err != nil {
+		return err
+	}
+	verts, indices := zoneMap.CollidableTriangles()
+
+	wtr, err := exportWtr(srcPath, quails[0], sheetDepth)
+	if err != nil {
+		return err
+	}
+
+	dst, err := navmesh.Build(verts, indices, wtr.ServerRegionType, cfg)
+	if err != nil {
+		return fmt.Errorf("navmesh build: %w", err)
+	}
+
+	w, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+
+	err = dst.Write(w)
+	if err != nil {
+		return fmt.Errorf("nav write: %w", err)
+	}
+
+	fmt.Printf("Exported %s\n%s", filepath.Base(dstPath), dst.String())
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	fmtCmd.Flags().Bool("check", false, "list files that are not formatted instead of rewriting them, exits with an error if any are found")
+}
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt",
+	Short: "Reformat .wce files in place",
+	Long: `Rewrite .wce files with the indentation, quoting and float precision WriteAscii uses, keeping comments.
+Folders are searched for .wce files
+Usage: quail fmt [--check] <path...>
+Example: quail fmt foo.quail
+Example: quail fmt --check foo.quail/_root.wce`,
+	RunE: runFmt,
+}
+
+func runFmt(cmd *cobra.Command, args []string) error {
+	err := runFmtE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runFmtE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	isCheck, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		return fmt.Errorf("check: %w", err)
+	}
+
+	paths, err := wceFiles(args)
+	if err != nil {
+		return err
+	}
+
+	unformatted := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		out, err := wce.AsciiFormat(data)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", path, err)
+		}
+		if bytes.Equal(data, out) {
+			continue
+		}
+		unformatted++
+		fmt.Println(path)
+		if isCheck {
+			continue
+		}
+		err = os.WriteFile(path, out, 0644)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	if isCheck && unformatted > 0 {
+		return fmt.Errorf("%d files not formatted", unformatted)
+	}
+	return nil
+}
+
+// wceFiles returns the .wce files at each path, searching folders
+func wceFiles(args []string) ([]string, error) {
+	paths := []string{}
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".wce") {
+				return nil
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %w", arg, err)
+		}
+	}
+	return paths, nil
+}
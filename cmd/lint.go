@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check a quail project for broken references and invalid geometry",
+	Long: `Validate tag references, face and vertex counts, bsp nodes and engine limits of a quail project or archive.
+Exits with an error if any errors are found
+Usage: quail lint <src>
+Example: quail lint foo.quail
+Example: quail lint foo.s3d`,
+	RunE: runLint,
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	err := runLintE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runLintE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+
+	quails, err := exportLoad(args[0])
+	if err != nil {
+		return err
+	}
+
+	errCount := 0
+	warnCount := 0
+	for _, q := range quails {
+		for _, wld := range []*wce.Wce{q.Wld, q.WldObject, q.WldLights} {
+			if wld == nil {
+				continue
+			}
+			for _, finding := range wld.Validate() {
+				if finding.Severity == wce.SeverityError {
+					errCount++
+				} else {
+					warnCount++
+				}
+				fmt.Println(finding)
+			}
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errCount, warnCount)
+	if errCount > 0 {
+		return fmt.Errorf("%d errors found", errCount)
+	}
+	return nil
+}
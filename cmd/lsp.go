@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/lsp"
+)
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
+
+// lspCmd represents the lsp command
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for .wce files over stdio",
+	Long: `Run a language server protocol server on stdin and stdout, providing diagnostics, hover,
+completion, go to definition and find references for .wce files
+Usage: quail lsp`,
+	RunE: runLsp,
+}
+
+func runLsp(cmd *cobra.Command, args []string) error {
+	err := runLspE(cmd, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runLspE(cmd *cobra.Command, args []string) error {
+	// stdout carries only the protocol, failures are logged to stderr
+	server, err := lsp.New(os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return server.Serve()
+}
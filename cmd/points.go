@@ -0,0 +1,407 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/gltf"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(pointsCmd)
+	pointsCmd.AddCommand(pointsListCmd)
+	pointsCmd.AddCommand(pointsAddCmd)
+	pointsCmd.AddCommand(pointsMoveCmd)
+	pointsCmd.AddCommand(pointsCopyCmd)
+	pointsCmd.AddCommand(pointsExportCmd)
+	pointsListCmd.Flags().String("skeleton", "", "only list points of this skeleton, such as HUM_HS_DEF")
+	for _, cmd := range []*cobra.Command{pointsAddCmd, pointsMoveCmd} {
+		cmd.Flags().String("skeleton", "", "skeleton of the point (default the only one)")
+		cmd.Flags().Float32Slice("translation", nil, "x,y,z offset from the bone")
+		cmd.Flags().Float32Slice("rotation", nil, "x,y,z turn from the bone in degrees")
+		cmd.Flags().Float32Slice("scale", nil, "x,y,z scale, eqg only")
+	}
+	pointsMoveCmd.Flags().String("bone", "", "bone the point follows instead")
+	pointsCopyCmd.Flags().String("from", "", "skeleton to copy points from, such as HUM_HS_DEF")
+	pointsCopyCmd.Flags().String("to", "", "skeleton to copy points to, such as ELF_HS_DEF")
+	pointsCopyCmd.Flags().StringSlice("name", nil, "points to copy, such as R_POINT (default all)")
+	pointsExportCmd.Flags().String("skeleton", "", "skeleton to export (default the only one)")
+}
+
+// pointsCmd represents the points command
+var pointsCmd = &cobra.Command{
+	Use:   "points",
+	Short: "Work with the attach points of character models",
+	Long: `Work with the points weapons, shields and other items attach to, such as R_POINT. s3d points are dags
+of a hierarchical sprite, eqg points are entries of the .pts of an mds
+Usage: quail points <list|add|move|copy|export> ...`,
+}
+
+// pointsListCmd represents the points list command
+var pointsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List attach points",
+	Long: `List the bone, offset and turn of each attach point of each skeleton
+Usage: quail points list <src> [--skeleton <skeleton>]
+Example: quail points list global_chr.s3d --skeleton hum`,
+	RunE: runPointsList,
+}
+
+func runPointsList(cmd *cobra.Command, args []string) error {
+	err := runPointsListE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPointsListE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	skelTag, err := cmd.Flags().GetString("skeleton")
+	if err != nil {
+		return fmt.Errorf("parse skeleton: %w", err)
+	}
+	q, err := animLoad(args[0])
+	if err != nil {
+		return err
+	}
+	skels, err := q.Wld.Skeletons()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, skel := range skels {
+		if skelTag != "" && !strings.EqualFold(skel.Tag, skelTag) && !strings.EqualFold(skel.Code, skelTag) {
+			continue
+		}
+		points := q.Wld.AttachPoints(skel)
+		if len(points) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n", skel.Tag)
+		for _, point := range points {
+			fmt.Printf("  %s on %s at %s turned %s\n", point.Name, point.Bone, pointsVector(point.Translation), pointsVector(pointsDegrees(point.Rotation)))
+			count++
+		}
+	}
+	fmt.Printf("%d point%s\n", count, helper.Pluralize(count))
+	return nil
+}
+
+// pointsAddCmd represents the points add command
+var pointsAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add an attach point",
+	Long: `Add an attach point following a bone
+Usage: quail points add <src> <dst> <name> <bone> [--translation x,y,z] [--rotation x,y,z] [--scale x,y,z] [--skeleton <skeleton>]
+Example: quail points add global_chr.s3d out.s3d SHIELD_POINT L_POINT --skeleton hum --translation 0,0.5,0`,
+	RunE: runPointsAdd,
+}
+
+func runPointsAdd(cmd *cobra.Command, args []string) error {
+	err := runPointsAddE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPointsAddE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if q.Wld.AttachPoint(skel, args[2]) != nil {
+		return fmt.Errorf("point %s already exists on %s, use move", args[2], skel.Tag)
+	}
+	point := &wce.AttachPoint{
+		Name:     args[2],
+		Bone:     args[3],
+		Rotation: helper.QuatIdentity,
+		Scale:    [3]float32{1, 1, 1},
+	}
+	err = pointsFlags(cmd, point)
+	if err != nil {
+		return err
+	}
+	err = q.Wld.AttachPointSet(skel, point)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s on %s of %s\n", strings.ToUpper(point.Name), point.Bone, skel.Tag)
+	return quailSave(q, args[1])
+}
+
+// pointsMoveCmd represents the points move command
+var pointsMoveCmd = &cobra.Command{
+	Use:   "move",
+	Short: "Move an attach point",
+	Long: `Change the bone, offset, turn or scale of an attach point. Flags left out keep their value
+Usage: quail points move <src> <dst> <name> [--bone <bone>] [--translation x,y,z] [--rotation x,y,z] [--scale x,y,z] [--skeleton <skeleton>]
+Example: quail points move global_chr.s3d out.s3d R_POINT --skeleton hum --rotation 0,0,90`,
+	RunE: runPointsMove,
+}
+
+func runPointsMove(cmd *cobra.Command, args []string) error {
+	err := runPointsMoveE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPointsMoveE(cmd *cobra.Command, args []string) error {
+	if len(args) < 3 {
+		return cmd.Usage()
+	}
+	bone, err := cmd.Flags().GetString("bone")
+	if err != nil {
+		return fmt.Errorf("parse bone: %w", err)
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	point := q.Wld.AttachPoint(skel, args[2])
+	if point == nil {
+		return fmt.Errorf("point %s not found on %s", args[2], skel.Tag)
+	}
+	if bone != "" {
+		point.Bone = bone
+	}
+	err = pointsFlags(cmd, point)
+	if err != nil {
+		return err
+	}
+	err = q.Wld.AttachPointSet(skel, point)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved %s to %s at %s turned %s\n", point.Name, point.Bone, pointsVector(point.Translation), pointsVector(pointsDegrees(point.Rotation)))
+	return quailSave(q, args[1])
+}
+
+// pointsCopyCmd represents the points copy command
+var pointsCopyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy attach points to another skeleton",
+	Long: `Copy attach points from one skeleton to another. The to skeleton needs a bone named as the bone each
+point follows. Points it already has are moved
+Usage: quail points copy <src> <dst> --from <skeleton> --to <skeleton> [--name <points>]
+Example: quail points copy global_chr.s3d out.s3d --from hum --to elf --name R_POINT,L_POINT`,
+	RunE: runPointsCopy,
+}
+
+func runPointsCopy(cmd *cobra.Command, args []string) error {
+	err := runPointsCopyE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPointsCopyE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	fromTag, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return fmt.Errorf("parse from: %w", err)
+	}
+	toTag, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return fmt.Errorf("parse to: %w", err)
+	}
+	if fromTag == "" || toTag == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+	names, err := cmd.Flags().GetStringSlice("name")
+	if err != nil {
+		return fmt.Errorf("parse name: %w", err)
+	}
+	q, err := animLoad(args[0])
+	if err != nil {
+		return err
+	}
+	from, err := q.Wld.Skeleton(fromTag)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, point := range q.Wld.AttachPoints(from) {
+		if len(names) > 0 && !animCodeIsIn(point.Name, names) {
+			continue
+		}
+		// a new dag changes the skeleton, so read it again for each point
+		to, err := q.Wld.Skeleton(toTag)
+		if err != nil {
+			return err
+		}
+		err = q.Wld.AttachPointSet(to, point)
+		if err != nil {
+			return fmt.Errorf("point %s: %w", point.Name, err)
+		}
+		fmt.Printf("Copied %s on %s to %s\n", point.Name, point.Bone, to.Tag)
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no points to copy from %s", from.Tag)
+	}
+	return quailSave(q, args[1])
+}
+
+// pointsExportCmd represents the points export command
+var pointsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export attach points as a glTF",
+	Long: `Export a skeleton as a glTF for checking attach points. Each point is a large marker named as it, each
+bone a small one, and the model is included when it is a playable race. Markers point along their x axis
+Usage: quail points export <src> <dst> [--skeleton <skeleton>]
+Example: quail points export global_chr.s3d hum.gltf --skeleton hum`,
+	RunE: runPointsExport,
+}
+
+func runPointsExport(cmd *cobra.Command, args []string) error {
+	err := runPointsExportE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPointsExportE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	q, skel, err := animLoadSkeleton(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	doc, err := pointsGltf(q, skel)
+	if err != nil {
+		return err
+	}
+	w, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+	err = doc.Write(w)
+	if err != nil {
+		return fmt.Errorf("gltf write: %w", err)
+	}
+	fmt.Printf("Exported %s to %s\n", skel.Tag, filepath.Base(args[1]))
+	return nil
+}
+
+// pointsGltf returns skel as a glTF doc with a marker for each bone and attach point
+func pointsGltf(q *quail.Quail, skel *wce.Skeleton) (*gltf.Doc, error) {
+	doc := gltf.New()
+	char, err := q.Character(skel.Code)
+	if err == nil {
+		look := wce.CharacterLook{}
+		if len(char.Heads) > 0 {
+			look.Head = char.Heads[0]
+		}
+		charDoc, err := charGltf(char, look)
+		if err != nil {
+			fmt.Printf("Skipping model of %s: %s\n", skel.Tag, err.Error())
+		} else {
+			doc = charDoc
+		}
+	}
+
+	poses, err := skel.RestPoses()
+	if err != nil {
+		return nil, err
+	}
+	// markers are sized to the skeleton
+	size := float32(0)
+	for _, pose := range poses {
+		for _, value := range pose.Translation {
+			size = max(size, float32(math.Abs(float64(value))))
+		}
+	}
+	size = max(size/20, 0.1)
+
+	points := q.Wld.AttachPoints(skel)
+	isPoint := make(map[string]bool)
+	for _, point := range points {
+		isPoint[point.Name] = true
+	}
+	for i, pose := range poses {
+		// s3d points are dags too, and get a large marker below
+		if isPoint[skel.Bones[i].Name] {
+			continue
+		}
+		doc.MarkerAdd(skel.Bones[i].Name, pose.Translation, pose.Rotation, size/2)
+	}
+	for _, point := range points {
+		pose, err := wce.AttachPointPose(skel, point)
+		if err != nil {
+			return nil, fmt.Errorf("point %s: %w", point.Name, err)
+		}
+		doc.MarkerAdd(point.Name, pose.Translation, pose.Rotation, size)
+	}
+	return doc, nil
+}
+
+// pointsFlags sets point from the translation, rotation and scale flags that were given
+func pointsFlags(cmd *cobra.Command, point *wce.AttachPoint) error {
+	for _, name := range []string{"translation", "rotation", "scale"} {
+		if !cmd.Flags().Changed(name) {
+			continue
+		}
+		values, err := cmd.Flags().GetFloat32Slice(name)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		if len(values) != 3 {
+			return fmt.Errorf("%s needs 3 values, x,y,z", name)
+		}
+		vector := [3]float32{values[0], values[1], values[2]}
+		switch name {
+		case "translation":
+			point.Translation = vector
+		case "rotation":
+			for i := range vector {
+				vector[i] *= math.Pi / 180
+			}
+			point.Rotation = helper.QuatFromEuler(vector)
+		case "scale":
+			point.Scale = vector
+		}
+	}
+	return nil
+}
+
+// pointsDegrees returns the x, y and z turns of a rotation in degrees
+func pointsDegrees(rotation [4]float32) [3]float32 {
+	euler := helper.QuatToEuler(rotation)
+	for i := range euler {
+		euler[i] *= 180 / math.Pi
+	}
+	return euler
+}
+
+func pointsVector(v [3]float32) string {
+	return fmt.Sprintf("%0.2f,%0.2f,%0.2f", v[0], v[1], v[2])
+}
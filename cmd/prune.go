@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringSlice("root", nil, "tag of a definition to keep with everything it references, can be repeated. Defaults to every actor, world tree, zone and light")
+}
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove definitions nothing uses from a wld",
+	Long: `Remove materials, sprites, tracks and other definitions that can not be reached from the given roots, then write the result
+Usage: quail prune [--root TAG...] <src> <dst>
+Example: quail prune foo.s3d foo_pruned.s3d
+Example: quail prune --root ELF_ACTORDEF global_chr.s3d elf_chr.s3d`,
+	RunE: runPrune,
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	err := runPruneE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runPruneE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	roots, err := cmd.Flags().GetStringSlice("root")
+	if err != nil {
+		return fmt.Errorf("root: %w", err)
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return err
+	}
+	q := quails[0]
+
+	// each wld is pruned from the roots it has, and a wld with none of them is left as is
+	isFound := make(map[string]bool)
+	total := 0
+	saved := 0
+	for _, wld := range []*wce.Wce{q.Wld, q.WldObject, q.WldLights} {
+		if wld == nil {
+			continue
+		}
+		wldRoots := []string{}
+		for _, root := range roots {
+			if wld.HasTag(root) {
+				wldRoots = append(wldRoots, root)
+				isFound[root] = true
+			}
+		}
+		if len(roots) > 0 && len(wldRoots) == 0 {
+			fmt.Printf("%s: no roots found, skipped\n", wld.FileName)
+			continue
+		}
+		before, err := wldSize(wld)
+		if err != nil {
+			return fmt.Errorf("%s: %w", wld.FileName, err)
+		}
+		removed, err := wld.Prune(wldRoots...)
+		if err != nil {
+			return fmt.Errorf("%s prune: %w", wld.FileName, err)
+		}
+		after, err := wldSize(wld)
+		if err != nil {
+			return fmt.Errorf("%s: %w", wld.FileName, err)
+		}
+		fmt.Printf("%s: removed %d definitions, %d bytes saved\n", wld.FileName, len(removed), before-after)
+		total += len(removed)
+		saved += before - after
+	}
+	for _, root := range roots {
+		if !isFound[root] {
+			return fmt.Errorf("root %s not found", root)
+		}
+	}
+	fmt.Printf("Removed %d definitions, %d bytes saved\n", total, saved)
+	return quailSave(q, dstPath)
+}
+
+// wldSize returns how many bytes a wce takes once written as a wld
+func wldSize(wld *wce.Wce) (int, error) {
+	w := &countWriter{}
+	err := wld.WriteWldRaw(w)
+	if err != nil {
+		return 0, fmt.Errorf("write wld: %w", err)
+	}
+	return w.n, nil
+}
+
+type countWriter struct {
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/wce"
+)
+
+func TestPruneRoots(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "zone.s3d")
+	q := quail.New()
+	q.Wld = wce.New("zone.wld")
+	q.Wld.ActorDefs = append(q.Wld.ActorDefs,
+		&wce.ActorDef{Tag: "FOO_ACTORDEF", Callback: "SPRITECALLBACK"},
+		&wce.ActorDef{Tag: "OTHER_ACTORDEF", Callback: "SPRITECALLBACK"},
+	)
+	q.WldObject = wce.New("objects.wld")
+	q.WldObject.ActorDefs = append(q.WldObject.ActorDefs, &wce.ActorDef{Tag: "BAR_ACTORDEF", Callback: "SPRITECALLBACK"})
+	err := q.PfsWrite(1, 1, srcPath)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	pruneCmd := func(roots ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringSlice("root", nil, "")
+		for _, root := range roots {
+			err := cmd.Flags().Set("root", root)
+			if err != nil {
+				t.Fatalf("set root: %s", err)
+			}
+		}
+		return cmd
+	}
+
+	// each root is in only one of the wlds
+	dstPath := filepath.Join(dir, "pruned.s3d")
+	err = runPruneE(pruneCmd("FOO_ACTORDEF", "BAR_ACTORDEF"), []string{srcPath, dstPath})
+	if err != nil {
+		t.Fatalf("prune: %s", err)
+	}
+	back := quail.New()
+	err = back.PfsRead(dstPath)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if back.Wld == nil || len(back.Wld.ActorDefs) != 1 || back.Wld.ActorDefs[0].Tag != "FOO_ACTORDEF" {
+		t.Fatalf("zone actors: %+v", back.Wld)
+	}
+	if back.WldObject == nil || len(back.WldObject.ActorDefs) != 1 || back.WldObject.ActorDefs[0].Tag != "BAR_ACTORDEF" {
+		t.Fatalf("object actors: %+v", back.WldObject)
+	}
+
+	err = runPruneE(pruneCmd("FOO_ACTORDEF", "MISSING_ACTORDEF"), []string{srcPath, dstPath})
+	if err == nil || err.Error() != "root MISSING_ACTORDEF not found" {
+		t.Fatalf("missing root: got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().Bool("server", false, "coordinates are EQEmu server coordinates (x and y swapped)")
+}
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Show the bsp region at a point in an s3d zone",
+	Long: `Walk the bsp tree of an s3d zone and print the region containing a point, with its type,
+zones, ambient light, vertices and visibility list
+Usage: quail query <src> <x> <y> <z>
+Example: quail query foo.s3d 100 -250 3.5
+Example: quail query foo.s3d -250 100 3.5 --server`,
+	RunE: runQuery,
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	err := runQueryE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runQueryE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	isServer, err := cmd.Flags().GetBool("server")
+	if err != nil {
+		return fmt.Errorf("parse server: %w", err)
+	}
+	pos := [3]float32{}
+	for i := range pos {
+		val, err := strconv.ParseFloat(args[i+1], 32)
+		if err != nil {
+			return fmt.Errorf("parse coordinate %d: %w", i, err)
+		}
+		pos[i] = float32(val)
+	}
+	if isServer {
+		pos[0], pos[1] = pos[1], pos[0]
+	}
+
+	q, err := regionLoad(args[0])
+	if err != nil {
+		return err
+	}
+	wld := q.Wld
+	if len(wld.WorldTrees) == 0 {
+		return fmt.Errorf("no bsp tree found")
+	}
+
+	tag := wld.WorldTrees[0].RegionAt(pos[0], pos[1], pos[2])
+	if tag == "" {
+		fmt.Printf("%0.2f %0.2f %0.2f is outside the bsp tree\n", pos[0], pos[1], pos[2])
+		return nil
+	}
+	regionIdx := wld.RegionIndex(tag)
+	if regionIdx == -1 {
+		return fmt.Errorf("bsp region %s not found", tag)
+	}
+	region := wld.Regions[regionIdx]
+
+	fmt.Printf("region: %s (index %d)\n", tag, regionIdx)
+	regionType := wld.RegionTypes()[regionIdx]
+	fmt.Printf("type: %s\n", regionType.Type)
+	if regionType.ZoneLine != nil {
+		fmt.Printf("zoneline: %s\n", regionType.ZoneLine)
+	}
+	for _, zone := range wld.RegionZones(regionIdx) {
+		fmt.Printf("zone: %s", zone.Tag)
+		if zone.UserData != "" {
+			fmt.Printf(" userdata %q", zone.UserData)
+		}
+		fmt.Println()
+	}
+	if region.UserData != "" {
+		fmt.Printf("userdata: %q\n", region.UserData)
+	}
+
+	light := wld.RegionAmbientLight(regionIdx)
+	if light != nil {
+		fmt.Printf("ambient light: %s (light %s)\n", light.Tag, light.LightTag)
+	}
+
+	if region.SpriteTag != "" {
+		fmt.Printf("mesh: %s\n", region.SpriteTag)
+	}
+	verts := wld.RegionVertices(regionIdx)
+	if len(verts) > 0 {
+		vmin := verts[0]
+		vmax := verts[0]
+		for _, v := range verts[1:] {
+			for i := 0; i < 3; i++ {
+				vmin[i] = min(vmin[i], v[i])
+				vmax[i] = max(vmax[i], v[i])
+			}
+		}
+		fmt.Printf("vertices: %d, bounds %0.2f %0.2f %0.2f to %0.2f %0.2f %0.2f\n", len(verts), vmin[0], vmin[1], vmin[2], vmax[0], vmax[1], vmax[2])
+	}
+	fmt.Printf("sphere: %0.2f %0.2f %0.2f radius %0.2f\n", region.Sphere[0], region.Sphere[1], region.Sphere[2], region.Sphere[3])
+
+	visible, err := region.VisibleRegions()
+	if err != nil {
+		return fmt.Errorf("region %s visibility: %w", tag, err)
+	}
+	fmt.Printf("visible regions: %d", len(visible))
+	for _, idx := range visible {
+		fmt.Printf(" %d", idx)
+	}
+	fmt.Println()
+	return nil
+}
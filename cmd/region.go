@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/gltf"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/pvs"
+	"github.com/xackery/quail/quail"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(regionCmd)
+	regionCmd.AddCommand(regionListCmd)
+	regionCmd.AddCommand(regionSetCmd)
+	regionCmd.AddCommand(regionVisCmd)
+	regionCmd.AddCommand(regionPvsCmd)
+	regionListCmd.Flags().Bool("all", false, "include normal regions")
+	regionVisCmd.Flags().Bool("all", false, "list how many regions each region sees")
+	regionVisCmd.Flags().String("gltf", "", "export the zone as a glTF, coloring the region red, what it sees green and the rest gray")
+	cfg := pvs.DefaultConfig()
+	regionPvsCmd.Flags().Float32("spacing", cfg.Spacing, "distance between sample points")
+	regionPvsCmd.Flags().Int("samples", cfg.Samples, "most sample points kept per region")
+	regionPvsCmd.Flags().Int("rays", cfg.Rays, "most rays cast between two regions before they are hidden")
+	regionPvsCmd.Flags().Float32("distance", cfg.MaxDistance, "regions farther apart are hidden, 0 for no limit")
+}
+
+// regionCmd represents the region command
+var regionCmd = &cobra.Command{
+	Use:   "region",
+	Short: "List and change s3d zone region types",
+	Long: `List and change the water, lava, slippery, pvp and zoneline regions of an s3d zone, and check
+or build which regions can see each other
+Usage: quail region <list|set|vis|pvs> ...`,
+}
+
+// regionListCmd represents the region list command
+var regionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List region types",
+	Long: `List the type of each region in a zone
+Usage: quail region list <src>
+Example: quail region list foo.s3d
+Example: quail region list foo.quail --all`,
+	RunE: runRegionList,
+}
+
+func runRegionList(cmd *cobra.Command, args []string) error {
+	err := runRegionListE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runRegionListE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	isAll, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return fmt.Errorf("parse all: %w", err)
+	}
+
+	q, err := regionLoad(args[0])
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[raw.WtrRegionType]int)
+	regionTypes := q.Wld.RegionTypes()
+	for _, regionType := range regionTypes {
+		counts[regionType.Type]++
+		if regionType.Type == raw.WtrRegionTypeNormal && !isAll {
+			continue
+		}
+		line := fmt.Sprintf("%d %s", regionType.Region, regionType.Type)
+		if regionType.ZoneLine != nil {
+			line += fmt.Sprintf(" to %s", regionType.ZoneLine)
+		}
+		if regionType.ZoneTag != "" {
+			line += fmt.Sprintf(" (%s)", regionType.ZoneTag)
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d regions", len(regionTypes))
+	for regionType := raw.WtrRegionTypeNormal; regionType <= raw.WtrRegionTypeDisableNavMesh; regionType++ {
+		if counts[regionType] == 0 {
+			continue
+		}
+		fmt.Printf(", %d %s", counts[regionType], regionType)
+	}
+	fmt.Println()
+	return nil
+}
+
+// regionSetCmd represents the region set command
+var regionSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set a region's type",
+	Long: `Set the type of a region and save the zone to dst
+Types: normal, water, lava, zoneline, pvp, slime, slippery, vwater
+Zonelines take a destination zone id and x y z heading, or zone id 255 and a zone point index
+Usage: quail region set <src> <dst> <region> <type> [zoneid x y z heading]
+Example: quail region set foo.s3d foo.s3d 12 water
+Example: quail region set foo.quail foo.s3d 40 zoneline 25 100 -200 5 128
+Example: quail region set foo.s3d foo.s3d 41 zoneline 255 3`,
+	RunE: runRegionSet,
+}
+
+func runRegionSet(cmd *cobra.Command, args []string) error {
+	err := runRegionSetE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runRegionSetE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+	regionIdx, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("parse region: %w", err)
+	}
+	regionType, err := raw.ParseWtrRegionType(args[3])
+	if err != nil {
+		return err
+	}
+
+	var zoneLine *wce.ZoneLine
+	if regionType == raw.WtrRegionTypeZoneLine {
+		zoneLine, err = regionZoneLineParse(args[4:])
+		if err != nil {
+			return err
+		}
+	}
+
+	q, err := regionLoad(srcPath)
+	if err != nil {
+		return err
+	}
+	err = q.Wld.SetRegionType(regionIdx, regionType, zoneLine)
+	if err != nil {
+		return fmt.Errorf("set region %d: %w", regionIdx, err)
+	}
+
+	err = quailSave(q, dstPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Set region %d to %s in %s\n", regionIdx, regionType, filepath.Base(dstPath))
+	return nil
+}
+
+// regionVisCmd represents the region vis command
+var regionVisCmd = &cobra.Command{
+	Use:   "vis",
+	Short: "Show which regions a region sees",
+	Long: `Decode the vis lists of a zone and show how many regions each region sees, or which regions
+one region sees. A region that sees most of the zone is drawn with most of the zone
+Usage: quail region vis <src> [region] [--all] [--gltf <dst>]
+Example: quail region vis foo.s3d
+Example: quail region vis foo.s3d 12
+Example: quail region vis foo.s3d 12 --gltf foo_12.gltf`,
+	RunE: runRegionVis,
+}
+
+func runRegionVis(cmd *cobra.Command, args []string) error {
+	err := runRegionVisE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runRegionVisE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	isAll, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return fmt.Errorf("parse all: %w", err)
+	}
+	gltfPath, err := cmd.Flags().GetString("gltf")
+	if err != nil {
+		return fmt.Errorf("parse gltf: %w", err)
+	}
+
+	q, err := regionLoad(args[0])
+	if err != nil {
+		return err
+	}
+	lists, err := q.Wld.Visibility()
+	if err != nil {
+		return fmt.Errorf("visibility: %w", err)
+	}
+
+	if len(args) < 2 {
+		if gltfPath != "" {
+			return fmt.Errorf("gltf needs a region")
+		}
+		seesAll := 0
+		for i, list := range lists {
+			if len(list) >= len(lists) {
+				seesAll++
+			}
+			if isAll {
+				fmt.Printf("%d sees %d region%s\n", i, len(list), helper.Pluralize(len(list)))
+			}
+		}
+		average, share := pvs.Stats(lists)
+		fmt.Printf("%d regions see %0.1f regions on average (%0.1f%%), %d see every region\n", len(lists), average, share*100, seesAll)
+		return nil
+	}
+
+	regionIdx, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("parse region: %w", err)
+	}
+	if regionIdx < 0 || regionIdx >= len(lists) {
+		return fmt.Errorf("region %d out of range, %d regions", regionIdx, len(lists))
+	}
+	list := lists[regionIdx]
+	fmt.Printf("%d sees %d of %d regions: %s\n", regionIdx, len(list), len(lists), regionRanges(list))
+	if gltfPath == "" {
+		return nil
+	}
+
+	doc, err := regionGltf(q, regionIdx, list)
+	if err != nil {
+		return err
+	}
+	w, err := os.Create(gltfPath)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+	err = doc.Write(w)
+	if err != nil {
+		return fmt.Errorf("gltf write: %w", err)
+	}
+	fmt.Printf("Exported %s\n", filepath.Base(gltfPath))
+	return nil
+}
+
+// regionPvsCmd represents the region pvs command
+var regionPvsCmd = &cobra.Command{
+	Use:   "pvs",
+	Short: "Build which regions see each other",
+	Long: `Replace the vis lists of a zone with a potentially visible set built by casting rays between
+sample points of each region, blocked by solid region faces. Regions that touch are always visible
+Usage: quail region pvs <src> <dst> [--spacing <distance>] [--samples <n>] [--rays <n>] [--distance <distance>]
+Example: quail region pvs foo.s3d foo.s3d
+Example: quail region pvs foo.quail foo.s3d --spacing 16 --distance 2000`,
+	RunE: runRegionPvs,
+}
+
+func runRegionPvs(cmd *cobra.Command, args []string) error {
+	err := runRegionPvsE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runRegionPvsE(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return cmd.Usage()
+	}
+	cfg := pvs.DefaultConfig()
+	for name, val := range map[string]*float32{
+		"spacing":  &cfg.Spacing,
+		"distance": &cfg.MaxDistance,
+	} {
+		v, err := cmd.Flags().GetFloat32(name)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		*val = v
+	}
+	for name, val := range map[string]*int{
+		"samples": &cfg.Samples,
+		"rays":    &cfg.Rays,
+	} {
+		v, err := cmd.Flags().GetInt(name)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", name, err)
+		}
+		*val = v
+	}
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	q, err := regionLoad(args[0])
+	if err != nil {
+		return err
+	}
+	lists, err := q.Wld.VisibilityBuild(cfg)
+	if err != nil {
+		return err
+	}
+	err = q.Wld.VisibilitySet(lists)
+	if err != nil {
+		return err
+	}
+	err = quailSave(q, args[1])
+	if err != nil {
+		return err
+	}
+	average, share := pvs.Stats(lists)
+	fmt.Printf("Built vis lists for %d regions in %s, %0.1f regions seen on average (%0.1f%%)\n", len(lists), filepath.Base(args[1]), average, share*100)
+	return nil
+}
+
+// regionRanges returns sorted region indices as ranges, such as 0-4 7 9-12
+func regionRanges(regions []int) string {
+	parts := []string{}
+	for i := 0; i < len(regions); i++ {
+		start := regions[i]
+		for i+1 < len(regions) && regions[i+1] == regions[i]+1 {
+			i++
+		}
+		if regions[i] == start {
+			parts = append(parts, strconv.Itoa(start))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d-%d", start, regions[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// regionGltf returns the region meshes of a zone colored by what region regionIdx sees
+func regionGltf(q *quail.Quail, regionIdx int, visible []int) (*gltf.Doc, error) {
+	isVisible := make(map[int]bool)
+	for _, region := range visible {
+		isVisible[region] = true
+	}
+	doc := gltf.New()
+	for i, region := range q.Wld.Regions {
+		sprite, ok := q.Wld.ByTag(region.SpriteTag).(*wce.DMSpriteDef2)
+		if !ok || len(sprite.Faces) == 0 {
+			continue
+		}
+		prim := &gltf.Primitive{Material: "hidden", Color: [4]float32{0.4, 0.4, 0.4, 1}}
+		switch {
+		case i == regionIdx:
+			prim = &gltf.Primitive{Material: "region", Color: [4]float32{1, 0.2, 0.2, 1}}
+		case isVisible[i]:
+			prim = &gltf.Primitive{Material: "visible", Color: [4]float32{0.2, 0.8, 0.2, 1}}
+		}
+		src := &gltf.Mesh{Name: region.Tag, Primitives: []*gltf.Primitive{prim}}
+		for _, vert := range sprite.Vertices {
+			src.Positions = append(src.Positions, [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]})
+		}
+		for _, face := range sprite.Faces {
+			prim.Indices = append(prim.Indices, uint32(face.Triangle[0]), uint32(face.Triangle[1]), uint32(face.Triangle[2]))
+		}
+		err := doc.MeshAdd(src)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// regionZoneLineParse parses zoneid x y z heading, or 255 and a zone point index
+func regionZoneLineParse(args []string) (*wce.ZoneLine, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("zoneline requires a zone id and destination")
+	}
+	vals := []int{}
+	for i, arg := range args {
+		val, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("parse zoneline arg %d: %w", i, err)
+		}
+		vals = append(vals, val)
+	}
+	if vals[0] == 255 {
+		return &wce.ZoneLine{ZoneID: 255, Index: vals[1]}, nil
+	}
+	if len(vals) < 5 {
+		return nil, fmt.Errorf("zoneline requires zoneid x y z heading")
+	}
+	return &wce.ZoneLine{ZoneID: vals[0], X: vals[1], Y: vals[2], Z: vals[3], Heading: vals[4]}, nil
+}
+
+func regionLoad(srcPath string) (*quail.Quail, error) {
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	q := quails[0]
+	if q.Wld == nil {
+		return nil, fmt.Errorf("no zone found in %s", filepath.Base(srcPath))
+	}
+	if len(q.Wld.Regions) == 0 {
+		return nil, fmt.Errorf("no regions found in %s", filepath.Base(srcPath))
+	}
+	return q, nil
+}
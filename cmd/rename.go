@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+	renameCmd.Flags().Bool("tag", false, "rename a single tag instead of a model code")
+}
+
+// renameCmd represents the rename command
+var renameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename a model code or tag and every reference to it",
+	Long: `Rename a model code, such as ELF to ZZZ, in every actor, hierarchical sprite, mesh, track, material and palette tag, and every reference to them.
+Nothing is written if a renamed tag is already used by another definition
+Usage: quail rename [--tag] <src> <dst> <old> <new>
+Example: quail rename global_chr.s3d zzz_chr.s3d ELF ZZZ
+Example: quail rename --tag foo.quail foo.quail OLD_ACTORDEF NEW_ACTORDEF`,
+	RunE: runRename,
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	err := runRenameE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runRenameE(cmd *cobra.Command, args []string) error {
+	if len(args) < 4 {
+		return cmd.Usage()
+	}
+	isTag, err := cmd.Flags().GetBool("tag")
+	if err != nil {
+		return fmt.Errorf("tag: %w", err)
+	}
+	srcPath := args[0]
+	dstPath := args[1]
+	oldName := args[2]
+	newName := args[3]
+
+	quails, err := exportLoad(srcPath)
+	if err != nil {
+		return err
+	}
+	q := quails[0]
+
+	// dst is only written once every wld renamed without a collision
+	total := 0
+	for _, wld := range []*wce.Wce{q.Wld, q.WldObject, q.WldLights} {
+		if wld == nil {
+			continue
+		}
+		var count int
+		if isTag {
+			count, err = wld.RenameTag(oldName, newName)
+		} else {
+			count, err = wld.RenamePrefix(oldName, newName)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", wld.FileName, err)
+		}
+		fmt.Printf("%s: renamed %d definitions\n", wld.FileName, count)
+		total += count
+	}
+	if total == 0 {
+		return fmt.Errorf("no definitions found for %s", oldName)
+	}
+
+	return quailSave(q, dstPath)
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"github.com/xackery/quail/helper"
+	"gopkg.in/yaml.v3"
 )
 
 var cfgFile string
@@ -42,16 +46,59 @@ func init() {
 
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in config file and ENV variables if set. It writes to stderr, as stdout is the
+// protocol of the lsp command
 func initConfig() {
 	isVerbose, err := rootCmd.Flags().GetBool("verbose")
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
 	if isVerbose {
-		fmt.Printf("Verbose logging enabled")
+		fmt.Fprintf(os.Stderr, "Verbose logging enabled\n")
+	}
+
+	err = configLoad(cfgFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", err.Error())
+		os.Exit(1)
 	}
+}
+
+// config is the layout of the quail config file
+type config struct {
+	ShaderMap *helper.ShaderMap `yaml:"shaderMap"`
+}
 
+// configLoad applies the config file at path, or at $HOME/.quail.yaml if path is empty and it exists
+func configLoad(path string) error {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".quail.yaml")
+		_, err = os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	cfg := &config{}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("decode config %s: %w", path, err)
+	}
+	if cfg.ShaderMap != nil {
+		err = helper.ShaderMapOverride(cfg.ShaderMap)
+		if err != nil {
+			return fmt.Errorf("config %s: shader map: %w", path, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/raw"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	rootCmd.AddCommand(shadersCmd)
+	shadersCmd.Flags().Bool("map", false, "print the shader map conversions use instead")
+}
+
+// shadersCmd represents the shaders command
+var shadersCmd = &cobra.Command{
+	Use:   "shaders",
+	Short: "Report the shaders and material properties of eqg models",
+	Long: `Report every shader and material property combination of the mod, mds and ter models in an eq
+directory or eqg, with the wld render method each converts to.
+The shaderMap section of the config file changes how shaders convert, and --map prints the result
+Usage: quail shaders <src>
+Example: quail shaders ~/eq
+Example: quail shaders foo.eqg
+Example: quail shaders --map --config quail.yaml`,
+	RunE: runShaders,
+}
+
+func runShaders(cmd *cobra.Command, args []string) error {
+	err := runShadersE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runShadersE(cmd *cobra.Command, args []string) error {
+	isMap, err := cmd.Flags().GetBool("map")
+	if err != nil {
+		return fmt.Errorf("parse map: %w", err)
+	}
+	if isMap {
+		data, err := yaml.Marshal(helper.ShaderMapCurrent())
+		if err != nil {
+			return fmt.Errorf("encode shader map: %w", err)
+		}
+		fmt.Print(string(data))
+		return nil
+	}
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+
+	shaders, err := shaderScan(args[0])
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range shaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shader := shaders[name]
+		fmt.Printf("%s: %d materials\n", name, shader.count)
+		for _, combo := range shader.combos {
+			properties := strings.Join(combo.properties, ", ")
+			if properties == "" {
+				properties = "(none)"
+			}
+			fmt.Printf("  %s -> %s: %d materials (e.g. %s)\n", properties, combo.renderMethod, combo.count, combo.example)
+		}
+	}
+	fmt.Printf("%d shaders\n", len(names))
+	return nil
+}
+
+// shaderUsage is how the materials of a shader are set up
+type shaderUsage struct {
+	count  int
+	combos []*shaderCombo
+}
+
+// shaderCombo is the materials of a shader that set the same properties
+type shaderCombo struct {
+	properties   []string
+	renderMethod string
+	count        int
+	example      string
+}
+
+// shaderScan reads the materials of every model in path, which is a directory, an eqg, or a
+// mod, mds or ter file
+func shaderScan(path string) (map[string]*shaderUsage, error) {
+	shaders := make(map[string]*shaderUsage)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if !fi.IsDir() {
+		err = shaderScanFile(shaders, path)
+		if err != nil {
+			return nil, err
+		}
+		return shaders, nil
+	}
+
+	err = filepath.WalkDir(path, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		err = shaderScanFile(shaders, path)
+		if err != nil {
+			fmt.Printf("Skipping %s: %s\n", filepath.Base(path), err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	return shaders, nil
+}
+
+func shaderScanFile(shaders map[string]*shaderUsage, path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".eqg":
+	case ".mod", ".mds", ".ter":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		return shaderScanData(shaders, filepath.Base(path), data)
+	default:
+		return nil
+	}
+
+	archive, err := pfs.NewFile(path)
+	if err != nil {
+		return fmt.Errorf("pfs open: %w", err)
+	}
+	defer archive.Close()
+	for _, file := range archive.Files() {
+		err = shaderScanData(shaders, filepath.Base(path)+":"+file.Name(), file.Data())
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name(), err)
+		}
+	}
+	return nil
+}
+
+func shaderScanData(shaders map[string]*shaderUsage, name string, data []byte) error {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".mod", ".mds", ".ter":
+	default:
+		return nil
+	}
+
+	rawRead, err := raw.Read(ext, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("raw read: %w", err)
+	}
+	var materials []*raw.ModMaterial
+	switch dat := rawRead.(type) {
+	case *raw.Mod:
+		materials = dat.Materials
+	case *raw.Mds:
+		materials = dat.Materials
+	case *raw.Ter:
+		materials = dat.Materials
+	}
+
+	for _, mat := range materials {
+		shader := shaders[mat.ShaderName]
+		if shader == nil {
+			shader = &shaderUsage{}
+			shaders[mat.ShaderName] = shader
+		}
+		shader.count++
+
+		properties := []string{}
+		setProperties := []string{}
+		for _, property := range mat.Properties {
+			properties = append(properties, property.Name)
+			if property.Value != "" {
+				setProperties = append(setProperties, property.Name)
+			}
+		}
+		if len(mat.Animation.Textures) > 0 {
+			setProperties = append(setProperties, "e_TextureDiffuse0")
+		}
+		sort.Strings(properties)
+		renderMethod := helper.ShaderRenderMethod(mat.ShaderName, setProperties...)
+
+		var combo *shaderCombo
+		for _, existing := range shader.combos {
+			if existing.renderMethod == renderMethod && strings.Join(existing.properties, ",") == strings.Join(properties, ",") {
+				combo = existing
+				break
+			}
+		}
+		if combo == nil {
+			combo = &shaderCombo{
+				properties:   properties,
+				renderMethod: renderMethod,
+				example:      name + " " + mat.Name,
+			}
+			shader.combos = append(shader.combos, combo)
+		}
+		combo.count++
+	}
+	return nil
+}
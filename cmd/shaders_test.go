@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/raw"
+)
+
+func TestShaderScan(t *testing.T) {
+	mod := &raw.Mod{
+		Version: 1,
+		Materials: []*raw.ModMaterial{
+			{Name: "wall", ShaderName: "Opaque_MaxCB1.fx", Properties: []*raw.ModMaterialParam{
+				{Name: "e_TextureDiffuse0", Type: raw.MaterialParamTypeTexture, Value: "wall.dds"},
+				{Name: "e_fShininess0", Type: raw.MaterialParamTypeUnused, Value: "0.00000000"},
+			}},
+			{Name: "floor", ShaderName: "Opaque_MaxCB1.fx", Properties: []*raw.ModMaterialParam{
+				{Name: "e_fShininess0", Type: raw.MaterialParamTypeUnused, Value: "0.00000000"},
+				{Name: "e_TextureDiffuse0", Type: raw.MaterialParamTypeTexture, Value: "floor.dds"},
+			}},
+			{Name: "glass", ShaderName: "Alpha_MaxC1.fx", Properties: []*raw.ModMaterialParam{
+				{Name: "e_fShininess0", Type: raw.MaterialParamTypeUnused, Value: "0.00000000"},
+			}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := mod.Write(buf)
+	if err != nil {
+		t.Fatalf("mod write: %s", err.Error())
+	}
+
+	archive, err := pfs.New("test.eqg")
+	if err != nil {
+		t.Fatalf("pfs new: %s", err.Error())
+	}
+	err = archive.Add("test.mod", buf.Bytes())
+	if err != nil {
+		t.Fatalf("pfs add: %s", err.Error())
+	}
+	path := filepath.Join(t.TempDir(), "test.eqg")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %s", err.Error())
+	}
+	err = archive.Write(w)
+	w.Close()
+	if err != nil {
+		t.Fatalf("pfs write: %s", err.Error())
+	}
+
+	shaders, err := shaderScan(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("scan: %s", err.Error())
+	}
+	if len(shaders) != 2 {
+		t.Fatalf("shaders = %d, want 2", len(shaders))
+	}
+	opaque := shaders["Opaque_MaxCB1.fx"]
+	if opaque == nil || opaque.count != 2 || len(opaque.combos) != 1 {
+		t.Fatalf("opaque = %+v, want 2 materials with 1 combination", opaque)
+	}
+	if opaque.combos[0].renderMethod != "USERDEFINED_2" {
+		t.Fatalf("opaque render method = %s, want USERDEFINED_2", opaque.combos[0].renderMethod)
+	}
+	alpha := shaders["Alpha_MaxC1.fx"]
+	if alpha == nil || len(alpha.combos) != 1 || alpha.combos[0].renderMethod != "SOLIDFILLAMBIENTGOURAUD1" {
+		t.Fatalf("alpha = %+v, want a solid fill combination", alpha)
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/xackery/quail/wce"
+)
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+	upgradeCmd.Flags().Bool("check", false, "list files that need upgrading instead of rewriting them, exits with an error if any are found")
+}
+
+// upgradeCmd represents the upgrade command
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade .wce files to the current wcemu version",
+	Long: `Rewrite .wce files written by an older version of quail or wcemu to the current version, keeping comments.
+The version is read from the // wcemu header, files without one are treated as v0.0.0.
+Folders are searched for .wce files
+Usage: quail upgrade [--check] <path...>
+Example: quail upgrade foo.quail`,
+	RunE: runUpgrade,
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	err := runUpgradeE(cmd, args)
+	if err != nil {
+		fmt.Printf("Failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runUpgradeE(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return cmd.Usage()
+	}
+	isCheck, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		return fmt.Errorf("check: %w", err)
+	}
+
+	paths, err := wceFiles(args)
+	if err != nil {
+		return err
+	}
+
+	upgraded := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		out, version, err := wce.AsciiUpgrade(data)
+		if err != nil {
+			return fmt.Errorf("upgrade %s: %w", path, err)
+		}
+		if bytes.Equal(data, out) {
+			continue
+		}
+		upgraded++
+		fmt.Printf("%s: %s -> %s\n", path, version, wce.AsciiVersion)
+		if isCheck {
+			continue
+		}
+		err = os.WriteFile(path, out, 0644)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	if isCheck && upgraded > 0 {
+		return fmt.Errorf("%d files need upgrading", upgraded)
+	}
+	fmt.Printf("%d of %d files upgraded to wcemu %s\n", upgraded, len(paths), wce.AsciiVersion)
+	return nil
+}
@@ -0,0 +1,379 @@
+// Package gltf writes meshes as a glTF 2.0 scene, so models can be looked at in other tools
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// glTF component and buffer view target values
+const (
+	componentFloat       = 5126
+	componentUnsignedInt = 5125
+	targetArrayBuffer    = 34962
+	targetElementArray   = 34963
+)
+
+// Doc is a glTF scene being built. Everything added is in the z up space of eq, and a root node
+// turns it to the y up space of glTF
+type Doc struct {
+	root            *node
+	nodes           []*node
+	meshes          []*mesh
+	accessors       []*accessor
+	bufferViews     []*bufferView
+	materials       []*material
+	textures        []*texture
+	images          []*image
+	buf             bytes.Buffer
+	materialIndexes map[string]int
+	imageIndexes    map[string]int
+	markerMesh      *int
+}
+
+// Mesh is triangles to add to a doc
+type Mesh struct {
+	Name       string
+	Positions  [][3]float32
+	Normals    [][3]float32 // optional, one per position
+	UVs        [][2]float32 // optional, one per position
+	Primitives []*Primitive
+}
+
+// Primitive is the triangles of a mesh drawn with the same material
+type Primitive struct {
+	Material string     // material name, empty for none
+	Texture  string     // image file name of the material, empty for none
+	Color    [4]float32 // rgba base color of the material, zero for white
+	Indices  []uint32
+}
+
+type gltfFile struct {
+	Asset       asset         `json:"asset"`
+	Scene       int           `json:"scene"`
+	Scenes      []scene       `json:"scenes"`
+	Nodes       []*node       `json:"nodes"`
+	Meshes      []*mesh       `json:"meshes,omitempty"`
+	Accessors   []*accessor   `json:"accessors,omitempty"`
+	BufferViews []*bufferView `json:"bufferViews,omitempty"`
+	Buffers     []buffer      `json:"buffers,omitempty"`
+	Materials   []*material   `json:"materials,omitempty"`
+	Textures    []*texture    `json:"textures,omitempty"`
+	Images      []*image      `json:"images,omitempty"`
+	Samplers    []sampler     `json:"samplers,omitempty"`
+}
+
+type asset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type scene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type node struct {
+	Name        string      `json:"name,omitempty"`
+	Mesh        *int        `json:"mesh,omitempty"`
+	Children    []int       `json:"children,omitempty"`
+	Translation *[3]float32 `json:"translation,omitempty"`
+	Rotation    *[4]float32 `json:"rotation,omitempty"`
+	Scale       *[3]float32 `json:"scale,omitempty"`
+}
+
+type mesh struct {
+	Name       string       `json:"name,omitempty"`
+	Primitives []*primitive `json:"primitives"`
+}
+
+type primitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+}
+
+type accessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type bufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type buffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+type material struct {
+	Name                 string `json:"name,omitempty"`
+	PbrMetallicRoughness pbr    `json:"pbrMetallicRoughness"`
+	DoubleSided          bool   `json:"doubleSided,omitempty"`
+}
+
+type pbr struct {
+	BaseColorFactor  *[4]float32  `json:"baseColorFactor,omitempty"`
+	BaseColorTexture *textureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float32      `json:"metallicFactor"`
+	RoughnessFactor  float32      `json:"roughnessFactor"`
+}
+
+type textureInfo struct {
+	Index int `json:"index"`
+}
+
+type texture struct {
+	Source  int `json:"source"`
+	Sampler int `json:"sampler"`
+}
+
+type image struct {
+	URI string `json:"uri"`
+}
+
+type sampler struct{}
+
+// New returns an empty doc
+func New() *Doc {
+	// turn z up to y up, -90 degrees around x
+	rotation := [4]float32{-float32(math.Sqrt2 / 2), 0, 0, float32(math.Sqrt2 / 2)}
+	root := &node{Name: "root", Rotation: &rotation}
+	return &Doc{
+		root:            root,
+		nodes:           []*node{root},
+		materialIndexes: make(map[string]int),
+		imageIndexes:    make(map[string]int),
+	}
+}
+
+// MeshAdd adds mesh to the scene, at the origin
+func (doc *Doc) MeshAdd(src *Mesh) error {
+	if len(src.Normals) > 0 && len(src.Normals) != len(src.Positions) {
+		return fmt.Errorf("mesh %s: %d normals for %d positions", src.Name, len(src.Normals), len(src.Positions))
+	}
+	if len(src.UVs) > 0 && len(src.UVs) != len(src.Positions) {
+		return fmt.Errorf("mesh %s: %d uvs for %d positions", src.Name, len(src.UVs), len(src.Positions))
+	}
+	isDrawn := false
+	for _, prim := range src.Primitives {
+		for _, index := range prim.Indices {
+			if int(index) >= len(src.Positions) {
+				return fmt.Errorf("mesh %s: index %d out of range", src.Name, index)
+			}
+		}
+		isDrawn = isDrawn || len(prim.Indices) > 0
+	}
+	if !isDrawn {
+		return fmt.Errorf("mesh %s: no triangles", src.Name)
+	}
+
+	dst := &mesh{Name: src.Name}
+	attributes := map[string]int{}
+	if len(src.Positions) > 0 {
+		attributes["POSITION"] = doc.vec3Add(src.Positions, true)
+	}
+	if len(src.Normals) > 0 {
+		attributes["NORMAL"] = doc.vec3Add(src.Normals, false)
+	}
+	if len(src.UVs) > 0 {
+		attributes["TEXCOORD_0"] = doc.vec2Add(src.UVs)
+	}
+
+	for _, prim := range src.Primitives {
+		if len(prim.Indices) == 0 {
+			continue
+		}
+		dstPrim := &primitive{
+			Attributes: attributes,
+			Indices:    doc.indicesAdd(prim.Indices),
+		}
+		if prim.Material != "" || prim.Texture != "" || prim.Color != [4]float32{} {
+			index := doc.materialAdd(prim.Material, prim.Texture, prim.Color)
+			dstPrim.Material = &index
+		}
+		dst.Primitives = append(dst.Primitives, dstPrim)
+	}
+
+	meshIndex := len(doc.meshes)
+	doc.meshes = append(doc.meshes, dst)
+	doc.childAdd(&node{Name: src.Name, Mesh: &meshIndex})
+	return nil
+}
+
+// MarkerAdd adds a marker named name at translation, turned by rotation and size units across.
+// The marker is a diamond with a longer tip along its x axis, so its turn can be seen
+func (doc *Doc) MarkerAdd(name string, translation [3]float32, rotation [4]float32, size float32) {
+	if doc.markerMesh == nil {
+		positions := [][3]float32{{1, 0, 0}, {-0.5, 0, 0}, {0, 0.5, 0}, {0, -0.5, 0}, {0, 0, 0.5}, {0, 0, -0.5}}
+		indices := []uint32{0, 2, 4, 2, 1, 4, 1, 3, 4, 3, 0, 4, 2, 0, 5, 1, 2, 5, 3, 1, 5, 0, 3, 5}
+		color := [4]float32{1, 0.2, 0.2, 1}
+		materialIndex := len(doc.materials)
+		doc.materials = append(doc.materials, &material{Name: "marker", PbrMetallicRoughness: pbr{BaseColorFactor: &color, RoughnessFactor: 1}, DoubleSided: true})
+		meshIndex := len(doc.meshes)
+		doc.meshes = append(doc.meshes, &mesh{Name: "marker", Primitives: []*primitive{{
+			Attributes: map[string]int{"POSITION": doc.vec3Add(positions, true)},
+			Indices:    doc.indicesAdd(indices),
+			Material:   &materialIndex,
+		}}})
+		doc.markerMesh = &meshIndex
+	}
+	scale := [3]float32{size, size, size}
+	doc.childAdd(&node{
+		Name:        name,
+		Mesh:        doc.markerMesh,
+		Translation: &translation,
+		Rotation:    &rotation,
+		Scale:       &scale,
+	})
+}
+
+// childAdd adds a node under the root node
+func (doc *Doc) childAdd(n *node) int {
+	index := len(doc.nodes)
+	doc.nodes = append(doc.nodes, n)
+	doc.root.Children = append(doc.root.Children, index)
+	return index
+}
+
+func (doc *Doc) materialAdd(name string, textureName string, color [4]float32) int {
+	key := fmt.Sprintf("%s|%s|%v", name, textureName, color)
+	index, ok := doc.materialIndexes[key]
+	if ok {
+		return index
+	}
+	mat := &material{Name: name, PbrMetallicRoughness: pbr{RoughnessFactor: 1}}
+	if color != [4]float32{} {
+		mat.PbrMetallicRoughness.BaseColorFactor = &color
+	}
+	if textureName != "" {
+		imageIndex, ok := doc.imageIndexes[textureName]
+		if !ok {
+			imageIndex = len(doc.images)
+			doc.images = append(doc.images, &image{URI: textureName})
+			doc.imageIndexes[textureName] = imageIndex
+		}
+		mat.PbrMetallicRoughness.BaseColorTexture = &textureInfo{Index: len(doc.textures)}
+		doc.textures = append(doc.textures, &texture{Source: imageIndex})
+	}
+	index = len(doc.materials)
+	doc.materials = append(doc.materials, mat)
+	doc.materialIndexes[key] = index
+	return index
+}
+
+// viewAdd appends data to the buffer, 4 byte aligned, and returns its buffer view
+func (doc *Doc) viewAdd(data []byte, target int) int {
+	for doc.buf.Len()%4 != 0 {
+		doc.buf.WriteByte(0)
+	}
+	view := &bufferView{ByteOffset: doc.buf.Len(), ByteLength: len(data), Target: target}
+	doc.buf.Write(data)
+	doc.bufferViews = append(doc.bufferViews, view)
+	return len(doc.bufferViews) - 1
+}
+
+func (doc *Doc) vec3Add(values [][3]float32, isBounded bool) int {
+	data := &bytes.Buffer{}
+	minValue := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	maxValue := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for _, value := range values {
+		binary.Write(data, binary.LittleEndian, value)
+		for i := 0; i < 3; i++ {
+			minValue[i] = min(minValue[i], value[i])
+			maxValue[i] = max(maxValue[i], value[i])
+		}
+	}
+	acc := &accessor{
+		BufferView:    doc.viewAdd(data.Bytes(), targetArrayBuffer),
+		ComponentType: componentFloat,
+		Count:         len(values),
+		Type:          "VEC3",
+	}
+	// positions need bounds
+	if isBounded {
+		acc.Min = minValue
+		acc.Max = maxValue
+	}
+	doc.accessors = append(doc.accessors, acc)
+	return len(doc.accessors) - 1
+}
+
+func (doc *Doc) vec2Add(values [][2]float32) int {
+	data := &bytes.Buffer{}
+	for _, value := range values {
+		binary.Write(data, binary.LittleEndian, value)
+	}
+	doc.accessors = append(doc.accessors, &accessor{
+		BufferView:    doc.viewAdd(data.Bytes(), targetArrayBuffer),
+		ComponentType: componentFloat,
+		Count:         len(values),
+		Type:          "VEC2",
+	})
+	return len(doc.accessors) - 1
+}
+
+func (doc *Doc) indicesAdd(indices []uint32) int {
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, indices)
+	doc.accessors = append(doc.accessors, &accessor{
+		BufferView:    doc.viewAdd(data.Bytes(), targetElementArray),
+		ComponentType: componentUnsignedInt,
+		Count:         len(indices),
+		Type:          "SCALAR",
+	})
+	return len(doc.accessors) - 1
+}
+
+// Images returns the file name of every texture the doc uses. They are read from beside the glTF
+func (doc *Doc) Images() []string {
+	names := []string{}
+	for _, img := range doc.images {
+		names = append(names, img.URI)
+	}
+	return names
+}
+
+// Write writes the doc as a .gltf, with its buffer embedded
+func (doc *Doc) Write(w io.Writer) error {
+	file := &gltfFile{
+		Asset:       asset{Version: "2.0", Generator: "quail"},
+		Scenes:      []scene{{Nodes: []int{0}}},
+		Nodes:       doc.nodes,
+		Meshes:      doc.meshes,
+		Accessors:   doc.accessors,
+		BufferViews: doc.bufferViews,
+		Materials:   doc.materials,
+		Textures:    doc.textures,
+		Images:      doc.images,
+	}
+	if len(doc.textures) > 0 {
+		file.Samplers = []sampler{{}}
+	}
+	if doc.buf.Len() > 0 {
+		file.Buffers = []buffer{{
+			ByteLength: doc.buf.Len(),
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(doc.buf.Bytes()),
+		}}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(file)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	doc := New()
+	err := doc.MeshAdd(&Mesh{
+		Name:      "box",
+		Positions: [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+		UVs:       [][2]float32{{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+		Primitives: []*Primitive{
+			{Material: "a", Texture: "a.bmp", Indices: []uint32{0, 1, 2}},
+			{Material: "b", Indices: []uint32{0, 2, 3}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("mesh add: %s", err)
+	}
+	err = doc.MeshAdd(&Mesh{Name: "bad", Positions: [][3]float32{{0, 0, 0}}, Primitives: []*Primitive{{Indices: []uint32{0, 1, 2}}}})
+	if err == nil {
+		t.Fatalf("index out of range: want error")
+	}
+
+	buf := &bytes.Buffer{}
+	err = doc.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	file := &gltfFile{}
+	err = json.Unmarshal(buf.Bytes(), file)
+	if err != nil {
+		t.Fatalf("unmarshal: %s", err)
+	}
+	if len(file.Meshes) != 1 || len(file.Meshes[0].Primitives) != 2 || len(file.Materials) != 2 || len(file.Images) != 1 {
+		t.Fatalf("got %d meshes, %d materials, %d images", len(file.Meshes), len(file.Materials), len(file.Images))
+	}
+	if len(file.Nodes) != 2 || len(file.Nodes[0].Children) != 1 {
+		t.Fatalf("nodes: got %d", len(file.Nodes))
+	}
+	position := file.Accessors[file.Meshes[0].Primitives[0].Attributes["POSITION"]]
+	if position.Count != 4 || position.Max[2] != 1 {
+		t.Fatalf("position accessor: %+v", position)
+	}
+	// 4 positions, 4 uvs and 2 sets of 3 indices, each aligned to 4 bytes
+	if file.Buffers[0].ByteLength != 48+32+12+12 {
+		t.Fatalf("buffer length: got %d", file.Buffers[0].ByteLength)
+	}
+}
+
+func TestMarkerAdd(t *testing.T) {
+	doc := New()
+	doc.MarkerAdd("R_POINT", [3]float32{1, 2, 3}, [4]float32{0, 0, 0, 1}, 0.5)
+	doc.MarkerAdd("L_POINT", [3]float32{-1, 2, 3}, [4]float32{0, 0, 0, 1}, 0.5)
+
+	buf := &bytes.Buffer{}
+	err := doc.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	file := &gltfFile{}
+	err = json.Unmarshal(buf.Bytes(), file)
+	if err != nil {
+		t.Fatalf("unmarshal: %s", err)
+	}
+	if len(file.Meshes) != 1 || len(file.Materials) != 1 {
+		t.Fatalf("got %d meshes, %d materials", len(file.Meshes), len(file.Materials))
+	}
+	if len(file.Nodes) != 3 || len(file.Nodes[0].Children) != 2 {
+		t.Fatalf("nodes: got %d", len(file.Nodes))
+	}
+	// markers share a mesh
+	if *file.Nodes[1].Mesh != 0 || *file.Nodes[2].Mesh != 0 || (*file.Nodes[2].Translation)[0] != -1 {
+		t.Fatalf("markers: got %+v %+v", file.Nodes[1], file.Nodes[2])
+	}
+	// the 6 positions and 24 indices of the marker
+	if file.Buffers[0].ByteLength != 72+96 {
+		t.Fatalf("buffer length: got %d", file.Buffers[0].ByteLength)
+	}
+}
@@ -168,3 +168,85 @@ func handleNewAniModelCode(newAniCode, newModelCode string) (string, string) {
 	currentAniModelCode = newModelCode
 	return newAniCode, newModelCode
 }
+
+// animationNames are what each animation code is used for by characters
+var animationNames = map[string]string{
+	"C01": "kick",
+	"C02": "pierce",
+	"C03": "2h slash",
+	"C04": "2h blunt",
+	"C05": "throw",
+	"C06": "offhand",
+	"C07": "bash",
+	"C08": "mainhand",
+	"C09": "archery",
+	"C10": "swim attack",
+	"C11": "round kick",
+	"D01": "minor damage",
+	"D02": "heavy damage",
+	"D03": "trap damage",
+	"D04": "drowning",
+	"D05": "death",
+	"L01": "walk",
+	"L02": "run",
+	"L03": "running jump",
+	"L04": "standing jump",
+	"L05": "fall",
+	"L06": "crouch walk",
+	"L07": "climb",
+	"L08": "crouch",
+	"L09": "swim",
+	"O01": "idle",
+	"O02": "idle arms",
+	"O03": "idle sit",
+	"P01": "stand",
+	"P02": "sit and stand",
+	"P03": "shuffle",
+	"P04": "float",
+	"P05": "kneel",
+	"P06": "swim idle",
+	"P07": "sit",
+	"P08": "stand arms",
+	"S01": "cheer",
+	"S02": "mourn",
+	"S03": "wave",
+	"S04": "rude",
+	"S05": "yawn",
+	"S06": "nod",
+	"S07": "amazed",
+	"S08": "plead",
+	"S09": "clap",
+	"S10": "distress",
+	"S11": "blush",
+	"S12": "chuckle",
+	"S13": "burp",
+	"S14": "duck",
+	"S15": "look around",
+	"S16": "dance",
+	"S17": "blink",
+	"S18": "glare",
+	"S19": "drool",
+	"S20": "kneel",
+	"S21": "laugh",
+	"S22": "point",
+	"S23": "shrug",
+	"S24": "raise hand",
+	"S25": "salute",
+	"S26": "shiver",
+	"S27": "tap foot",
+	"S28": "bow",
+	"T02": "stringed instrument",
+	"T03": "wind instrument",
+	"T04": "cast pray",
+	"T05": "cast point",
+	"T06": "cast arms up",
+	"T07": "flying kick",
+	"T08": "rapid punch",
+	"T09": "large punch",
+}
+
+// AnimationName returns what an animation code, such as C01, is used for, or an empty string if
+// it is not known
+func AnimationName(code string) string {
+	return animationNames[strings.ToUpper(code)]
+}
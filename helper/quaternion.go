@@ -0,0 +1,104 @@
+package helper
+
+import "math"
+
+// Quaternions are x, y, z, w
+
+// QuatIdentity is a quaternion that does not rotate
+var QuatIdentity = [4]float32{0, 0, 0, 1}
+
+// QuatNormalize returns q scaled to unit length, or the identity if q has no length
+func QuatNormalize(q [4]float32) [4]float32 {
+	length := math.Sqrt(float64(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3]))
+	if length == 0 {
+		return QuatIdentity
+	}
+	return [4]float32{
+		float32(float64(q[0]) / length),
+		float32(float64(q[1]) / length),
+		float32(float64(q[2]) / length),
+		float32(float64(q[3]) / length),
+	}
+}
+
+// QuatMul returns the rotation of b followed by a
+func QuatMul(a [4]float32, b [4]float32) [4]float32 {
+	return [4]float32{
+		a[3]*b[0] + a[0]*b[3] + a[1]*b[2] - a[2]*b[1],
+		a[3]*b[1] - a[0]*b[2] + a[1]*b[3] + a[2]*b[0],
+		a[3]*b[2] + a[0]*b[1] - a[1]*b[0] + a[2]*b[3],
+		a[3]*b[3] - a[0]*b[0] - a[1]*b[1] - a[2]*b[2],
+	}
+}
+
+// QuatRotate rotates v by the unit quaternion q
+func QuatRotate(q [4]float32, v [3]float32) [3]float32 {
+	// t = 2 * cross(q.xyz, v), v' = v + w*t + cross(q.xyz, t)
+	t := [3]float32{
+		2 * (q[1]*v[2] - q[2]*v[1]),
+		2 * (q[2]*v[0] - q[0]*v[2]),
+		2 * (q[0]*v[1] - q[1]*v[0]),
+	}
+	return [3]float32{
+		v[0] + q[3]*t[0] + q[1]*t[2] - q[2]*t[1],
+		v[1] + q[3]*t[1] + q[2]*t[0] - q[0]*t[2],
+		v[2] + q[3]*t[2] + q[0]*t[1] - q[1]*t[0],
+	}
+}
+
+// QuatConjugate returns the inverse of the unit quaternion q
+func QuatConjugate(q [4]float32) [4]float32 {
+	return [4]float32{-q[0], -q[1], -q[2], q[3]}
+}
+
+// QuatSlerp returns the unit quaternion t of the way from a to b, turning the short way around
+func QuatSlerp(a [4]float32, b [4]float32, t float32) [4]float32 {
+	dot := float64(a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3])
+	if dot < 0 {
+		dot = -dot
+		b = [4]float32{-b[0], -b[1], -b[2], -b[3]}
+	}
+	wa := 1 - float64(t)
+	wb := float64(t)
+	// nearly the same rotation, where sin(theta) is too small to divide by
+	if dot < 0.9995 {
+		theta := math.Acos(dot)
+		sinTheta := math.Sin(theta)
+		wa = math.Sin((1-float64(t))*theta) / sinTheta
+		wb = math.Sin(float64(t)*theta) / sinTheta
+	}
+	return QuatNormalize([4]float32{
+		float32(wa*float64(a[0]) + wb*float64(b[0])),
+		float32(wa*float64(a[1]) + wb*float64(b[1])),
+		float32(wa*float64(a[2]) + wb*float64(b[2])),
+		float32(wa*float64(a[3]) + wb*float64(b[3])),
+	})
+}
+
+// QuatAngle returns the angle in radians of the rotation from a to b
+func QuatAngle(a [4]float32, b [4]float32) float64 {
+	dot := math.Abs(float64(a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]))
+	return 2 * math.Acos(math.Min(dot, 1))
+}
+
+// QuatFromEuler returns the unit quaternion turning x radians around x, then y around y, then z
+// around z
+func QuatFromEuler(euler [3]float32) [4]float32 {
+	axis := func(i int) [4]float32 {
+		q := [4]float32{0, 0, 0, float32(math.Cos(float64(euler[i]) / 2))}
+		q[i] = float32(math.Sin(float64(euler[i]) / 2))
+		return q
+	}
+	return QuatNormalize(QuatMul(axis(2), QuatMul(axis(1), axis(0))))
+}
+
+// QuatToEuler returns the x, y and z turns in radians of q, as QuatFromEuler takes them
+func QuatToEuler(q [4]float32) [3]float32 {
+	x, y, z, w := float64(q[0]), float64(q[1]), float64(q[2]), float64(q[3])
+	sinY := math.Max(-1, math.Min(1, 2*(w*y-z*x)))
+	return [3]float32{
+		float32(math.Atan2(2*(w*x+y*z), 1-2*(x*x+y*y))),
+		float32(math.Asin(sinY)),
+		float32(math.Atan2(2*(w*z+x*y), 1-2*(y*y+z*z))),
+	}
+}
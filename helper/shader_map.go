@@ -0,0 +1,199 @@
+package helper
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ShaderMap relates eqg shaders and their material properties to wld render methods
+type ShaderMap struct {
+	Shaders       []ShaderRule       `yaml:"shaders,omitempty"`
+	RenderMethods []RenderMethodRule `yaml:"renderMethods,omitempty"`
+}
+
+// ShaderRule picks the render method of eqg materials with a matching shader
+type ShaderRule struct {
+	Shader       string `yaml:"shader"`
+	Property     string `yaml:"property,omitempty"` // the material must have this property
+	Missing      string `yaml:"missing,omitempty"`  // the material must not have this property
+	RenderMethod string `yaml:"renderMethod"`
+}
+
+// RenderMethodRule picks the shader of wld materials with a matching render method
+type RenderMethodRule struct {
+	RenderMethod string `yaml:"renderMethod"`
+	Shader       string `yaml:"shader"`
+}
+
+//go:embed shader_map.yaml
+var shaderMapDefaultData []byte
+
+var (
+	shaderMapMu      sync.RWMutex
+	shaderMapCurrent *ShaderMap
+)
+
+// ShaderMapDefault returns the built in shader map
+func ShaderMapDefault() (*ShaderMap, error) {
+	shaderMap := &ShaderMap{}
+	err := yaml.Unmarshal(shaderMapDefaultData, shaderMap)
+	if err != nil {
+		return nil, fmt.Errorf("decode default shader map: %w", err)
+	}
+	return shaderMap, nil
+}
+
+// ShaderMapCurrent returns the shader map conversions use
+func ShaderMapCurrent() *ShaderMap {
+	shaderMapMu.RLock()
+	shaderMap := shaderMapCurrent
+	shaderMapMu.RUnlock()
+	if shaderMap != nil {
+		return shaderMap
+	}
+	shaderMapMu.Lock()
+	defer shaderMapMu.Unlock()
+	return shaderMapLocked()
+}
+
+// shaderMapLocked returns the shader map conversions use, setting the default one when none is.
+// shaderMapMu must be locked for writing
+func shaderMapLocked() *ShaderMap {
+	if shaderMapCurrent == nil {
+		shaderMap, err := ShaderMapDefault()
+		if err != nil {
+			// the default map is embedded, so this is a build problem
+			panic(err)
+		}
+		shaderMapCurrent = shaderMap
+	}
+	return shaderMapCurrent
+}
+
+// ShaderMapOverride puts the rules of override before those of the shader map conversions use
+func ShaderMapOverride(override *ShaderMap) error {
+	err := override.Validate()
+	if err != nil {
+		return err
+	}
+	shaderMapMu.Lock()
+	defer shaderMapMu.Unlock()
+	// read and replace under one lock, so overrides made at the same time are all kept
+	current := shaderMapLocked()
+	shaderMapCurrent = &ShaderMap{
+		Shaders:       append(append([]ShaderRule{}, override.Shaders...), current.Shaders...),
+		RenderMethods: append(append([]RenderMethodRule{}, override.RenderMethods...), current.RenderMethods...),
+	}
+	return nil
+}
+
+// ShaderMapReset makes conversions use the built in shader map again
+func ShaderMapReset() {
+	shaderMapMu.Lock()
+	shaderMapCurrent = nil
+	shaderMapMu.Unlock()
+}
+
+// ShaderMapLoad reads a yaml shader map from path and puts its rules before the current ones
+func ShaderMapLoad(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read shader map: %w", err)
+	}
+	shaderMap := &ShaderMap{}
+	err = yaml.Unmarshal(data, shaderMap)
+	if err != nil {
+		return fmt.Errorf("decode shader map %s: %w", path, err)
+	}
+	err = ShaderMapOverride(shaderMap)
+	if err != nil {
+		return fmt.Errorf("shader map %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks every rule has a valid pattern and a result
+func (e *ShaderMap) Validate() error {
+	for i, rule := range e.Shaders {
+		_, err := path.Match(rule.Shader, "")
+		if err != nil || rule.Shader == "" {
+			return fmt.Errorf("shader rule %d: invalid shader pattern %q", i, rule.Shader)
+		}
+		if RenderMethodInt(rule.RenderMethod) == 0 && rule.RenderMethod != "TRANSPARENT" {
+			return fmt.Errorf("shader rule %d: unknown render method %q", i, rule.RenderMethod)
+		}
+	}
+	for i, rule := range e.RenderMethods {
+		_, err := path.Match(rule.RenderMethod, "")
+		if err != nil || rule.RenderMethod == "" {
+			return fmt.Errorf("render method rule %d: invalid render method pattern %q", i, rule.RenderMethod)
+		}
+		if rule.Shader == "" {
+			return fmt.Errorf("render method rule %d: shader not set", i)
+		}
+	}
+	return nil
+}
+
+// RenderMethod returns the render method of the first rule matching a shader and the names of
+// its material properties, or an empty string if none match
+func (e *ShaderMap) RenderMethod(shader string, properties ...string) string {
+	hasProperty := make(map[string]bool)
+	for _, property := range properties {
+		hasProperty[strings.ToLower(property)] = true
+	}
+	for _, rule := range e.Shaders {
+		if !patternMatch(rule.Shader, shader) {
+			continue
+		}
+		if rule.Property != "" && !hasProperty[strings.ToLower(rule.Property)] {
+			continue
+		}
+		if rule.Missing != "" && hasProperty[strings.ToLower(rule.Missing)] {
+			continue
+		}
+		return rule.RenderMethod
+	}
+	return ""
+}
+
+// Shader returns the shader of the first rule matching a render method, or an empty string if
+// none match
+func (e *ShaderMap) Shader(method string) string {
+	for _, rule := range e.RenderMethods {
+		if patternMatch(rule.RenderMethod, method) {
+			return rule.Shader
+		}
+	}
+	return ""
+}
+
+func patternMatch(pattern string, name string) bool {
+	isMatch, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return err == nil && isMatch
+}
+
+// RenderMethodShader returns the eqg shader closest to a wld render method
+func RenderMethodShader(method string) string {
+	shader := ShaderMapCurrent().Shader(method)
+	if shader == "" {
+		return "Opaque_MaxCB1.fx"
+	}
+	return shader
+}
+
+// ShaderRenderMethod returns the wld render method closest to an eqg shader and the names of its
+// material properties
+func ShaderRenderMethod(shader string, properties ...string) string {
+	method := ShaderMapCurrent().RenderMethod(shader, properties...)
+	if method == "" {
+		return "USERDEFINED_2"
+	}
+	return method
+}
@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestShaderRenderMethod(t *testing.T) {
+	tests := []struct {
+		name       string
+		shader     string
+		properties []string
+		want       string
+	}{
+		{name: "opaque", shader: "Opaque_MaxCB1.fx", properties: []string{"e_TextureDiffuse0", "e_fShininess0"}, want: "USERDEFINED_2"},
+		{name: "alpha", shader: "Alpha_MaxC1.fx", properties: []string{"e_TextureDiffuse0"}, want: "USERDEFINED_6"},
+		{name: "addalpha", shader: "AddAlpha_MaxCB1.fx", properties: []string{"e_TextureDiffuse0"}, want: "USERDEFINED_11"},
+		{name: "chroma lower case", shader: "chroma_maxcb1.fx", properties: []string{"e_texturediffuse0"}, want: "USERDEFINED_20"},
+		{name: "no diffuse", shader: "Alpha_MaxCB1.fx", properties: []string{"e_fShininess0"}, want: "SOLIDFILLAMBIENTGOURAUD1"},
+		{name: "unknown", shader: "Foo.fx", properties: []string{"e_TextureDiffuse0"}, want: "USERDEFINED_2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShaderRenderMethod(tt.shader, tt.properties...); got != tt.want {
+				t.Fatalf("ShaderRenderMethod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMethodShader(t *testing.T) {
+	tests := map[string]string{
+		"USERDEFINED_2":            "Opaque_MaxCB1.fx",
+		"USERDEFINED_10":           "Alpha_MaxCB1.fx",
+		"USERDEFINED_20":           "Chroma_MaxCB1.fx",
+		"TRANSPARENT":              "Opaque_MaxCB1.fx",
+		"TRANSSOLIDFILLCONSTANT":   "Alpha_MaxCB1.fx",
+		"SOLIDFILLAMBIENTGOURAUD1": "Opaque_MaxCB1.fx",
+	}
+	for method, want := range tests {
+		if got := RenderMethodShader(method); got != want {
+			t.Fatalf("RenderMethodShader(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestShaderMapLoad(t *testing.T) {
+	defer ShaderMapReset()
+
+	path := filepath.Join(t.TempDir(), "shader_map.yaml")
+	err := os.WriteFile(path, []byte(`shaders:
+  - shader: "alpha*"
+    property: e_fShininess0
+    renderMethod: USERDEFINED_10
+renderMethods:
+  - renderMethod: USERDEFINED_6
+    shader: AlphaBump_MaxCB1.fx
+`), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err.Error())
+	}
+	err = ShaderMapLoad(path)
+	if err != nil {
+		t.Fatalf("load: %s", err.Error())
+	}
+
+	if got := ShaderRenderMethod("Alpha_MaxCB1.fx", "e_TextureDiffuse0", "e_fShininess0"); got != "USERDEFINED_10" {
+		t.Fatalf("override shader = %s, want USERDEFINED_10", got)
+	}
+	if got := ShaderRenderMethod("Alpha_MaxCB1.fx", "e_TextureDiffuse0"); got != "USERDEFINED_6" {
+		t.Fatalf("default shader = %s, want USERDEFINED_6", got)
+	}
+	if got := RenderMethodShader("USERDEFINED_6"); got != "AlphaBump_MaxCB1.fx" {
+		t.Fatalf("override render method = %s, want AlphaBump_MaxCB1.fx", got)
+	}
+
+	err = ShaderMapOverride(&ShaderMap{Shaders: []ShaderRule{{Shader: "*", RenderMethod: "FOO"}}})
+	if err == nil {
+		t.Fatalf("override with unknown render method should fail")
+	}
+
+	ShaderMapReset()
+	if got := RenderMethodShader("USERDEFINED_6"); got != "Alpha_MaxCB1.fx" {
+		t.Fatalf("reset render method = %s, want Alpha_MaxCB1.fx", got)
+	}
+}
+
+func TestShaderMapOverrideConcurrent(t *testing.T) {
+	defer ShaderMapReset()
+
+	defaults := len(ShaderMapCurrent().RenderMethods)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := ShaderMapOverride(&ShaderMap{RenderMethods: []RenderMethodRule{{RenderMethod: fmt.Sprintf("USERDEFINED_%d", i), Shader: "Opaque_MaxCB1.fx"}}})
+			if err != nil {
+				t.Errorf("override %d: %s", i, err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(ShaderMapCurrent().RenderMethods); got != defaults+20 {
+		t.Fatalf("got %d render method rules, want %d", got, defaults+20)
+	}
+}
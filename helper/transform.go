@@ -0,0 +1,17 @@
+package helper
+
+import "math"
+
+// EulerTransform rotates v about x, then y, then z by euler in radians, scales it and moves it
+func EulerTransform(v [3]float32, euler [3]float32, scale [3]float32, translation [3]float32) [3]float32 {
+	sin, cos := math.Sincos(float64(euler[0]))
+	v = [3]float32{v[0], float32(cos)*v[1] - float32(sin)*v[2], float32(sin)*v[1] + float32(cos)*v[2]}
+	sin, cos = math.Sincos(float64(euler[1]))
+	v = [3]float32{float32(cos)*v[0] + float32(sin)*v[2], v[1], -float32(sin)*v[0] + float32(cos)*v[2]}
+	sin, cos = math.Sincos(float64(euler[2]))
+	v = [3]float32{float32(cos)*v[0] - float32(sin)*v[1], float32(sin)*v[0] + float32(cos)*v[1], v[2]}
+	for i := 0; i < 3; i++ {
+		v[i] = v[i]*scale[i] + translation[i]
+	}
+	return v
+}
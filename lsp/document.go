@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xackery/quail/wce/def"
+)
+
+var regexToken = regexp.MustCompile(`"([^"]*)"|(\S+)`)
+
+// token is a word on a line, quoted strings have their quotes removed from Text
+type token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// lineTokens splits a line the same way the wce reader does, stopping at a // comment
+func lineTokens(line string) []token {
+	tokens := []token{}
+	for _, match := range regexToken.FindAllStringSubmatchIndex(line, -1) {
+		if match[2] >= 0 {
+			tokens = append(tokens, token{Text: line[match[2]:match[3]], Start: match[0], End: match[1]})
+			continue
+		}
+		text := line[match[4]:match[5]]
+		idx := strings.Index(text, "//")
+		if idx >= 0 {
+			if idx > 0 {
+				tokens = append(tokens, token{Text: text[:idx], Start: match[4], End: match[4] + idx})
+			}
+			break
+		}
+		tokens = append(tokens, token{Text: text, Start: match[0], End: match[1]})
+	}
+	return tokens
+}
+
+// tokenAt returns the index of the token under character, or -1
+func tokenAt(tokens []token, character int) int {
+	for i, tok := range tokens {
+		if character >= tok.Start && character <= tok.End {
+			return i
+		}
+	}
+	return -1
+}
+
+func isIndented(line string) bool {
+	return len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// enclosingDefinition returns the definition a line belongs to and the line its header is on
+func enclosingDefinition(lines []string, line int, defs map[string]*def.Definition) (*def.Definition, int) {
+	for i := min(line, len(lines)-1); i >= 0; i-- {
+		if isIndented(lines[i]) {
+			continue
+		}
+		tokens := lineTokens(lines[i])
+		if len(tokens) == 0 {
+			continue
+		}
+		yamlDef, ok := defs[strings.ToUpper(tokens[0].Text)]
+		if ok {
+			return yamlDef, i
+		}
+		return nil, -1
+	}
+	return nil, -1
+}
+
+// findProperty searches a definition's properties and their children for name
+func findProperty(props []def.Property, name string) *def.Property {
+	for i := range props {
+		prop := &props[i]
+		if strings.EqualFold(strings.TrimSuffix(prop.Name, "?"), strings.TrimSuffix(name, "?")) {
+			return prop
+		}
+		child := findProperty(prop.Properties, name)
+		if child != nil {
+			return child
+		}
+	}
+	return nil
+}
+
+// flattenProperties returns every property of a definition, children included
+func flattenProperties(props []def.Property) []def.Property {
+	flat := []def.Property{}
+	for _, prop := range props {
+		flat = append(flat, prop)
+		flat = append(flat, flattenProperties(prop.Properties)...)
+	}
+	return flat
+}
+
+// findFold returns the path of name in dir matched case insensitively, the same way the wce reader opens files
+func findFold(dir string, name string, docs map[string]string) string {
+	path, ok := docFold(docs, filepath.Join(dir, name))
+	if ok {
+		return path
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return ""
+}
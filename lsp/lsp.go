@@ -0,0 +1,408 @@
+// lsp is a language server for .wce files, speaking the language server protocol over a stream
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/quail/wce/def"
+)
+
+// Server answers language server requests for .wce files
+type Server struct {
+	r         *bufio.Reader
+	w         io.Writer // protocol stream, nothing else may be written to it
+	log       io.Writer
+	defs      map[string]*def.Definition
+	docs      map[string]string   // open documents by path
+	published map[string][]string // files with diagnostics published, by project root
+}
+
+// New returns a server reading requests from r and writing responses to w. Failures that have no
+// response, such as a notification that could not be handled, are written to log
+func New(r io.Reader, w io.Writer, log io.Writer) (*Server, error) {
+	defs, err := def.Definitions()
+	if err != nil {
+		return nil, fmt.Errorf("definitions: %w", err)
+	}
+	return &Server{
+		r:         bufio.NewReader(r),
+		w:         w,
+		log:       log,
+		defs:      defs,
+		docs:      make(map[string]string),
+		published: make(map[string][]string),
+	}, nil
+}
+
+// Serve handles requests until the client sends exit or closes the stream
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read: %w", err)
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			if err != nil && err != errMethodNotFound {
+				fmt.Fprintf(s.log, "%s: %s\n", msg.Method, err.Error())
+			}
+			continue
+		}
+		resp := &message{JSONRPC: "2.0", ID: msg.ID, Result: result}
+		if err != nil {
+			resp.Result = nil
+			resp.Error = &responseError{Code: codeInvalidParams, Message: err.Error()}
+			if err == errMethodNotFound {
+				resp.Error.Code = codeMethodNotFound
+			}
+		}
+		err = s.write(resp)
+		if err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+}
+
+var errMethodNotFound = fmt.Errorf("method not found")
+
+// nullResult is sent for requests that have no answer, since a nil result is omitted
+var nullResult = json.RawMessage("null")
+
+func (s *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full document on every change
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"\""},
+				},
+			},
+			"serverInfo": map[string]string{"name": "quail"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		return nullResult, nil
+	case "textDocument/didOpen":
+		params := &didOpenParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		s.docs[path] = params.TextDocument.Text
+		return nil, s.publish(path)
+	case "textDocument/didChange":
+		params := &didChangeParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		for _, change := range params.ContentChanges {
+			s.docs[path] = change.Text
+		}
+		return nil, s.publish(path)
+	case "textDocument/didSave":
+		params := &didCloseParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.publish(uriToPath(params.TextDocument.URI))
+	case "textDocument/didClose":
+		params := &didCloseParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.docs, uriToPath(params.TextDocument.URI))
+		return nil, nil
+	case "textDocument/hover":
+		params := &textDocumentPositionParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		hover := s.hover(uriToPath(params.TextDocument.URI), params.Position)
+		if hover == nil {
+			return nullResult, nil
+		}
+		return hover, nil
+	case "textDocument/completion":
+		params := &textDocumentPositionParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		return s.completion(uriToPath(params.TextDocument.URI), params.Position), nil
+	case "textDocument/definition":
+		params := &textDocumentPositionParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(uriToPath(params.TextDocument.URI), params.Position), nil
+	case "textDocument/references":
+		params := &referenceParams{}
+		err := json.Unmarshal(msg.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		path := uriToPath(params.TextDocument.URI)
+		tag := s.wordAt(path, params.Position)
+		if tag == "" {
+			return []Location{}, nil
+		}
+		return s.project(path).references(tag, params.Context.IncludeDeclaration), nil
+	}
+	if strings.HasPrefix(msg.Method, "$/") {
+		return nil, nil
+	}
+	return nil, errMethodNotFound
+}
+
+// publish sends diagnostics for every file in the project path belongs to
+func (s *Server) publish(path string) error {
+	p := s.project(path)
+	diags := p.diagnostics(s)
+	for _, old := range s.published[p.root] {
+		_, ok := diags[old]
+		if !ok {
+			diags[old] = []Diagnostic{}
+		}
+	}
+
+	paths := []string{}
+	for path := range diags {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	s.published[p.root] = paths
+	for _, path := range paths {
+		params, err := json.Marshal(&publishDiagnosticsParams{URI: pathToURI(path), Diagnostics: diags[path]})
+		if err != nil {
+			return fmt.Errorf("marshal diagnostics: %w", err)
+		}
+		err = s.write(&message{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+		if err != nil {
+			return fmt.Errorf("write diagnostics: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) lines(path string) []string {
+	text, err := s.text(path)
+	if err != nil {
+		return nil
+	}
+	return splitLines(text)
+}
+
+// wordAt returns the token under pos
+func (s *Server) wordAt(path string, pos Position) string {
+	lines := s.lines(path)
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	tokens := lineTokens(lines[pos.Line])
+	idx := tokenAt(tokens, pos.Character)
+	if idx == -1 {
+		return ""
+	}
+	return tokens[idx].Text
+}
+
+func (s *Server) hover(path string, pos Position) *Hover {
+	lines := s.lines(path)
+	if pos.Line >= len(lines) {
+		return nil
+	}
+	line := lines[pos.Line]
+	tokens := lineTokens(line)
+	idx := tokenAt(tokens, pos.Character)
+	if idx == -1 {
+		return nil
+	}
+	tok := tokens[idx]
+	yamlDef, _ := enclosingDefinition(lines, pos.Line, s.defs)
+	if yamlDef == nil {
+		return nil
+	}
+
+	doc := &strings.Builder{}
+	prop := findProperty(yamlDef.Properties, tokens[0].Text)
+	switch {
+	case !isIndented(line) && idx == 0:
+		fmt.Fprintf(doc, "**%s**\n\n", yamlDef.Name)
+		docWrite(doc, yamlDef.Note, yamlDef.Description)
+	case isIndented(line) && idx == 0 && prop != nil:
+		fmt.Fprintf(doc, "**%s** (%s)\n\n", strings.TrimSuffix(prop.Name, "?"), yamlDef.Name)
+		docWrite(doc, prop.Note, prop.Description)
+		for i, arg := range prop.Args {
+			fmt.Fprintf(doc, "%d. `%s` %s: %s\n", i+1, arg.Format, arg.Name, arg.Note)
+		}
+	case isIndented(line) && prop != nil && idx-1 < len(prop.Args):
+		arg := prop.Args[idx-1]
+		fmt.Fprintf(doc, "**%s** argument %d `%s` %s\n\n", strings.TrimSuffix(prop.Name, "?"), idx, arg.Format, arg.Name)
+		docWrite(doc, arg.Note, arg.Description)
+	}
+
+	for _, tagDef := range s.project(path).tags[tok.Text] {
+		fmt.Fprintf(doc, "\n`%s` is a %s at %s:%d\n", tagDef.Tag, tagDef.Definition, filepath.Base(tagDef.Path), tagDef.Line+1)
+	}
+	if doc.Len() == 0 {
+		return nil
+	}
+	return &Hover{
+		Contents: markupContent{Kind: "markdown", Value: doc.String()},
+		Range: &Range{
+			Start: Position{Line: pos.Line, Character: tok.Start},
+			End:   Position{Line: pos.Line, Character: tok.End},
+		},
+	}
+}
+
+func docWrite(doc *strings.Builder, note string, description string) {
+	if note != "" {
+		doc.WriteString(note + "\n\n")
+	}
+	if description != "" {
+		doc.WriteString(description + "\n\n")
+	}
+}
+
+func (s *Server) completion(path string, pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	lines := s.lines(path)
+	if pos.Line >= len(lines) {
+		return items
+	}
+	line := lines[pos.Line]
+	prefix := line[:min(pos.Character, len(line))]
+	isFirst := len(lineTokens(prefix)) == 0 || (len(lineTokens(prefix)) == 1 && !strings.HasSuffix(prefix, " ") && !strings.HasSuffix(prefix, "\t"))
+
+	if isFirst && !isIndented(line) {
+		for name, yamlDef := range s.defs {
+			items = append(items, CompletionItem{Label: name, Kind: completionKindClass, Detail: yamlDef.Note})
+		}
+		items = append(items, CompletionItem{Label: "INCLUDE", Kind: completionKindKeyword, Detail: "Read definitions from another file"})
+		sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+		return items
+	}
+	if isFirst {
+		yamlDef, _ := enclosingDefinition(lines, pos.Line, s.defs)
+		if yamlDef == nil {
+			return items
+		}
+		isAdded := make(map[string]bool)
+		for _, prop := range flattenProperties(yamlDef.Properties) {
+			name := strings.TrimSuffix(prop.Name, "?")
+			if isAdded[name] {
+				continue
+			}
+			isAdded[name] = true
+			items = append(items, CompletionItem{Label: name, Kind: completionKindProperty, Detail: prop.Note})
+		}
+		return items
+	}
+
+	p := s.project(path)
+	for _, tag := range p.sortedTags() {
+		items = append(items, CompletionItem{Label: tag, Kind: completionKindReference, Detail: p.tags[tag][0].Definition})
+	}
+	return items
+}
+
+func (s *Server) definition(path string, pos Position) []Location {
+	locs := []Location{}
+	tag := s.wordAt(path, pos)
+	if tag == "" {
+		return locs
+	}
+	for _, tagDef := range s.project(path).tags[tag] {
+		locs = append(locs, Location{URI: pathToURI(tagDef.Path), Range: Range{
+			Start: Position{Line: tagDef.Line, Character: tagDef.Start},
+			End:   Position{Line: tagDef.Line, Character: tagDef.End},
+		}})
+	}
+	return locs
+}
+
+// read reads a single Content-Length framed message
+func (s *Server) read() (*message, error) {
+	length := -1
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		length, err = strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("content length: %w", err)
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing content length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(s.r, data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	err = json.Unmarshal(data, msg)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Server) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return filepath.Clean(uri)
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+func pathToURI(path string) string {
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
@@ -0,0 +1,213 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	root := `// wcemu v0.0.1
+INCLUDE "sub.wce"
+
+BLITSPRITEDEF "FIRE_SPB"
+	SPRITE "FIRE_SPRITE"
+	RENDERMETHOD "TRANSPARENT"
+	TRANSPARENT 1
+
+BLITSPRITEDEF "SMOKE_SPB"
+	SPRITE "TEST_MP"
+	RENDERMETHOD "TRANSPARENT"
+	TRANSPARENT 1
+`
+	sub := `MATERIALPALETTE "TEST_MP"
+	NUMMATERIALS 0
+`
+	rootPath := filepath.Join(dir, "_root.wce")
+	subPath := filepath.Join(dir, "sub.wce")
+	err := os.WriteFile(rootPath, []byte(root), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	err = os.WriteFile(subPath, []byte(sub), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	in := &bytes.Buffer{}
+	send := func(id int, method string, params interface{}) {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+		if id > 0 {
+			msg["id"] = id
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("marshal: %s", err)
+		}
+		fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	doc := map[string]string{"uri": pathToURI(rootPath)}
+	at := func(line int, character int) map[string]interface{} {
+		return map[string]interface{}{"textDocument": doc, "position": Position{Line: line, Character: character}}
+	}
+	send(1, "initialize", map[string]interface{}{})
+	send(0, "textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": doc["uri"], "text": root, "version": 1}})
+	send(2, "textDocument/hover", at(4, 2))
+	send(3, "textDocument/definition", at(9, 10))
+	refs := at(9, 10)
+	refs["context"] = map[string]bool{"includeDeclaration": true}
+	send(4, "textDocument/references", refs)
+	send(5, "textDocument/completion", at(5, 1))
+	send(6, "shutdown", nil)
+	send(0, "exit", nil)
+
+	out := &bytes.Buffer{}
+	s, err := New(in, out, io.Discard)
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+	err = s.Serve()
+	if err != nil {
+		t.Fatalf("serve: %s", err)
+	}
+
+	results := make(map[int]json.RawMessage)
+	diags := make(map[string][]Diagnostic)
+	r := &Server{r: s.r}
+	r.r.Reset(out)
+	for {
+		msg, err := r.read()
+		if err != nil {
+			break
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			params := &publishDiagnosticsParams{}
+			err = json.Unmarshal(msg.Params, params)
+			if err != nil {
+				t.Fatalf("diagnostics: %s", err)
+			}
+			diags[uriToPath(params.URI)] = params.Diagnostics
+			continue
+		}
+		id := 0
+		err = json.Unmarshal(*msg.ID, &id)
+		if err != nil {
+			t.Fatalf("id: %s", err)
+		}
+		data, err := json.Marshal(msg.Result)
+		if err != nil {
+			t.Fatalf("result: %s", err)
+		}
+		results[id] = data
+	}
+
+	rootDiags := diags[rootPath]
+	if len(rootDiags) != 1 || rootDiags[0].Range.Start.Line != 3 || !strings.Contains(rootDiags[0].Message, "FIRE_SPRITE") {
+		t.Fatalf("diagnostics: got %+v, want FIRE_SPRITE not found on line 3", rootDiags)
+	}
+	_, ok := diags[subPath]
+	if !ok {
+		t.Fatalf("diagnostics: sub.wce was not published")
+	}
+
+	hover := &Hover{}
+	err = json.Unmarshal(results[2], hover)
+	if err != nil || !strings.Contains(hover.Contents.Value, "Sprite tag") {
+		t.Fatalf("hover: got %s", results[2])
+	}
+
+	locs := []Location{}
+	err = json.Unmarshal(results[3], &locs)
+	if err != nil || len(locs) != 1 || uriToPath(locs[0].URI) != subPath || locs[0].Range.Start.Line != 0 {
+		t.Fatalf("definition: got %s", results[3])
+	}
+
+	err = json.Unmarshal(results[4], &locs)
+	if err != nil || len(locs) != 2 {
+		t.Fatalf("references: got %s", results[4])
+	}
+
+	items := []CompletionItem{}
+	err = json.Unmarshal(results[5], &items)
+	if err != nil {
+		t.Fatalf("completion: %s", err)
+	}
+	isFound := false
+	for _, item := range items {
+		if item.Label == "RENDERMETHOD" {
+			isFound = true
+		}
+	}
+	if !isFound {
+		t.Fatalf("completion: got %s, want RENDERMETHOD", results[5])
+	}
+}
+
+func TestServeUnsavedInclude(t *testing.T) {
+	dir := t.TempDir()
+	root := `// wcemu v0.0.1
+INCLUDE "NEW.WCE"
+
+BLITSPRITEDEF "SMOKE_SPB"
+	SPRITE "TEST_MP"
+	RENDERMETHOD "TRANSPARENT"
+	TRANSPARENT 1
+`
+	sub := `MATERIALPALETTE "TEST_MP"
+	NUMMATERIALS 0
+`
+	rootPath := filepath.Join(dir, "_root.wce")
+	err := os.WriteFile(rootPath, []byte(root), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	in := &bytes.Buffer{}
+	send := func(method string, params interface{}) {
+		data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+		if err != nil {
+			t.Fatalf("marshal: %s", err)
+		}
+		fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	// new.wce only exists as an open buffer, and is included with different casing
+	send("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": pathToURI(filepath.Join(dir, "new.wce")), "text": sub, "version": 1}})
+	send("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": pathToURI(rootPath), "text": root, "version": 1}})
+	send("exit", nil)
+
+	out := &bytes.Buffer{}
+	s, err := New(in, out, io.Discard)
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+	err = s.Serve()
+	if err != nil {
+		t.Fatalf("serve: %s", err)
+	}
+
+	diags := make(map[string][]Diagnostic)
+	r := &Server{r: s.r}
+	r.r.Reset(out)
+	for {
+		msg, err := r.read()
+		if err != nil {
+			break
+		}
+		params := &publishDiagnosticsParams{}
+		err = json.Unmarshal(msg.Params, params)
+		if err != nil {
+			t.Fatalf("diagnostics: %s", err)
+		}
+		diags[uriToPath(params.URI)] = params.Diagnostics
+	}
+	rootDiags, ok := diags[rootPath]
+	if !ok || len(rootDiags) != 0 {
+		t.Fatalf("diagnostics: got %+v, want none", rootDiags)
+	}
+}
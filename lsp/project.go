@@ -0,0 +1,312 @@
+package lsp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xackery/quail/qfs"
+	"github.com/xackery/quail/wce"
+)
+
+// project is a root .wce file and every file it includes
+type project struct {
+	root  string
+	files []string
+	lines map[string][]string
+	tags  map[string][]*tagDef
+}
+
+// tagDef is where a tag is declared by a top-level definition
+type tagDef struct {
+	Tag        string
+	Definition string
+	Path       string
+	Line       int
+	Start      int
+	End        int
+}
+
+// project returns the project a document belongs to. The root is the topmost _root.wce above path,
+// stopping at a .quail folder, that includes path. A file outside of any project is its own root
+func (s *Server) project(path string) *project {
+	candidates := []string{}
+	dir := filepath.Dir(path)
+	for {
+		root := findFold(dir, "_root.wce", s.docs)
+		if root != "" {
+			candidates = append(candidates, root)
+		}
+		if strings.HasSuffix(strings.ToLower(dir), ".quail") {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		p := s.projectLoad(candidates[i])
+		for _, file := range p.files {
+			if file == path {
+				return p
+			}
+		}
+	}
+	return s.projectLoad(path)
+}
+
+// projectLoad indexes root and its includes
+func (s *Server) projectLoad(root string) *project {
+	p := &project{
+		root:  root,
+		lines: make(map[string][]string),
+		tags:  make(map[string][]*tagDef),
+	}
+	p.load(s, root)
+	return p
+}
+
+func (p *project) load(s *Server, path string) {
+	_, ok := p.lines[path]
+	if ok {
+		return
+	}
+	text, err := s.text(path)
+	if err != nil {
+		return
+	}
+	lines := splitLines(text)
+	p.lines[path] = lines
+	p.files = append(p.files, path)
+
+	for i, line := range lines {
+		if isIndented(line) {
+			continue
+		}
+		tokens := lineTokens(line)
+		if len(tokens) < 2 {
+			continue
+		}
+		name := strings.ToUpper(tokens[0].Text)
+		if strings.HasPrefix(name, "INCLUDE") {
+			dir, base := filepath.Split(filepath.Join(filepath.Dir(path), tokens[1].Text))
+			include := findFold(dir, base, s.docs)
+			if include != "" {
+				p.load(s, include)
+			}
+			continue
+		}
+		_, ok := s.defs[name]
+		if !ok {
+			continue
+		}
+		tag := tokens[1]
+		p.tags[tag.Text] = append(p.tags[tag.Text], &tagDef{
+			Tag:        tag.Text,
+			Definition: name,
+			Path:       path,
+			Line:       i,
+			Start:      tag.Start,
+			End:        tag.End,
+		})
+	}
+}
+
+// references returns every use of tag in the project, optionally including where it is declared
+func (p *project) references(tag string, isDeclaration bool) []Location {
+	locs := []Location{}
+	for _, path := range p.files {
+		for i, line := range p.lines[path] {
+			tokens := lineTokens(line)
+			for j, tok := range tokens {
+				if tok.Text != tag {
+					continue
+				}
+				if j == 1 && !isIndented(line) && !isDeclaration {
+					continue
+				}
+				locs = append(locs, Location{URI: pathToURI(path), Range: Range{
+					Start: Position{Line: i, Character: tok.Start},
+					End:   Position{Line: i, Character: tok.End},
+				}})
+			}
+		}
+	}
+	return locs
+}
+
+// sortedTags returns every declared tag in the project
+func (p *project) sortedTags() []string {
+	tags := make([]string, 0, len(p.tags))
+	for tag := range p.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// diagnostics reads the project with the wce reader and validator, returning problems for every file
+func (p *project) diagnostics(s *Server) map[string][]Diagnostic {
+	diags := make(map[string][]Diagnostic)
+	for _, path := range p.files {
+		diags[path] = []Diagnostic{}
+	}
+	add := func(pos wce.Position, severity int, msg string) {
+		path := s.resolve(pos.File)
+		line := max(pos.Line-1, 0)
+		lines := p.lines[path]
+		end := 0
+		if line < len(lines) {
+			end = len(lines[line])
+		}
+		diags[path] = append(diags[path], Diagnostic{
+			Range: Range{
+				Start: Position{Line: line, Character: max(pos.Col-1, 0)},
+				End:   Position{Line: line, Character: end},
+			},
+			Severity: severity,
+			Source:   "quail",
+			Message:  msg,
+		})
+	}
+
+	w := wce.New(p.root)
+	w.FileSystem = &overlayFS{docs: s.docs}
+	err := w.ReadAscii(p.root)
+	if err != nil {
+		var parseErrs wce.ParseErrors
+		if errors.As(err, &parseErrs) {
+			for _, parseErr := range parseErrs {
+				add(parseErr.Position, SeverityError, parseErr.Err.Error())
+			}
+		} else {
+			add(wce.Position{File: p.root, Line: 1}, SeverityError, err.Error())
+		}
+	}
+	for _, finding := range w.Validate() {
+		if finding.Position.File == "" {
+			continue
+		}
+		severity := SeverityError
+		if finding.Severity == wce.SeverityWarning {
+			severity = SeverityWarning
+		}
+		msg := finding.Message
+		if finding.Tag != "" {
+			msg = finding.Tag + ": " + msg
+		}
+		add(finding.Position, severity, msg)
+	}
+	return diags
+}
+
+// resolve returns the path of a file as the wce reader named it, with the casing it has on disk
+func (s *Server) resolve(path string) string {
+	path = filepath.Clean(path)
+	key, ok := docFold(s.docs, path)
+	if ok {
+		return key
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return path
+	}
+	resolved := findFold(filepath.Dir(path), filepath.Base(path), s.docs)
+	if resolved == "" {
+		return path
+	}
+	return resolved
+}
+
+// text returns the open document at path, or the file on disk
+func (s *Server) text(path string) (string, error) {
+	text, ok := s.docs[path]
+	if ok {
+		return text, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// overlayFS reads open documents from memory and everything else from disk
+type overlayFS struct {
+	qfs.OSFS
+	docs map[string]string
+}
+
+func (o *overlayFS) ReadFile(name string) ([]byte, error) {
+	path, ok := docFold(o.docs, name)
+	if ok {
+		return []byte(o.docs[path]), nil
+	}
+	return o.OSFS.ReadFile(name)
+}
+
+// ReadDir lists a directory on disk with the open documents in it, so unsaved files can be included
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir := filepath.Clean(name)
+	entries, err := o.OSFS.ReadDir(name)
+	isFound := err == nil
+	for path, text := range o.docs {
+		if !strings.EqualFold(filepath.Dir(path), dir) {
+			continue
+		}
+		isFound = true
+		base := filepath.Base(path)
+		isListed := false
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), base) {
+				isListed = true
+				break
+			}
+		}
+		if isListed {
+			continue
+		}
+		entries = append(entries, docEntry{name: base, size: int64(len(text))})
+	}
+	if !isFound {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// docEntry is an open document listed by overlayFS.ReadDir
+type docEntry struct {
+	name string
+	size int64
+}
+
+func (e docEntry) Name() string               { return e.name }
+func (e docEntry) IsDir() bool                { return false }
+func (e docEntry) Type() fs.FileMode          { return 0 }
+func (e docEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e docEntry) Size() int64                { return e.size }
+func (e docEntry) Mode() fs.FileMode          { return 0644 }
+func (e docEntry) ModTime() time.Time         { return time.Time{} }
+func (e docEntry) Sys() interface{}           { return nil }
+
+// docFold returns the key of the open document at path, matched case insensitively
+func docFold(docs map[string]string, path string) (string, bool) {
+	path = filepath.Clean(path)
+	_, ok := docs[path]
+	if ok {
+		return path, true
+	}
+	for key := range docs {
+		if strings.EqualFold(key, path) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"runtime/debug"
@@ -31,7 +32,8 @@ func main() {
 		Version = "dev-" + time.Now().Format("20060102")
 	}
 
-	fmt.Printf("Quail version %s, Go version: %s\n", Version, info.GoVersion)
+	// stderr, so stdout only holds command output, such as the protocol the lsp command speaks
+	fmt.Fprintf(os.Stderr, "Quail version %s, Go version: %s\n", Version, info.GoVersion)
 	helper.Version = Version
 	cmd.Execute()
 }
@@ -0,0 +1,239 @@
+package navmesh
+
+const (
+	notConnected = 0x3f
+	borderReg    = 0x8000
+)
+
+type compactCell struct {
+	index uint32
+	count uint32
+}
+
+type compactSpan struct {
+	y   uint16
+	reg uint16
+	con uint32 // 6 bits per direction
+	h   uint8
+}
+
+func (s *compactSpan) setCon(dir int, i int) {
+	shift := uint(dir * 6)
+	s.con = (s.con &^ (0x3f << shift)) | (uint32(i)&0x3f)<<shift
+}
+
+func (s *compactSpan) getCon(dir int) int {
+	return int((s.con >> uint(dir*6)) & 0x3f)
+}
+
+// compactHeightfield holds the open space above each walkable span with links to neighbor spans
+type compactHeightfield struct {
+	width          int
+	height         int
+	walkableHeight int
+	walkableClimb  int
+	borderSize     int
+	bmin           [3]float32
+	bmax           [3]float32
+	cs             float32
+	ch             float32
+	cells          []compactCell
+	spans          []compactSpan
+	areas          []uint8
+}
+
+func newCompactHeightfield(hf *heightfield, walkableHeight, walkableClimb int) *compactHeightfield {
+	chf := &compactHeightfield{
+		width:          hf.width,
+		height:         hf.height,
+		walkableHeight: walkableHeight,
+		walkableClimb:  walkableClimb,
+		bmin:           hf.bmin,
+		bmax:           hf.bmax,
+		cs:             hf.cs,
+		ch:             hf.ch,
+		cells:          make([]compactCell, hf.width*hf.height),
+	}
+	chf.bmax[1] += float32(walkableHeight) * hf.ch
+
+	for c, first := range hf.columns {
+		cell := &chf.cells[c]
+		cell.index = uint32(len(chf.spans))
+		for i := first; i != -1; i = hf.pool[i].next {
+			s := &hf.pool[i]
+			if s.area == nullArea {
+				continue
+			}
+			bot := int(s.smax)
+			top := spanMaxHeight
+			if s.next != -1 {
+				top = int(hf.pool[s.next].smin)
+			}
+			chf.spans = append(chf.spans, compactSpan{
+				y: uint16(clamp(bot, 0, 0xffff)),
+				h: uint8(clamp(top-bot, 0, 0xff)),
+			})
+			chf.areas = append(chf.areas, s.area)
+			cell.count++
+		}
+	}
+
+	for z := 0; z < chf.height; z++ {
+		for x := 0; x < chf.width; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := cell.index; i < cell.index+cell.count; i++ {
+				s := &chf.spans[i]
+				for dir := 0; dir < 4; dir++ {
+					s.setCon(dir, notConnected)
+					nx := x + dirOffsetX(dir)
+					nz := z + dirOffsetY(dir)
+					if nx < 0 || nz < 0 || nx >= chf.width || nz >= chf.height {
+						continue
+					}
+					ncell := chf.cells[nx+nz*chf.width]
+					for k := ncell.index; k < ncell.index+ncell.count; k++ {
+						ns := &chf.spans[k]
+						bot := max(int(s.y), int(ns.y))
+						top := min(int(s.y)+int(s.h), int(ns.y)+int(ns.h))
+						if top-bot < walkableHeight || abs(int(ns.y)-int(s.y)) > walkableClimb {
+							continue
+						}
+						layer := int(k - ncell.index)
+						if layer >= notConnected {
+							continue
+						}
+						s.setCon(dir, layer)
+						break
+					}
+				}
+			}
+		}
+	}
+	return chf
+}
+
+// neighbor returns the span index connected to span i in a direction, or -1
+func (chf *compactHeightfield) neighbor(x, z int, i int, dir int) int {
+	con := chf.spans[i].getCon(dir)
+	if con == notConnected {
+		return -1
+	}
+	nx := x + dirOffsetX(dir)
+	nz := z + dirOffsetY(dir)
+	return int(chf.cells[nx+nz*chf.width].index) + con
+}
+
+// erodeWalkableArea removes walkable spans closer than radius cells to a wall or ledge
+func (chf *compactHeightfield) erodeWalkableArea(radius int) {
+	dist := make([]uint8, len(chf.spans))
+	for i := range dist {
+		dist[i] = 0xff
+	}
+
+	for z := 0; z < chf.height; z++ {
+		for x := 0; x < chf.width; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if chf.areas[i] == nullArea {
+					dist[i] = 0
+					continue
+				}
+				neighbors := 0
+				for dir := 0; dir < 4; dir++ {
+					ni := chf.neighbor(x, z, i, dir)
+					if ni != -1 && chf.areas[ni] != nullArea {
+						neighbors++
+					}
+				}
+				if neighbors != 4 {
+					dist[i] = 0
+				}
+			}
+		}
+	}
+
+	relax := func(i int, ni int, cost int) {
+		nd := min(int(dist[ni])+cost, 255)
+		if nd < int(dist[i]) {
+			dist[i] = uint8(nd)
+		}
+	}
+
+	for z := 0; z < chf.height; z++ {
+		for x := 0; x < chf.width; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				// (-1,0) and (-1,-1)
+				ai := chf.neighbor(x, z, i, 0)
+				if ai != -1 {
+					relax(i, ai, 2)
+					aai := chf.neighbor(x-1, z, ai, 3)
+					if aai != -1 {
+						relax(i, aai, 3)
+					}
+				}
+				// (0,-1) and (1,-1)
+				ai = chf.neighbor(x, z, i, 3)
+				if ai != -1 {
+					relax(i, ai, 2)
+					aai := chf.neighbor(x, z-1, ai, 2)
+					if aai != -1 {
+						relax(i, aai, 3)
+					}
+				}
+			}
+		}
+	}
+
+	for z := chf.height - 1; z >= 0; z-- {
+		for x := chf.width - 1; x >= 0; x-- {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				// (1,0) and (1,1)
+				ai := chf.neighbor(x, z, i, 2)
+				if ai != -1 {
+					relax(i, ai, 2)
+					aai := chf.neighbor(x+1, z, ai, 1)
+					if aai != -1 {
+						relax(i, aai, 3)
+					}
+				}
+				// (0,1) and (-1,1)
+				ai = chf.neighbor(x, z, i, 1)
+				if ai != -1 {
+					relax(i, ai, 2)
+					aai := chf.neighbor(x, z+1, ai, 0)
+					if aai != -1 {
+						relax(i, aai, 3)
+					}
+				}
+			}
+		}
+	}
+
+	thr := radius * 2
+	for i := range chf.spans {
+		if int(dist[i]) < thr {
+			chf.areas[i] = nullArea
+		}
+	}
+}
+
+// markAreas sets each walkable span's area from the region type one cell above its floor
+func (chf *compactHeightfield) markAreas(areaFn AreaFunc) {
+	for z := 0; z < chf.height; z++ {
+		for x := 0; x < chf.width; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if chf.areas[i] == nullArea {
+					continue
+				}
+				px := chf.bmin[0] + (float32(x)+0.5)*chf.cs
+				py := chf.bmin[1] + float32(chf.spans[i].y+1)*chf.ch
+				pz := chf.bmin[2] + (float32(z)+0.5)*chf.cs
+				// detour y up back to server z up
+				chf.areas[i] = walkableArea(areaFromRegionType(areaFn(px, pz, py)))
+			}
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package navmesh
+
+const (
+	contourRegMask = 0xffff
+	borderVertex   = 0x10000
+	areaBorder     = 0x20000
+)
+
+// contour is a region outline, verts are x, y, z, flags in cells
+type contour struct {
+	verts []int
+	reg   uint16
+	area  uint8
+}
+
+type contourSet struct {
+	contours []*contour
+	bmin     [3]float32
+	bmax     [3]float32
+	cs       float32
+	ch       float32
+	width    int
+	height   int
+}
+
+// buildContours traces and simplifies the outline of every region, shifted so the tile border is at 0
+func buildContours(chf *compactHeightfield, maxError float32, maxEdgeLen int) *contourSet {
+	w := chf.width
+	h := chf.height
+	pad := float32(chf.borderSize) * chf.cs
+	cset := &contourSet{
+		bmin:   chf.bmin,
+		bmax:   chf.bmax,
+		cs:     chf.cs,
+		ch:     chf.ch,
+		width:  w - chf.borderSize*2,
+		height: h - chf.borderSize*2,
+	}
+	cset.bmin[0] += pad
+	cset.bmin[2] += pad
+	cset.bmax[0] -= pad
+	cset.bmax[2] -= pad
+
+	// mark edges of each span that border another region
+	flags := make([]uint8, len(chf.spans))
+	for z := 0; z < h; z++ {
+		for x := 0; x < w; x++ {
+			cell := chf.cells[x+z*w]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				reg := chf.spans[i].reg
+				if reg == 0 || reg&borderReg != 0 {
+					continue
+				}
+				res := uint8(0)
+				for dir := 0; dir < 4; dir++ {
+					ni := chf.neighbor(x, z, i, dir)
+					if ni != -1 && chf.spans[ni].reg == reg {
+						res |= 1 << dir
+					}
+				}
+				flags[i] = res ^ 0xf
+			}
+		}
+	}
+
+	for z := 0; z < h; z++ {
+		for x := 0; x < w; x++ {
+			cell := chf.cells[x+z*w]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if flags[i] == 0 || flags[i] == 0xf {
+					flags[i] = 0
+					continue
+				}
+				reg := chf.spans[i].reg
+				if reg == 0 || reg&borderReg != 0 {
+					continue
+				}
+
+				verts := chf.walkContour(x, z, i, flags)
+				simplified := simplifyContour(verts, maxError, maxEdgeLen)
+				simplified = removeDegenerateSegments(simplified)
+				if len(simplified)/4 < 3 {
+					continue
+				}
+				if chf.borderSize > 0 {
+					for j := 0; j < len(simplified); j += 4 {
+						simplified[j] -= chf.borderSize
+						simplified[j+2] -= chf.borderSize
+					}
+				}
+				cset.contours = append(cset.contours, &contour{
+					verts: simplified,
+					reg:   reg,
+					area:  chf.areas[i],
+				})
+			}
+		}
+	}
+	return cset
+}
+
+// walkContour follows a region's boundary clockwise, emitting a vertex at each boundary edge corner
+func (chf *compactHeightfield) walkContour(x, z, i int, flags []uint8) []int {
+	dir := 0
+	for flags[i]&(1<<dir) == 0 {
+		dir++
+	}
+	startDir := dir
+	startI := i
+	area := chf.areas[i]
+
+	points := []int{}
+	for iter := 0; iter < 40000; iter++ {
+		if flags[i]&(1<<dir) != 0 {
+			px := x
+			py, isBorderVertex := chf.cornerHeight(x, z, i, dir)
+			pz := z
+			switch dir {
+			case 0:
+				pz++
+			case 1:
+				px++
+				pz++
+			case 2:
+				px++
+			}
+			r := 0
+			isAreaBorder := false
+			ai := chf.neighbor(x, z, i, dir)
+			if ai != -1 {
+				r = int(chf.spans[ai].reg)
+				if area != chf.areas[ai] {
+					isAreaBorder = true
+				}
+			}
+			if isBorderVertex {
+				r |= borderVertex
+			}
+			if isAreaBorder {
+				r |= areaBorder
+			}
+			points = append(points, px, py, pz, r)
+			flags[i] &^= 1 << dir
+			dir = (dir + 1) & 3
+		} else {
+			ni := chf.neighbor(x, z, i, dir)
+			if ni == -1 {
+				return points
+			}
+			x += dirOffsetX(dir)
+			z += dirOffsetY(dir)
+			i = ni
+			dir = (dir + 3) & 3
+		}
+		if startI == i && startDir == dir {
+			break
+		}
+	}
+	return points
+}
+
+// cornerHeight returns the highest floor around a corner and whether the corner only splits two border cells
+func (chf *compactHeightfield) cornerHeight(x, z, i, dir int) (int, bool) {
+	s := chf.spans[i]
+	ch := int(s.y)
+	dirp := (dir + 1) & 3
+	regs := [4]uint32{}
+	regs[0] = uint32(s.reg) | uint32(chf.areas[i])<<16
+
+	ai := chf.neighbor(x, z, i, dir)
+	if ai != -1 {
+		ax := x + dirOffsetX(dir)
+		az := z + dirOffsetY(dir)
+		ch = max(ch, int(chf.spans[ai].y))
+		regs[1] = uint32(chf.spans[ai].reg) | uint32(chf.areas[ai])<<16
+		ai2 := chf.neighbor(ax, az, ai, dirp)
+		if ai2 != -1 {
+			ch = max(ch, int(chf.spans[ai2].y))
+			regs[2] = uint32(chf.spans[ai2].reg) | uint32(chf.areas[ai2])<<16
+		}
+	}
+	ai = chf.neighbor(x, z, i, dirp)
+	if ai != -1 {
+		ax := x + dirOffsetX(dirp)
+		az := z + dirOffsetY(dirp)
+		ch = max(ch, int(chf.spans[ai].y))
+		regs[3] = uint32(chf.spans[ai].reg) | uint32(chf.areas[ai])<<16
+		ai2 := chf.neighbor(ax, az, ai, dir)
+		if ai2 != -1 {
+			ch = max(ch, int(chf.spans[ai2].y))
+			regs[2] = uint32(chf.spans[ai2].reg) | uint32(chf.areas[ai2])<<16
+		}
+	}
+
+	for j := 0; j < 4; j++ {
+		a := j
+		b := (j + 1) & 3
+		c := (j + 2) & 3
+		d := (j + 3) & 3
+		twoSameExts := regs[a]&regs[b]&borderReg != 0 && regs[a] == regs[b]
+		twoInts := (regs[c]|regs[d])&borderReg == 0
+		intsSameArea := regs[c]>>16 == regs[d]>>16
+		noZeros := regs[a] != 0 && regs[b] != 0 && regs[c] != 0 && regs[d] != 0
+		if twoSameExts && twoInts && intsSameArea && noZeros {
+			return ch, true
+		}
+	}
+	return ch, false
+}
+
+// simplifyContour keeps vertices where the neighbor region changes, then adds raw vertices until
+// the outline is within maxError and wall edges are shorter than maxEdgeLen
+func simplifyContour(points []int, maxError float32, maxEdgeLen int) []int {
+	pn := len(points) / 4
+	simplified := []int{}
+
+	hasConnections := false
+	for i := 0; i < len(points); i += 4 {
+		if points[i+3]&contourRegMask != 0 {
+			hasConnections = true
+			break
+		}
+	}
+
+	if hasConnections {
+		for i := 0; i < pn; i++ {
+			ii := (i + 1) % pn
+			differentRegs := points[i*4+3]&contourRegMask != points[ii*4+3]&contourRegMask
+			areaBorders := points[i*4+3]&areaBorder != points[ii*4+3]&areaBorder
+			if differentRegs || areaBorders {
+				simplified = append(simplified, points[i*4], points[i*4+1], points[i*4+2], i)
+			}
+		}
+	}
+
+	if len(simplified) == 0 {
+		// no connections, start from the lower left and upper right corners
+		llx, lly, llz, lli := points[0], points[1], points[2], 0
+		urx, ury, urz, uri := points[0], points[1], points[2], 0
+		for i := 0; i < len(points); i += 4 {
+			x, y, z := points[i], points[i+1], points[i+2]
+			if x < llx || (x == llx && z < llz) {
+				llx, lly, llz, lli = x, y, z, i/4
+			}
+			if x > urx || (x == urx && z > urz) {
+				urx, ury, urz, uri = x, y, z, i/4
+			}
+		}
+		simplified = append(simplified, llx, lly, llz, lli, urx, ury, urz, uri)
+	}
+
+	insert := func(after int, pi int) {
+		vert := []int{points[pi*4], points[pi*4+1], points[pi*4+2], pi}
+		pos := (after + 1) * 4
+		simplified = append(simplified[:pos], append(vert, simplified[pos:]...)...)
+	}
+
+	for i := 0; i < len(simplified)/4; {
+		ii := (i + 1) % (len(simplified) / 4)
+		ax, az, ai := simplified[i*4], simplified[i*4+2], simplified[i*4+3]
+		bx, bz, bi := simplified[ii*4], simplified[ii*4+2], simplified[ii*4+3]
+
+		maxd := float32(0)
+		maxi := -1
+		var ci, cinc, endi int
+		// traverse in lexilogical order so opposite segments simplify the same way
+		if bx > ax || (bx == ax && bz > az) {
+			cinc = 1
+			ci = (ai + cinc) % pn
+			endi = bi
+		} else {
+			cinc = pn - 1
+			ci = (bi + cinc) % pn
+			endi = ai
+			ax, bx = bx, ax
+			az, bz = bz, az
+		}
+
+		// only tessellate outer edges or edges between areas
+		if points[ci*4+3]&contourRegMask == 0 || points[ci*4+3]&areaBorder != 0 {
+			for ci != endi {
+				d := distancePtSeg(points[ci*4], points[ci*4+2], ax, az, bx, bz)
+				if d > maxd {
+					maxd = d
+					maxi = ci
+				}
+				ci = (ci + cinc) % pn
+			}
+		}
+
+		if maxi != -1 && maxd > maxError*maxError {
+			insert(i, maxi)
+			continue
+		}
+		i++
+	}
+
+	if maxEdgeLen > 0 {
+		for i := 0; i < len(simplified)/4; {
+			ii := (i + 1) % (len(simplified) / 4)
+			ax, az, ai := simplified[i*4], simplified[i*4+2], simplified[i*4+3]
+			bx, bz, bi := simplified[ii*4], simplified[ii*4+2], simplified[ii*4+3]
+
+			maxi := -1
+			ci := (ai + 1) % pn
+			// only split wall edges
+			if points[ci*4+3]&contourRegMask == 0 {
+				dx := bx - ax
+				dz := bz - az
+				if dx*dx+dz*dz > maxEdgeLen*maxEdgeLen {
+					n := bi - ai
+					if bi < ai {
+						n = bi + pn - ai
+					}
+					if n > 1 {
+						if bx > ax || (bx == ax && bz > az) {
+							maxi = (ai + n/2) % pn
+						} else {
+							maxi = (ai + (n+1)/2) % pn
+						}
+					}
+				}
+			}
+
+			if maxi != -1 {
+				insert(i, maxi)
+				continue
+			}
+			i++
+		}
+	}
+
+	for i := 0; i < len(simplified)/4; i++ {
+		// the neighbor region comes from the next raw point, the border vertex flag from the current one
+		ai := (simplified[i*4+3] + 1) % pn
+		bi := simplified[i*4+3]
+		simplified[i*4+3] = points[ai*4+3]&(contourRegMask|areaBorder) | points[bi*4+3]&borderVertex
+	}
+	return simplified
+}
+
+func distancePtSeg(x, z, px, pz, qx, qz int) float32 {
+	pqx := float32(qx - px)
+	pqz := float32(qz - pz)
+	dx := float32(x - px)
+	dz := float32(z - pz)
+	d := pqx*pqx + pqz*pqz
+	t := pqx*dx + pqz*dz
+	if d > 0 {
+		t /= d
+	}
+	t = min(max(t, 0), 1)
+	dx = float32(px) + t*pqx - float32(x)
+	dz = float32(pz) + t*pqz - float32(z)
+	return dx*dx + dz*dz
+}
+
+// removeDegenerateSegments drops vertices that are equal on the xz plane to the next vertex
+func removeDegenerateSegments(simplified []int) []int {
+	npts := len(simplified) / 4
+	for i := 0; i < npts; i++ {
+		ni := (i + 1) % npts
+		if simplified[i*4] == simplified[ni*4] && simplified[i*4+2] == simplified[ni*4+2] {
+			simplified = append(simplified[:i*4], simplified[i*4+4:]...)
+			npts--
+			i--
+		}
+	}
+	return simplified
+}
@@ -0,0 +1,74 @@
+package navmesh
+
+// detailMesh holds height detail per polygon. Each mesh is vertBase, vertCount, triBase, triCount,
+// the first vertices of a mesh are copies of its polygon's vertices
+type detailMesh struct {
+	meshes [][4]uint32
+	verts  [][3]float32
+	tris   [][4]uint8
+}
+
+// buildDetailMesh fans each polygon around its centroid, with the centroid height sampled from the heightfield
+func buildDetailMesh(pmesh *polyMesh, chf *compactHeightfield) *detailMesh {
+	dmesh := &detailMesh{}
+	for i, p := range pmesh.polys {
+		nv := polyVertCount(p)
+		vertBase := len(dmesh.verts)
+		triBase := len(dmesh.tris)
+
+		cx := float32(0)
+		cy := float32(0)
+		cz := float32(0)
+		for j := 0; j < nv; j++ {
+			v := pmesh.verts[p[j]]
+			dmesh.verts = append(dmesh.verts, [3]float32{
+				pmesh.bmin[0] + float32(v[0])*pmesh.cs,
+				pmesh.bmin[1] + float32(v[1])*pmesh.ch,
+				pmesh.bmin[2] + float32(v[2])*pmesh.cs,
+			})
+			cx += float32(v[0])
+			cy += float32(v[1])
+			cz += float32(v[2])
+		}
+		cx /= float32(nv)
+		cy /= float32(nv)
+		cz /= float32(nv)
+		cy = chf.sampleHeight(int(cx)+chf.borderSize, int(cz)+chf.borderSize, pmesh.regs[i], cy)
+		dmesh.verts = append(dmesh.verts, [3]float32{
+			pmesh.bmin[0] + cx*pmesh.cs,
+			pmesh.bmin[1] + cy*pmesh.ch,
+			pmesh.bmin[2] + cz*pmesh.cs,
+		})
+
+		for j := 0; j < nv; j++ {
+			// only the first edge lies on the polygon boundary
+			dmesh.tris = append(dmesh.tris, [4]uint8{uint8(j), uint8((j + 1) % nv), uint8(nv), 1})
+		}
+		dmesh.meshes = append(dmesh.meshes, [4]uint32{uint32(vertBase), uint32(nv + 1), uint32(triBase), uint32(nv)})
+	}
+	return dmesh
+}
+
+// sampleHeight returns the floor of the span in region reg at a cell, or fallback
+func (chf *compactHeightfield) sampleHeight(x, z int, reg uint16, fallback float32) float32 {
+	x = clamp(x, 0, chf.width-1)
+	z = clamp(z, 0, chf.height-1)
+	cell := chf.cells[x+z*chf.width]
+	best := fallback
+	bestDist := float32(-1)
+	for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+		s := chf.spans[i]
+		if s.reg != reg {
+			continue
+		}
+		d := float32(s.y) - fallback
+		if d < 0 {
+			d = -d
+		}
+		if bestDist < 0 || d < bestDist {
+			best = float32(s.y)
+			bestDist = d
+		}
+	}
+	return best
+}
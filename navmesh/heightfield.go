@@ -0,0 +1,335 @@
+package navmesh
+
+import "math"
+
+const spanMaxHeight = 0xffff
+
+type span struct {
+	smin uint16
+	smax uint16
+	area uint8
+	next int32
+}
+
+// heightfield is a grid of columns, each a sorted linked list of solid spans
+type heightfield struct {
+	width   int
+	height  int
+	bmin    [3]float32
+	bmax    [3]float32
+	cs      float32
+	ch      float32
+	columns []int32 // first span index per column, -1 when empty
+	pool    []span
+	free    int32
+}
+
+func newHeightfield(width, height int, bmin, bmax [3]float32, cs, ch float32) *heightfield {
+	hf := &heightfield{
+		width:   width,
+		height:  height,
+		bmin:    bmin,
+		bmax:    bmax,
+		cs:      cs,
+		ch:      ch,
+		columns: make([]int32, width*height),
+		free:    -1,
+	}
+	for i := range hf.columns {
+		hf.columns[i] = -1
+	}
+	return hf
+}
+
+func (hf *heightfield) allocSpan() int32 {
+	if hf.free != -1 {
+		i := hf.free
+		hf.free = hf.pool[i].next
+		return i
+	}
+	hf.pool = append(hf.pool, span{})
+	return int32(len(hf.pool) - 1)
+}
+
+func (hf *heightfield) freeSpan(i int32) {
+	hf.pool[i].next = hf.free
+	hf.free = i
+}
+
+// addSpan inserts a span into a column, merging it with any span it overlaps
+func (hf *heightfield) addSpan(x, z int, smin, smax uint16, area uint8, flagMergeThr int) {
+	column := x + z*hf.width
+	newSmin := smin
+	newSmax := smax
+	newArea := area
+
+	prev := int32(-1)
+	cur := hf.columns[column]
+	for cur != -1 {
+		s := &hf.pool[cur]
+		if s.smin > newSmax {
+			break
+		}
+		if s.smax < newSmin {
+			prev = cur
+			cur = s.next
+			continue
+		}
+		newSmin = min(newSmin, s.smin)
+		newSmax = max(newSmax, s.smax)
+		if abs(int(newSmax)-int(s.smax)) <= flagMergeThr {
+			newArea = max(newArea, s.area)
+		}
+		next := s.next
+		hf.freeSpan(cur)
+		if prev != -1 {
+			hf.pool[prev].next = next
+		} else {
+			hf.columns[column] = next
+		}
+		cur = next
+	}
+
+	i := hf.allocSpan()
+	hf.pool[i] = span{smin: newSmin, smax: newSmax, area: newArea, next: cur}
+	if prev != -1 {
+		hf.pool[prev].next = i
+	} else {
+		hf.columns[column] = i
+	}
+}
+
+// dividePoly splits a convex polygon along an axis aligned line, out1 gets the side below x
+func dividePoly(in [][3]float32, x float32, axis int, out1, out2 [][3]float32) ([][3]float32, [][3]float32) {
+	out1 = out1[:0]
+	out2 = out2[:0]
+	d := make([]float32, len(in))
+	for i := range in {
+		d[i] = x - in[i][axis]
+	}
+	for i, j := 0, len(in)-1; i < len(in); j, i = i, i+1 {
+		ina := d[j] >= 0
+		inb := d[i] >= 0
+		if ina != inb {
+			s := d[j] / (d[j] - d[i])
+			pt := [3]float32{
+				in[j][0] + (in[i][0]-in[j][0])*s,
+				in[j][1] + (in[i][1]-in[j][1])*s,
+				in[j][2] + (in[i][2]-in[j][2])*s,
+			}
+			out1 = append(out1, pt)
+			out2 = append(out2, pt)
+			if d[i] > 0 {
+				out1 = append(out1, in[i])
+			} else if d[i] < 0 {
+				out2 = append(out2, in[i])
+			}
+			continue
+		}
+		if d[i] >= 0 {
+			out1 = append(out1, in[i])
+			if d[i] != 0 {
+				continue
+			}
+		}
+		out2 = append(out2, in[i])
+	}
+	return out1, out2
+}
+
+// rasterizeTriangle clips a triangle to each cell it covers and adds the covered height range as a span
+func (hf *heightfield) rasterizeTriangle(v0, v1, v2 [3]float32, area uint8, flagMergeThr int) {
+	tmin := v0
+	tmax := v0
+	for _, v := range [][3]float32{v1, v2} {
+		for i := 0; i < 3; i++ {
+			tmin[i] = min(tmin[i], v[i])
+			tmax[i] = max(tmax[i], v[i])
+		}
+	}
+	if tmin[0] > hf.bmax[0] || tmax[0] < hf.bmin[0] || tmin[1] > hf.bmax[1] || tmax[1] < hf.bmin[1] || tmin[2] > hf.bmax[2] || tmax[2] < hf.bmin[2] {
+		return
+	}
+
+	ics := 1 / hf.cs
+	ich := 1 / hf.ch
+	by := hf.bmax[1] - hf.bmin[1]
+
+	z0 := int((tmin[2] - hf.bmin[2]) * ics)
+	z1 := int((tmax[2] - hf.bmin[2]) * ics)
+	z0 = clamp(z0, -1, hf.height-1)
+	z1 = clamp(z1, 0, hf.height-1)
+
+	in := [][3]float32{v0, v1, v2}
+	var row, rest, cell, rowRest [][3]float32
+	for z := z0; z <= z1; z++ {
+		cz := hf.bmin[2] + float32(z)*hf.cs
+		row, rest = dividePoly(in, cz+hf.cs, 2, row, rest)
+		in, rest = append(in[:0:0], rest...), in
+		if len(row) < 3 || z < 0 {
+			continue
+		}
+
+		minX := row[0][0]
+		maxX := row[0][0]
+		for _, v := range row[1:] {
+			minX = min(minX, v[0])
+			maxX = max(maxX, v[0])
+		}
+		x0 := int((minX - hf.bmin[0]) * ics)
+		x1 := int((maxX - hf.bmin[0]) * ics)
+		if x1 < 0 || x0 >= hf.width {
+			continue
+		}
+		x0 = clamp(x0, -1, hf.width-1)
+		x1 = clamp(x1, 0, hf.width-1)
+
+		for x := x0; x <= x1; x++ {
+			cx := hf.bmin[0] + float32(x)*hf.cs
+			cell, rowRest = dividePoly(row, cx+hf.cs, 0, cell, rowRest)
+			row, rowRest = append(row[:0:0], rowRest...), row
+			if len(cell) < 3 || x < 0 {
+				continue
+			}
+
+			smin := cell[0][1]
+			smax := cell[0][1]
+			for _, v := range cell[1:] {
+				smin = min(smin, v[1])
+				smax = max(smax, v[1])
+			}
+			smin -= hf.bmin[1]
+			smax -= hf.bmin[1]
+			if smax < 0 || smin > by {
+				continue
+			}
+			smin = max(smin, 0)
+			smax = min(smax, by)
+
+			ismin := clamp(int(math.Floor(float64(smin*ich))), 0, spanMaxHeight)
+			ismax := clamp(int(math.Ceil(float64(smax*ich))), ismin+1, spanMaxHeight)
+			hf.addSpan(x, z, uint16(ismin), uint16(ismax), area, flagMergeThr)
+		}
+	}
+}
+
+// filterLowHangingWalkableObstacles lets agents step onto low obstacles such as curbs
+func (hf *heightfield) filterLowHangingWalkableObstacles(walkableClimb int) {
+	for _, first := range hf.columns {
+		prev := int32(-1)
+		prevWalkable := false
+		prevArea := uint8(nullArea)
+		for i := first; i != -1; i = hf.pool[i].next {
+			s := &hf.pool[i]
+			walkable := s.area != nullArea
+			if !walkable && prevWalkable && abs(int(s.smax)-int(hf.pool[prev].smax)) <= walkableClimb {
+				s.area = prevArea
+			}
+			prevWalkable = walkable
+			prevArea = s.area
+			prev = i
+		}
+	}
+}
+
+// filterLedgeSpans removes walkable spans next to drops higher than walkableClimb
+func (hf *heightfield) filterLedgeSpans(walkableHeight, walkableClimb int) {
+	for z := 0; z < hf.height; z++ {
+		for x := 0; x < hf.width; x++ {
+			for i := hf.columns[x+z*hf.width]; i != -1; i = hf.pool[i].next {
+				s := &hf.pool[i]
+				if s.area == nullArea {
+					continue
+				}
+				bot := int(s.smax)
+				top := spanMaxHeight
+				if s.next != -1 {
+					top = int(hf.pool[s.next].smin)
+				}
+
+				minh := spanMaxHeight
+				asmin := int(s.smax)
+				asmax := int(s.smax)
+				for dir := 0; dir < 4; dir++ {
+					dx := x + dirOffsetX(dir)
+					dz := z + dirOffsetY(dir)
+					if dx < 0 || dz < 0 || dx >= hf.width || dz >= hf.height {
+						minh = min(minh, -walkableClimb-bot)
+						continue
+					}
+
+					ns := hf.columns[dx+dz*hf.width]
+					nbot := -walkableClimb
+					ntop := spanMaxHeight
+					if ns != -1 {
+						ntop = int(hf.pool[ns].smin)
+					}
+					if min(top, ntop)-max(bot, nbot) > walkableHeight {
+						minh = min(minh, nbot-bot)
+					}
+
+					for ; ns != -1; ns = hf.pool[ns].next {
+						nbot = int(hf.pool[ns].smax)
+						ntop = spanMaxHeight
+						if hf.pool[ns].next != -1 {
+							ntop = int(hf.pool[hf.pool[ns].next].smin)
+						}
+						if min(top, ntop)-max(bot, nbot) > walkableHeight {
+							minh = min(minh, nbot-bot)
+							if abs(nbot-bot) <= walkableClimb {
+								asmin = min(asmin, nbot)
+								asmax = max(asmax, nbot)
+							}
+						}
+					}
+				}
+
+				if minh < -walkableClimb || asmax-asmin > walkableClimb {
+					s.area = nullArea
+				}
+			}
+		}
+	}
+}
+
+// filterWalkableLowHeightSpans removes walkable spans without enough clearance above them
+func (hf *heightfield) filterWalkableLowHeightSpans(walkableHeight int) {
+	for _, first := range hf.columns {
+		for i := first; i != -1; i = hf.pool[i].next {
+			s := &hf.pool[i]
+			bot := int(s.smax)
+			top := spanMaxHeight
+			if s.next != -1 {
+				top = int(hf.pool[s.next].smin)
+			}
+			if top-bot < walkableHeight {
+				s.area = nullArea
+			}
+		}
+	}
+}
+
+func dirOffsetX(dir int) int {
+	return [4]int{-1, 0, 1, 0}[dir&3]
+}
+
+func dirOffsetY(dir int) int {
+	return [4]int{0, 1, 0, -1}[dir&3]
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,313 @@
+// Package navmesh builds EQEmu navigation meshes from zone triangles.
+// It follows recast's pipeline: triangles are voxelized into a heightfield, walkable
+// spans are split into monotone regions, region outlines are traced into contours,
+// contours are triangulated into convex polygons and each polygon gets a detail mesh.
+// The result is a set of detour tiles written as raw.Nav.
+package navmesh
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/xackery/quail/raw"
+)
+
+// Config controls navmesh generation, distances are in world units unless noted
+type Config struct {
+	CellSize      float32 // horizontal voxel size
+	CellHeight    float32 // vertical voxel size
+	AgentHeight   float32 // minimum ceiling clearance
+	AgentRadius   float32 // distance kept from walls
+	AgentMaxClimb float32 // max step height
+	AgentMaxSlope float32 // max walkable slope in degrees
+	RegionMinSize int     // regions with fewer cells than RegionMinSize^2 are removed
+	EdgeMaxLen    float32 // contour edges on walls are split when longer than this
+	EdgeMaxError  float32 // max contour deviation from the voxel outline, in cells
+	TileSize      int     // tile width and height, in cells
+}
+
+// DefaultConfig returns settings suited to player sized agents
+func DefaultConfig() *Config {
+	return &Config{
+		CellSize:      1,
+		CellHeight:    0.5,
+		AgentHeight:   6,
+		AgentRadius:   1.5,
+		AgentMaxClimb: 3,
+		AgentMaxSlope: 60,
+		RegionMinSize: 8,
+		EdgeMaxLen:    12,
+		EdgeMaxError:  1.3,
+		TileSize:      256,
+	}
+}
+
+// AreaFunc returns the region type at a position in server coordinates, such as raw.Wtr's ServerRegionType
+type AreaFunc func(x, y, z float32) raw.WtrRegionType
+
+// Area is an EQEmu navmesh polygon area, the polygon flag is 1<<Area
+type Area uint8
+
+const (
+	AreaNormal Area = iota
+	AreaWater
+	AreaLava
+	AreaZoneLine
+	AreaPvP
+	AreaSlime
+	AreaIce
+	AreaVWater
+	AreaGeneralArea
+	AreaPortal
+	AreaPrefer
+	AreaDisabled
+)
+
+// areaFromRegionType converts a water map region type to a navmesh area
+func areaFromRegionType(regionType raw.WtrRegionType) Area {
+	switch regionType {
+	case raw.WtrRegionTypeWater:
+		return AreaWater
+	case raw.WtrRegionTypeLava:
+		return AreaLava
+	case raw.WtrRegionTypeZoneLine:
+		return AreaZoneLine
+	case raw.WtrRegionTypePVP:
+		return AreaPvP
+	case raw.WtrRegionTypeSlime:
+		return AreaSlime
+	case raw.WtrRegionTypeIce:
+		return AreaIce
+	case raw.WtrRegionTypeVWater:
+		return AreaVWater
+	case raw.WtrRegionTypeGeneralArea:
+		return AreaGeneralArea
+	case raw.WtrRegionTypePreferPathing:
+		return AreaPrefer
+	case raw.WtrRegionTypeDisableNavMesh:
+		return AreaDisabled
+	}
+	return AreaNormal
+}
+
+// spans store area+1 so that 0 can mean not walkable
+const nullArea = 0
+
+func walkableArea(area Area) uint8 {
+	return uint8(area) + 1
+}
+
+// Build generates a navmesh from triangles in server coordinates (z up).
+// areaFn may be nil, in which case every polygon is AreaNormal
+func Build(verts [][3]float32, indices []uint32, areaFn AreaFunc, cfg *Config) (*raw.Nav, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if cfg.CellSize <= 0 || cfg.CellHeight <= 0 {
+		return nil, fmt.Errorf("cell size and height must be positive")
+	}
+	if cfg.TileSize <= 0 {
+		return nil, fmt.Errorf("tile size must be positive")
+	}
+	if len(indices)%3 != 0 {
+		return nil, fmt.Errorf("index count %d is not a multiple of 3", len(indices))
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no triangles")
+	}
+
+	// detour is y up
+	dtVerts := make([][3]float32, len(verts))
+	for i, v := range verts {
+		dtVerts[i] = [3]float32{v[0], v[2], v[1]}
+	}
+	for i, index := range indices {
+		if int(index) >= len(verts) {
+			return nil, fmt.Errorf("index %d: vertex %d out of range", i, index)
+		}
+	}
+
+	bmin := dtVerts[indices[0]]
+	bmax := bmin
+	for _, index := range indices {
+		v := dtVerts[index]
+		for j := 0; j < 3; j++ {
+			bmin[j] = min(bmin[j], v[j])
+			bmax[j] = max(bmax[j], v[j])
+		}
+	}
+
+	b := &builder{
+		cfg:            cfg,
+		verts:          dtVerts,
+		indices:        indices,
+		areaFn:         areaFn,
+		walkableHeight: int(math.Ceil(float64(cfg.AgentHeight / cfg.CellHeight))),
+		walkableClimb:  int(math.Floor(float64(cfg.AgentMaxClimb / cfg.CellHeight))),
+		walkableRadius: int(math.Ceil(float64(cfg.AgentRadius / cfg.CellSize))),
+		walkableThr:    float32(math.Cos(float64(cfg.AgentMaxSlope) / 180 * math.Pi)),
+		maxEdgeLen:     int(cfg.EdgeMaxLen / cfg.CellSize),
+		minRegionArea:  cfg.RegionMinSize * cfg.RegionMinSize,
+	}
+	b.borderSize = b.walkableRadius + 3
+	b.markWalkableTriangles()
+
+	tileWorldSize := float32(cfg.TileSize) * cfg.CellSize
+	tilesX := int(math.Ceil(float64((bmax[0] - bmin[0]) / tileWorldSize)))
+	tilesZ := int(math.Ceil(float64((bmax[2] - bmin[2]) / tileWorldSize)))
+	tilesX = max(tilesX, 1)
+	tilesZ = max(tilesZ, 1)
+
+	nav := &raw.Nav{
+		Version: raw.NavVersion,
+		Params: raw.NavParams{
+			Origin:     bmin,
+			TileWidth:  tileWorldSize,
+			TileHeight: tileWorldSize,
+		},
+	}
+
+	maxPolys := 1
+	for tz := 0; tz < tilesZ; tz++ {
+		for tx := 0; tx < tilesX; tx++ {
+			tileMin := [3]float32{bmin[0] + float32(tx)*tileWorldSize, bmin[1], bmin[2] + float32(tz)*tileWorldSize}
+			tileMax := [3]float32{bmin[0] + float32(tx+1)*tileWorldSize, bmax[1], bmin[2] + float32(tz+1)*tileWorldSize}
+			tile, err := b.buildTile(tx, tz, tileMin, tileMax)
+			if err != nil {
+				return nil, fmt.Errorf("tile %d,%d: %w", tx, tz, err)
+			}
+			if tile == nil {
+				continue
+			}
+			maxPolys = max(maxPolys, len(tile.Polys))
+			nav.Tiles = append(nav.Tiles, tile)
+		}
+	}
+	if len(nav.Tiles) == 0 {
+		return nil, fmt.Errorf("no walkable area found")
+	}
+
+	// detour packs salt, tile and poly index into a 32 bit ref and needs at least 10 salt bits
+	tileBits := ilog2(nextPow2(uint32(len(nav.Tiles))))
+	polyBits := ilog2(nextPow2(uint32(maxPolys)))
+	if tileBits+polyBits > 22 {
+		return nil, fmt.Errorf("%d tiles with up to %d polys do not fit a 32 bit poly ref, increase tile size or cell size", len(nav.Tiles), maxPolys)
+	}
+	nav.Params.MaxTiles = int32(nextPow2(uint32(len(nav.Tiles))))
+	nav.Params.MaxPolys = int32(nextPow2(uint32(maxPolys)))
+	for i, tile := range nav.Tiles {
+		tile.Ref = 1<<(polyBits+tileBits) | uint32(i)<<polyBits
+	}
+	return nav, nil
+}
+
+type builder struct {
+	cfg            *Config
+	verts          [][3]float32
+	indices        []uint32
+	triAreas       []uint8
+	areaFn         AreaFunc
+	walkableHeight int
+	walkableClimb  int
+	walkableRadius int
+	walkableThr    float32
+	borderSize     int
+	maxEdgeLen     int
+	minRegionArea  int
+}
+
+// markWalkableTriangles flags triangles that are flat enough to walk on.
+// Zone meshes do not have consistent winding, so either facing counts
+func (b *builder) markWalkableTriangles() {
+	b.triAreas = make([]uint8, len(b.indices)/3)
+	for i := range b.triAreas {
+		v0 := b.verts[b.indices[i*3]]
+		v1 := b.verts[b.indices[i*3+1]]
+		v2 := b.verts[b.indices[i*3+2]]
+		e0 := sub(v1, v0)
+		e1 := sub(v2, v0)
+		norm := cross(e0, e1)
+		length := float32(math.Sqrt(float64(dot(norm, norm))))
+		if length == 0 {
+			continue
+		}
+		if float32(math.Abs(float64(norm[1]/length))) > b.walkableThr {
+			b.triAreas[i] = walkableArea(AreaNormal)
+		}
+	}
+}
+
+func (b *builder) buildTile(tx, tz int, tileMin, tileMax [3]float32) (*raw.NavTile, error) {
+	cs := b.cfg.CellSize
+	pad := float32(b.borderSize) * cs
+	size := b.cfg.TileSize + b.borderSize*2
+
+	hf := newHeightfield(size, size, [3]float32{tileMin[0] - pad, tileMin[1], tileMin[2] - pad}, [3]float32{tileMax[0] + pad, tileMax[1], tileMax[2] + pad}, cs, b.cfg.CellHeight)
+	for i := range b.triAreas {
+		hf.rasterizeTriangle(b.verts[b.indices[i*3]], b.verts[b.indices[i*3+1]], b.verts[b.indices[i*3+2]], b.triAreas[i], b.walkableClimb)
+	}
+
+	hf.filterLowHangingWalkableObstacles(b.walkableClimb)
+	hf.filterLedgeSpans(b.walkableHeight, b.walkableClimb)
+	hf.filterWalkableLowHeightSpans(b.walkableHeight)
+
+	chf := newCompactHeightfield(hf, b.walkableHeight, b.walkableClimb)
+	if len(chf.spans) == 0 {
+		return nil, nil
+	}
+	chf.erodeWalkableArea(b.walkableRadius)
+	if b.areaFn != nil {
+		chf.markAreas(b.areaFn)
+	}
+	err := chf.buildRegionsMonotone(b.borderSize, b.minRegionArea)
+	if err != nil {
+		return nil, fmt.Errorf("regions: %w", err)
+	}
+
+	cset := buildContours(chf, b.cfg.EdgeMaxError, b.maxEdgeLen)
+	if len(cset.contours) == 0 {
+		return nil, nil
+	}
+	pmesh, err := buildPolyMesh(cset)
+	if err != nil {
+		return nil, fmt.Errorf("poly mesh: %w", err)
+	}
+	if len(pmesh.polys) == 0 {
+		return nil, nil
+	}
+	dmesh := buildDetailMesh(pmesh, chf)
+
+	return b.createTile(tx, tz, pmesh, dmesh)
+}
+
+func nextPow2(v uint32) uint32 {
+	v--
+	v |= v >> 1
+	v |= v >> 2
+	v |= v >> 4
+	v |= v >> 8
+	v |= v >> 16
+	v++
+	return v
+}
+
+func ilog2(v uint32) uint32 {
+	r := uint32(0)
+	for v > 1 {
+		v >>= 1
+		r++
+	}
+	return r
+}
+
+func sub(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func cross(a, b [3]float32) [3]float32 {
+	return [3]float32{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
@@ -0,0 +1,90 @@
+package navmesh
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xackery/quail/raw"
+)
+
+func TestBuild(t *testing.T) {
+	// a flat 100x100 floor at z 0, with water where x > 50
+	verts := [][3]float32{{0, 0, 0}, {100, 0, 0}, {100, 100, 0}, {0, 100, 0}}
+	indices := []uint32{0, 1, 2, 0, 2, 3}
+	areaFn := func(x, y, z float32) raw.WtrRegionType {
+		if x > 50 {
+			return raw.WtrRegionTypeWater
+		}
+		return raw.WtrRegionTypeNormal
+	}
+	cfg := DefaultConfig()
+	cfg.TileSize = 64
+
+	nav, err := Build(verts, indices, areaFn, cfg)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+	if len(nav.Tiles) != 4 {
+		t.Fatalf("tiles: got %d, want 4", len(nav.Tiles))
+	}
+
+	areas := make(map[uint8]int)
+	for _, tile := range nav.Tiles {
+		for i, poly := range tile.Polys {
+			areas[poly.Area]++
+			if poly.VertCount < 3 || poly.VertCount > raw.NavVertsPerPoly {
+				t.Fatalf("tile %d,%d poly %d: vert count %d", tile.X, tile.Y, i, poly.VertCount)
+			}
+			for j := 0; j < int(poly.VertCount); j++ {
+				if int(poly.Verts[j]) >= len(tile.Verts) {
+					t.Fatalf("tile %d,%d poly %d: vert %d out of range", tile.X, tile.Y, i, poly.Verts[j])
+				}
+				nei := poly.Neis[j]
+				if nei != 0 && nei&raw.NavExtLink == 0 && int(nei) > len(tile.Polys) {
+					t.Fatalf("tile %d,%d poly %d: neighbor %d out of range", tile.X, tile.Y, i, nei)
+				}
+			}
+			if poly.Flags != 1<<poly.Area {
+				t.Fatalf("tile %d,%d poly %d: flags 0x%x for area %d", tile.X, tile.Y, i, poly.Flags, poly.Area)
+			}
+		}
+		for _, v := range tile.Verts {
+			if v[1] < -1 || v[1] > 1 {
+				t.Fatalf("tile %d,%d: vertex height %0.2f, want about 0", tile.X, tile.Y, v[1])
+			}
+		}
+		if len(tile.DetailMeshes) != len(tile.Polys) {
+			t.Fatalf("tile %d,%d: %d detail meshes for %d polys", tile.X, tile.Y, len(tile.DetailMeshes), len(tile.Polys))
+		}
+		if len(tile.BVTree) == 0 || len(tile.BVTree) > len(tile.Polys)*2 {
+			t.Fatalf("tile %d,%d: bv tree size %d for %d polys", tile.X, tile.Y, len(tile.BVTree), len(tile.Polys))
+		}
+	}
+	if areas[uint8(AreaNormal)] == 0 || areas[uint8(AreaWater)] == 0 {
+		t.Fatalf("areas: got %v, want normal and water polys", areas)
+	}
+
+	buf := &bytes.Buffer{}
+	err = nav.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	dst := &raw.Nav{}
+	err = dst.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if len(dst.Tiles) != len(nav.Tiles) {
+		t.Fatalf("read tiles: got %d, want %d", len(dst.Tiles), len(nav.Tiles))
+	}
+}
+
+func TestBuildSteepWall(t *testing.T) {
+	// a vertical wall is never walkable
+	verts := [][3]float32{{0, 0, 0}, {100, 0, 0}, {100, 0, 100}, {0, 0, 100}}
+	indices := []uint32{0, 1, 2, 0, 2, 3}
+	_, err := Build(verts, indices, nil, nil)
+	if err == nil {
+		t.Fatalf("build: expected no walkable area error")
+	}
+}
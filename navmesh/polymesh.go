@@ -0,0 +1,473 @@
+package navmesh
+
+import "fmt"
+
+const (
+	meshNullIdx   = 0xffff
+	vertsPerPoly  = 6
+	diagonalFlag  = 0x80000000
+	diagonalMask  = 0x0fffffff
+	maxMeshVerts  = 0xfffe
+	portalNeiFlag = 0x8000
+)
+
+// polyMesh is a set of convex polygons, each poly is vertsPerPoly vertex indices followed by
+// vertsPerPoly neighbor indices, verts are x, y, z in cells relative to bmin
+type polyMesh struct {
+	verts  [][3]int
+	polys  [][vertsPerPoly * 2]uint16
+	regs   []uint16
+	areas  []uint8
+	bmin   [3]float32
+	bmax   [3]float32
+	cs     float32
+	ch     float32
+	width  int
+	height int
+}
+
+// buildPolyMesh triangulates each contour and merges the triangles into convex polygons
+func buildPolyMesh(cset *contourSet) (*polyMesh, error) {
+	pmesh := &polyMesh{
+		bmin:   cset.bmin,
+		bmax:   cset.bmax,
+		cs:     cset.cs,
+		ch:     cset.ch,
+		width:  cset.width,
+		height: cset.height,
+	}
+	vertIndex := make(map[[2]int][]int)
+
+	for _, cont := range cset.contours {
+		nverts := len(cont.verts) / 4
+		if nverts < 3 {
+			continue
+		}
+		verts := make([][3]int, nverts)
+		indices := make([]int, nverts)
+		for j := range verts {
+			verts[j] = [3]int{cont.verts[j*4], cont.verts[j*4+1], cont.verts[j*4+2]}
+			indices[j] = j
+		}
+
+		tris := triangulate(verts, indices)
+		if len(tris) == 0 {
+			continue
+		}
+
+		// add and merge vertices
+		for j, v := range verts {
+			indices[j] = pmesh.addVertex(v, vertIndex)
+		}
+		if len(pmesh.verts) > maxMeshVerts {
+			return nil, fmt.Errorf("too many vertices %d, max %d", len(pmesh.verts), maxMeshVerts)
+		}
+
+		polys := [][]uint16{}
+		for _, t := range tris {
+			if t[0] == t[1] || t[0] == t[2] || t[1] == t[2] {
+				continue
+			}
+			polys = append(polys, []uint16{uint16(indices[t[0]]), uint16(indices[t[1]]), uint16(indices[t[2]])})
+		}
+		if len(polys) == 0 {
+			continue
+		}
+
+		for {
+			bestMergeVal := 0
+			bestPa, bestPb, bestEa, bestEb := 0, 0, 0, 0
+			for j := 0; j < len(polys)-1; j++ {
+				for k := j + 1; k < len(polys); k++ {
+					v, ea, eb := pmesh.polyMergeValue(polys[j], polys[k])
+					if v > bestMergeVal {
+						bestMergeVal = v
+						bestPa, bestPb, bestEa, bestEb = j, k, ea, eb
+					}
+				}
+			}
+			if bestMergeVal <= 0 {
+				break
+			}
+			polys[bestPa] = mergePolyVerts(polys[bestPa], polys[bestPb], bestEa, bestEb)
+			polys[bestPb] = polys[len(polys)-1]
+			polys = polys[:len(polys)-1]
+		}
+
+		for _, p := range polys {
+			poly := [vertsPerPoly * 2]uint16{}
+			for k := range poly {
+				poly[k] = meshNullIdx
+			}
+			copy(poly[:], p)
+			pmesh.polys = append(pmesh.polys, poly)
+			pmesh.regs = append(pmesh.regs, cont.reg)
+			pmesh.areas = append(pmesh.areas, cont.area)
+		}
+	}
+
+	pmesh.buildAdjacency()
+	pmesh.markPortalEdges()
+	return pmesh, nil
+}
+
+// addVertex returns the index of a vertex at the same x, z and within 2 cells of y, adding it if needed
+func (pmesh *polyMesh) addVertex(v [3]int, vertIndex map[[2]int][]int) int {
+	key := [2]int{v[0], v[2]}
+	for _, i := range vertIndex[key] {
+		if abs(pmesh.verts[i][1]-v[1]) <= 2 {
+			return i
+		}
+	}
+	i := len(pmesh.verts)
+	pmesh.verts = append(pmesh.verts, v)
+	vertIndex[key] = append(vertIndex[key], i)
+	return i
+}
+
+// polyMergeValue returns the squared length of the shared edge of two polygons if merging them
+// stays convex and within vertsPerPoly, or -1
+func (pmesh *polyMesh) polyMergeValue(pa, pb []uint16) (int, int, int) {
+	na := len(pa)
+	nb := len(pb)
+	if na+nb-2 > vertsPerPoly {
+		return -1, 0, 0
+	}
+
+	ea := -1
+	eb := -1
+	for i := 0; i < na && ea == -1; i++ {
+		va0 := pa[i]
+		va1 := pa[(i+1)%na]
+		if va0 > va1 {
+			va0, va1 = va1, va0
+		}
+		for j := 0; j < nb; j++ {
+			vb0 := pb[j]
+			vb1 := pb[(j+1)%nb]
+			if vb0 > vb1 {
+				vb0, vb1 = vb1, vb0
+			}
+			if va0 == vb0 && va1 == vb1 {
+				ea = i
+				eb = j
+				break
+			}
+		}
+	}
+	if ea == -1 {
+		return -1, 0, 0
+	}
+
+	verts := pmesh.verts
+	if !uleft(verts[pa[(ea+na-1)%na]], verts[pa[ea]], verts[pb[(eb+2)%nb]]) {
+		return -1, 0, 0
+	}
+	if !uleft(verts[pb[(eb+nb-1)%nb]], verts[pb[eb]], verts[pa[(ea+2)%na]]) {
+		return -1, 0, 0
+	}
+
+	va := verts[pa[ea]]
+	vb := verts[pa[(ea+1)%na]]
+	dx := va[0] - vb[0]
+	dz := va[2] - vb[2]
+	return dx*dx + dz*dz, ea, eb
+}
+
+func mergePolyVerts(pa, pb []uint16, ea, eb int) []uint16 {
+	na := len(pa)
+	nb := len(pb)
+	merged := make([]uint16, 0, na+nb-2)
+	for i := 0; i < na-1; i++ {
+		merged = append(merged, pa[(ea+1+i)%na])
+	}
+	for i := 0; i < nb-1; i++ {
+		merged = append(merged, pb[(eb+1+i)%nb])
+	}
+	return merged
+}
+
+func uleft(a, b, c [3]int) bool {
+	return (b[0]-a[0])*(c[2]-a[2])-(c[0]-a[0])*(b[2]-a[2]) < 0
+}
+
+// buildAdjacency fills in the neighbor half of each polygon for edges shared by two polygons
+func (pmesh *polyMesh) buildAdjacency() {
+	type edge struct {
+		vert     [2]uint16
+		poly     [2]int
+		polyEdge [2]int
+	}
+	edges := []edge{}
+	firstEdge := make(map[uint16][]int)
+
+	for i, p := range pmesh.polys {
+		nv := polyVertCount(p)
+		for j := 0; j < nv; j++ {
+			v0 := p[j]
+			v1 := p[(j+1)%nv]
+			if v0 < v1 {
+				firstEdge[v0] = append(firstEdge[v0], len(edges))
+				edges = append(edges, edge{vert: [2]uint16{v0, v1}, poly: [2]int{i, i}, polyEdge: [2]int{j, 0}})
+			}
+		}
+	}
+
+	for i, p := range pmesh.polys {
+		nv := polyVertCount(p)
+		for j := 0; j < nv; j++ {
+			v0 := p[j]
+			v1 := p[(j+1)%nv]
+			if v0 <= v1 {
+				continue
+			}
+			for _, e := range firstEdge[v1] {
+				if edges[e].vert[1] == v0 && edges[e].poly[0] == edges[e].poly[1] {
+					edges[e].poly[1] = i
+					edges[e].polyEdge[1] = j
+					break
+				}
+			}
+		}
+	}
+
+	for _, e := range edges {
+		if e.poly[0] == e.poly[1] {
+			continue
+		}
+		pmesh.polys[e.poly[0]][vertsPerPoly+e.polyEdge[0]] = uint16(e.poly[1])
+		pmesh.polys[e.poly[1]][vertsPerPoly+e.polyEdge[1]] = uint16(e.poly[0])
+	}
+}
+
+// markPortalEdges flags open edges on the tile boundary with the side they face
+func (pmesh *polyMesh) markPortalEdges() {
+	for i := range pmesh.polys {
+		p := &pmesh.polys[i]
+		nv := polyVertCount(*p)
+		for j := 0; j < nv; j++ {
+			if p[vertsPerPoly+j] != meshNullIdx {
+				continue
+			}
+			va := pmesh.verts[p[j]]
+			vb := pmesh.verts[p[(j+1)%nv]]
+			switch {
+			case va[0] == 0 && vb[0] == 0:
+				p[vertsPerPoly+j] = portalNeiFlag | 0
+			case va[2] == pmesh.height && vb[2] == pmesh.height:
+				p[vertsPerPoly+j] = portalNeiFlag | 1
+			case va[0] == pmesh.width && vb[0] == pmesh.width:
+				p[vertsPerPoly+j] = portalNeiFlag | 2
+			case va[2] == 0 && vb[2] == 0:
+				p[vertsPerPoly+j] = portalNeiFlag | 3
+			}
+		}
+	}
+}
+
+func polyVertCount(p [vertsPerPoly * 2]uint16) int {
+	for i := 0; i < vertsPerPoly; i++ {
+		if p[i] == meshNullIdx {
+			return i
+		}
+	}
+	return vertsPerPoly
+}
+
+// triangulate ear clips a contour, shortest diagonal first, returning indices into verts.
+// indices is consumed
+func triangulate(verts [][3]int, indices []int) [][3]int {
+	n := len(indices)
+	tris := [][3]int{}
+	vert := func(i int) [3]int {
+		return verts[indices[i]&diagonalMask]
+	}
+
+	for i := 0; i < n; i++ {
+		i1 := next(i, n)
+		i2 := next(i1, n)
+		if diagonal(i, i2, verts, indices[:n]) {
+			indices[i1] |= diagonalFlag
+		}
+	}
+
+	for n > 3 {
+		minLen := -1
+		mini := -1
+		for i := 0; i < n; i++ {
+			i1 := next(i, n)
+			if indices[i1]&diagonalFlag == 0 {
+				continue
+			}
+			p0 := vert(i)
+			p2 := vert(next(i1, n))
+			dx := p2[0] - p0[0]
+			dz := p2[2] - p0[2]
+			l := dx*dx + dz*dz
+			if minLen < 0 || l < minLen {
+				minLen = l
+				mini = i
+			}
+		}
+
+		if mini == -1 {
+			// overlapping segments, try a looser test
+			for i := 0; i < n; i++ {
+				i1 := next(i, n)
+				i2 := next(i1, n)
+				if !diagonalLoose(i, i2, verts, indices[:n]) {
+					continue
+				}
+				p0 := vert(i)
+				p2 := vert(i2)
+				dx := p2[0] - p0[0]
+				dz := p2[2] - p0[2]
+				l := dx*dx + dz*dz
+				if minLen < 0 || l < minLen {
+					minLen = l
+					mini = i
+				}
+			}
+			if mini == -1 {
+				// the contour is degenerate, keep the triangles found so far
+				return tris
+			}
+		}
+
+		i := mini
+		i1 := next(i, n)
+		i2 := next(i1, n)
+		tris = append(tris, [3]int{indices[i] & diagonalMask, indices[i1] & diagonalMask, indices[i2] & diagonalMask})
+
+		// remove i1
+		n--
+		copy(indices[i1:n], indices[i1+1:n+1])
+		if i1 >= n {
+			i1 = 0
+		}
+		i = prev(i1, n)
+
+		if diagonal(prev(i, n), i1, verts, indices[:n]) {
+			indices[i] |= diagonalFlag
+		} else {
+			indices[i] &= diagonalMask
+		}
+		if diagonal(i, next(i1, n), verts, indices[:n]) {
+			indices[i1] |= diagonalFlag
+		} else {
+			indices[i1] &= diagonalMask
+		}
+	}
+
+	tris = append(tris, [3]int{indices[0] & diagonalMask, indices[1] & diagonalMask, indices[2] & diagonalMask})
+	return tris
+}
+
+func prev(i, n int) int {
+	if i-1 >= 0 {
+		return i - 1
+	}
+	return n - 1
+}
+
+func next(i, n int) int {
+	if i+1 < n {
+		return i + 1
+	}
+	return 0
+}
+
+func area2(a, b, c [3]int) int {
+	return (b[0]-a[0])*(c[2]-a[2]) - (c[0]-a[0])*(b[2]-a[2])
+}
+
+func left(a, b, c [3]int) bool {
+	return area2(a, b, c) < 0
+}
+
+func leftOn(a, b, c [3]int) bool {
+	return area2(a, b, c) <= 0
+}
+
+func collinear(a, b, c [3]int) bool {
+	return area2(a, b, c) == 0
+}
+
+// intersectProp returns true if ab and cd properly intersect, sharing a point interior to both
+func intersectProp(a, b, c, d [3]int) bool {
+	if collinear(a, b, c) || collinear(a, b, d) || collinear(c, d, a) || collinear(c, d, b) {
+		return false
+	}
+	return left(a, b, c) != left(a, b, d) && left(c, d, a) != left(c, d, b)
+}
+
+// between returns true if c is on the closed segment ab
+func between(a, b, c [3]int) bool {
+	if !collinear(a, b, c) {
+		return false
+	}
+	if a[0] != b[0] {
+		return (a[0] <= c[0] && c[0] <= b[0]) || (a[0] >= c[0] && c[0] >= b[0])
+	}
+	return (a[2] <= c[2] && c[2] <= b[2]) || (a[2] >= c[2] && c[2] >= b[2])
+}
+
+func intersect(a, b, c, d [3]int) bool {
+	if intersectProp(a, b, c, d) {
+		return true
+	}
+	return between(a, b, c) || between(a, b, d) || between(c, d, a) || between(c, d, b)
+}
+
+func vequal(a, b [3]int) bool {
+	return a[0] == b[0] && a[2] == b[2]
+}
+
+// diagonalie returns true if ij is a diagonal that does not cross any contour edge
+func diagonalie(i, j int, verts [][3]int, indices []int, loose bool) bool {
+	n := len(indices)
+	d0 := verts[indices[i]&diagonalMask]
+	d1 := verts[indices[j]&diagonalMask]
+	for k := 0; k < n; k++ {
+		k1 := next(k, n)
+		if k == i || k1 == i || k == j || k1 == j {
+			continue
+		}
+		p0 := verts[indices[k]&diagonalMask]
+		p1 := verts[indices[k1]&diagonalMask]
+		if vequal(d0, p0) || vequal(d1, p0) || vequal(d0, p1) || vequal(d1, p1) {
+			continue
+		}
+		if loose && intersectProp(d0, d1, p0, p1) {
+			return false
+		}
+		if !loose && intersect(d0, d1, p0, p1) {
+			return false
+		}
+	}
+	return true
+}
+
+// inCone returns true if the diagonal ij is inside the polygon near i
+func inCone(i, j int, verts [][3]int, indices []int, loose bool) bool {
+	n := len(indices)
+	pi := verts[indices[i]&diagonalMask]
+	pj := verts[indices[j]&diagonalMask]
+	pi1 := verts[indices[next(i, n)]&diagonalMask]
+	pin1 := verts[indices[prev(i, n)]&diagonalMask]
+	if leftOn(pin1, pi, pi1) {
+		if loose {
+			return leftOn(pi, pj, pin1) && leftOn(pj, pi, pi1)
+		}
+		return left(pi, pj, pin1) && left(pj, pi, pi1)
+	}
+	return !(leftOn(pi, pj, pi1) && leftOn(pj, pi, pin1))
+}
+
+func diagonal(i, j int, verts [][3]int, indices []int) bool {
+	return inCone(i, j, verts, indices, false) && diagonalie(i, j, verts, indices, false)
+}
+
+func diagonalLoose(i, j int, verts [][3]int, indices []int) bool {
+	return inCone(i, j, verts, indices, true) && diagonalie(i, j, verts, indices, true)
+}
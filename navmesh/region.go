@@ -0,0 +1,208 @@
+package navmesh
+
+import "fmt"
+
+const nullNei = 0xffff
+
+type sweepSpan struct {
+	rid uint16 // row id
+	id  uint16 // region id
+	ns  int    // number of samples
+	nei uint16 // neighbour id
+}
+
+// buildRegionsMonotone partitions walkable spans into regions that are monotone along z, so they never contain holes.
+// The border of the tile is painted as border regions, and regions smaller than minRegionArea
+// that do not touch the border are removed
+func (chf *compactHeightfield) buildRegionsMonotone(borderSize int, minRegionArea int) error {
+	w := chf.width
+	h := chf.height
+	chf.borderSize = borderSize
+	srcReg := make([]uint16, len(chf.spans))
+	id := 1
+
+	if borderSize > 0 {
+		bw := min(w, borderSize)
+		bh := min(h, borderSize)
+		chf.paintRectRegion(0, bw, 0, h, uint16(id)|borderReg, srcReg)
+		id++
+		chf.paintRectRegion(w-bw, w, 0, h, uint16(id)|borderReg, srcReg)
+		id++
+		chf.paintRectRegion(0, w, 0, bh, uint16(id)|borderReg, srcReg)
+		id++
+		chf.paintRectRegion(0, w, h-bh, h, uint16(id)|borderReg, srcReg)
+		id++
+	}
+
+	sweeps := []sweepSpan{}
+	for z := borderSize; z < h-borderSize; z++ {
+		prev := make([]int, id+1)
+		rid := 1
+		for x := borderSize; x < w-borderSize; x++ {
+			cell := chf.cells[x+z*w]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if chf.areas[i] == nullArea {
+					continue
+				}
+
+				// -x
+				previd := 0
+				ai := chf.neighbor(x, z, i, 0)
+				if ai != -1 && srcReg[ai]&borderReg == 0 && chf.areas[i] == chf.areas[ai] {
+					previd = int(srcReg[ai])
+				}
+				if previd == 0 {
+					previd = rid
+					rid++
+					for len(sweeps) <= previd {
+						sweeps = append(sweeps, sweepSpan{})
+					}
+					sweeps[previd] = sweepSpan{rid: uint16(previd)}
+				}
+
+				// -z
+				ai = chf.neighbor(x, z, i, 3)
+				if ai != -1 && srcReg[ai] != 0 && srcReg[ai]&borderReg == 0 && chf.areas[i] == chf.areas[ai] {
+					nr := srcReg[ai]
+					if sweeps[previd].nei == 0 || sweeps[previd].nei == nr {
+						sweeps[previd].nei = nr
+						sweeps[previd].ns++
+						prev[nr]++
+					} else {
+						sweeps[previd].nei = nullNei
+					}
+				}
+				srcReg[i] = uint16(previd)
+			}
+		}
+
+		// continue a region from the previous row only when the connection is unique both ways
+		for i := 1; i < rid; i++ {
+			if sweeps[i].nei != nullNei && sweeps[i].nei != 0 && prev[sweeps[i].nei] == sweeps[i].ns {
+				sweeps[i].id = sweeps[i].nei
+				continue
+			}
+			if id >= borderReg {
+				return fmt.Errorf("region id overflow")
+			}
+			sweeps[i].id = uint16(id)
+			id++
+		}
+
+		for x := borderSize; x < w-borderSize; x++ {
+			cell := chf.cells[x+z*w]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if srcReg[i] > 0 && int(srcReg[i]) < rid {
+					srcReg[i] = sweeps[srcReg[i]].id
+				}
+			}
+		}
+	}
+
+	chf.filterRegions(srcReg, id, minRegionArea)
+	for i := range chf.spans {
+		chf.spans[i].reg = srcReg[i]
+	}
+	return nil
+}
+
+func (chf *compactHeightfield) paintRectRegion(minx, maxx, minz, maxz int, regID uint16, srcReg []uint16) {
+	for z := minz; z < maxz; z++ {
+		for x := minx; x < maxx; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				if chf.areas[i] != nullArea {
+					srcReg[i] = regID
+				}
+			}
+		}
+	}
+}
+
+// filterRegions removes islands of regions smaller than minRegionArea and regions that overlap themselves in a column
+func (chf *compactHeightfield) filterRegions(srcReg []uint16, regionCount int, minRegionArea int) {
+	spanCount := make([]int, regionCount)
+	overlap := make([]bool, regionCount)
+	connectsToBorder := make([]bool, regionCount)
+	neighbors := make([]map[uint16]bool, regionCount)
+
+	for z := 0; z < chf.height; z++ {
+		for x := 0; x < chf.width; x++ {
+			cell := chf.cells[x+z*chf.width]
+			for i := int(cell.index); i < int(cell.index+cell.count); i++ {
+				r := srcReg[i]
+				if r == 0 || r&borderReg != 0 {
+					continue
+				}
+				spanCount[r]++
+				for j := int(cell.index); j < i; j++ {
+					if srcReg[j] == r {
+						overlap[r] = true
+					}
+				}
+				for dir := 0; dir < 4; dir++ {
+					ni := chf.neighbor(x, z, i, dir)
+					if ni == -1 {
+						continue
+					}
+					nr := srcReg[ni]
+					if nr == 0 || nr == r {
+						continue
+					}
+					if nr&borderReg != 0 {
+						connectsToBorder[r] = true
+						continue
+					}
+					if neighbors[r] == nil {
+						neighbors[r] = make(map[uint16]bool)
+					}
+					neighbors[r][nr] = true
+				}
+			}
+		}
+	}
+
+	remove := make([]bool, regionCount)
+	visited := make([]bool, regionCount)
+	for r := 1; r < regionCount; r++ {
+		if visited[r] || spanCount[r] == 0 {
+			continue
+		}
+		island := []uint16{}
+		stack := []uint16{uint16(r)}
+		visited[r] = true
+		total := 0
+		touchesBorder := false
+		for len(stack) > 0 {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			island = append(island, cur)
+			total += spanCount[cur]
+			if connectsToBorder[cur] {
+				touchesBorder = true
+			}
+			for nr := range neighbors[cur] {
+				if visited[nr] {
+					continue
+				}
+				visited[nr] = true
+				stack = append(stack, nr)
+			}
+		}
+		if total >= minRegionArea || touchesBorder {
+			continue
+		}
+		for _, ir := range island {
+			remove[ir] = true
+		}
+	}
+
+	for i, r := range srcReg {
+		if r == 0 || r&borderReg != 0 {
+			continue
+		}
+		if remove[r] || overlap[r] {
+			srcReg[i] = 0
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package navmesh
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xackery/quail/raw"
+)
+
+// createTile converts a poly mesh and its detail mesh into a detour tile, like dtCreateNavMeshData
+func (b *builder) createTile(tx, tz int, pmesh *polyMesh, dmesh *detailMesh) (*raw.NavTile, error) {
+	if len(pmesh.verts) >= 0xffff {
+		return nil, fmt.Errorf("too many vertices %d", len(pmesh.verts))
+	}
+
+	tile := &raw.NavTile{
+		X:              int32(tx),
+		Y:              int32(tz),
+		WalkableHeight: b.cfg.AgentHeight,
+		WalkableRadius: b.cfg.AgentRadius,
+		WalkableClimb:  b.cfg.AgentMaxClimb,
+		BMin:           pmesh.bmin,
+		BMax:           pmesh.bmax,
+		BVQuantFactor:  1 / pmesh.cs,
+		OffMeshBase:    int32(len(pmesh.polys)),
+	}
+
+	for _, v := range pmesh.verts {
+		tile.Verts = append(tile.Verts, [3]float32{
+			pmesh.bmin[0] + float32(v[0])*pmesh.cs,
+			pmesh.bmin[1] + float32(v[1])*pmesh.ch,
+			pmesh.bmin[2] + float32(v[2])*pmesh.cs,
+		})
+	}
+
+	edgeCount := 0
+	portalCount := 0
+	for i, p := range pmesh.polys {
+		nv := polyVertCount(p)
+		area := Area(0)
+		if pmesh.areas[i] != nullArea {
+			area = Area(pmesh.areas[i] - 1)
+		}
+		poly := &raw.NavPoly{
+			VertCount: uint8(nv),
+			Area:      uint8(area),
+			Flags:     1 << area,
+		}
+		for j := 0; j < nv; j++ {
+			poly.Verts[j] = p[j]
+			nei := p[vertsPerPoly+j]
+			edgeCount++
+			if nei&portalNeiFlag == 0 {
+				// internal edge, detour stores neighbor index + 1 and 0 for none
+				poly.Neis[j] = nei + 1
+				continue
+			}
+			switch nei & 0xf {
+			case 0:
+				poly.Neis[j] = raw.NavExtLink | 4
+			case 1:
+				poly.Neis[j] = raw.NavExtLink | 2
+			case 2:
+				poly.Neis[j] = raw.NavExtLink | 0
+			case 3:
+				poly.Neis[j] = raw.NavExtLink | 6
+			default:
+				continue
+			}
+			portalCount++
+		}
+		tile.Polys = append(tile.Polys, poly)
+	}
+	tile.Links = make([]raw.NavLink, edgeCount+portalCount*2)
+
+	for i, m := range dmesh.meshes {
+		nv := uint32(polyVertCount(pmesh.polys[i]))
+		tile.DetailMeshes = append(tile.DetailMeshes, raw.NavPolyDetail{
+			VertBase:  uint32(len(tile.DetailVerts)),
+			VertCount: uint8(m[1] - nv),
+			TriBase:   m[2],
+			TriCount:  uint8(m[3]),
+		})
+		tile.DetailVerts = append(tile.DetailVerts, dmesh.verts[m[0]+nv:m[0]+m[1]]...)
+	}
+	tile.DetailTris = append(tile.DetailTris, dmesh.tris...)
+
+	tile.BVTree = createBVTree(tile, dmesh)
+	return tile, nil
+}
+
+type bvItem struct {
+	bmin [3]uint16
+	bmax [3]uint16
+	i    int
+}
+
+// createBVTree builds a bounding volume tree over each polygon's detail mesh, quantized to cells
+func createBVTree(tile *raw.NavTile, dmesh *detailMesh) []raw.NavBVNode {
+	quant := tile.BVQuantFactor
+	items := make([]bvItem, len(dmesh.meshes))
+	for i, m := range dmesh.meshes {
+		bmin := dmesh.verts[m[0]]
+		bmax := bmin
+		for _, v := range dmesh.verts[m[0]+1 : m[0]+m[1]] {
+			for k := 0; k < 3; k++ {
+				bmin[k] = min(bmin[k], v[k])
+				bmax[k] = max(bmax[k], v[k])
+			}
+		}
+		items[i].i = i
+		for k := 0; k < 3; k++ {
+			items[i].bmin[k] = uint16(clamp(int((bmin[k]-tile.BMin[k])*quant), 0, 0xffff))
+			items[i].bmax[k] = uint16(clamp(int((bmax[k]-tile.BMin[k])*quant), 0, 0xffff))
+		}
+	}
+
+	nodes := make([]raw.NavBVNode, 0, len(items)*2)
+	var subdivide func(imin, imax int)
+	subdivide = func(imin, imax int) {
+		icur := len(nodes)
+		nodes = append(nodes, raw.NavBVNode{})
+		if imax-imin == 1 {
+			nodes[icur].BMin = items[imin].bmin
+			nodes[icur].BMax = items[imin].bmax
+			nodes[icur].I = int32(items[imin].i)
+			return
+		}
+
+		bmin := items[imin].bmin
+		bmax := items[imin].bmax
+		for _, it := range items[imin+1 : imax] {
+			for k := 0; k < 3; k++ {
+				bmin[k] = min(bmin[k], it.bmin[k])
+				bmax[k] = max(bmax[k], it.bmax[k])
+			}
+		}
+		nodes[icur].BMin = bmin
+		nodes[icur].BMax = bmax
+
+		axis := 0
+		if bmax[1]-bmin[1] > bmax[axis]-bmin[axis] {
+			axis = 1
+		}
+		if bmax[2]-bmin[2] > bmax[axis]-bmin[axis] {
+			axis = 2
+		}
+		sub := items[imin:imax]
+		sort.SliceStable(sub, func(a, b int) bool {
+			return sub[a].bmin[axis] < sub[b].bmin[axis]
+		})
+
+		isplit := imin + (imax-imin)/2
+		subdivide(imin, isplit)
+		subdivide(isplit, imax)
+		// negative index is the escape distance past this node's subtree
+		nodes[icur].I = -int32(len(nodes) - icur)
+	}
+	if len(items) > 0 {
+		subdivide(0, len(items))
+	}
+	return nodes
+}
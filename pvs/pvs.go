@@ -0,0 +1,270 @@
+// Package pvs computes which regions of a zone can see each other, for the vis lists of a bsp.
+// Sample points are taken on a grid and from seed points, classified into regions, and two
+// regions see each other when a ray between their samples is not blocked by the occluders.
+// Sampling can miss a thin gap, so regions that touch, and regions without samples, are always
+// treated as visible
+package pvs
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/xackery/quail/bake"
+)
+
+// Config controls how many samples and rays are used
+type Config struct {
+	Spacing     float32 // distance between grid samples
+	Samples     int     // most samples kept per region
+	Rays        int     // most rays cast between two regions before they are hidden
+	MaxDistance float32 // regions farther apart than this are hidden, 0 for no limit
+}
+
+// DefaultConfig returns a config suited to most zones
+func DefaultConfig() *Config {
+	return &Config{
+		Spacing: 32,
+		Samples: 24,
+		Rays:    32,
+	}
+}
+
+// Zone is what visibility is computed for
+type Zone struct {
+	RegionCount int
+	RegionAt    func(point [3]float32) int // region index of a point, or -1 outside of every region
+	Min         [3]float32                 // bounds of the grid samples
+	Max         [3]float32
+	Seeds       [][3]float32 // extra sample points, such as just above the floor of each region
+	Occluders   *bake.Scene  // triangles that block sight, nil to see through everything
+}
+
+// Validate returns an error if cfg can not be used
+func (cfg *Config) Validate() error {
+	if cfg.Spacing <= 0 {
+		return fmt.Errorf("spacing %0.2f must be above 0", cfg.Spacing)
+	}
+	if cfg.Samples < 1 {
+		return fmt.Errorf("samples %d must be at least 1", cfg.Samples)
+	}
+	if cfg.Rays < 1 {
+		return fmt.Errorf("rays %d must be at least 1", cfg.Rays)
+	}
+	if cfg.MaxDistance < 0 {
+		return fmt.Errorf("max distance %0.2f is negative", cfg.MaxDistance)
+	}
+	return nil
+}
+
+// Build returns the sorted indices of regions visible from each region, which always includes itself
+func Build(zone *Zone, cfg *Config) ([][]int, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if zone.RegionAt == nil {
+		return nil, fmt.Errorf("zone has no region lookup")
+	}
+	for i := 0; i < 3; i++ {
+		if zone.Max[i] < zone.Min[i] {
+			return nil, fmt.Errorf("bounds max %v is below min %v", zone.Max, zone.Min)
+		}
+	}
+	count := zone.RegionCount
+	if count == 0 {
+		return [][]int{}, nil
+	}
+
+	s := &sampler{cfg: cfg, samples: make([][][3]float32, count), seen: make([]int, count), visible: make([][]bool, count), random: 0x9e3779b97f4a7c15}
+	for i := range s.visible {
+		s.visible[i] = make([]bool, count)
+		s.visible[i][i] = true
+	}
+	s.grid(zone)
+	for _, seed := range zone.Seeds {
+		s.add(zone.RegionAt(seed), seed)
+	}
+
+	lows := make([][3]float32, count)
+	highs := make([][3]float32, count)
+	for i, samples := range s.samples {
+		for j, sample := range samples {
+			if j == 0 {
+				lows[i], highs[i] = sample, sample
+				continue
+			}
+			for k := 0; k < 3; k++ {
+				lows[i][k] = min(lows[i][k], sample[k])
+				highs[i][k] = max(highs[i][k], sample[k])
+			}
+		}
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				for j := i + 1; j < count; j++ {
+					if s.visible[i][j] || len(s.samples[i]) == 0 || len(s.samples[j]) == 0 {
+						continue
+					}
+					if cfg.MaxDistance > 0 && boxDistance(lows[i], highs[i], lows[j], highs[j]) > cfg.MaxDistance {
+						continue
+					}
+					s.visible[i][j] = s.isVisible(zone.Occluders, s.samples[i], s.samples[j])
+				}
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	lists := make([][]int, count)
+	for i := 0; i < count; i++ {
+		for j := 0; j < count; j++ {
+			isVisible := s.visible[min(i, j)][max(i, j)]
+			// a region without samples could be anywhere, so it sees and is seen by all
+			if len(s.samples[i]) == 0 || len(s.samples[j]) == 0 {
+				isVisible = true
+			}
+			if isVisible {
+				lists[i] = append(lists[i], j)
+			}
+		}
+	}
+	return lists, nil
+}
+
+// sampler keeps the samples of each region and which regions are visible, i below j
+type sampler struct {
+	cfg     *Config
+	samples [][][3]float32
+	seen    []int // samples offered to each region, for reservoir sampling
+	visible [][]bool
+	random  uint64
+}
+
+// grid samples the bounds and marks regions with neighboring samples visible to each other
+func (s *sampler) grid(zone *Zone) {
+	steps := [3]int{}
+	for i := range steps {
+		steps[i] = int(math.Floor(float64((zone.Max[i]-zone.Min[i])/s.cfg.Spacing))) + 1
+	}
+	// the previous row and layer, to find touching regions
+	prevRow := make([]int, steps[0])
+	prevLayer := make([]int, steps[0]*steps[1])
+	for i := range prevLayer {
+		prevLayer[i] = -1
+	}
+	for z := 0; z < steps[2]; z++ {
+		for i := range prevRow {
+			prevRow[i] = -1
+		}
+		for y := 0; y < steps[1]; y++ {
+			prev := -1
+			for x := 0; x < steps[0]; x++ {
+				point := [3]float32{
+					zone.Min[0] + float32(x)*s.cfg.Spacing,
+					zone.Min[1] + float32(y)*s.cfg.Spacing,
+					zone.Min[2] + float32(z)*s.cfg.Spacing,
+				}
+				region := zone.RegionAt(point)
+				s.add(region, point)
+				layerIndex := y*steps[0] + x
+				for _, other := range []int{prev, prevRow[x], prevLayer[layerIndex]} {
+					s.touch(region, other)
+				}
+				prev = region
+				prevRow[x] = region
+				prevLayer[layerIndex] = region
+			}
+		}
+	}
+}
+
+// add offers a sample to a region, keeping an even spread of at most cfg.Samples
+func (s *sampler) add(region int, point [3]float32) {
+	if region < 0 || region >= len(s.samples) {
+		return
+	}
+	s.seen[region]++
+	if len(s.samples[region]) < s.cfg.Samples {
+		s.samples[region] = append(s.samples[region], point)
+		return
+	}
+	// a fixed xorshift keeps builds repeatable
+	s.random ^= s.random << 13
+	s.random ^= s.random >> 7
+	s.random ^= s.random << 17
+	slot := int(s.random % uint64(s.seen[region]))
+	if slot < s.cfg.Samples {
+		s.samples[region][slot] = point
+	}
+}
+
+// touch marks two neighboring regions visible to each other
+func (s *sampler) touch(a, b int) {
+	if a < 0 || b < 0 || a == b || a >= len(s.visible) || b >= len(s.visible) {
+		return
+	}
+	s.visible[min(a, b)][max(a, b)] = true
+}
+
+// isVisible returns true if any of up to cfg.Rays rays between two sample sets is not blocked
+func (s *sampler) isVisible(occluders *bake.Scene, from, to [][3]float32) bool {
+	pairs := len(from) * len(to)
+	rays := min(pairs, s.cfg.Rays)
+	// stepping by a coprime of the pair count spreads the rays over all pairs of samples
+	step := pairs*5/8 | 1
+	for gcd(step, pairs) != 1 {
+		step++
+	}
+	for k := 0; k < rays; k++ {
+		pair := k * step % pairs
+		if !occluders.IsOccluded(from[pair%len(from)], to[pair/len(from)]) {
+			return true
+		}
+	}
+	return false
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// boxDistance returns the distance between two boxes, 0 if they overlap
+func boxDistance(minA, maxA, minB, maxB [3]float32) float32 {
+	sum := float32(0)
+	for i := 0; i < 3; i++ {
+		gap := max(minA[i]-maxB[i], minB[i]-maxA[i], 0)
+		sum += gap * gap
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+// Stats returns how many regions see each other on average and the share of all regions that is
+func Stats(lists [][]int) (float64, float64) {
+	if len(lists) == 0 {
+		return 0, 0
+	}
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+	average := float64(total) / float64(len(lists))
+	return average, average / float64(len(lists))
+}
@@ -0,0 +1,84 @@
+package pvs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xackery/quail/bake"
+)
+
+// corridorZone is three regions along x with a wall across the middle one at x 0
+func corridorZone(isWalled bool) *Zone {
+	zone := &Zone{
+		RegionCount: 3,
+		RegionAt: func(point [3]float32) int {
+			switch {
+			case point[0] < -10:
+				return 0
+			case point[0] < 10:
+				return 1
+			default:
+				return 2
+			}
+		},
+		Min: [3]float32{-30, -10, -10},
+		Max: [3]float32{30, 10, 10},
+	}
+	if isWalled {
+		zone.Occluders = bake.NewScene([][3]float32{{0, -50, -50}, {0, 50, -50}, {0, 50, 50}, {0, -50, 50}}, []uint32{0, 1, 2, 0, 2, 3})
+	}
+	return zone
+}
+
+func TestBuild(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Spacing = 4
+
+	lists, err := Build(corridorZone(true), cfg)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+	want := [][]int{{0, 1}, {0, 1, 2}, {1, 2}}
+	if !reflect.DeepEqual(lists, want) {
+		t.Fatalf("walled: got %v, want %v", lists, want)
+	}
+
+	lists, err = Build(corridorZone(false), cfg)
+	if err != nil {
+		t.Fatalf("build open: %s", err)
+	}
+	if len(lists[0]) != 3 {
+		t.Fatalf("open: got %v, want all visible", lists)
+	}
+
+	// far regions are hidden even without a wall
+	cfg.MaxDistance = 10
+	lists, err = Build(corridorZone(false), cfg)
+	if err != nil {
+		t.Fatalf("build far: %s", err)
+	}
+	if !reflect.DeepEqual(lists, want) {
+		t.Fatalf("far: got %v, want %v", lists, want)
+	}
+
+	// a region without samples could be anywhere
+	zone := corridorZone(true)
+	zone.RegionCount = 4
+	lists, err = Build(zone, cfg)
+	if err != nil {
+		t.Fatalf("build unsampled: %s", err)
+	}
+	if len(lists[3]) != 4 || lists[0][len(lists[0])-1] != 3 {
+		t.Fatalf("unsampled: got %v", lists)
+	}
+
+	average, share := Stats([][]int{{0}, {0, 1}})
+	if average != 1.5 || share != 0.75 {
+		t.Fatalf("stats: got %f %f", average, share)
+	}
+	cfg.Rays = 0
+	_, err = Build(corridorZone(true), cfg)
+	if err == nil {
+		t.Fatalf("no rays: want error")
+	}
+}
@@ -0,0 +1,24 @@
+package quail
+
+import (
+	"fmt"
+
+	"github.com/xackery/quail/wce"
+)
+
+// Character returns the playable race model with the race code code, such as ELF, and the heads,
+// faces and armor texture sets it can be drawn with
+func (q *Quail) Character(code string) (*wce.Character, error) {
+	if q.Wld == nil {
+		return nil, fmt.Errorf("no models loaded")
+	}
+	return q.Wld.Character(code)
+}
+
+// Characters returns the race code of every playable race model, such as ELF
+func (q *Quail) Characters() ([]string, error) {
+	if q.Wld == nil {
+		return nil, fmt.Errorf("no models loaded")
+	}
+	return q.Wld.Characters()
+}
@@ -20,33 +20,36 @@ func (q *Quail) JsonRead(path string) error {
 		return fmt.Errorf("path %s is a directory, but should be a file", path+"/_root.wce")
 	}
 
-	baseFilePath := filepath.Base(path)
-	baseName := strings.TrimSuffix(baseFilePath, ".json")
+	basePath := strings.TrimSuffix(path, filepath.Ext(path))
+	baseName := filepath.Base(basePath)
 
-	q.Wld, err = wce.ReadJSON(baseName+".wld", baseFilePath)
+	q.Wld, err = wce.ReadJSON(baseName+".wld", path)
 	if err != nil {
 		return fmt.Errorf("json read: %w", err)
 	}
-	lightsPath := baseName + "_lights.json"
-	lights, err := os.Stat(lightsPath)
+	lightsPath := basePath + "_lights.json"
+	_, err = os.Stat(lightsPath)
 	if err == nil {
-		q.WldLights, err = wce.ReadJSON("lights.wld", lights.Name())
+		q.WldLights, err = wce.ReadJSON("lights.wld", lightsPath)
 		if err != nil {
 			return fmt.Errorf("json lights read: %w", err)
 		}
 	}
 
-	objectsPath := baseName + "_objects.json"
-	objects, err := os.Stat(objectsPath)
+	objectsPath := basePath + "_objects.json"
+	_, err = os.Stat(objectsPath)
 	if err == nil {
-		q.WldObject, err = wce.ReadJSON("objects.wld", objects.Name())
+		q.WldObject, err = wce.ReadJSON("objects.wld", objectsPath)
 		if err != nil {
 			return fmt.Errorf("json objects read: %w", err)
 		}
 	}
 
-	dirs, err := os.ReadDir(baseName)
+	dirs, err := os.ReadDir(basePath)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
 	for _, dir := range dirs {
@@ -55,7 +58,7 @@ func (q *Quail) JsonRead(path string) error {
 		}
 		ext := strings.ToLower(filepath.Ext(dir.Name()))
 		if ext == ".bmp" || ext == ".dds" || ext == ".png" || ext == ".jpg" {
-			textureData, err := os.ReadFile(baseName + "/" + dir.Name())
+			textureData, err := os.ReadFile(filepath.Join(basePath, dir.Name()))
 			if err != nil {
 				fmt.Println("Text", err)
 				continue
@@ -0,0 +1,70 @@
+package quail
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/wce"
+)
+
+func TestJsonReadWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	src := New()
+	src.Wld = wce.New("box_chr.wld")
+	src.Wld.SimpleSpriteDefs = append(src.Wld.SimpleSpriteDefs, &wce.SimpleSpriteDef{
+		Tag:                "BOX_SPRITE",
+		SimpleSpriteFrames: []wce.SimpleSpriteFrame{{TextureTag: "BOX", TextureFiles: []string{"BOX.BMP"}}},
+	})
+	src.Wld.MaterialDefs = append(src.Wld.MaterialDefs, &wce.MaterialDef{
+		Tag:             "BOX_MDF",
+		RenderMethod:    "TRANSPARENT",
+		SimpleSpriteTag: "BOX_SPRITE",
+	})
+	src.Wld.MaterialPalettes = append(src.Wld.MaterialPalettes, &wce.MaterialPalette{Tag: "BOX_MP", Materials: []string{"BOX_MDF"}})
+	src.Assets = map[string][]byte{"box.bmp": bytes.Repeat([]byte("BM"), 100)}
+
+	err := src.JsonWrite(filepath.Join(dir, "box_chr.json"))
+	if err != nil {
+		t.Fatalf("json write: %s", err)
+	}
+	dst := New()
+	err = dst.JsonRead(filepath.Join(dir, "box_chr.json"))
+	if err != nil {
+		t.Fatalf("json read: %s", err)
+	}
+
+	err = src.PfsWrite(1, 1, filepath.Join(dir, "src.s3d"))
+	if err != nil {
+		t.Fatalf("pfs write: %s", err)
+	}
+	err = dst.PfsWrite(1, 1, filepath.Join(dir, "dst.s3d"))
+	if err != nil {
+		t.Fatalf("pfs write from json: %s", err)
+	}
+
+	want, err := pfs.NewFile(filepath.Join(dir, "src.s3d"))
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer want.Close()
+	got, err := pfs.NewFile(filepath.Join(dir, "dst.s3d"))
+	if err != nil {
+		t.Fatalf("open from json: %s", err)
+	}
+	defer got.Close()
+	if got.Len() != want.Len() {
+		t.Fatalf("s3d from json has %d files, want %d", got.Len(), want.Len())
+	}
+	for _, file := range want.Files() {
+		data, err := got.File(file.Name())
+		if err != nil {
+			t.Fatalf("s3d from json: %s", err)
+		}
+		if !bytes.Equal(data, file.Data()) {
+			t.Fatalf("s3d from json: %s differs", file.Name())
+		}
+	}
+}
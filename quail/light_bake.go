@@ -0,0 +1,111 @@
+package quail
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/xackery/quail/bake"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+// Lights returns the point lights of the zone and the color of its global ambient light, which is
+// false if the zone has none
+func (q *Quail) Lights() ([]bake.Light, [3]float32, bool) {
+	wlds := []*wce.Wce{}
+	for _, wld := range []*wce.Wce{q.Wld, q.WldLights} {
+		if wld != nil {
+			wlds = append(wlds, wld)
+		}
+	}
+	lights := []bake.Light{}
+	for _, wld := range wlds {
+		lights = append(lights, wld.Lights(wlds...)...)
+	}
+	if q.Wld == nil {
+		return lights, [3]float32{}, false
+	}
+	ambient, ok := q.Wld.LightAmbient()
+	return lights, ambient, ok
+}
+
+// LightMeshes returns the meshes of the zone that take baked lighting. Actor instances find their
+// model in the zone first and then in models, such as an _obj.s3d
+func (q *Quail) LightMeshes(models ...*Quail) ([]*wce.LightMesh, error) {
+	modelWlds := []*wce.Wce{}
+	if q.Wld != nil {
+		modelWlds = append(modelWlds, q.Wld)
+	}
+	for _, model := range models {
+		if model != nil && model.Wld != nil {
+			modelWlds = append(modelWlds, model.Wld)
+		}
+	}
+	meshes := []*wce.LightMesh{}
+	for _, wld := range []*wce.Wce{q.Wld, q.WldObject} {
+		if wld == nil {
+			continue
+		}
+		wldMeshes, err := wld.LightMeshes(modelWlds...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", wld.FileName, err)
+		}
+		meshes = append(meshes, wldMeshes...)
+	}
+	return meshes, nil
+}
+
+// LightBake bakes vertex lighting for meshes with baker. Colors go to region sprites, rgb tracks
+// and zon lits, or to .lit assets for meshes with a LitName
+func (q *Quail) LightBake(baker *bake.Baker, meshes []*wce.LightMesh) error {
+	err := baker.Validate()
+	if err != nil {
+		return fmt.Errorf("baker: %w", err)
+	}
+	for _, mesh := range meshes {
+		colors := baker.Bake(mesh.Positions, mesh.Normals)
+		err = mesh.SetColors(colors)
+		if err != nil {
+			return fmt.Errorf("set colors: %w", err)
+		}
+		if mesh.LitName == "" {
+			continue
+		}
+		lit := &raw.Lit{}
+		for _, color := range colors {
+			lit.Entries = append(lit.Entries, wce.LitEntry(color))
+		}
+		buf := &bytes.Buffer{}
+		err = lit.Write(buf)
+		if err != nil {
+			return fmt.Errorf("lit %s: %w", mesh.LitName, err)
+		}
+		name := mesh.LitName
+		for assetName := range q.Assets {
+			if strings.EqualFold(assetName, name) {
+				name = assetName
+				break
+			}
+		}
+		err = q.assetAdd(name, buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("lit %s: %w", mesh.LitName, err)
+		}
+	}
+	return nil
+}
+
+// LightScene returns the triangles of meshes as a scene that casts shadows
+func LightScene(meshes []*wce.LightMesh) *bake.Scene {
+	positions := [][3]float32{}
+	indices := []uint32{}
+	for _, mesh := range meshes {
+		base := uint32(len(positions))
+		positions = append(positions, mesh.Positions...)
+		for _, index := range mesh.Indices {
+			indices = append(indices, base+index)
+		}
+	}
+	return bake.NewScene(positions, indices)
+}
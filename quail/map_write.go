@@ -0,0 +1,47 @@
+package quail
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+// MapExport appends the collision geometry of every loaded wld to an EQEmu map
+func (q *Quail) MapExport(dst *raw.Map) error {
+	if q == nil {
+		return fmt.Errorf("quail is nil")
+	}
+	for _, wld := range []*wce.Wce{q.Wld, q.WldObject} {
+		if wld == nil {
+			continue
+		}
+		err := wld.WriteMapRaw(dst)
+		if err != nil {
+			return fmt.Errorf("%s: %w", wld.FileName, err)
+		}
+	}
+	return nil
+}
+
+// MapWrite writes an EQEmu map file of the loaded zone
+func (q *Quail) MapWrite(path string) error {
+	dst := &raw.Map{}
+	err := q.MapExport(dst)
+	if err != nil {
+		return fmt.Errorf("map export: %w", err)
+	}
+
+	w, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer w.Close()
+
+	err = dst.Write(w)
+	if err != nil {
+		return fmt.Errorf("map write: %w", err)
+	}
+	return nil
+}
@@ -45,7 +45,14 @@ func (e *Quail) EQGExport(fileVersion uint32, pfsVersion int, path string) error
 		return fmt.Errorf("no wld found")
 	}
 
-	err = e.Wld.WriteEqgRaw(archive)
+	parts := []*wce.Wce{}
+	if e.WldObject != nil {
+		parts = append(parts, e.WldObject)
+	}
+	if e.WldLights != nil {
+		parts = append(parts, e.WldLights)
+	}
+	err = e.Wld.WriteEqgRaw(archive, parts...)
 	if err != nil {
 		return fmt.Errorf("write eqg: %w", err)
 	}
@@ -0,0 +1,42 @@
+package raw
+
+import "github.com/xackery/quail/anim"
+
+// Process resamples, reduces and smooths the loop of every bone, as opts ask
+func (ani *Ani) Process(opts anim.Options) {
+	if opts.IsZero() {
+		return
+	}
+	ani.bonesApply(opts.Process)
+}
+
+// Resample spreads the frames of every bone evenly, at rate frames per second
+func (ani *Ani) Resample(rate float64) {
+	ani.Process(anim.Options{Rate: rate})
+}
+
+// Reduce removes frames the frames around them reproduce, within angle radians and distance units
+func (ani *Ani) Reduce(angle float64, distance float32) {
+	ani.Process(anim.Options{Angle: angle, Distance: distance})
+}
+
+// SmoothLoop eases the last blend milliseconds of every bone into its first frame
+func (ani *Ani) SmoothLoop(blend uint32) {
+	ani.Process(anim.Options{LoopBlend: blend})
+}
+
+func (ani *Ani) bonesApply(fn func(keys []anim.Key) []anim.Key) {
+	for _, bone := range ani.Bones {
+		keys := []anim.Key{}
+		for _, frame := range bone.Frames {
+			keys = append(keys, anim.Key(*frame))
+		}
+		keys = fn(keys)
+		frames := []*AniBoneFrame{}
+		for _, key := range keys {
+			frame := AniBoneFrame(key)
+			frames = append(frames, &frame)
+		}
+		bone.Frames = frames
+	}
+}
@@ -53,6 +53,10 @@ func (e *Dat) Read(r io.ReadSeeker) error {
 		e.DatType = DatTypeFloraExclude
 		e.DatFe = &DatFe{}
 		return e.DatFe.Read(r)
+	case "*WAT":
+		e.DatType = DatTypeWater
+		e.DatWtr = &DatWtr{}
+		return e.DatWtr.Read(r)
 
 	//case DatTypeUnknown:
 	//	return fmt.Errorf("unknown dat type")
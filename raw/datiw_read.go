@@ -1,20 +1,57 @@
 package raw
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
+
+	"github.com/xackery/encdec"
 )
 
+// DatIw is an invisible wall dat (invw.dat) of an eqg zone
 type DatIw struct {
 	MetaFileName string
 	Version      uint32
+	Walls        []*DatIwWall
+}
+
+// DatIwWall is a wall along a line of vertices, reaching up from each edge
+type DatIwWall struct {
+	Name     string
+	Flags    uint32
+	Vertices [][3]float32
 }
 
 func (e *DatIw) Identity() string {
 	return "datiw"
 }
 
+// Read reads an invisible wall dat file
+// https://github.com/EQEmu/zone-utilities/blob/master/src/common/eqg_v4_loader.cpp
 func (e *DatIw) Read(r io.ReadSeeker) error {
+	dec := encdec.NewDecoder(r, binary.LittleEndian)
+
+	wallCount := dec.Uint32()
+	if wallCount > 9999 {
+		return fmt.Errorf("wall count %d is too high", wallCount)
+	}
+	for i := 0; i < int(wallCount); i++ {
+		wall := &DatIwWall{}
+		wall.Name = dec.StringZero()
+		wall.Flags = dec.Uint32()
+		vertexCount := dec.Uint32()
+		if vertexCount > 99999 {
+			return fmt.Errorf("wall %d vertex count %d is too high", i, vertexCount)
+		}
+		for j := 0; j < int(vertexCount); j++ {
+			wall.Vertices = append(wall.Vertices, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+		}
+		e.Walls = append(e.Walls, wall)
+	}
 
+	if dec.Error() != nil {
+		return fmt.Errorf("read: %w", dec.Error())
+	}
 	return nil
 }
 
@@ -8,11 +8,23 @@ import (
 	"github.com/xackery/encdec"
 )
 
-// Encode encodes a v4 zone dat file
-// https://github.com/EQEmu/zone-utilities/blob/master/src/common/eqg_v4_loader.cpp#L115
+// Write writes an invisible wall dat file
+// https://github.com/EQEmu/zone-utilities/blob/master/src/common/eqg_v4_loader.cpp
 func (e *DatIw) Write(w io.Writer) error {
 	enc := encdec.NewEncoder(w, binary.LittleEndian)
 
+	enc.Uint32(uint32(len(e.Walls)))
+	for _, wall := range e.Walls {
+		enc.StringZero(wall.Name)
+		enc.Uint32(wall.Flags)
+		enc.Uint32(uint32(len(wall.Vertices)))
+		for _, vert := range wall.Vertices {
+			enc.Float32(vert[0])
+			enc.Float32(vert[1])
+			enc.Float32(vert[2])
+		}
+	}
+
 	err := enc.Error()
 	if err != nil {
 		return fmt.Errorf("encoder error: %w", err)
@@ -0,0 +1,318 @@
+package raw
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/encdec"
+)
+
+// MapVersion2 is the only EQEmu map version quail reads and writes
+const MapVersion2 = 0x02000000
+
+// mapQuadsPerTileMax bounds QuadsPerTile, so the per tile sizes can't overflow
+const mapQuadsPerTileMax = 1024
+
+// mapPlaceableSizeMin is a placeable with an empty model name
+const mapPlaceableSizeMin = 37
+
+// Map is an EQEmu server collision map, found in maps/base/<zone>.map
+// https://github.com/EQEmu/Server/blob/master/zone/map.cpp
+type Map struct {
+	MetaFileName    string
+	Version         uint32
+	Vertices        [][3]float32 // collidable vertices
+	Indices         []uint32     // collidable triangle indices, 3 per face
+	NCVertices      [][3]float32 // non-collidable vertices
+	NCIndices       []uint32     // non-collidable triangle indices, 3 per face
+	Models          []*MapModel
+	Placeables      []*MapPlaceable
+	PlaceableGroups []*MapPlaceableGroup
+	QuadsPerTile    uint32
+	UnitsPerVertex  float32
+	Tiles           []*MapTile
+	vertIndex       map[[3]float32]uint32
+	ncVertIndex     map[[3]float32]uint32
+}
+
+// MapModel is a mesh that is instanced by placeables
+type MapModel struct {
+	Name     string
+	Vertices [][3]float32
+	Faces    []MapModelFace
+}
+
+// MapModelFace is a triangle of a model
+type MapModelFace struct {
+	Index      [3]uint32
+	Collidable bool
+}
+
+// MapPlaceable is an instance of a model, rotation is in radians
+type MapPlaceable struct {
+	ModelName   string
+	Translation [3]float32
+	Rotation    [3]float32
+	Scale       [3]float32
+}
+
+// MapPlaceableGroup is a group of placeables sharing a transform
+type MapPlaceableGroup struct {
+	Translation [3]float32
+	Rotation    [3]float32
+	Scale       [3]float32
+	Tile        [3]float32
+	Placeables  []*MapPlaceable
+}
+
+// MapTile is a v4 terrain tile
+type MapTile struct {
+	Flat   bool
+	X      float32
+	Y      float32
+	Z      float32   // only used when flat
+	Floats []float32 // (QuadsPerTile+1)^2 heights, only used when not flat
+	Flags  []uint8   // QuadsPerTile^2 quad flags, only used when not flat
+}
+
+// Identity returns the type of the struct
+func (e *Map) Identity() string {
+	return "map"
+}
+
+func (e *Map) String() string {
+	out := ""
+	out += fmt.Sprintf("metafilename: %s\n", e.MetaFileName)
+	out += fmt.Sprintf("version: 0x%x\n", e.Version)
+	out += fmt.Sprintf("collidable vertices: %d\n", len(e.Vertices))
+	out += fmt.Sprintf("collidable faces: %d\n", len(e.Indices)/3)
+	out += fmt.Sprintf("non-collidable vertices: %d\n", len(e.NCVertices))
+	out += fmt.Sprintf("non-collidable faces: %d\n", len(e.NCIndices)/3)
+	out += fmt.Sprintf("models: %d\n", len(e.Models))
+	out += fmt.Sprintf("placeables: %d\n", len(e.Placeables))
+	out += fmt.Sprintf("placeable groups: %d\n", len(e.PlaceableGroups))
+	out += fmt.Sprintf("tiles: %d\n", len(e.Tiles))
+	return out
+}
+
+// Read reads a v2 map file
+func (e *Map) Read(r io.ReadSeeker) error {
+	dec := encdec.NewDecoder(r, binary.LittleEndian)
+	e.Version = dec.Uint32()
+	if e.Version != MapVersion2 {
+		return fmt.Errorf("invalid version 0x%x, wanted 0x%x", e.Version, MapVersion2)
+	}
+
+	dataSize := dec.Uint32()
+	bufferSize := dec.Uint32()
+	data := dec.Bytes(int(dataSize))
+	if dec.Error() != nil {
+		return fmt.Errorf("read header: %w", dec.Error())
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("zlib: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	// read one byte past the header size, so a zlib bomb is caught without inflating all of it
+	_, err = io.Copy(buf, io.LimitReader(zr, int64(bufferSize)+1))
+	if err != nil {
+		return fmt.Errorf("inflate: %w", err)
+	}
+	if buf.Len() != int(bufferSize) {
+		return fmt.Errorf("inflated size %d does not match header size %d", buf.Len(), bufferSize)
+	}
+
+	dec = encdec.NewDecoder(bytes.NewReader(buf.Bytes()), binary.LittleEndian)
+	// countCheck rejects a count of elements that can't fit in the rest of the inflated buffer
+	countCheck := func(name string, count uint32, size int) error {
+		remaining := int64(bufferSize) - dec.Pos()
+		if int64(count) > remaining/int64(size) {
+			return fmt.Errorf("%s %d exceeds remaining %d bytes", name, count, remaining)
+		}
+		return nil
+	}
+
+	vertCount := dec.Uint32()
+	indCount := dec.Uint32()
+	ncVertCount := dec.Uint32()
+	ncIndCount := dec.Uint32()
+	modelCount := dec.Uint32()
+	placeableCount := dec.Uint32()
+	placeableGroupCount := dec.Uint32()
+	tileCount := dec.Uint32()
+	e.QuadsPerTile = dec.Uint32()
+	e.UnitsPerVertex = dec.Float32()
+	if dec.Error() != nil {
+		return fmt.Errorf("read counts: %w", dec.Error())
+	}
+	if e.QuadsPerTile > mapQuadsPerTileMax {
+		return fmt.Errorf("quads per tile %d exceeds %d", e.QuadsPerTile, mapQuadsPerTileMax)
+	}
+
+	err = countCheck("vertex count", vertCount, 12)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(vertCount); i++ {
+		e.Vertices = append(e.Vertices, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+	}
+	err = countCheck("index count", indCount, 4)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(indCount); i++ {
+		e.Indices = append(e.Indices, dec.Uint32())
+	}
+	err = countCheck("non-collidable vertex count", ncVertCount, 12)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(ncVertCount); i++ {
+		e.NCVertices = append(e.NCVertices, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+	}
+	err = countCheck("non-collidable index count", ncIndCount, 4)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(ncIndCount); i++ {
+		e.NCIndices = append(e.NCIndices, dec.Uint32())
+	}
+	if dec.Error() != nil {
+		return fmt.Errorf("read vertices: %w", dec.Error())
+	}
+
+	// a model is at least a terminated name and two counts
+	err = countCheck("model count", modelCount, 9)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(modelCount); i++ {
+		model := &MapModel{}
+		model.Name = dec.StringZero()
+		modelVertCount := dec.Uint32()
+		modelFaceCount := dec.Uint32()
+		if dec.Error() != nil {
+			return fmt.Errorf("read model %d: %w", i, dec.Error())
+		}
+		err = countCheck(fmt.Sprintf("model %d vertex count", i), modelVertCount, 12)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < int(modelVertCount); j++ {
+			model.Vertices = append(model.Vertices, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+		}
+		err = countCheck(fmt.Sprintf("model %d face count", i), modelFaceCount, 13)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < int(modelFaceCount); j++ {
+			face := MapModelFace{}
+			face.Index = [3]uint32{dec.Uint32(), dec.Uint32(), dec.Uint32()}
+			face.Collidable = dec.Uint8() != 0
+			model.Faces = append(model.Faces, face)
+		}
+		e.Models = append(e.Models, model)
+	}
+	if dec.Error() != nil {
+		return fmt.Errorf("read models: %w", dec.Error())
+	}
+
+	err = countCheck("placeable count", placeableCount, mapPlaceableSizeMin)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(placeableCount); i++ {
+		e.Placeables = append(e.Placeables, mapPlaceableRead(dec))
+	}
+	if dec.Error() != nil {
+		return fmt.Errorf("read placeables: %w", dec.Error())
+	}
+
+	// a group is four vectors and a placeable count
+	err = countCheck("placeable group count", placeableGroupCount, 52)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(placeableGroupCount); i++ {
+		group := &MapPlaceableGroup{}
+		group.Translation = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+		group.Rotation = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+		group.Scale = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+		group.Tile = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+		count := dec.Uint32()
+		if dec.Error() != nil {
+			return fmt.Errorf("read placeable group %d: %w", i, dec.Error())
+		}
+		err = countCheck(fmt.Sprintf("placeable group %d count", i), count, mapPlaceableSizeMin)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < int(count); j++ {
+			group.Placeables = append(group.Placeables, mapPlaceableRead(dec))
+		}
+		e.PlaceableGroups = append(e.PlaceableGroups, group)
+	}
+	if dec.Error() != nil {
+		return fmt.Errorf("read placeable groups: %w", dec.Error())
+	}
+
+	quadCount := int(e.QuadsPerTile * e.QuadsPerTile)
+	tileVertCount := int((e.QuadsPerTile + 1) * (e.QuadsPerTile + 1))
+	// a flat tile is a flag and three floats, the smallest a tile can be
+	err = countCheck("tile count", tileCount, 13)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(tileCount); i++ {
+		tile := &MapTile{}
+		tile.Flat = dec.Bool()
+		tile.X = dec.Float32()
+		tile.Y = dec.Float32()
+		if tile.Flat {
+			tile.Z = dec.Float32()
+			e.Tiles = append(e.Tiles, tile)
+			continue
+		}
+		err = countCheck(fmt.Sprintf("tile %d size", i), 1, tileVertCount*4+quadCount)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < tileVertCount; j++ {
+			tile.Floats = append(tile.Floats, dec.Float32())
+		}
+		for j := 0; j < quadCount; j++ {
+			tile.Flags = append(tile.Flags, dec.Uint8())
+		}
+		e.Tiles = append(e.Tiles, tile)
+	}
+
+	if dec.Error() != nil {
+		return fmt.Errorf("read tiles: %w", dec.Error())
+	}
+
+	return nil
+}
+
+func mapPlaceableRead(dec *encdec.Decoder) *MapPlaceable {
+	placeable := &MapPlaceable{}
+	placeable.ModelName = dec.StringZero()
+	placeable.Translation = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	placeable.Rotation = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	placeable.Scale = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	return placeable
+}
+
+// SetFileName sets the name of the file
+func (e *Map) SetFileName(name string) {
+	e.MetaFileName = name
+}
+
+// FileName returns the name of the file
+func (e *Map) FileName() string {
+	return e.MetaFileName
+}
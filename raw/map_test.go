@@ -0,0 +1,87 @@
+package raw
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestMapReadWrite(t *testing.T) {
+	src := &Map{QuadsPerTile: 2, UnitsPerVertex: 4}
+	src.FaceAdd([3]float32{0, 0, 0}, [3]float32{1, 0, 0}, [3]float32{0, 1, 0}, true)
+	src.FaceAdd([3]float32{1, 0, 0}, [3]float32{1, 1, 0}, [3]float32{0, 1, 0}, true)
+	src.FaceAdd([3]float32{0, 0, 5}, [3]float32{1, 0, 5}, [3]float32{0, 1, 5}, false)
+	if len(src.Vertices) != 4 {
+		t.Fatalf("expected 4 shared collidable vertices, got %d", len(src.Vertices))
+	}
+	src.Models = append(src.Models, &MapModel{
+		Name:     "box",
+		Vertices: [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		Faces:    []MapModelFace{{Index: [3]uint32{0, 1, 2}, Collidable: true}},
+	})
+	src.Placeables = append(src.Placeables, &MapPlaceable{ModelName: "box", Translation: [3]float32{10, 20, 30}, Rotation: [3]float32{0, 0, 1.5}, Scale: [3]float32{1, 1, 1}})
+	src.PlaceableGroups = append(src.PlaceableGroups, &MapPlaceableGroup{
+		Scale:      [3]float32{1, 1, 1},
+		Placeables: []*MapPlaceable{{ModelName: "box", Scale: [3]float32{2, 2, 2}}},
+	})
+	src.Tiles = append(src.Tiles, &MapTile{Flat: true, X: 1, Y: 2, Z: 3})
+	src.Tiles = append(src.Tiles, &MapTile{X: 4, Y: 5, Floats: make([]float32, 9), Flags: make([]uint8, 4)})
+
+	buf := &bytes.Buffer{}
+	err := src.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	dst := &Map{}
+	err = dst.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+
+	src.Version = MapVersion2
+	src.vertIndex = nil
+	src.ncVertIndex = nil
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("round trip mismatch\nsrc: %s\ndst: %s", src, dst)
+	}
+}
+
+func TestMapReadCorrupt(t *testing.T) {
+	// mapCorrupt returns a map whose inflated payload is counts, with header size bufferSize
+	mapCorrupt := func(counts [10]uint32, bufferSize uint32) []byte {
+		payload := &bytes.Buffer{}
+		binary.Write(payload, binary.LittleEndian, counts)
+		data := &bytes.Buffer{}
+		zw := zlib.NewWriter(data)
+		zw.Write(payload.Bytes())
+		zw.Close()
+		out := &bytes.Buffer{}
+		binary.Write(out, binary.LittleEndian, [3]uint32{MapVersion2, uint32(data.Len()), bufferSize})
+		out.Write(data.Bytes())
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		name       string
+		counts     [10]uint32
+		bufferSize uint32
+	}{
+		{"vertex count", [10]uint32{0xFFFFFFFF}, 40},
+		{"model count", [10]uint32{4: 0x10000000}, 40},
+		{"tile count", [10]uint32{7: 1000}, 40},
+		{"quads per tile", [10]uint32{8: 0x10000}, 40},
+		{"inflated size", [10]uint32{}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Map{}
+			err := e.Read(bytes.NewReader(mapCorrupt(tt.counts, tt.bufferSize)))
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package raw
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/encdec"
+	"github.com/xackery/quail/helper"
+)
+
+// Write writes a v2 map file
+func (e *Map) Write(w io.Writer) error {
+	buf := bytes.NewBuffer(nil)
+	enc := encdec.NewEncoder(buf, binary.LittleEndian)
+
+	enc.Uint32(uint32(len(e.Vertices)))
+	enc.Uint32(uint32(len(e.Indices)))
+	enc.Uint32(uint32(len(e.NCVertices)))
+	enc.Uint32(uint32(len(e.NCIndices)))
+	enc.Uint32(uint32(len(e.Models)))
+	enc.Uint32(uint32(len(e.Placeables)))
+	enc.Uint32(uint32(len(e.PlaceableGroups)))
+	enc.Uint32(uint32(len(e.Tiles)))
+	enc.Uint32(e.QuadsPerTile)
+	enc.Float32(e.UnitsPerVertex)
+
+	for _, vert := range e.Vertices {
+		enc.Float32(vert[0])
+		enc.Float32(vert[1])
+		enc.Float32(vert[2])
+	}
+	for _, index := range e.Indices {
+		enc.Uint32(index)
+	}
+	for _, vert := range e.NCVertices {
+		enc.Float32(vert[0])
+		enc.Float32(vert[1])
+		enc.Float32(vert[2])
+	}
+	for _, index := range e.NCIndices {
+		enc.Uint32(index)
+	}
+
+	for _, model := range e.Models {
+		enc.StringZero(model.Name)
+		enc.Uint32(uint32(len(model.Vertices)))
+		enc.Uint32(uint32(len(model.Faces)))
+		for _, vert := range model.Vertices {
+			enc.Float32(vert[0])
+			enc.Float32(vert[1])
+			enc.Float32(vert[2])
+		}
+		for _, face := range model.Faces {
+			enc.Uint32(face.Index[0])
+			enc.Uint32(face.Index[1])
+			enc.Uint32(face.Index[2])
+			if face.Collidable {
+				enc.Uint8(1)
+			} else {
+				enc.Uint8(0)
+			}
+		}
+	}
+
+	for _, placeable := range e.Placeables {
+		mapPlaceableWrite(enc, placeable)
+	}
+
+	for _, group := range e.PlaceableGroups {
+		for _, val := range [][3]float32{group.Translation, group.Rotation, group.Scale, group.Tile} {
+			enc.Float32(val[0])
+			enc.Float32(val[1])
+			enc.Float32(val[2])
+		}
+		enc.Uint32(uint32(len(group.Placeables)))
+		for _, placeable := range group.Placeables {
+			mapPlaceableWrite(enc, placeable)
+		}
+	}
+
+	quadCount := int(e.QuadsPerTile * e.QuadsPerTile)
+	tileVertCount := int((e.QuadsPerTile + 1) * (e.QuadsPerTile + 1))
+	for i, tile := range e.Tiles {
+		enc.Bool(tile.Flat)
+		enc.Float32(tile.X)
+		enc.Float32(tile.Y)
+		if tile.Flat {
+			enc.Float32(tile.Z)
+			continue
+		}
+		if len(tile.Floats) != tileVertCount {
+			return fmt.Errorf("tile %d: expected %d floats, got %d", i, tileVertCount, len(tile.Floats))
+		}
+		if len(tile.Flags) != quadCount {
+			return fmt.Errorf("tile %d: expected %d flags, got %d", i, quadCount, len(tile.Flags))
+		}
+		for _, val := range tile.Floats {
+			enc.Float32(val)
+		}
+		for _, val := range tile.Flags {
+			enc.Uint8(val)
+		}
+	}
+
+	err := enc.Error()
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	data := bytes.NewBuffer(nil)
+	zw := zlib.NewWriter(data)
+	_, err = zw.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("deflate close: %w", err)
+	}
+
+	enc = encdec.NewEncoder(w, binary.LittleEndian)
+	enc.Uint32(MapVersion2)
+	enc.Uint32(uint32(data.Len()))
+	enc.Uint32(uint32(buf.Len()))
+	enc.Bytes(data.Bytes())
+	err = enc.Error()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func mapPlaceableWrite(enc *encdec.Encoder, placeable *MapPlaceable) {
+	enc.StringZero(placeable.ModelName)
+	for _, val := range [][3]float32{placeable.Translation, placeable.Rotation, placeable.Scale} {
+		enc.Float32(val[0])
+		enc.Float32(val[1])
+		enc.Float32(val[2])
+	}
+}
+
+// FaceAdd adds a triangle to the collidable or non-collidable set, reusing identical vertices
+func (e *Map) FaceAdd(a, b, c [3]float32, isCollidable bool) {
+	if isCollidable {
+		if e.vertIndex == nil {
+			e.vertIndex = make(map[[3]float32]uint32)
+		}
+		for _, vert := range [][3]float32{a, b, c} {
+			e.Indices = append(e.Indices, mapVertexIndex(&e.Vertices, e.vertIndex, vert))
+		}
+		return
+	}
+	if e.ncVertIndex == nil {
+		e.ncVertIndex = make(map[[3]float32]uint32)
+	}
+	for _, vert := range [][3]float32{a, b, c} {
+		e.NCIndices = append(e.NCIndices, mapVertexIndex(&e.NCVertices, e.ncVertIndex, vert))
+	}
+}
+
+func mapVertexIndex(verts *[][3]float32, lookup map[[3]float32]uint32, vert [3]float32) uint32 {
+	index, ok := lookup[vert]
+	if ok {
+		return index
+	}
+	index = uint32(len(*verts))
+	*verts = append(*verts, vert)
+	lookup[vert] = index
+	return index
+}
+
+// CollidableTriangles returns the collidable world triangles plus every placed model's collidable triangles,
+// transformed the way the server does (rotate x, y, z in radians, scale, translate, then the same for the group),
+// and the quads of every terrain tile
+func (e *Map) CollidableTriangles() ([][3]float32, []uint32) {
+	verts := append([][3]float32{}, e.Vertices...)
+	indices := append([]uint32{}, e.Indices...)
+
+	quad := func(a, b, c, d [3]float32) {
+		base := uint32(len(verts))
+		verts = append(verts, a, b, c, d)
+		indices = append(indices, base+3, base+1, base+2, base+3, base, base+1)
+	}
+	size := float32(e.QuadsPerTile) * e.UnitsPerVertex
+	for _, tile := range e.Tiles {
+		if tile.Flat {
+			quad([3]float32{tile.X, tile.Y, tile.Z}, [3]float32{tile.X + size, tile.Y, tile.Z}, [3]float32{tile.X + size, tile.Y + size, tile.Z}, [3]float32{tile.X, tile.Y + size, tile.Z})
+			continue
+		}
+		stride := int(e.QuadsPerTile) + 1
+		for i, flag := range tile.Flags {
+			// flagged quads are holes in the terrain
+			if flag&0x01 != 0 {
+				continue
+			}
+			row, col := i/int(e.QuadsPerTile), i%int(e.QuadsPerTile)
+			height := row*stride + col
+			if height+stride+1 >= len(tile.Floats) {
+				break
+			}
+			x := tile.X + float32(row)*e.UnitsPerVertex
+			y := tile.Y + float32(col)*e.UnitsPerVertex
+			quad([3]float32{x, y, tile.Floats[height]},
+				[3]float32{x + e.UnitsPerVertex, y, tile.Floats[height+stride]},
+				[3]float32{x + e.UnitsPerVertex, y + e.UnitsPerVertex, tile.Floats[height+stride+1]},
+				[3]float32{x, y + e.UnitsPerVertex, tile.Floats[height+1]})
+		}
+	}
+
+	models := make(map[string]*MapModel)
+	for _, model := range e.Models {
+		models[model.Name] = model
+	}
+
+	add := func(placeable *MapPlaceable, group *MapPlaceableGroup) {
+		model, ok := models[placeable.ModelName]
+		if !ok {
+			return
+		}
+		base := uint32(len(verts))
+		for _, vert := range model.Vertices {
+			vert = helper.EulerTransform(vert, placeable.Rotation, placeable.Scale, placeable.Translation)
+			if group != nil {
+				vert = helper.EulerTransform(vert, group.Rotation, group.Scale, group.Translation)
+				vert = [3]float32{vert[0] + group.Tile[0], vert[1] + group.Tile[1], vert[2] + group.Tile[2]}
+			}
+			verts = append(verts, vert)
+		}
+		for _, face := range model.Faces {
+			if !face.Collidable {
+				continue
+			}
+			indices = append(indices, base+face.Index[0], base+face.Index[1], base+face.Index[2])
+		}
+	}
+
+	for _, placeable := range e.Placeables {
+		add(placeable, nil)
+	}
+	for _, group := range e.PlaceableGroups {
+		for _, placeable := range group.Placeables {
+			add(placeable, group)
+		}
+	}
+	return verts, indices
+}
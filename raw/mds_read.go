@@ -151,7 +151,7 @@ func (mds *Mds) Read(r io.ReadSeeker) error {
 		model.Name = mds.name.byOffset(dec.Int32())
 		verticesCount := dec.Uint32()
 		faceCount := dec.Uint32()
-		boneCount = dec.Uint32()
+		model.BoneCount = dec.Uint32()
 		for i := 0; i < int(verticesCount); i++ {
 			v := &ModVertex{}
 			v.Position[0] = dec.Float32()
@@ -208,7 +208,7 @@ func (mds *Mds) Read(r io.ReadSeeker) error {
 						BoneIndex: dec.Int32(),
 						Value:     dec.Float32(),
 					}
-					if j < int(count) {
+					if j >= int(count) {
 						continue
 					}
 					model.Vertices[i].Weights = append(model.Vertices[i].Weights, weight)
@@ -0,0 +1,318 @@
+package raw
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/encdec"
+)
+
+const (
+	// NavMagic is the header of an EQEmu navmesh
+	NavMagic = "EQNAVMESH"
+	// NavVersion is the only EQEmu navmesh version quail reads and writes
+	NavVersion = 2
+	// NavTileMagic is the detour tile magic, 'DNAV'
+	NavTileMagic = 'D'<<24 | 'N'<<16 | 'A'<<8 | 'V'
+	// NavTileVersion is the detour tile version
+	NavTileVersion = 7
+	// NavVertsPerPoly is the max vertices of a detour polygon
+	NavVertsPerPoly = 6
+	// NavExtLink flags a polygon neighbor as a portal to another tile
+	NavExtLink = 0x8000
+)
+
+// Nav is an EQEmu server navigation mesh, found in maps/nav/<zone>.nav
+// It is a zlib compressed set of detour tiles, positions are y up (server x, z, y)
+// https://github.com/EQEmu/Server/blob/master/zone/pathfinder_nav_mesh.cpp
+type Nav struct {
+	MetaFileName string
+	Version      uint32
+	Params       NavParams
+	Tiles        []*NavTile
+}
+
+// NavParams is dtNavMeshParams
+type NavParams struct {
+	Origin     [3]float32
+	TileWidth  float32
+	TileHeight float32
+	MaxTiles   int32
+	MaxPolys   int32
+}
+
+// NavTile is a detour tile, dtMeshHeader plus its data
+type NavTile struct {
+	Ref            uint32
+	X              int32
+	Y              int32
+	Layer          int32
+	UserID         uint32
+	OffMeshBase    int32
+	WalkableHeight float32
+	WalkableRadius float32
+	WalkableClimb  float32
+	BMin           [3]float32
+	BMax           [3]float32
+	BVQuantFactor  float32
+	Verts          [][3]float32
+	Polys          []*NavPoly
+	Links          []NavLink // runtime data, usually zeroed
+	DetailMeshes   []NavPolyDetail
+	DetailVerts    [][3]float32
+	DetailTris     [][4]uint8
+	BVTree         []NavBVNode
+	OffMeshCons    []NavOffMeshCon
+}
+
+// NavPoly is dtPoly, Area is the EQEmu area type and Flags is 1<<Area
+type NavPoly struct {
+	FirstLink uint32
+	Verts     [NavVertsPerPoly]uint16
+	Neis      [NavVertsPerPoly]uint16
+	Flags     uint16
+	VertCount uint8
+	Area      uint8
+	Type      uint8
+}
+
+// NavLink is dtLink
+type NavLink struct {
+	Ref  uint32
+	Next uint32
+	Edge uint8
+	Side uint8
+	BMin uint8
+	BMax uint8
+}
+
+// NavPolyDetail is dtPolyDetail
+type NavPolyDetail struct {
+	VertBase  uint32
+	TriBase   uint32
+	VertCount uint8
+	TriCount  uint8
+}
+
+// NavBVNode is dtBVNode, a negative I is an escape index
+type NavBVNode struct {
+	BMin [3]uint16
+	BMax [3]uint16
+	I    int32
+}
+
+// NavOffMeshCon is dtOffMeshConnection
+type NavOffMeshCon struct {
+	Pos    [6]float32
+	Radius float32
+	Poly   uint16
+	Flags  uint8
+	Side   uint8
+	UserID uint32
+}
+
+// Identity returns the type of the struct
+func (e *Nav) Identity() string {
+	return "nav"
+}
+
+func (e *Nav) String() string {
+	out := ""
+	out += fmt.Sprintf("metafilename: %s\n", e.MetaFileName)
+	out += fmt.Sprintf("version: %d\n", e.Version)
+	out += fmt.Sprintf("origin: %0.2f %0.2f %0.2f\n", e.Params.Origin[0], e.Params.Origin[1], e.Params.Origin[2])
+	out += fmt.Sprintf("tile size: %0.2f x %0.2f\n", e.Params.TileWidth, e.Params.TileHeight)
+	out += fmt.Sprintf("max tiles: %d, max polys: %d\n", e.Params.MaxTiles, e.Params.MaxPolys)
+	polyCount := 0
+	vertCount := 0
+	areas := make(map[uint8]int)
+	for _, tile := range e.Tiles {
+		polyCount += len(tile.Polys)
+		vertCount += len(tile.Verts)
+		for _, poly := range tile.Polys {
+			areas[poly.Area]++
+		}
+	}
+	out += fmt.Sprintf("tiles: %d\n", len(e.Tiles))
+	out += fmt.Sprintf("polys: %d\n", polyCount)
+	out += fmt.Sprintf("verts: %d\n", vertCount)
+	for area := 0; area < 64; area++ {
+		if areas[uint8(area)] == 0 {
+			continue
+		}
+		out += fmt.Sprintf("%s polys: %d\n", WtrRegionType(area), areas[uint8(area)])
+	}
+	return out
+}
+
+// Read reads a navmesh file
+func (e *Nav) Read(r io.ReadSeeker) error {
+	dec := encdec.NewDecoder(r, binary.LittleEndian)
+	magic := string(dec.Bytes(len(NavMagic)))
+	if magic != NavMagic {
+		return fmt.Errorf("invalid magic %q, wanted %q", magic, NavMagic)
+	}
+	e.Version = dec.Uint32()
+	if e.Version != NavVersion {
+		return fmt.Errorf("invalid version %d, wanted %d", e.Version, NavVersion)
+	}
+	dataSize := dec.Uint32()
+	bufferSize := dec.Uint32()
+	data := dec.Bytes(int(dataSize))
+	if dec.Error() != nil {
+		return fmt.Errorf("read header: %w", dec.Error())
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("zlib: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, zr)
+	if err != nil {
+		return fmt.Errorf("inflate: %w", err)
+	}
+	if buf.Len() != int(bufferSize) {
+		return fmt.Errorf("inflated size %d does not match header size %d", buf.Len(), bufferSize)
+	}
+
+	dec = encdec.NewDecoder(bytes.NewReader(buf.Bytes()), binary.LittleEndian)
+	tileCount := dec.Uint32()
+	e.Params.Origin = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	e.Params.TileWidth = dec.Float32()
+	e.Params.TileHeight = dec.Float32()
+	e.Params.MaxTiles = dec.Int32()
+	e.Params.MaxPolys = dec.Int32()
+	if dec.Error() != nil {
+		return fmt.Errorf("read params: %w", dec.Error())
+	}
+
+	for i := 0; i < int(tileCount); i++ {
+		ref := dec.Uint32()
+		size := dec.Uint32()
+		tileData := dec.Bytes(int(size))
+		if dec.Error() != nil {
+			return fmt.Errorf("read tile %d: %w", i, dec.Error())
+		}
+		tile := &NavTile{Ref: ref}
+		err = tile.read(tileData)
+		if err != nil {
+			return fmt.Errorf("tile %d: %w", i, err)
+		}
+		e.Tiles = append(e.Tiles, tile)
+	}
+	return nil
+}
+
+func (e *NavTile) read(data []byte) error {
+	dec := encdec.NewDecoder(bytes.NewReader(data), binary.LittleEndian)
+	magic := dec.Int32()
+	if magic != NavTileMagic {
+		return fmt.Errorf("invalid tile magic 0x%x", magic)
+	}
+	version := dec.Int32()
+	if version != NavTileVersion {
+		return fmt.Errorf("invalid tile version %d, wanted %d", version, NavTileVersion)
+	}
+	e.X = dec.Int32()
+	e.Y = dec.Int32()
+	e.Layer = dec.Int32()
+	e.UserID = dec.Uint32()
+	polyCount := dec.Int32()
+	vertCount := dec.Int32()
+	maxLinkCount := dec.Int32()
+	detailMeshCount := dec.Int32()
+	detailVertCount := dec.Int32()
+	detailTriCount := dec.Int32()
+	bvNodeCount := dec.Int32()
+	offMeshConCount := dec.Int32()
+	e.OffMeshBase = dec.Int32()
+	e.WalkableHeight = dec.Float32()
+	e.WalkableRadius = dec.Float32()
+	e.WalkableClimb = dec.Float32()
+	e.BMin = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	e.BMax = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+	e.BVQuantFactor = dec.Float32()
+
+	for i := 0; i < int(vertCount); i++ {
+		e.Verts = append(e.Verts, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+	}
+	for i := 0; i < int(polyCount); i++ {
+		poly := &NavPoly{}
+		poly.FirstLink = dec.Uint32()
+		for j := range poly.Verts {
+			poly.Verts[j] = dec.Uint16()
+		}
+		for j := range poly.Neis {
+			poly.Neis[j] = dec.Uint16()
+		}
+		poly.Flags = dec.Uint16()
+		poly.VertCount = dec.Uint8()
+		areaAndType := dec.Uint8()
+		poly.Area = areaAndType & 0x3f
+		poly.Type = areaAndType >> 6
+		e.Polys = append(e.Polys, poly)
+	}
+	for i := 0; i < int(maxLinkCount); i++ {
+		link := NavLink{}
+		link.Ref = dec.Uint32()
+		link.Next = dec.Uint32()
+		link.Edge = dec.Uint8()
+		link.Side = dec.Uint8()
+		link.BMin = dec.Uint8()
+		link.BMax = dec.Uint8()
+		e.Links = append(e.Links, link)
+	}
+	for i := 0; i < int(detailMeshCount); i++ {
+		detail := NavPolyDetail{}
+		detail.VertBase = dec.Uint32()
+		detail.TriBase = dec.Uint32()
+		detail.VertCount = dec.Uint8()
+		detail.TriCount = dec.Uint8()
+		dec.Bytes(2) // padding
+		e.DetailMeshes = append(e.DetailMeshes, detail)
+	}
+	for i := 0; i < int(detailVertCount); i++ {
+		e.DetailVerts = append(e.DetailVerts, [3]float32{dec.Float32(), dec.Float32(), dec.Float32()})
+	}
+	for i := 0; i < int(detailTriCount); i++ {
+		e.DetailTris = append(e.DetailTris, [4]uint8{dec.Uint8(), dec.Uint8(), dec.Uint8(), dec.Uint8()})
+	}
+	for i := 0; i < int(bvNodeCount); i++ {
+		node := NavBVNode{}
+		node.BMin = [3]uint16{dec.Uint16(), dec.Uint16(), dec.Uint16()}
+		node.BMax = [3]uint16{dec.Uint16(), dec.Uint16(), dec.Uint16()}
+		node.I = dec.Int32()
+		e.BVTree = append(e.BVTree, node)
+	}
+	for i := 0; i < int(offMeshConCount); i++ {
+		con := NavOffMeshCon{}
+		for j := range con.Pos {
+			con.Pos[j] = dec.Float32()
+		}
+		con.Radius = dec.Float32()
+		con.Poly = dec.Uint16()
+		con.Flags = dec.Uint8()
+		con.Side = dec.Uint8()
+		con.UserID = dec.Uint32()
+		e.OffMeshCons = append(e.OffMeshCons, con)
+	}
+
+	if dec.Error() != nil {
+		return fmt.Errorf("read: %w", dec.Error())
+	}
+	return nil
+}
+
+// SetFileName sets the name of the file
+func (e *Nav) SetFileName(name string) {
+	e.MetaFileName = name
+}
+
+// FileName returns the name of the file
+func (e *Nav) FileName() string {
+	return e.MetaFileName
+}
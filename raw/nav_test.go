@@ -0,0 +1,61 @@
+package raw
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNavReadWrite(t *testing.T) {
+	src := &Nav{
+		Version: NavVersion,
+		Params: NavParams{
+			Origin:     [3]float32{-100, -10, -100},
+			TileWidth:  256,
+			TileHeight: 256,
+			MaxTiles:   1,
+			MaxPolys:   2,
+		},
+		Tiles: []*NavTile{{
+			Ref:            1 << 1,
+			WalkableHeight: 6,
+			WalkableRadius: 1.5,
+			WalkableClimb:  3,
+			BMin:           [3]float32{-100, -10, -100},
+			BMax:           [3]float32{156, 10, 156},
+			BVQuantFactor:  1,
+			OffMeshBase:    2,
+			Verts:          [][3]float32{{0, 0, 0}, {10, 0, 0}, {10, 0, 10}, {0, 0, 10}},
+			Polys: []*NavPoly{
+				{Verts: [NavVertsPerPoly]uint16{0, 1, 2}, Neis: [NavVertsPerPoly]uint16{0, 2, NavExtLink | 4}, Flags: 1, VertCount: 3},
+				{Verts: [NavVertsPerPoly]uint16{0, 2, 3}, Neis: [NavVertsPerPoly]uint16{1}, Flags: 1 << 1, VertCount: 3, Area: 1},
+			},
+			Links: make([]NavLink, 8),
+			DetailMeshes: []NavPolyDetail{
+				{VertBase: 0, VertCount: 0, TriBase: 0, TriCount: 1},
+				{VertBase: 0, VertCount: 1, TriBase: 1, TriCount: 3},
+			},
+			DetailVerts: [][3]float32{{3, 0, 6}},
+			DetailTris:  [][4]uint8{{0, 1, 2, 0x15}, {0, 1, 3, 1}, {1, 2, 3, 1}, {2, 0, 3, 1}},
+			BVTree: []NavBVNode{
+				{BMin: [3]uint16{100, 10, 100}, BMax: [3]uint16{110, 10, 110}, I: -3},
+				{BMin: [3]uint16{100, 10, 100}, BMax: [3]uint16{110, 10, 110}, I: 0},
+				{BMin: [3]uint16{100, 10, 100}, BMax: [3]uint16{110, 10, 110}, I: 1},
+			},
+		}},
+	}
+
+	buf := &bytes.Buffer{}
+	err := src.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	dst := &Nav{}
+	err = dst.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("round trip mismatch\nsrc: %s\ndst: %s", src, dst)
+	}
+}
@@ -0,0 +1,160 @@
+package raw
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/encdec"
+)
+
+// Write writes a navmesh file
+func (e *Nav) Write(w io.Writer) error {
+	buf := bytes.NewBuffer(nil)
+	enc := encdec.NewEncoder(buf, binary.LittleEndian)
+	enc.Uint32(uint32(len(e.Tiles)))
+	enc.Float32(e.Params.Origin[0])
+	enc.Float32(e.Params.Origin[1])
+	enc.Float32(e.Params.Origin[2])
+	enc.Float32(e.Params.TileWidth)
+	enc.Float32(e.Params.TileHeight)
+	enc.Int32(e.Params.MaxTiles)
+	enc.Int32(e.Params.MaxPolys)
+
+	for i, tile := range e.Tiles {
+		tileData := bytes.NewBuffer(nil)
+		err := tile.write(tileData)
+		if err != nil {
+			return fmt.Errorf("tile %d: %w", i, err)
+		}
+		enc.Uint32(tile.Ref)
+		enc.Uint32(uint32(tileData.Len()))
+		enc.Bytes(tileData.Bytes())
+	}
+	err := enc.Error()
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	data := bytes.NewBuffer(nil)
+	zw := zlib.NewWriter(data)
+	_, err = zw.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("deflate: %w", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("deflate close: %w", err)
+	}
+
+	enc = encdec.NewEncoder(w, binary.LittleEndian)
+	enc.Bytes([]byte(NavMagic))
+	enc.Uint32(NavVersion)
+	enc.Uint32(uint32(data.Len()))
+	enc.Uint32(uint32(buf.Len()))
+	enc.Bytes(data.Bytes())
+	err = enc.Error()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (e *NavTile) write(w io.Writer) error {
+	if len(e.Verts) >= 0xffff {
+		return fmt.Errorf("too many verts (%d)", len(e.Verts))
+	}
+	enc := encdec.NewEncoder(w, binary.LittleEndian)
+	enc.Int32(NavTileMagic)
+	enc.Int32(NavTileVersion)
+	enc.Int32(e.X)
+	enc.Int32(e.Y)
+	enc.Int32(e.Layer)
+	enc.Uint32(e.UserID)
+	enc.Int32(int32(len(e.Polys)))
+	enc.Int32(int32(len(e.Verts)))
+	enc.Int32(int32(len(e.Links)))
+	enc.Int32(int32(len(e.DetailMeshes)))
+	enc.Int32(int32(len(e.DetailVerts)))
+	enc.Int32(int32(len(e.DetailTris)))
+	enc.Int32(int32(len(e.BVTree)))
+	enc.Int32(int32(len(e.OffMeshCons)))
+	enc.Int32(e.OffMeshBase)
+	enc.Float32(e.WalkableHeight)
+	enc.Float32(e.WalkableRadius)
+	enc.Float32(e.WalkableClimb)
+	for _, val := range [][3]float32{e.BMin, e.BMax} {
+		enc.Float32(val[0])
+		enc.Float32(val[1])
+		enc.Float32(val[2])
+	}
+	enc.Float32(e.BVQuantFactor)
+
+	for _, vert := range e.Verts {
+		enc.Float32(vert[0])
+		enc.Float32(vert[1])
+		enc.Float32(vert[2])
+	}
+	for _, poly := range e.Polys {
+		enc.Uint32(poly.FirstLink)
+		for _, val := range poly.Verts {
+			enc.Uint16(val)
+		}
+		for _, val := range poly.Neis {
+			enc.Uint16(val)
+		}
+		enc.Uint16(poly.Flags)
+		enc.Uint8(poly.VertCount)
+		enc.Uint8(poly.Area&0x3f | poly.Type<<6)
+	}
+	for _, link := range e.Links {
+		enc.Uint32(link.Ref)
+		enc.Uint32(link.Next)
+		enc.Uint8(link.Edge)
+		enc.Uint8(link.Side)
+		enc.Uint8(link.BMin)
+		enc.Uint8(link.BMax)
+	}
+	for _, detail := range e.DetailMeshes {
+		enc.Uint32(detail.VertBase)
+		enc.Uint32(detail.TriBase)
+		enc.Uint8(detail.VertCount)
+		enc.Uint8(detail.TriCount)
+		enc.Uint16(0) // padding
+	}
+	for _, vert := range e.DetailVerts {
+		enc.Float32(vert[0])
+		enc.Float32(vert[1])
+		enc.Float32(vert[2])
+	}
+	for _, tri := range e.DetailTris {
+		enc.Bytes(tri[:])
+	}
+	for _, node := range e.BVTree {
+		for _, val := range node.BMin {
+			enc.Uint16(val)
+		}
+		for _, val := range node.BMax {
+			enc.Uint16(val)
+		}
+		enc.Int32(node.I)
+	}
+	for _, con := range e.OffMeshCons {
+		for _, val := range con.Pos {
+			enc.Float32(val)
+		}
+		enc.Float32(con.Radius)
+		enc.Uint16(con.Poly)
+		enc.Uint8(con.Flags)
+		enc.Uint8(con.Side)
+		enc.Uint32(con.UserID)
+	}
+
+	err := enc.Error()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
@@ -42,10 +42,14 @@ func New(ext string) ReadWriter {
 		return &Lit{}
 	case ".lod":
 		return &Lod{}
+	case ".map":
+		return &Map{}
 	case ".mds":
 		return &Mds{}
 	case ".mod":
 		return &Mod{}
+	case ".nav":
+		return &Nav{}
 	case ".png":
 		return &Png{}
 	case ".prt":
@@ -58,6 +62,8 @@ func New(ext string) ReadWriter {
 		return &Tog{}
 	case ".wld":
 		return &Wld{}
+	case ".wtr":
+		return &Wtr{}
 	case ".zon":
 		return &Zon{}
 	case ".env":
@@ -22,6 +22,8 @@ type Wld struct {
 	Unk3         uint32
 	names        []*nameEntry
 	nameBuf      []byte
+	nameOffsets  map[string]int32 // offset of the first entry of each name, filled in by NameOffset
+	nameCount    int              // number of names in nameOffsets
 }
 
 func (wld *Wld) Identity() string {
@@ -61,6 +63,7 @@ func (wld *Wld) Read(r io.ReadSeeker) error {
 	nameData := helper.ReadStringHash(hashRaw)
 
 	wld.names = []*nameEntry{}
+	wld.nameOffsets = nil
 	chunk := []rune{}
 	lastOffset := 0
 	//nameBuf = []byte{}
@@ -199,6 +202,7 @@ func (wld *Wld) rawFrags(r io.ReadSeeker) ([][]byte, error) {
 	nameData := helper.ReadStringHash(hashRaw)
 
 	wld.names = []*nameEntry{}
+	wld.nameOffsets = nil
 	chunk := []rune{}
 	lastOffset := 0
 	for i, b := range nameData {
@@ -300,6 +304,7 @@ func (wld *Wld) NameSet(newNames map[int32]string) {
 	for k, v := range newNames {
 		wld.names = append(wld.names, &nameEntry{offset: int(k), name: v})
 	}
+	wld.nameOffsets = nil
 	wld.nameBuf = []byte{0x00}
 
 	for _, v := range wld.names {
@@ -339,12 +344,24 @@ func (wld *Wld) NameOffset(name string) int32 {
 	if wld.names == nil {
 		return -1
 	}
-	for _, v := range wld.names {
-		if v.name == name {
-			return int32(v.offset)
+	if wld.nameOffsets == nil || wld.nameCount > len(wld.names) {
+		wld.nameOffsets = make(map[string]int32)
+		wld.nameCount = 0
+	}
+	// names are only appended, so index the new ones
+	for _, v := range wld.names[wld.nameCount:] {
+		_, ok := wld.nameOffsets[v.name]
+		if !ok {
+			wld.nameOffsets[v.name] = int32(v.offset)
 		}
 	}
-	return -1
+	wld.nameCount = len(wld.names)
+
+	offset, ok := wld.nameOffsets[name]
+	if !ok {
+		return -1
+	}
+	return offset
 }
 
 // NameIndex is used when reading, returns the index of a name, or -1 if not found
@@ -370,6 +387,7 @@ func (wld *Wld) NameData() []byte {
 func (wld *Wld) NameClear() {
 	wld.names = nil
 	wld.nameBuf = nil
+	wld.nameOffsets = nil
 }
 
 func (wld *Wld) TagByFrag(srcFrag interface{}) string {
@@ -0,0 +1,253 @@
+package raw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/xackery/encdec"
+)
+
+// WtrMagic is the header of an EQEmu water map
+const WtrMagic = "EQEMUWATER"
+
+// WtrRegionType is the type of an EQEmu water map region
+type WtrRegionType int32
+
+const (
+	WtrRegionTypeUnsupported    WtrRegionType = -2
+	WtrRegionTypeUntagged       WtrRegionType = -1
+	WtrRegionTypeNormal         WtrRegionType = 0
+	WtrRegionTypeWater          WtrRegionType = 1
+	WtrRegionTypeLava           WtrRegionType = 2
+	WtrRegionTypeZoneLine       WtrRegionType = 3
+	WtrRegionTypePVP            WtrRegionType = 4
+	WtrRegionTypeSlime          WtrRegionType = 5
+	WtrRegionTypeIce            WtrRegionType = 6
+	WtrRegionTypeVWater         WtrRegionType = 7
+	WtrRegionTypeGeneralArea    WtrRegionType = 8
+	WtrRegionTypePreferPathing  WtrRegionType = 9
+	WtrRegionTypeDisableNavMesh WtrRegionType = 10
+)
+
+var wtrRegionTypeNames = map[WtrRegionType]string{
+	WtrRegionTypeUnsupported:    "unsupported",
+	WtrRegionTypeUntagged:       "untagged",
+	WtrRegionTypeNormal:         "normal",
+	WtrRegionTypeWater:          "water",
+	WtrRegionTypeLava:           "lava",
+	WtrRegionTypeZoneLine:       "zoneline",
+	WtrRegionTypePVP:            "pvp",
+	WtrRegionTypeSlime:          "slime",
+	WtrRegionTypeIce:            "ice",
+	WtrRegionTypeVWater:         "vwater",
+	WtrRegionTypeGeneralArea:    "generalarea",
+	WtrRegionTypePreferPathing:  "preferpathing",
+	WtrRegionTypeDisableNavMesh: "disablenavmesh",
+}
+
+func (e WtrRegionType) String() string {
+	name, ok := wtrRegionTypeNames[e]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", int32(e))
+	}
+	return name
+}
+
+// ParseWtrRegionType returns the region type with a name as returned by String, slippery is accepted for ice
+func ParseWtrRegionType(name string) (WtrRegionType, error) {
+	name = strings.ToLower(name)
+	if name == "slippery" {
+		return WtrRegionTypeIce, nil
+	}
+	for regionType, regionName := range wtrRegionTypeNames {
+		if regionName == name {
+			return regionType, nil
+		}
+	}
+	return WtrRegionTypeUnsupported, fmt.Errorf("unknown region type %q", name)
+}
+
+// Wtr is an EQEmu server water map, found in maps/water/<zone>.wtr
+// Version 1 is a copy of the s3d bsp tree, version 2 is a list of oriented boxes used by eqg zones
+// https://github.com/EQEmu/Server/blob/master/zone/water_map.cpp
+type Wtr struct {
+	MetaFileName string
+	Version      uint32
+	Nodes        []*WtrNode   // version 1
+	Regions      []*WtrRegion // version 2
+}
+
+// WtrNode is a bsp node, left and right are 1 based node numbers with 0 meaning none
+type WtrNode struct {
+	NodeNumber    int32
+	Normal        [3]float32
+	SplitDistance float32
+	Region        int32
+	Special       WtrRegionType
+	Left          int32
+	Right         int32
+}
+
+// WtrRegion is an oriented bounding box, rotation is in degrees and extents are half sizes
+type WtrRegion struct {
+	Type     WtrRegionType
+	Position [3]float32
+	Rotation [3]float32
+	Scale    [3]float32
+	Extents  [3]float32
+}
+
+// Identity returns the type of the struct
+func (e *Wtr) Identity() string {
+	return "wtr"
+}
+
+func (e *Wtr) String() string {
+	out := ""
+	out += fmt.Sprintf("metafilename: %s\n", e.MetaFileName)
+	out += fmt.Sprintf("version: %d\n", e.Version)
+	counts := make(map[WtrRegionType]int)
+	switch e.Version {
+	case 1:
+		out += fmt.Sprintf("nodes: %d\n", len(e.Nodes))
+		for _, node := range e.Nodes {
+			if node.Left == 0 && node.Right == 0 {
+				counts[node.Special]++
+			}
+		}
+	case 2:
+		out += fmt.Sprintf("regions: %d\n", len(e.Regions))
+		for _, region := range e.Regions {
+			counts[region.Type]++
+		}
+	}
+	for regionType := WtrRegionTypeUnsupported; regionType <= WtrRegionTypeDisableNavMesh; regionType++ {
+		if counts[regionType] == 0 {
+			continue
+		}
+		out += fmt.Sprintf("%s: %d\n", regionType, counts[regionType])
+	}
+	return out
+}
+
+// Read reads a water map
+func (e *Wtr) Read(r io.ReadSeeker) error {
+	dec := encdec.NewDecoder(r, binary.LittleEndian)
+	magic := string(dec.Bytes(len(WtrMagic)))
+	if magic != WtrMagic {
+		return fmt.Errorf("invalid magic %q, wanted %q", magic, WtrMagic)
+	}
+	e.Version = dec.Uint32()
+
+	switch e.Version {
+	case 1:
+		count := dec.Uint32()
+		for i := 0; i < int(count); i++ {
+			node := &WtrNode{}
+			node.NodeNumber = dec.Int32()
+			node.Normal = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+			node.SplitDistance = dec.Float32()
+			node.Region = dec.Int32()
+			node.Special = WtrRegionType(dec.Int32())
+			node.Left = dec.Int32()
+			node.Right = dec.Int32()
+			e.Nodes = append(e.Nodes, node)
+		}
+	case 2:
+		count := dec.Uint32()
+		for i := 0; i < int(count); i++ {
+			region := &WtrRegion{}
+			region.Type = WtrRegionType(dec.Uint32())
+			region.Position = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+			region.Rotation = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+			region.Scale = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+			region.Extents = [3]float32{dec.Float32(), dec.Float32(), dec.Float32()}
+			e.Regions = append(e.Regions, region)
+		}
+	default:
+		return fmt.Errorf("unsupported version %d", e.Version)
+	}
+
+	if dec.Error() != nil {
+		return fmt.Errorf("read: %w", dec.Error())
+	}
+	return nil
+}
+
+// RegionType returns the region type at a point in zone coordinates, walking the bsp like the server does
+func (e *Wtr) RegionType(x, y, z float32) WtrRegionType {
+	if e.Version == 2 {
+		for _, region := range e.Regions {
+			if region.contains(x, y, z) {
+				return region.Type
+			}
+		}
+		return WtrRegionTypeNormal
+	}
+
+	nodeNumber := int32(1)
+	for depth := 0; depth < len(e.Nodes); depth++ {
+		if nodeNumber < 1 || int(nodeNumber) > len(e.Nodes) {
+			return WtrRegionTypeNormal
+		}
+		node := e.Nodes[nodeNumber-1]
+		if node.Left == 0 && node.Right == 0 {
+			return node.Special
+		}
+		distance := x*node.Normal[0] + y*node.Normal[1] + z*node.Normal[2] + node.SplitDistance
+		if distance == 0 {
+			return WtrRegionTypeNormal
+		}
+		if distance > 0 {
+			nodeNumber = node.Left
+		} else {
+			nodeNumber = node.Right
+		}
+		if nodeNumber == 0 {
+			return WtrRegionTypeNormal
+		}
+	}
+	return WtrRegionTypeNormal
+}
+
+// ServerRegionType returns the region type at a point in server coordinates, like the server's ReturnRegionType.
+// Server coordinates have x and y swapped from the zone coordinates water maps are stored in
+func (e *Wtr) ServerRegionType(x, y, z float32) WtrRegionType {
+	return e.RegionType(y, x, z)
+}
+
+// contains returns true if a point is inside the oriented box, rotation is in degrees and applied x, y, z
+func (e *WtrRegion) contains(x, y, z float32) bool {
+	p := [3]float64{float64(x - e.Position[0]), float64(y - e.Position[1]), float64(z - e.Position[2])}
+	// undo rotation in reverse order: z, y, x
+	sin, cos := math.Sincos(-float64(e.Rotation[2]) * math.Pi / 180)
+	p = [3]float64{cos*p[0] - sin*p[1], sin*p[0] + cos*p[1], p[2]}
+	sin, cos = math.Sincos(-float64(e.Rotation[1]) * math.Pi / 180)
+	p = [3]float64{cos*p[0] + sin*p[2], p[1], -sin*p[0] + cos*p[2]}
+	sin, cos = math.Sincos(-float64(e.Rotation[0]) * math.Pi / 180)
+	p = [3]float64{p[0], cos*p[1] - sin*p[2], sin*p[1] + cos*p[2]}
+	for i := 0; i < 3; i++ {
+		scale := float64(e.Scale[i])
+		if scale == 0 {
+			return false
+		}
+		val := p[i] / scale
+		if val < -float64(e.Extents[i]) || val > float64(e.Extents[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetFileName sets the name of the file
+func (e *Wtr) SetFileName(name string) {
+	e.MetaFileName = name
+}
+
+// FileName returns the name of the file
+func (e *Wtr) FileName() string {
+	return e.MetaFileName
+}
@@ -0,0 +1,83 @@
+package raw
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWtrReadWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		src  *Wtr
+	}{
+		{name: "v1", src: &Wtr{Version: 1, Nodes: []*WtrNode{
+			{NodeNumber: 1, Normal: [3]float32{0, 0, 1}, SplitDistance: -10, Left: 2, Right: 3},
+			{NodeNumber: 2, Region: 1, Special: WtrRegionTypeNormal},
+			{NodeNumber: 3, Region: 2, Special: WtrRegionTypeWater},
+		}}},
+		{name: "v2", src: &Wtr{Version: 2, Regions: []*WtrRegion{
+			{Type: WtrRegionTypeLava, Position: [3]float32{1, 2, 3}, Rotation: [3]float32{0, 0, 1}, Scale: [3]float32{1, 1, 1}, Extents: [3]float32{5, 5, 5}},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := tt.src.Write(buf)
+			if err != nil {
+				t.Fatalf("write: %s", err)
+			}
+			dst := &Wtr{}
+			err = dst.Read(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("read: %s", err)
+			}
+			if !reflect.DeepEqual(tt.src, dst) {
+				t.Fatalf("round trip mismatch\nsrc: %s\ndst: %s", tt.src, dst)
+			}
+		})
+	}
+}
+
+func TestWtrRegionType(t *testing.T) {
+	wtr := &Wtr{Version: 1, Nodes: []*WtrNode{
+		{NodeNumber: 1, Normal: [3]float32{0, 0, 1}, SplitDistance: -10, Left: 2, Right: 3},
+		{NodeNumber: 2, Region: 1, Special: WtrRegionTypeNormal},
+		{NodeNumber: 3, Region: 2, Special: WtrRegionTypeWater},
+	}}
+	if got := wtr.RegionType(0, 0, 20); got != WtrRegionTypeNormal {
+		t.Fatalf("above split: got %s, want normal", got)
+	}
+	if got := wtr.RegionType(0, 0, 0); got != WtrRegionTypeWater {
+		t.Fatalf("below split: got %s, want water", got)
+	}
+
+	// water where zone x is below 10
+	wtr.Nodes[0].Normal = [3]float32{1, 0, 0}
+	if got := wtr.RegionType(20, 0, 0); got != WtrRegionTypeNormal {
+		t.Fatalf("zone x 20: got %s, want normal", got)
+	}
+	if got := wtr.RegionType(0, 20, 0); got != WtrRegionTypeWater {
+		t.Fatalf("zone y 20: got %s, want water", got)
+	}
+	if got := wtr.ServerRegionType(0, 20, 0); got != WtrRegionTypeNormal {
+		t.Fatalf("server y 20: got %s, want normal", got)
+	}
+	if got := wtr.ServerRegionType(20, 0, 0); got != WtrRegionTypeWater {
+		t.Fatalf("server x 20: got %s, want water", got)
+	}
+
+	// lava in a box around zone 10, -20
+	wtr = &Wtr{Version: 2, Regions: []*WtrRegion{
+		{Type: WtrRegionTypeLava, Position: [3]float32{10, -20, 0}, Scale: [3]float32{1, 1, 1}, Extents: [3]float32{5, 5, 5}},
+	}}
+	if got := wtr.RegionType(10, -20, 0); got != WtrRegionTypeLava {
+		t.Fatalf("v2 zone: got %s, want lava", got)
+	}
+	if got := wtr.RegionType(-20, 10, 0); got != WtrRegionTypeNormal {
+		t.Fatalf("v2 zone swapped: got %s, want normal", got)
+	}
+	if got := wtr.ServerRegionType(-20, 10, 0); got != WtrRegionTypeLava {
+		t.Fatalf("v2 server: got %s, want lava", got)
+	}
+}
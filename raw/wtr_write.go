@@ -0,0 +1,62 @@
+package raw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/xackery/encdec"
+)
+
+// Write writes a water map
+func (e *Wtr) Write(w io.Writer) error {
+	enc := encdec.NewEncoder(w, binary.LittleEndian)
+	enc.Bytes([]byte(WtrMagic))
+	enc.Uint32(e.Version)
+
+	switch e.Version {
+	case 1:
+		enc.Uint32(uint32(len(e.Nodes)))
+		for _, node := range e.Nodes {
+			enc.Int32(node.NodeNumber)
+			enc.Float32(node.Normal[0])
+			enc.Float32(node.Normal[1])
+			enc.Float32(node.Normal[2])
+			enc.Float32(node.SplitDistance)
+			enc.Int32(node.Region)
+			enc.Int32(int32(node.Special))
+			enc.Int32(node.Left)
+			enc.Int32(node.Right)
+		}
+	case 2:
+		enc.Uint32(uint32(len(e.Regions)))
+		for _, region := range e.Regions {
+			enc.Uint32(uint32(region.Type))
+			for _, val := range [][3]float32{region.Position, region.Rotation, region.Scale, region.Extents} {
+				enc.Float32(val[0])
+				enc.Float32(val[1])
+				enc.Float32(val[2])
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported version %d", e.Version)
+	}
+
+	err := enc.Error()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// SheetAdd adds a version 2 water region spanning from the surface of a watersheet down by depth
+func (e *Wtr) SheetAdd(sheet *DatWtrSheet, depth float32) {
+	minX, maxX := float32(sheet.MinX), float32(sheet.MaxX)
+	minY, maxY := float32(sheet.MinY), float32(sheet.MaxY)
+	e.Regions = append(e.Regions, &WtrRegion{
+		Type:     WtrRegionTypeWater,
+		Position: [3]float32{(minX + maxX) / 2, (minY + maxY) / 2, float32(sheet.ZHeight) - depth/2},
+		Scale:    [3]float32{1, 1, 1},
+		Extents:  [3]float32{(maxX - minX) / 2, (maxY - minY) / 2, depth / 2},
+	})
+}
@@ -42,9 +42,6 @@ func BuildFragReferenceTree(isChr bool, wld *raw.Wld) (map[int32]*Node, map[int3
 	// Map to store all nodes
 	nodes := make(map[int32]*Node)
 
-	// Map to track which nodes are linkedNodes as children
-	linkedNodes := make(map[int32]bool)
-
 	actorNodes := make(map[string]*Node)
 	// find actornodes and build them first
 	for i := 1; i < len(wld.Fragments); i++ { // Start at index 1
@@ -92,8 +89,6 @@ func BuildFragReferenceTree(isChr bool, wld *raw.Wld) (map[int32]*Node, map[int3
 			if refID <= 0 {
 				continue
 			}
-			// Mark this refID as being referenced
-			linkedNodes[refID] = true
 
 			// Find or create the child node
 			childFrag := wld.Fragments[refID]
@@ -105,21 +100,63 @@ func BuildFragReferenceTree(isChr bool, wld *raw.Wld) (map[int32]*Node, map[int3
 			child := upsertNode(nodes, fmt.Sprintf("%T", childFrag), refID, strings.TrimSpace(childTag))
 
 			// Establish the parent-child relationship
-			node.Children[refID] = child
+			node.Link(child)
+		}
+	}
+
+	return Roots(nodes), nodes, nil
+}
+
+// Link adds child as a reference of node
+func (node *Node) Link(child *Node) {
+	node.Children[child.FragID] = child
+}
+
+// Roots returns the nodes that no other node references
+func Roots(nodes map[int32]*Node) map[int32]*Node {
+	linkedNodes := make(map[int32]bool)
+	for _, node := range nodes {
+		for childID := range node.Children {
+			linkedNodes[childID] = true
 		}
 	}
 
-	// Identify root nodes (nodes that are not referenced as children)
 	roots := make(map[int32]*Node)
 	for fragID, node := range nodes {
 		if linkedNodes[fragID] {
 			continue
 		}
 		roots[fragID] = node
+	}
+	return roots
+}
 
+// Reachable returns the IDs of every node referenced directly or indirectly by roots, roots included
+func Reachable(roots ...*Node) map[int32]bool {
+	visited := make(map[int32]bool)
+	stack := append([]*Node{}, roots...)
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[node.FragID] {
+			continue
+		}
+		visited[node.FragID] = true
+		for _, child := range node.Children {
+			stack = append(stack, child)
+		}
 	}
+	return visited
+}
 
-	return roots, nodes, nil
+// NewNode returns a node with no references
+func NewNode(fragType string, fragID int32, tag string) *Node {
+	return &Node{
+		FragID:   fragID,
+		Tag:      tag,
+		FragType: fragType,
+		Children: make(map[int32]*Node),
+	}
 }
 
 // upsertNode finds or creates a node in the map
@@ -132,12 +169,7 @@ func upsertNode(nodes map[int32]*Node, fragType string, fragID int32, tag string
 		node.FragType = fragType
 		return node
 	}
-	node = &Node{
-		FragID:   fragID,
-		Tag:      tag,
-		FragType: fragType,
-		Children: make(map[int32]*Node),
-	}
+	node = NewNode(fragType, fragID, tag)
 	nodes[fragID] = node
 	return node
 }
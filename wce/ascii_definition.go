@@ -0,0 +1,60 @@
+package wce
+
+import (
+	"embed"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AsciiArg is an argument of a property as described in def/*.yaml
+type AsciiArg struct {
+	Name        string `yaml:"name"`
+	Note        string `yaml:"note"`
+	Description string `yaml:"description"`
+	Format      string `yaml:"format"`
+	Example     string `yaml:"example"`
+}
+
+// AsciiProperty is a property of a definition as described in def/*.yaml
+type AsciiProperty struct {
+	Name        string          `yaml:"name"`
+	Note        string          `yaml:"note"`
+	Description string          `yaml:"description"`
+	Args        []AsciiArg      `yaml:"args"`
+	Properties  []AsciiProperty `yaml:"properties"`
+}
+
+// AsciiDefinition is a definition as described in def/*.yaml
+type AsciiDefinition struct {
+	Name        string          `yaml:"name"`
+	HasTag      bool            `yaml:"hasTag,omitempty"`
+	Note        string          `yaml:"note"`
+	Description string          `yaml:"description"`
+	Properties  []AsciiProperty `yaml:"properties"`
+}
+
+//go:embed def/*.yaml
+var asciiDefinitionFS embed.FS
+
+// AsciiDefinitions returns every definition described by the yaml files, keyed by definition name
+func AsciiDefinitions() (map[string]*AsciiDefinition, error) {
+	entries, err := asciiDefinitionFS.ReadDir("def")
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	defs := make(map[string]*AsciiDefinition)
+	for _, entry := range entries {
+		data, err := asciiDefinitionFS.ReadFile("def/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+		yamlDef := &AsciiDefinition{}
+		err = yaml.Unmarshal(data, yamlDef)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", entry.Name(), err)
+		}
+		defs[yamlDef.Name] = yamlDef
+	}
+	return defs, nil
+}
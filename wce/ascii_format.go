@@ -0,0 +1,244 @@
+package wce
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// AsciiFormat lays out a .wce file the way WriteAscii writes it. Each nesting level of a definition is
+// indented by one tab, strings are quoted, %d arguments are plain integers and %0.8e arguments are
+// rewritten at full precision. Comments and blank lines are kept where they are
+func AsciiFormat(data []byte) ([]byte, error) {
+	defs, err := AsciiDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &asciiFormatter{defs: defs}
+	r := AsciiReadTokenNew(bytes.NewBuffer(data), nil)
+	r.isCommentKept = true
+
+	out := &strings.Builder{}
+	flush := func(comments []string, depth int) {
+		for _, comment := range comments {
+			if comment != "" {
+				out.WriteString(strings.Repeat("\t", depth))
+			}
+			out.WriteString(comment + "\n")
+		}
+	}
+	for {
+		line, err := r.ReadLine()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("line %d: %w", r.lineNumber, err)
+		}
+		isEOF := err == io.EOF
+		comments := r.commentsTake()
+		if isEOF {
+			// the last line is returned as is, and is empty after a trailing newline
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+				if line != "" {
+					comments = append(comments, trimmed)
+				}
+				flush(comments, 0)
+				break
+			}
+		}
+		depth, formatted := f.line(line)
+		flush(comments, depth)
+		out.WriteString(strings.Repeat("\t", depth) + formatted + "\n")
+		if isEOF {
+			break
+		}
+	}
+	return []byte(out.String()), nil
+}
+
+type asciiFormatter struct {
+	defs   map[string]*AsciiDefinition
+	def    *AsciiDefinition
+	stack  []*AsciiProperty // property path of the last line
+	indent []int            // indent widths of the nesting levels in the current definition
+}
+
+// line returns the depth and formatted content of a line that is not a comment
+func (f *asciiFormatter) line(line string) (int, string) {
+	tokens, comment := asciiFormatTokens(line)
+	name := strings.ToUpper(tokens[0])
+	isIndented := line[0] == ' ' || line[0] == '\t'
+
+	if strings.HasPrefix(name, "INCLUDE") {
+		f.def = nil
+		f.stack = nil
+		f.indent = nil
+		return 0, asciiFormatJoin(name, asciiFormatArgs(tokens[1:], []AsciiArg{{Format: "%s"}}), comment)
+	}
+
+	var prop *AsciiProperty
+	isProperty := f.def != nil && (isIndented || f.defs[name] == nil)
+	if isProperty {
+		prop = f.property(name)
+	}
+	if !isProperty || (prop == nil && !isIndented) {
+		yamlDef := f.defs[name]
+		if yamlDef != nil {
+			f.def = yamlDef
+			f.stack = nil
+			f.indent = nil
+			args := []AsciiArg{}
+			if yamlDef.HasTag {
+				args = append(args, AsciiArg{Format: "%s"})
+			}
+			return 0, asciiFormatJoin(name, asciiFormatArgs(tokens[1:], args), comment)
+		}
+	}
+	depth := 0
+	if f.def != nil {
+		depth = f.depth(line)
+	}
+	if prop == nil {
+		// unknown lines keep their words
+		return depth, asciiFormatJoin(tokens[0], tokens[1:], comment)
+	}
+	return depth, asciiFormatJoin(strings.ToUpper(tokens[0]), asciiFormatArgs(tokens[1:], prop.Args), comment)
+}
+
+// depth returns the nesting level of a property line from how far it is indented compared to the
+// lines before it, so any consistent use of tabs or spaces keeps its structure
+func (f *asciiFormatter) depth(line string) int {
+	width := 0
+	for _, c := range line {
+		if c == '\t' {
+			width += 4
+			continue
+		}
+		if c != ' ' {
+			break
+		}
+		width++
+	}
+	for len(f.indent) > 0 && f.indent[len(f.indent)-1] > width {
+		f.indent = f.indent[:len(f.indent)-1]
+	}
+	if len(f.indent) == 0 || f.indent[len(f.indent)-1] < width {
+		f.indent = append(f.indent, width)
+	}
+	return len(f.indent)
+}
+
+// property finds name among the children of the last property path, innermost first, then anywhere
+// in the definition
+func (f *asciiFormatter) property(name string) *AsciiProperty {
+	for level := len(f.stack); level >= 0; level-- {
+		props := f.def.Properties
+		if level > 0 {
+			props = f.stack[level-1].Properties
+		}
+		for i := range props {
+			if !asciiPropertyNameMatch(props[i].Name, name) {
+				continue
+			}
+			f.stack = append(f.stack[:level], &props[i])
+			return &props[i]
+		}
+	}
+	return asciiPropertyFind(f.def.Properties, name)
+}
+
+func asciiPropertyFind(props []AsciiProperty, name string) *AsciiProperty {
+	for i := range props {
+		if asciiPropertyNameMatch(props[i].Name, name) {
+			return &props[i]
+		}
+		child := asciiPropertyFind(props[i].Properties, name)
+		if child != nil {
+			return child
+		}
+	}
+	return nil
+}
+
+func asciiPropertyNameMatch(propName string, name string) bool {
+	return strings.EqualFold(strings.TrimSuffix(propName, "?"), strings.TrimSuffix(name, "?"))
+}
+
+// asciiFormatTokens splits a line into arguments like the reader does, keeping quotes, and returns
+// any trailing comment
+func asciiFormatTokens(line string) ([]string, string) {
+	tokens := []string{}
+	i := 0
+	for {
+		arg, isQuoted, next, comment := segmentNext(line, i)
+		if next < 0 {
+			return tokens, ""
+		}
+		if isQuoted {
+			arg = `"` + arg + `"`
+		}
+		if arg != "" {
+			tokens = append(tokens, arg)
+		}
+		if comment >= 0 {
+			return tokens, strings.TrimSpace(line[comment:])
+		}
+		i = next
+	}
+}
+
+// asciiFormatArgs rewrites each argument by the format of its yaml arg. Formats ending in ... repeat for the rest
+func asciiFormatArgs(tokens []string, args []AsciiArg) []string {
+	out := []string{}
+	for i, token := range tokens {
+		format := ""
+		if i < len(args) {
+			format = args[i].Format
+		} else if len(args) > 0 && strings.HasSuffix(args[len(args)-1].Format, "...") {
+			format = args[len(args)-1].Format
+		}
+		out = append(out, asciiFormatArg(token, strings.TrimSuffix(format, "...")))
+	}
+	return out
+}
+
+func asciiFormatArg(token string, format string) string {
+	if strings.HasPrefix(token, `"`) || token == "NULL" {
+		return token
+	}
+	switch format {
+	case "%d":
+		val, err := strconv.ParseInt(token, 10, 64)
+		if err != nil {
+			return token
+		}
+		return strconv.FormatInt(val, 10)
+	case "%0.8e":
+		val, err := strconv.ParseFloat(token, 32)
+		if err != nil {
+			return token
+		}
+		return fmt.Sprintf("%0.8e", float32(val))
+	case "%s":
+		// nullable numbers are written unquoted with a %s format
+		_, err := strconv.ParseFloat(token, 64)
+		if err == nil {
+			return token
+		}
+		return `"` + token + `"`
+	}
+	return token
+}
+
+func asciiFormatJoin(name string, args []string, comment string) string {
+	line := name
+	if len(args) > 0 {
+		line += " " + strings.Join(args, " ")
+	}
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
@@ -0,0 +1,214 @@
+package wce
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// asciiLine is a line of an ascii file and the line number it was read from before migrating
+type asciiLine struct {
+	text   string
+	source int
+}
+
+// asciiMigration upgrades the lines of an ascii file written by an older version. Lines that are
+// added should use the source of the line they follow, so errors point at the original file
+type asciiMigration struct {
+	from    string
+	to      string
+	migrate func(lines []asciiLine) ([]asciiLine, error)
+}
+
+// asciiMigrations are applied in order to files older than AsciiVersion
+var asciiMigrations = []asciiMigration{
+	{from: "v0.0.0", to: "v0.0.1", migrate: migrateOptionalMarkers},
+}
+
+// AsciiFileVersion returns the version in the // wcemu header of an ascii file, or v0.0.0 if it has none
+func AsciiFileVersion(data []byte) (string, error) {
+	lines := []string{}
+	for len(data) > 0 {
+		line := data
+		end := bytes.IndexByte(data, '\n')
+		if end >= 0 {
+			line = data[:end]
+			data = data[end+1:]
+		} else {
+			data = nil
+		}
+		lines = append(lines, string(line))
+		if len(bytes.TrimSpace(line)) > 0 {
+			break
+		}
+	}
+	version, _, err := asciiHeader(lines)
+	return version, err
+}
+
+// AsciiUpgrade applies every migration newer than the version of an ascii file, returning the
+// upgraded file with its header set to AsciiVersion and the version it was
+func AsciiUpgrade(data []byte) ([]byte, string, error) {
+	from, err := AsciiFileVersion(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if from == AsciiVersion {
+		return data, from, nil
+	}
+	lines, err := asciiMigrate(data, from)
+	if err != nil {
+		return nil, "", err
+	}
+
+	texts := make([]string, 0, len(lines)+1)
+	for _, line := range lines {
+		texts = append(texts, line.text)
+	}
+	header := fmt.Sprintf("// wcemu %s", AsciiVersion)
+	_, index, err := asciiHeader(texts)
+	if err != nil {
+		return nil, "", err
+	}
+	if index >= 0 {
+		texts[index] = header
+	} else {
+		texts = append([]string{header}, texts...)
+	}
+	return []byte(strings.Join(texts, "\n")), from, nil
+}
+
+// asciiMigrate applies every migration newer than from, the version of data, returning its lines.
+// The header is left as is
+func asciiMigrate(data []byte, from string) ([]asciiLine, error) {
+	fromVersion, err := asciiVersionParse(from)
+	if err != nil {
+		return nil, err
+	}
+	currentVersion, err := asciiVersionParse(AsciiVersion)
+	if err != nil {
+		return nil, err
+	}
+	if asciiVersionCompare(fromVersion, currentVersion) > 0 {
+		return nil, fmt.Errorf("file is wcemu %s, which is newer than %s", from, AsciiVersion)
+	}
+
+	texts := strings.Split(string(data), "\n")
+
+	lines := make([]asciiLine, len(texts))
+	for i, text := range texts {
+		lines[i] = asciiLine{text: text, source: i + 1}
+	}
+	for _, migration := range asciiMigrations {
+		migrationVersion, err := asciiVersionParse(migration.from)
+		if err != nil {
+			return nil, err
+		}
+		if asciiVersionCompare(migrationVersion, fromVersion) < 0 {
+			continue
+		}
+		lines, err = migration.migrate(lines)
+		if err != nil {
+			return nil, fmt.Errorf("migrate %s to %s: %w", migration.from, migration.to, err)
+		}
+	}
+	return lines, nil
+}
+
+// asciiHeader returns the version in the // wcemu header and the index of its line.
+// A file without one is v0.0.0, with an index of -1
+func asciiHeader(lines []string) (string, int, error) {
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "//" || fields[1] != "wcemu" {
+			break
+		}
+		if len(fields) < 3 {
+			return "", i, fmt.Errorf("line %d: wcemu header has no version", i+1)
+		}
+		_, err := asciiVersionParse(fields[2])
+		if err != nil {
+			return "", i, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		return fields[2], i, nil
+	}
+	return "v0.0.0", -1, nil
+}
+
+// asciiVersionParse parses a version such as v0.0.1
+func asciiVersionParse(version string) ([3]int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if !strings.HasPrefix(version, "v") || len(parts) != 3 {
+		return [3]int{}, fmt.Errorf("invalid wcemu version %s", version)
+	}
+	out := [3]int{}
+	for i, part := range parts {
+		val, err := strconv.Atoi(part)
+		if err != nil || val < 0 {
+			return [3]int{}, fmt.Errorf("invalid wcemu version %s", version)
+		}
+		out[i] = val
+	}
+	return out, nil
+}
+
+func asciiVersionCompare(a [3]int, b [3]int) int {
+	for i := 0; i < 3; i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+// optionalMarkers are the properties of each definition that may be NULL. Before v0.0.1 they were
+// written without the ? quail marks them with
+var optionalMarkers = map[string][]string{
+	"ACTORDEF":              {"CURRENTACTION", "LOCATION", "ACTIVEGEOMETRY"},
+	"ACTORINST":             {"CURRENTACTION", "LOCATION", "BOUNDINGRADIUS", "SCALEFACTOR", "SOUND", "ACTIVE", "SPRITEVOLUMEONLY", "DMRGBTRACK"},
+	"DMSPRITEDEFINITION":    {"CENTER", "PARAMS1", "PARAMS2"},
+	"HIERARCHICALSPRITEDEF": {"CENTEROFFSET", "BOUNDINGRADIUS"},
+	"LIGHTDEFINITION":       {"CURRENTFRAME", "SLEEP"},
+	"MATERIALDEFINITION":    {"PAIRS"},
+	"PARTICLECLOUDDEF":      {"SPAWNBOXMIN", "SPAWNBOXMAX", "BOXMIN", "BOXMAX"},
+	"SIMPLESPRITEDEF":       {"SKIPFRAMES", "ANIMATED", "SLEEP", "CURRENTFRAME"},
+	"SPRITE2DDEF":           {"DEPTHSCALE", "CENTEROFFSET", "BOUNDINGRADIUS", "CURRENTFRAMEREF", "SLEEP", "PEN", "BRIGHTNESS", "SCALEDAMBIENT", "SPRITE", "UVORIGIN", "UAXIS", "VAXIS"},
+	"SPRITE3DDEF":           {"CENTEROFFSET", "BOUNDINGRADIUS", "PEN", "BRIGHTNESS", "SCALEDAMBIENT", "SPRITE", "UVORIGIN", "UAXIS", "VAXIS"},
+	"TRACKINSTANCE":         {"SLEEP"},
+	"WORLDDEF":              {"EQGVERSION"},
+}
+
+// migrateOptionalMarkers adds the ? to properties that may be NULL, as written by wcemu before quail
+func migrateOptionalMarkers(lines []asciiLine) ([]asciiLine, error) {
+	optionals := map[string]bool{}
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line.text, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		name := trimmed
+		end := strings.IndexAny(trimmed, " \t\r")
+		if end >= 0 {
+			name = trimmed[:end]
+		}
+		if len(trimmed) == len(line.text) {
+			// a definition starts at the left edge
+			optionals = map[string]bool{}
+			for _, optional := range optionalMarkers[strings.ToUpper(name)] {
+				optionals[optional] = true
+			}
+			continue
+		}
+		if !optionals[strings.ToUpper(name)] {
+			continue
+		}
+		indent := line.text[:len(line.text)-len(trimmed)]
+		lines[i].text = indent + name + "?" + trimmed[len(name):]
+	}
+	return lines, nil
+}
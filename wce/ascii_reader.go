@@ -1,29 +1,86 @@
 package wce
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
-var regexLine = regexp.MustCompile(`"([^"]*)"|(\S+)`)
-
 type AsciiReadToken struct {
+	path           string
 	folder         string
 	basePath       string
 	lineNumber     int
+	sourceLines    []int // line numbers before migrating, by line number after, when migrated
 	buf            *bytes.Buffer
+	reader         *bufio.Reader
+	long           []byte // holds lines longer than reader's buffer
 	wce            *Wce
-	totalLineCount int // will be higher than lineNumber due to includes
+	totalLineCount int    // will be higher than lineNumber due to includes
+	line           string // last line read
+	isUnread       bool   // when true, the next ReadLine returns line again
+	errs           ParseErrors
+	isCommentKept  bool     // when true, ReadLine keeps the comments and blank lines it skips
+	comments       []string // comments and blank lines skipped since the last commentsTake
+}
+
+// Position is where a definition or error was read from in an ascii file
+type Position struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return ""
+	}
+	if p.Col == 0 {
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// ParseError is a problem found while reading a definition in an ascii file
+type ParseError struct {
+	Position   Position
+	Definition string
+	Tag        string
+	Err        error
+}
+
+func (e *ParseError) Error() string {
+	name := e.Definition
+	if e.Tag != "" {
+		name += " " + e.Tag
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Position, name, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is every error found while reading an ascii file and its includes
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	lines := []string{}
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
 }
 
 func AsciiReadTokenNew(buf *bytes.Buffer, wce *Wce) *AsciiReadToken {
 	return &AsciiReadToken{
 		lineNumber: 0,
 		buf:        buf,
+		reader:     bufio.NewReader(buf),
 		wce:        wce,
 	}
 }
@@ -34,17 +91,41 @@ func LoadAsciiFile(path string, wce *Wce) (*AsciiReadToken, error) {
 	if err != nil {
 		return nil, err
 	}
+	version, err := AsciiFileVersion(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var sourceLines []int
+	if version != AsciiVersion {
+		lines, err := asciiMigrate(buf.Bytes(), version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		texts := make([]string, len(lines))
+		sourceLines = make([]int, len(lines))
+		for i, line := range lines {
+			texts[i] = line.text
+			sourceLines[i] = line.source
+		}
+		buf = bytes.NewBufferString(strings.Join(texts, "\n"))
+	}
 	a := &AsciiReadToken{
-		lineNumber: 0,
-		buf:        buf,
-		wce:        wce,
+		path:        path,
+		lineNumber:  0,
+		sourceLines: sourceLines,
+		buf:         buf,
+		reader:      bufio.NewReader(buf),
+		wce:         wce,
 	}
 	a.basePath = filepath.Dir(path)
 	a.folder = filepath.Base(a.basePath)
 
 	err = a.readDefinitions()
 	if err != nil {
-		return nil, fmt.Errorf("%s:%d: %w", path, a.lineNumber, err)
+		return nil, fmt.Errorf("%s:%d: %w", path, a.sourceLine(), err)
+	}
+	if len(a.errs) > 0 {
+		return a, a.errs
 	}
 	return a, nil
 }
@@ -88,35 +169,51 @@ func caseInsensitiveOpen(path string, wce *Wce) (*bytes.Buffer, error) {
 	return nil, fmt.Errorf("file %s not found", path)
 }
 
-// Read reads up to len(p) bytes into p. It returns the number of bytes read (0 <= n <= len(p)) and any error encountered.
+// ReadLine returns the next line that is not blank or a comment
 func (a *AsciiReadToken) ReadLine() (string, error) {
-	line := ""
-	p := make([]byte, 1)
+	if a.isUnread {
+		a.isUnread = false
+		return a.line, nil
+	}
 	for {
-		_, err := a.buf.Read(p)
+		data, err := a.readSlice()
 		if err != nil {
 			if err == io.EOF {
 				a.lineNumber++
-				return line, err
+				a.line = string(data)
+				return a.line, err
 			}
 
 			return "", err
 		}
-		if p[0] != '\n' {
-			line += string(p)
-			continue
-		}
 		a.lineNumber++
-		if strings.HasPrefix(strings.TrimSpace(line), "//") {
-			line = ""
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) == 0 || bytes.HasPrefix(trimmed, []byte("//")) {
+			if a.isCommentKept {
+				a.comments = append(a.comments, string(trimmed))
+			}
 			continue
 		}
-		if strings.TrimSpace(line) == "" {
-			line = ""
-			continue
+		a.line = string(data)
+		return a.line, nil
+	}
+}
+
+// readSlice returns the next line without its newline, only valid until the next read
+func (a *AsciiReadToken) readSlice() ([]byte, error) {
+	data, err := a.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		a.long = append(a.long[:0], data...)
+		for err == bufio.ErrBufferFull {
+			data, err = a.reader.ReadSlice('\n')
+			a.long = append(a.long, data...)
 		}
-		return line, nil
+		data = a.long
 	}
+	if err == nil {
+		data = data[:len(data)-1]
+	}
+	return data, err
 }
 
 func (a *AsciiReadToken) ReadSegmentedLine() ([]string, error) {
@@ -129,22 +226,62 @@ func (a *AsciiReadToken) ReadSegmentedLine() ([]string, error) {
 			return nil, err
 		}
 	}
-	matches := regexLine.FindAllStringSubmatch(line, -1)
+	return segments(line), nil
+}
+
+// commentsTake returns the comments and blank lines skipped by ReadLine since the last call, trimmed
+func (a *AsciiReadToken) commentsTake() []string {
+	comments := a.comments
+	a.comments = nil
+	return comments
+}
+
+// segments splits line into arguments. Quoted arguments lose their quotes and may hold spaces,
+// others end at whitespace and are cut at //. An argument that starts with // ends the line
+func segments(line string) []string {
 	args := []string{}
-	for _, match := range matches {
-		if strings.Contains(match[2], "//") {
-			match[2] = match[2][:strings.Index(match[2], "//")]
-			if match[2] == "" {
-				break
-			}
+	i := 0
+	for {
+		arg, _, next, comment := segmentNext(line, i)
+		if next < 0 || (comment >= 0 && arg == "") {
+			break
 		}
-		if match[1] != "" {
-			args = append(args, match[1])
-		} else {
-			args = append(args, match[2])
+		args = append(args, arg)
+		i = next
+	}
+	return args
+}
+
+// segmentNext returns the argument of line starting at or after i, if it was quoted, and where the
+// next one may start, which is -1 at the end of line. comment is where a // in the argument starts, or -1
+func segmentNext(line string, i int) (arg string, isQuoted bool, next int, comment int) {
+	for i < len(line) && isSegmentSpace(line[i]) {
+		i++
+	}
+	if i == len(line) {
+		return "", false, -1, -1
+	}
+	if line[i] == '"' {
+		end := strings.IndexByte(line[i+1:], '"')
+		if end >= 0 {
+			return line[i+1 : i+1+end], true, i + end + 2, -1
 		}
 	}
-	return args, nil
+	start := i
+	for i < len(line) && !isSegmentSpace(line[i]) {
+		i++
+	}
+	arg = line[start:i]
+	index := strings.Index(arg, "//")
+	if index >= 0 {
+		return arg[:index], false, i, start + index
+	}
+	return arg, false, i, -1
+}
+
+// isSegmentSpace reports if c separates arguments
+func isSegmentSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
 }
 
 type PropOpt struct {
@@ -192,12 +329,14 @@ func (a *AsciiReadToken) TotalLineCountRead() int {
 	return a.totalLineCount + a.lineNumber
 }
 
-func (a *AsciiReadToken) readDefinitions() error {
-	type definitionReader interface {
-		Definition() string
-		Read(r *AsciiReadToken) error
-	}
-	definitions := []definitionReader{
+type definitionReader interface {
+	Definition() string
+	Read(r *AsciiReadToken) error
+}
+
+// asciiDefinitions returns a fresh instance of every top-level definition
+func asciiDefinitions() []definitionReader {
+	return []definitionReader{
 		&ActorDef{},
 		&ActorInst{},
 		&EqgAniDef{},
@@ -235,6 +374,14 @@ func (a *AsciiReadToken) readDefinitions() error {
 		&WorldTree{},
 		&Zone{},
 	}
+}
+
+func (a *AsciiReadToken) readDefinitions() error {
+	definitions := asciiDefinitions()
+	names := make(map[string]bool)
+	for _, def := range definitions {
+		names[def.Definition()] = true
+	}
 
 	definition := ""
 	for {
@@ -253,7 +400,7 @@ func (a *AsciiReadToken) readDefinitions() error {
 		if strings.HasPrefix(definition, "INCLUDE") {
 			err = a.readInclude(args)
 			if err != nil {
-				return fmt.Errorf("-> %w", err)
+				a.errorAdd("INCLUDE", "", err)
 			}
 			definition = ""
 			continue
@@ -273,20 +420,32 @@ func (a *AsciiReadToken) readDefinitions() error {
 			}
 
 			definition = ""
+			def := definitions[i]
+			headerLine := a.lineNumber
+			pos := a.position()
+			tag := ""
+			if len(args) > 1 {
+				tag = args[1]
+			}
 			err = defRead(a)
 			if err != nil {
-				return fmt.Errorf("%s: %w", defName, err)
+				a.errorAdd(defName, tag, err)
+				definitions[i] = asciiDefinitions()[i]
+				a.skipDefinition(headerLine, names)
+				break
 			}
 			switch frag := (definitions[i]).(type) {
 			case *GlobalAmbientLightDef:
 				if a.wce.GlobalAmbientLightDef != nil {
-					return fmt.Errorf("duplicate global ambient light definition")
+					err = fmt.Errorf("duplicate global ambient light definition")
+					break
 				}
 				a.wce.GlobalAmbientLightDef = frag
 				definitions[i] = &GlobalAmbientLightDef{}
 			case *BlitSpriteDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				// Check if the Tag already exists in wce.BlitSpriteDefs
@@ -304,7 +463,8 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &BlitSpriteDef{}
 			case *DMSpriteDef2:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				if existingIndex, exists := a.wce.tagIndexes[frag.Tag]; exists && existingIndex == frag.TagIndex {
@@ -316,7 +476,8 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &DMSpriteDef2{}
 			case *HierarchicalSpriteDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				exists := false
@@ -332,7 +493,8 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &HierarchicalSpriteDef{}
 			case *MaterialDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				// Check if the Tag and TagIndex combination already exists
@@ -346,35 +508,40 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &MaterialDef{}
 			case *MaterialPalette:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.MaterialPalettes = append(a.wce.MaterialPalettes, frag)
 				definitions[i] = &MaterialPalette{}
 			case *PolyhedronDefinition:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.PolyhedronDefs = append(a.wce.PolyhedronDefs, frag)
 				definitions[i] = &PolyhedronDefinition{}
 			case *SimpleSpriteDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.SimpleSpriteDefs = append(a.wce.SimpleSpriteDefs, frag)
 				definitions[i] = &SimpleSpriteDef{}
 			case *TrackDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.TrackDefs = append(a.wce.TrackDefs, frag)
 				definitions[i] = &TrackDef{}
 			case *TrackInstance:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				if existingIndex, exists := a.wce.tagIndexes[frag.Tag]; exists && existingIndex == frag.TagIndex {
@@ -386,7 +553,8 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &TrackInstance{}
 			case *DMTrackDef2:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				exists := false
@@ -402,91 +570,104 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &DMTrackDef2{}
 			case *LightDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.LightDefs = append(a.wce.LightDefs, frag)
 				definitions[i] = &LightDef{}
 			case *Sprite3DDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.Sprite3DDefs = append(a.wce.Sprite3DDefs, frag)
 				definitions[i] = &Sprite3DDef{}
 			case *WorldTree:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.WorldTrees = append(a.wce.WorldTrees, frag)
 				definitions[i] = &WorldTree{}
 			case *Region:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.Regions = append(a.wce.Regions, frag)
 				definitions[i] = &Region{}
 			case *AmbientLight:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.AmbientLights = append(a.wce.AmbientLights, frag)
 				definitions[i] = &AmbientLight{}
 			case *ActorDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.ActorDefs = append(a.wce.ActorDefs, frag)
 				definitions[i] = &ActorDef{}
 			case *ActorInst:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.ActorInsts = append(a.wce.ActorInsts, frag)
 				definitions[i] = &ActorInst{}
 			case *Zone:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.Zones = append(a.wce.Zones, frag)
 				definitions[i] = &Zone{}
 			case *RGBTrackDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.RGBTrackDefs = append(a.wce.RGBTrackDefs, frag)
 				definitions[i] = &RGBTrackDef{}
 			case *ParticleCloudDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.ParticleCloudDefs = append(a.wce.ParticleCloudDefs, frag)
 				definitions[i] = &ParticleCloudDef{}
 			case *Sprite2DDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.Sprite2DDefs = append(a.wce.Sprite2DDefs, frag)
 				definitions[i] = &Sprite2DDef{}
 			case *PointLight:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.PointLights = append(a.wce.PointLights, frag)
 				definitions[i] = &PointLight{}
 			case *DMSpriteDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				if existingIndex, exists := a.wce.tagIndexes[frag.Tag]; exists && existingIndex == frag.TagIndex {
@@ -502,81 +683,166 @@ func (a *AsciiReadToken) readDefinitions() error {
 				definitions[i] = &WorldDef{folders: []string{"world"}}
 			case *EqgMdsDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.MdsDefs = append(a.wce.MdsDefs, frag)
 				definitions[i] = &EqgMdsDef{}
 			case *EqgModDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.ModDefs = append(a.wce.ModDefs, frag)
 				definitions[i] = &EqgModDef{}
 			case *EqgTerDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.TerDefs = append(a.wce.TerDefs, frag)
 				definitions[i] = &EqgTerDef{}
 			case *EqgAniDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.AniDefs = append(a.wce.AniDefs, frag)
 				definitions[i] = &EqgAniDef{}
 			case *EqgLodDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.LodDefs = append(a.wce.LodDefs, frag)
 				definitions[i] = &EqgLodDef{}
 			case *EqgLayDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.LayDefs = append(a.wce.LayDefs, frag)
 				definitions[i] = &EqgLayDef{}
 			case *EqgParticlePointDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.PtsDefs = append(a.wce.PtsDefs, frag)
 				definitions[i] = &EqgParticlePointDef{}
 			case *EqgParticleRenderDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.PrtDefs = append(a.wce.PrtDefs, frag)
 				definitions[i] = &EqgParticleRenderDef{}
 			case *EqgZonDef:
 				if len(args) == 1 {
-					return fmt.Errorf("definition %s has no arguments", defName)
+					err = fmt.Errorf("definition %s has no arguments", defName)
+					break
 				}
 				frag.Tag = args[1]
 				a.wce.ZonDefs = append(a.wce.ZonDefs, frag)
 				definitions[i] = &EqgZonDef{}
 			default:
-				return fmt.Errorf("unknown definition type for rebuild: %T", definitions[i])
+				err = fmt.Errorf("unknown definition type for rebuild: %T", definitions[i])
+			}
+			if err != nil {
+				a.errorAdd(defName, tag, err)
+				definitions[i] = asciiDefinitions()[i]
+				break
 			}
+			a.wce.positionSet(def, pos)
 
 			break
 		}
-	}
 
-	if definition != "" {
-		return fmt.Errorf("unknown definition: %s", definition)
+		// unknown lines inside a definition are ignored, unknown top-level definitions are errors
+		if definition != "" && a.isDefinitionStart() {
+			a.errorAdd(definition, "", fmt.Errorf("unknown definition: %s", definition))
+			a.skipDefinition(a.lineNumber, names)
+		}
 	}
+
 	return nil
 }
 
+// sourceLine returns the line number of the last line read in the file before it was migrated
+func (a *AsciiReadToken) sourceLine() int {
+	if a.lineNumber > 0 && a.lineNumber <= len(a.sourceLines) {
+		return a.sourceLines[a.lineNumber-1]
+	}
+	return a.lineNumber
+}
+
+// position returns the position of the last line read
+func (a *AsciiReadToken) position() Position {
+	return Position{
+		File: a.path,
+		Line: a.sourceLine(),
+		Col:  len(a.line) - len(strings.TrimLeft(a.line, " \t")) + 1,
+	}
+}
+
+// errorAdd records a parse error at the last line read so reading can continue
+func (a *AsciiReadToken) errorAdd(definition string, tag string, err error) {
+	var parseErrs ParseErrors
+	if errors.As(err, &parseErrs) {
+		a.errs = append(a.errs, parseErrs...)
+		return
+	}
+	a.errs = append(a.errs, &ParseError{
+		Position:   a.position(),
+		Definition: definition,
+		Tag:        tag,
+		Err:        err,
+	})
+}
+
+// isDefinitionStart returns true if the last line read is not indented, which is how top-level definitions are written
+func (a *AsciiReadToken) isDefinitionStart() bool {
+	return len(a.line) > 0 && a.line[0] != ' ' && a.line[0] != '\t'
+}
+
+// skipDefinition discards lines until the next top-level definition after a read error.
+// If the failed read already consumed the next definition's header, it is read again
+func (a *AsciiReadToken) skipDefinition(headerLine int, names map[string]bool) {
+	isNext := func() bool {
+		if !a.isDefinitionStart() {
+			return false
+		}
+		fields := strings.Fields(a.line)
+		name := strings.ToUpper(fields[0])
+		return names[name] || strings.HasPrefix(name, "INCLUDE")
+	}
+	if a.lineNumber != headerLine && isNext() {
+		a.isUnread = true
+		return
+	}
+	for {
+		line, err := a.ReadLine()
+		if err != nil {
+			if line != "" && isNext() {
+				a.isUnread = true
+			}
+			return
+		}
+		if isNext() {
+			a.isUnread = true
+			return
+		}
+	}
+}
+
 func (a *AsciiReadToken) readInclude(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("INCLUDE needs 1 argument")
@@ -588,7 +854,11 @@ func (a *AsciiReadToken) readInclude(args []string) error {
 	}
 	ir, err := LoadAsciiFile(path, a.wce)
 	if err != nil {
-		return err
+		var parseErrs ParseErrors
+		if !errors.As(err, &parseErrs) {
+			return err
+		}
+		a.errs = append(a.errs, parseErrs...)
 	}
 	err = ir.readDefinitions()
 	if err != nil {
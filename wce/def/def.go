@@ -1,25 +1,17 @@
 package def
 
-type Arg struct {
-	Name        string `yaml:"name"`
-	Note        string `yaml:"note"`
-	Description string `yaml:"description"`
-	Format      string `yaml:"format"`
-	Example     string `yaml:"example"`
-}
+import (
+	"github.com/xackery/quail/wce"
+)
 
-type Property struct {
-	Name        string     `yaml:"name"`
-	Note        string     `yaml:"note"`
-	Description string     `yaml:"description"`
-	Args        []Arg      `yaml:"args"`
-	Properties  []Property `yaml:"properties"`
-}
+// Arg, Property and Definition are the yaml descriptions of the wce format, loaded by package wce
+type Arg = wce.AsciiArg
+
+type Property = wce.AsciiProperty
+
+type Definition = wce.AsciiDefinition
 
-type Definition struct {
-	Name        string     `yaml:"name"`
-	HasTag      bool       `yaml:"hasTag,omitempty"`
-	Note        string     `yaml:"note"`
-	Description string     `yaml:"description"`
-	Properties  []Property `yaml:"properties"`
+// Definitions returns every definition described by the yaml files, keyed by definition name
+func Definitions() (map[string]*Definition, error) {
+	return wce.AsciiDefinitions()
 }
@@ -0,0 +1,240 @@
+package def
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/wce"
+)
+
+// schemaNonNullable are pointer fields that must be set, by type and field name
+var schemaNonNullable = map[string]bool{
+	"Wce.WorldDef": true,
+}
+
+// schemaRequired are properties that must be present, by type name
+var schemaRequired = map[string][]string{
+	"Wce": {"FileName", "WorldDef"},
+}
+
+func TestWceGenJsonSchema(t *testing.T) {
+	yamlDefs, err := Definitions()
+	if err != nil {
+		t.Fatalf("definitions: %v", err)
+	}
+
+	gen := &schemaGen{
+		yamlDefs: yamlDefs,
+		defs:     make(map[string]interface{}),
+	}
+	root, err := gen.structSchema(reflect.TypeOf(wce.Wce{}))
+	if err != nil {
+		t.Fatalf("wce: %v", err)
+	}
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = "https://github.com/xackery/quail/wce/wce.schema.json"
+	root["title"] = "wce"
+	root["description"] = "A wce document, as written by quail convert foo.s3d foo.json"
+	root["$defs"] = gen.defs
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	err = enc.Encode(root)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	dirTest := helper.DirTest()
+	err = os.WriteFile(fmt.Sprintf("%s/wce.schema.json", dirTest), buf.Bytes(), os.ModePerm)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), wce.JSONSchema()) {
+		t.Fatalf("wce/wce.schema.json is out of date, replace it with %s/wce.schema.json", dirTest)
+	}
+	fmt.Println("Generated schema for", len(gen.defs), "types")
+}
+
+// schemaGen builds a json schema from the types encoding/json writes
+type schemaGen struct {
+	yamlDefs map[string]*Definition
+	defs     map[string]interface{}
+}
+
+// typeSchema returns the schema of t, named structs are added to defs and referenced
+func (gen *schemaGen) typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int8:
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt8, "maximum": math.MaxInt8}, nil
+	case reflect.Int16:
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt16, "maximum": math.MaxInt16}, nil
+	case reflect.Int32:
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}, nil
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint8:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": math.MaxUint8}, nil
+	case reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": math.MaxUint16}, nil
+	case reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": math.MaxUint32}, nil
+	case reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Ptr:
+		// elements of slices are never null, fields are handled by structSchema
+		return gen.typeSchema(t.Elem())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": []string{"string", "null"}, "contentEncoding": "base64"}, nil
+		}
+		items, err := gen.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": items}, nil
+	case reflect.Array:
+		items, err := gen.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items, "minItems": t.Len(), "maxItems": t.Len()}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key %s is not a string", t.Key())
+		}
+		values, err := gen.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return gen.structSchema(t)
+		}
+		_, ok := gen.defs[t.Name()]
+		if !ok {
+			gen.defs[t.Name()] = nil // placeholder for recursive types
+			schema, err := gen.structSchema(t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Name(), err)
+			}
+			gen.defs[t.Name()] = schema
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}, nil
+	}
+	return nil, fmt.Errorf("unhandled kind %s", t.Kind())
+}
+
+// structSchema returns the schema of the exported fields of t
+func (gen *schemaGen) structSchema(t reflect.Type) (map[string]interface{}, error) {
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+	}
+
+	var yamlDef *Definition
+	definer, ok := reflect.New(t).Interface().(interface{ Definition() string })
+	if ok {
+		yamlDef = gen.yamlDefs[definer.Definition()]
+	}
+	if yamlDef != nil {
+		description := yamlDef.Description
+		if description == "" {
+			description = yamlDef.Note
+		}
+		if description != "" {
+			schema["description"] = description
+		}
+	}
+
+	required := schemaRequired[t.Name()]
+	if yamlDef != nil && yamlDef.HasTag {
+		required = append(required, "Tag")
+	}
+
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		if jsonTag != "" && strings.Split(jsonTag, ",")[0] != "" {
+			name = strings.Split(jsonTag, ",")[0]
+		}
+
+		fieldType := field.Type
+		isNullable := false
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			isNullable = !schemaNonNullable[t.Name()+"."+field.Name]
+		}
+		if fieldType.Kind() == reflect.Interface {
+			return nil, fmt.Errorf("field %s is an interface", field.Name)
+		}
+
+		prop, err := gen.typeSchema(fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if isNullable {
+			prop = map[string]interface{}{"anyOf": []interface{}{prop, map[string]interface{}{"type": "null"}}}
+		}
+		if yamlDef != nil {
+			description := propertyDescription(yamlDef.Properties, field.Name)
+			if description != "" {
+				prop["description"] = description
+			}
+		}
+		properties[name] = prop
+	}
+	for _, name := range required {
+		_, ok := properties[name]
+		if !ok {
+			return nil, fmt.Errorf("required property %s not found", name)
+		}
+	}
+
+	schema["properties"] = properties
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// propertyDescription returns the description of the yaml property named like a field
+func propertyDescription(props []Property, fieldName string) string {
+	for _, prop := range props {
+		if strings.EqualFold(strings.TrimSuffix(prop.Name, "?"), fieldName) {
+			if prop.Description != "" {
+				return prop.Description
+			}
+			return prop.Note
+		}
+		description := propertyDescription(prop.Properties, fieldName)
+		if description != "" {
+			return description
+		}
+	}
+	return ""
+}
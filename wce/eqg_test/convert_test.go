@@ -0,0 +1,448 @@
+package wce_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+// chrWce returns an elf with a root and a head dag, one skin and a two frame C01 animation
+func chrWce() *wce.Wce {
+	w := wce.New("elf_chr.s3d")
+	w.ActorDefs = append(w.ActorDefs, &wce.ActorDef{
+		Tag:     "ELF_ACTORDEF",
+		Actions: []wce.ActorAction{{LevelOfDetails: []wce.ActorLevelOfDetail{{SpriteTag: "ELF_HS_DEF"}}}},
+	})
+	w.HierarchicalSpriteDefs = append(w.HierarchicalSpriteDefs, &wce.HierarchicalSpriteDef{
+		Tag: "ELF_HS_DEF",
+		Dags: []wce.Dag{
+			{Tag: "ELF_DAG", Track: "ELF_TRACK", SubDags: []uint32{1}},
+			{Tag: "ELFHE_DAG", Track: "ELFHE_TRACK"},
+		},
+		AttachedSkins: []wce.AttachedSkin{{DMSpriteTag: "ELF_DMSPRITEDEF"}},
+	})
+	w.TrackInstances = append(w.TrackInstances,
+		&wce.TrackInstance{Tag: "ELF_TRACK", SpriteTag: "ELF_TRACKDEF"},
+		&wce.TrackInstance{Tag: "ELFHE_TRACK", SpriteTag: "ELFHE_TRACKDEF"},
+		&wce.TrackInstance{Tag: "C01ELFHE_TRACK", SpriteTag: "C01ELFHE_TRACKDEF", Sleep: wce.NullUint32{Uint32: 50, Valid: true}},
+	)
+	// the head is 2 units above the root and turned a quarter around z
+	quarter := int16(11585) // 16384 * sin(45 degrees)
+	w.TrackDefs = append(w.TrackDefs,
+		&wce.TrackDef{Tag: "ELF_TRACKDEF", Frames: []*wce.Frame{{RotScale: 16384}}},
+		&wce.TrackDef{Tag: "ELFHE_TRACKDEF", Frames: []*wce.Frame{{XYZScale: 256, XYZ: [3]int16{0, 0, 512}, RotScale: quarter, Rotation: [3]int16{0, 0, quarter}}}},
+		&wce.TrackDef{Tag: "C01ELFHE_TRACKDEF", Frames: []*wce.Frame{
+			{XYZScale: 256, XYZ: [3]int16{0, 0, 512}, RotScale: 16384},
+			{XYZScale: 256, XYZ: [3]int16{0, 0, 768}, RotScale: 16384},
+		}},
+	)
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s, &wce.DMSpriteDef2{
+		Tag:                  "ELF_DMSPRITEDEF",
+		MaterialPaletteTag:   "ELF_MP",
+		Vertices:             [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 0, 0}},
+		VertexNormals:        [][3]float32{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {1, 0, 0}},
+		UVs:                  [][2]float32{{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+		SkinAssignmentGroups: [][2]int16{{3, 0}, {1, 1}},
+		Faces:                []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}, {Triangle: [3]uint16{0, 1, 3}, Passable: 1}},
+		FaceMaterialGroups:   [][2]uint16{{1, 0}, {1, 1}},
+	})
+	w.MaterialPalettes = append(w.MaterialPalettes, &wce.MaterialPalette{Tag: "ELF_MP", Materials: []string{"ELFCH0001_MDF", "ELFHE0001_MDF"}})
+	w.MaterialDefs = append(w.MaterialDefs,
+		&wce.MaterialDef{Tag: "ELFCH0001_MDF", RenderMethod: "USERDEFINED_2", SimpleSpriteTag: "ELFCH0001_SPRITE"},
+		&wce.MaterialDef{Tag: "ELFHE0001_MDF", RenderMethod: "TRANSTEXTURE1", SimpleSpriteTag: "ELFHE0001_SPRITE"},
+	)
+	w.SimpleSpriteDefs = append(w.SimpleSpriteDefs,
+		&wce.SimpleSpriteDef{Tag: "ELFCH0001_SPRITE", SimpleSpriteFrames: []wce.SimpleSpriteFrame{{TextureFiles: []string{"ELFCH0001.BMP"}}}},
+		&wce.SimpleSpriteDef{Tag: "ELFHE0001_SPRITE", SimpleSpriteFrames: []wce.SimpleSpriteFrame{{TextureFiles: []string{"ELFHE0001.BMP"}}}},
+	)
+	return w
+}
+
+func TestConvertChr(t *testing.T) {
+	w := chrWce()
+	files, err := eqgFiles(w)
+	if err != nil {
+		t.Fatalf("write eqg: %s", err)
+	}
+	if len(files) != 2 || files["elf.mds"] == nil || files["elf_c01.ani"] == nil {
+		t.Fatalf("got %d files, want elf.mds and elf_c01.ani", len(files))
+	}
+
+	mds := &raw.Mds{}
+	err = mds.Read(bytes.NewReader(files["elf.mds"]))
+	if err != nil {
+		t.Fatalf("read mds: %s", err)
+	}
+	if len(mds.Bones) != 2 || mds.Bones[0].Name != "elf" || mds.Bones[1].Name != "elfhe" {
+		t.Fatalf("bones: %+v", mds.Bones)
+	}
+	if mds.Bones[0].ChildIndex != 1 || mds.Bones[0].ChildrenCount != 1 || mds.Bones[1].ChildIndex != -1 || mds.Bones[1].Next != -1 {
+		t.Fatalf("bone hierarchy: %+v %+v", mds.Bones[0], mds.Bones[1])
+	}
+	if len(mds.Materials) != 2 || mds.Materials[0].ShaderName != "Opaque_MaxCB1.fx" || mds.Materials[1].ShaderName != "Alpha_MaxCB1.fx" {
+		t.Fatalf("materials: %+v", mds.Materials)
+	}
+	if len(mds.Materials[0].Properties) != 1 || mds.Materials[0].Properties[0].Value != "elfch0001.bmp" {
+		t.Fatalf("material properties: %+v", mds.Materials[0].Properties)
+	}
+	if len(mds.Models) != 1 || len(mds.Models[0].Vertices) != 4 || len(mds.Models[0].Faces) != 2 {
+		t.Fatalf("models: %+v", mds.Models)
+	}
+
+	// the head vertex is moved from head space to model space
+	head := mds.Models[0].Vertices[3]
+	if !near(head.Position, [3]float32{0, 1, 2}) || !near(head.Normal, [3]float32{0, 1, 0}) {
+		t.Fatalf("head vertex: position %v normal %v, want [0 1 2] [0 1 0]", head.Position, head.Normal)
+	}
+	if len(head.Weights) != 1 || head.Weights[0].BoneIndex != 1 || head.Weights[0].Value != 1 {
+		t.Fatalf("head vertex weights: %+v", head.Weights)
+	}
+	face := mds.Models[0].Faces[1]
+	if face.MaterialName != "ELFHE0001_MDF" || face.Flags&1 == 0 {
+		t.Fatalf("face: %+v", face)
+	}
+
+	ani := &raw.Ani{}
+	err = ani.Read(bytes.NewReader(files["elf_c01.ani"]))
+	if err != nil {
+		t.Fatalf("read ani: %s", err)
+	}
+	if len(ani.Bones) != 1 || ani.Bones[0].Name != "elfhe" || len(ani.Bones[0].Frames) != 2 {
+		t.Fatalf("ani bones: %+v", ani.Bones)
+	}
+	frame := ani.Bones[0].Frames[1]
+	if frame.Milliseconds != 50 || !near(frame.Translation, [3]float32{0, 0, 3}) || frame.Rotation != [4]float32{0, 0, 0, 1} {
+		t.Fatalf("ani frame: %+v", frame)
+	}
+
+	again, err := eqgFiles(w)
+	if err != nil {
+		t.Fatalf("write eqg again: %s", err)
+	}
+	for name, data := range files {
+		if !bytes.Equal(again[name], data) {
+			t.Fatalf("second write: %s differs", name)
+		}
+	}
+}
+
+func TestConvertZone(t *testing.T) {
+	w := wce.New("tiny.wld")
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s,
+		&wce.DMSpriteDef2{
+			Tag:                "R1_DMSPRITEDEF",
+			CenterOffset:       [3]float32{10, 0, 0},
+			Vertices:           [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+			Faces:              []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+			FaceMaterialGroups: [][2]uint16{{1, 0}},
+			MaterialPaletteTag: "TINY_MP",
+		},
+		&wce.DMSpriteDef2{
+			Tag:                "TREE_DMSPRITEDEF",
+			Vertices:           [][3]float32{{0, 0, 0}, {0, 0, 5}, {1, 0, 0}},
+			Faces:              []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+			FaceMaterialGroups: [][2]uint16{{1, 0}},
+		},
+	)
+	w.MaterialPalettes = append(w.MaterialPalettes, &wce.MaterialPalette{Tag: "TINY_MP", Materials: []string{"GRASS_MDF"}})
+	w.MaterialDefs = append(w.MaterialDefs, &wce.MaterialDef{Tag: "GRASS_MDF", RenderMethod: "TRANSPARENT"})
+	w.ActorDefs = append(w.ActorDefs, &wce.ActorDef{
+		Tag:     "TREE_ACTORDEF",
+		Actions: []wce.ActorAction{{LevelOfDetails: []wce.ActorLevelOfDetail{{SpriteTag: "TREE_DMSPRITEDEF"}}}},
+	})
+
+	objects := wce.New("objects.wld")
+	objects.ActorInsts = append(objects.ActorInsts,
+		&wce.ActorInst{DefinitionTag: "TREE_ACTORDEF", Location: wce.NullFloat32Slice6{Float32Slice6: [6]float32{1, 2, 3, 0, 0, 128}, Valid: true}},
+		&wce.ActorInst{DefinitionTag: "ROCK_ACTORDEF", Location: wce.NullFloat32Slice6{Float32Slice6: [6]float32{4, 5, 6, 0, 0, 0}, Valid: true}, Scale: wce.NullFloat32{Float32: 2, Valid: true}},
+	)
+	lights := wce.New("lights.wld")
+	lights.LightDefs = append(lights.LightDefs, &wce.LightDef{Tag: "TORCH_LDEF", Colors: [][3]float32{{1, 0.5, 0}}})
+	lights.PointLights = append(lights.PointLights, &wce.PointLight{Tag: "TORCH_PL", LightDefTag: "TORCH_LDEF", Location: [3]float32{7, 8, 9}, Radius: 30})
+
+	files, err := eqgFiles(w, objects, lights)
+	if err != nil {
+		t.Fatalf("write eqg: %s", err)
+	}
+	if len(files) != 3 || files["tiny.ter"] == nil || files["tree.mod"] == nil || files["tiny.zon"] == nil {
+		t.Fatalf("got %d files, want tiny.ter, tree.mod and tiny.zon", len(files))
+	}
+
+	ter := &raw.Ter{}
+	err = ter.Read(bytes.NewReader(files["tiny.ter"]))
+	if err != nil {
+		t.Fatalf("read ter: %s", err)
+	}
+	if len(ter.Vertices) != 3 || ter.Vertices[1].Position != [3]float32{11, 0, 0} {
+		t.Fatalf("ter vertices: %+v", ter.Vertices)
+	}
+	if len(ter.Faces) != 1 || ter.Faces[0].Flags&2 == 0 {
+		t.Fatalf("ter faces: %+v, want transparent", ter.Faces)
+	}
+
+	zon := &raw.Zon{}
+	err = zon.Read(bytes.NewReader(files["tiny.zon"]))
+	if err != nil {
+		t.Fatalf("read zon: %s", err)
+	}
+	if len(zon.Models) != 3 || zon.Models[0] != "tiny.ter" || zon.Models[1] != "tree.mod" || zon.Models[2] != "rock.mod" {
+		t.Fatalf("zon models: %v", zon.Models)
+	}
+	if len(zon.Instances) != 3 {
+		t.Fatalf("zon instances: %+v", zon.Instances)
+	}
+	tree := zon.Instances[1]
+	if tree.ModelTag != "tree.mod" || tree.Translation != [3]float32{1, 2, 3} || !near(tree.Rotation, [3]float32{math.Pi / 2, 0, 0}) || tree.Scale != 1 {
+		t.Fatalf("tree instance: %+v", tree)
+	}
+	if zon.Instances[2].Scale != 2 {
+		t.Fatalf("rock instance: %+v", zon.Instances[2])
+	}
+	if len(zon.Lights) != 1 || zon.Lights[0].Color != [3]float32{1, 0.5, 0} || zon.Lights[0].Radius != 30 {
+		t.Fatalf("zon lights: %+v", zon.Lights)
+	}
+}
+
+func TestConvertChrToWld(t *testing.T) {
+	files, err := eqgFiles(chrWce())
+	if err != nil {
+		t.Fatalf("write eqg: %s", err)
+	}
+	archive, err := pfs.New("elf.eqg")
+	if err != nil {
+		t.Fatalf("new archive: %s", err)
+	}
+	defer archive.Close()
+	for name, data := range files {
+		err = archive.Add(name, data)
+		if err != nil {
+			t.Fatalf("add %s: %s", name, err)
+		}
+	}
+	w := wce.New("elf.eqg")
+	err = w.ReadEqgRaw(archive)
+	if err != nil {
+		t.Fatalf("read eqg: %s", err)
+	}
+	w.LayDefs = append(w.LayDefs, &wce.EqgLayDef{
+		Tag:    "elf_02",
+		Layers: []*wce.LayEntry{{Material: "ELFCH0001_MDF", Diffuse: "elfch0201.dds"}},
+	})
+	if len(w.MdsDefs) != 1 || len(w.MdsDefs[0].Bones) != 2 {
+		t.Fatalf("mds: %+v", w.MdsDefs)
+	}
+	w.PtsDefs = append(w.PtsDefs, &wce.EqgParticlePointDef{
+		Tag:    w.MdsDefs[0].Tag,
+		Points: []*wce.ParticlePointEntry{{Name: "HEAD_POINT", BoneName: w.MdsDefs[0].Bones[1].Name, Translation: [3]float32{0, 0, 1}, Scale: [3]float32{1, 1, 1}}},
+	})
+
+	back, err := wldRoundTrip(w, "elf_chr.wld")
+	if err != nil {
+		t.Fatalf("wld: %s", err)
+	}
+	if len(back.HierarchicalSpriteDefs) != 1 {
+		t.Fatalf("got %d hierarchical sprites, want 1", len(back.HierarchicalSpriteDefs))
+	}
+	hiSprite := back.HierarchicalSpriteDefs[0]
+	if hiSprite.Tag != "ELF_HS_DEF" || len(hiSprite.Dags) != 3 || hiSprite.Dags[0].Tag != "ELF_DAG" || hiSprite.Dags[1].Tag != "ELFHE_DAG" || hiSprite.Dags[2].Tag != "ELFHEAD_POINT_DAG" {
+		t.Fatalf("hierarchical sprite: %+v", hiSprite)
+	}
+	if len(hiSprite.Dags[0].SubDags) != 1 || hiSprite.Dags[0].SubDags[0] != 1 {
+		t.Fatalf("root sub dags: %v", hiSprite.Dags[0].SubDags)
+	}
+	skel, err := back.Skeleton("ELF_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	point := back.AttachPoint(skel, "HEAD_POINT")
+	if point == nil || point.Bone != "HE" || !nearWld(point.Translation, [3]float32{0, 0, 1}) {
+		t.Fatalf("head point: %+v", point)
+	}
+	if len(hiSprite.AttachedSkins) != 1 || hiSprite.AttachedSkins[0].DMSpriteTag != "ELF_DMSPRITEDEF" {
+		t.Fatalf("attached skins: %+v", hiSprite.AttachedSkins)
+	}
+
+	var skin *wce.DMSpriteDef2
+	for _, sprite := range back.DMSpriteDef2s {
+		if sprite.Tag == "ELF_DMSPRITEDEF" {
+			skin = sprite
+		}
+	}
+	if skin == nil || len(skin.Vertices) != 4 || len(skin.Faces) != 2 {
+		t.Fatalf("skin: %+v", skin)
+	}
+	// the head vertex is moved back to head space
+	if !nearWld(skin.Vertices[3], [3]float32{1, 0, 0}) || !nearWld(skin.VertexNormals[3], [3]float32{1, 0, 0}) {
+		t.Fatalf("head vertex: position %v normal %v, want [1 0 0] [1 0 0]", skin.Vertices[3], skin.VertexNormals[3])
+	}
+	if len(skin.SkinAssignmentGroups) != 2 || skin.SkinAssignmentGroups[0] != [2]int16{3, 0} || skin.SkinAssignmentGroups[1] != [2]int16{1, 1} {
+		t.Fatalf("skin assignment groups: %v", skin.SkinAssignmentGroups)
+	}
+	if skin.Faces[1].Passable != 1 || len(skin.FaceMaterialGroups) != 2 {
+		t.Fatalf("faces: %+v groups %v", skin.Faces[1], skin.FaceMaterialGroups)
+	}
+
+	methods := make(map[string]string)
+	variations := make(map[string]int)
+	for _, matDef := range back.MaterialDefs {
+		methods[matDef.Tag] = matDef.RenderMethod
+		variations[matDef.Tag] = matDef.Variation
+	}
+	if methods["ELFCH0001_MDF"] != "USERDEFINED_2" || methods["ELFHE0001_MDF"] != "USERDEFINED_6" {
+		t.Fatalf("render methods: %v", methods)
+	}
+	if variations["ELFCH0201_MDF"] != 1 {
+		t.Fatalf("lay variation ELFCH0201_MDF not found: %v", variations)
+	}
+
+	var animation *wce.TrackInstance
+	for _, track := range back.TrackInstances {
+		if track.Tag == "C01ELFHE_TRACK" {
+			animation = track
+		}
+	}
+	if animation == nil || !animation.Sleep.Valid || animation.Sleep.Uint32 != 50 {
+		t.Fatalf("animation track: %+v", animation)
+	}
+	for _, trackDef := range back.TrackDefs {
+		if trackDef.Tag != animation.SpriteTag {
+			continue
+		}
+		transforms := trackDef.Transforms()
+		if len(transforms) != 2 || !near(transforms[1].Translation, [3]float32{0, 0, 3}) {
+			t.Fatalf("animation frames: %+v", transforms)
+		}
+		return
+	}
+	t.Fatalf("animation trackdef %s not found", animation.SpriteTag)
+}
+
+func TestConvertZoneToWld(t *testing.T) {
+	grass := &wce.EQMaterialDef{
+		Tag:        "grass",
+		ShaderTag:  "Chroma_MaxCB1.fx",
+		Properties: []*wce.MaterialProperty{{Name: "e_TextureDiffuse0", Type: raw.MaterialParamTypeTexture, Value: "grass.dds"}},
+	}
+	w := wce.New("tiny.eqg")
+	w.TerDefs = append(w.TerDefs, &wce.EqgTerDef{
+		Tag:       "tiny",
+		Materials: []*wce.EQMaterialDef{grass},
+		Vertices: []*wce.ModVertex{
+			{Position: [3]float32{0, 0, 0}}, {Position: [3]float32{10, 0, 0}}, {Position: [3]float32{0, 10, 0}},
+			{Position: [3]float32{1000, 0, 0}}, {Position: [3]float32{1010, 0, 0}}, {Position: [3]float32{1000, 10, 0}},
+		},
+		Faces: []*wce.ModFace{
+			{Index: [3]uint32{0, 1, 2}, MaterialName: "grass"},
+			{Index: [3]uint32{3, 4, 5}, MaterialName: "grass", Transparent: 1},
+		},
+	})
+	w.ModDefs = append(w.ModDefs, &wce.EqgModDef{
+		Tag:       "tree",
+		Materials: []*wce.EQMaterialDef{{Tag: "bark", ShaderTag: "Opaque_MaxCB1.fx"}},
+		Vertices:  []*wce.ModVertex{{Position: [3]float32{0, 0, 0}}, {Position: [3]float32{0, 0, 5}}, {Position: [3]float32{1, 0, 0}}},
+		Faces:     []*wce.ModFace{{Index: [3]uint32{0, 1, 2}, MaterialName: "bark"}},
+	})
+	w.ZonDefs = append(w.ZonDefs, &wce.EqgZonDef{
+		Tag:    "tiny",
+		Models: []string{"tiny.ter", "tree.mod"},
+		Instances: []wce.EqgZonInstance{
+			{ModelTag: "tiny.ter", InstanceTag: "tiny", Scale: 1},
+			{ModelTag: "tree.mod", InstanceTag: "tree01", Translation: [3]float32{1, 2, 3}, Rotation: [3]float32{math.Pi / 2, 0, 0}, Scale: 2},
+		},
+		Lights: []wce.EqgZonLight{{Name: "torch", Position: [3]float32{7, 8, 9}, Color: [3]float32{1, 0.5, 0}, Radius: 30}},
+	})
+
+	back, err := wldRoundTrip(w, "tiny.wld")
+	if err != nil {
+		t.Fatalf("wld: %s", err)
+	}
+
+	sprites := make(map[string]*wce.DMSpriteDef2)
+	for _, sprite := range back.DMSpriteDef2s {
+		sprites[sprite.Tag] = sprite
+	}
+	if len(sprites) != 3 || sprites["R1_DMSPRITEDEF"] == nil || sprites["R2_DMSPRITEDEF"] == nil || sprites["TREE_DMSPRITEDEF"] == nil {
+		t.Fatalf("sprites: %v", sprites)
+	}
+	region := sprites["R2_DMSPRITEDEF"]
+	if !near(region.CenterOffset, [3]float32{1005, 5, 0}) || !near(region.Vertices[0], [3]float32{-5, -5, 0}) {
+		t.Fatalf("region: center %v vertices %v", region.CenterOffset, region.Vertices)
+	}
+	if len(back.Regions) != 2 || back.Regions[1].SpriteTag != "R2_DMSPRITEDEF" {
+		t.Fatalf("regions: %+v", back.Regions)
+	}
+	visible, err := back.Regions[0].VisibleRegions()
+	if err != nil || len(visible) != 2 {
+		t.Fatalf("region 1 visible: %v %v", visible, err)
+	}
+	if len(back.WorldTrees) != 1 {
+		t.Fatalf("got %d world trees, want 1", len(back.WorldTrees))
+	}
+	tree := back.WorldTrees[0]
+	if tree.RegionAt(5, 5, 0) != "R000001" || tree.RegionAt(1005, 5, 0) != "R000002" || tree.RegionAt(2000, 5, 0) != "R000002" {
+		t.Fatalf("world tree: %+v", tree.WorldNodes)
+	}
+
+	methods := make(map[string]string)
+	for _, matDef := range back.MaterialDefs {
+		methods[matDef.Tag] = matDef.RenderMethod
+	}
+	if methods["GRASS_MDF"] != "USERDEFINED_20" || methods["TRANSPARENT_MDF"] != "TRANSPARENT" || methods["BARK_MDF"] != "SOLIDFILLAMBIENTGOURAUD1" {
+		t.Fatalf("render methods: %v", methods)
+	}
+
+	if len(back.ActorInsts) != 1 {
+		t.Fatalf("got %d actor instances, want 1", len(back.ActorInsts))
+	}
+	actor := back.ActorInsts[0]
+	loc := actor.Location.Float32Slice6
+	if actor.DefinitionTag != "TREE_ACTORDEF" || !near([3]float32{loc[0], loc[1], loc[2]}, [3]float32{1, 2, 3}) || !near([3]float32{loc[3], loc[4], loc[5]}, [3]float32{0, 0, 128}) || actor.Scale.Float32 != 2 {
+		t.Fatalf("tree actor: %+v", actor)
+	}
+	if len(back.PointLights) != 1 || back.PointLights[0].Location != [3]float32{7, 8, 9} || back.PointLights[0].Radius != 30 {
+		t.Fatalf("point lights: %+v", back.PointLights)
+	}
+}
+
+// wldRoundTrip writes w to a wld and reads it back
+func wldRoundTrip(w *wce.Wce, name string) (*wce.Wce, error) {
+	buf := &bytes.Buffer{}
+	err := w.WriteWldRaw(buf)
+	if err != nil {
+		return nil, err
+	}
+	rawWld := &raw.Wld{}
+	err = rawWld.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	back := wce.New(name)
+	err = back.ReadWldRaw(rawWld)
+	if err != nil {
+		return nil, err
+	}
+	return back, nil
+}
+
+func near(a [3]float32, b [3]float32) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 0.001 {
+			return false
+		}
+	}
+	return true
+}
+
+// nearWld compares a value that went through a wld, whose normals are stored in an int8
+func nearWld(a [3]float32, b [3]float32) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 0.01 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package wce_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/xackery/quail/pfs"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	src := wce.New("box.eqg")
+	src.ModDefs = append(src.ModDefs, &wce.EqgModDef{
+		Tag:     "box",
+		Version: 1,
+		Materials: []*wce.EQMaterialDef{{
+			Tag:       "box_mat",
+			ShaderTag: "Opaque_MaxCB1.fx",
+			Properties: []*wce.MaterialProperty{
+				{Name: "e_TextureDiffuse0", Type: raw.MaterialParamTypeTexture, Value: "box.dds"},
+			},
+		}},
+		Vertices: []*wce.ModVertex{
+			{Position: [3]float32{0, 0, 0}, Normal: [3]float32{0, 0, 1}, Tint: [4]uint8{128, 128, 128, 255}, Uv: [2]float32{0, 0}},
+			{Position: [3]float32{1, 0, 0}, Normal: [3]float32{0, 0, 1}, Tint: [4]uint8{128, 128, 128, 255}, Uv: [2]float32{1, 0}},
+			{Position: [3]float32{0, 1, 0}, Normal: [3]float32{0, 0, 1}, Tint: [4]uint8{128, 128, 128, 255}, Uv: [2]float32{0, 1}},
+		},
+		Faces: []*wce.ModFace{{Index: [3]uint32{0, 1, 2}, MaterialName: "box_mat", Collision: 1}},
+	})
+
+	want, err := eqgFiles(src)
+	if err != nil {
+		t.Fatalf("write eqg: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "box.json")
+	err = src.WriteJSON(path)
+	if err != nil {
+		t.Fatalf("write json: %s", err)
+	}
+	dst, err := wce.ReadJSON("box.eqg", path)
+	if err != nil {
+		t.Fatalf("read json: %s", err)
+	}
+	got, err := eqgFiles(dst)
+	if err != nil {
+		t.Fatalf("write eqg from json: %s", err)
+	}
+
+	if len(got) != len(want) || len(want) == 0 {
+		t.Fatalf("eqg from json has %d files, want %d", len(got), len(want))
+	}
+	for name, data := range want {
+		if !bytes.Equal(got[name], data) {
+			t.Fatalf("eqg from json: %s differs", name)
+		}
+	}
+}
+
+// eqgFiles returns the files WriteEqgRaw adds to an archive
+func eqgFiles(w *wce.Wce, parts ...*wce.Wce) (map[string][]byte, error) {
+	archive, err := pfs.New(w.FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	err = w.WriteEqgRaw(archive, parts...)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.Files() {
+		files[file.Name()] = file.Data()
+	}
+	return files, nil
+}
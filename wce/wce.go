@@ -4,6 +4,7 @@ package wce
 import (
 	"strings"
 
+	"github.com/xackery/quail/anim"
 	"github.com/xackery/quail/qfs"
 	"github.com/xackery/quail/raw"
 )
@@ -12,14 +13,17 @@ var AsciiVersion = "v0.0.1"
 
 // Wce is a struct representing a Wce file
 type Wce struct {
-	FileSystem             qfs.QFS
-	isVariationMaterial    bool   // set true while writing or reading variations
-	lastReadFolder         string // used during wce parsing to remember context
-	isObj                  bool   // true when a _obj suffix is found in path
-	isChr                  bool   // true when a _chr suffix is found in path
+	FileSystem             qfs.QFS `json:"-"`
+	isVariationMaterial    bool    // set true while writing or reading variations
+	lastReadFolder         string  // used during wce parsing to remember context
+	isObj                  bool    // true when a _obj suffix is found in path
+	isChr                  bool    // true when a _chr suffix is found in path
 	maxMaterialHeads       map[string]int
 	maxMaterialTextures    map[string]int
-	tagIndexes             map[string]int // used when parsing to keep track of indexes
+	tagIndexes             map[string]int           // used when parsing to keep track of indexes
+	positions              map[interface{}]Position // source position of each definition read from ascii
+	lookup                 *tagLookup               // definitions by tag, see ByTag
+	animationOptions       anim.Options             // applied to keyframes as animations are written, see SetAnimationOptions
 	FileName               string
 	WorldDef               *WorldDef
 	GlobalAmbientLightDef  *GlobalAmbientLightDef
@@ -59,6 +63,12 @@ type Wce struct {
 	ZonDefs                []*EqgZonDef
 }
 
+// SetAnimationOptions sets how keyframes are resampled, reduced and smoothed as animations are
+// written or converted, including those added by AnimationSet
+func (wce *Wce) SetAnimationOptions(opts anim.Options) {
+	wce.animationOptions = opts
+}
+
 type WldDefinitioner interface {
 	Definition() string
 	ToRaw(src *Wce, dst *raw.Wld) (int32, error)
@@ -81,155 +91,64 @@ func New(filename string) *Wce {
 	}
 }
 
-// ByTag returns a instance by tag
+// byTagSuffixes are the kinds of definition ByTag tries first for a tag suffix, in order
+var byTagSuffixes = []struct {
+	suffix      string
+	definitions []string
+}{
+	{"_SPRITE", []string{"SIMPLESPRITEDEF", "BLITSPRITEDEF"}},
+	{"_PCD", []string{"PARTICLECLOUDDEF"}},
+	{"_SPB", []string{"BLITSPRITEDEF"}},
+	{"_MDF", []string{"MATERIALDEFINITION"}},
+	{"_MP", []string{"MATERIALPALETTE"}},
+	{"_DMSPRITEDEF", []string{"DMSPRITEDEF2", "DMSPRITEDEFINITION"}},
+	{"_DMTRACKDEF", []string{"DMTRACKDEF2"}},
+	{"_LIGHTDEF", []string{"LIGHTDEFINITION"}},
+	{"_LDEF", []string{"LIGHTDEFINITION"}},
+	{"_TRACKDEF", []string{"TRACKDEFINITION"}},
+	{"_HS_DEF", []string{"HIERARCHICALSPRITEDEF"}},
+	{"_POLYHDEF", []string{"POLYHEDRONDEFINITION"}},
+	{"_DMT", []string{"RGBDEFORMATIONTRACKDEF"}},
+}
+
+// byTagWithIndexSuffixes are the kinds of definition ByTagWithIndex tries for a tag suffix, in order
+var byTagWithIndexSuffixes = []struct {
+	suffix      string
+	definitions []string
+}{
+	{"_DMSPRITEDEF", []string{"DMSPRITEDEF2", "DMSPRITEDEFINITION"}},
+	{"_TRACK", []string{"TRACKINSTANCE"}},
+	{"_TRACKDEF", []string{"TRACKDEFINITION"}},
+	{"_MDF", []string{"MATERIALDEFINITION"}},
+	{"_SPRITE", []string{"SIMPLESPRITEDEF"}},
+	{"_PCD", []string{"PARTICLECLOUDDEF"}},
+}
+
+// ByTag returns a instance by tag. The suffix of a tag decides which kind of definition is tried first
 func (wce *Wce) ByTag(tag string) WldDefinitioner {
 	if tag == "" {
 		return nil
 	}
-	if strings.HasSuffix(tag, "_SPRITE") {
-		for _, sprite := range wce.SimpleSpriteDefs {
-			if sprite.Tag == tag {
-				return sprite
-			}
-		}
-		for _, sprite := range wce.BlitSpriteDefs {
-			if sprite.Tag == tag {
-				return sprite
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_PCD") {
-		for _, cloud := range wce.ParticleCloudDefs {
-			if cloud.Tag == tag {
-				return cloud
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_SPB") {
-		for _, sprite := range wce.BlitSpriteDefs {
-			if sprite.Tag == tag {
-				return sprite
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_MDF") {
-		for _, material := range wce.MaterialDefs {
-			if material.Tag == tag {
-				return material
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_MP") {
-		for _, palette := range wce.MaterialPalettes {
-			if palette.Tag == tag {
-				return palette
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_DMSPRITEDEF") {
-		for _, sprite := range wce.DMSpriteDef2s {
-			if sprite.Tag == tag {
-				return sprite
-			}
-		}
-		for _, sprite := range wce.DMSpriteDefs {
-			if sprite.Tag == tag {
-				return sprite
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_DMTRACKDEF") {
-		for _, track := range wce.DMTrackDef2s {
-			if track.Tag == tag {
-				return track
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_LIGHTDEF") {
-		for _, light := range wce.LightDefs {
-			if light.Tag == tag {
-				return light
-			}
-		}
-	}
-	if strings.HasSuffix(tag, "_LDEF") {
-		for _, light := range wce.LightDefs {
-			if light.Tag == tag {
-				return light
-			}
+	for _, s := range byTagSuffixes {
+		if !strings.HasSuffix(tag, s.suffix) {
+			continue
 		}
-	}
-
-	if strings.HasSuffix(tag, "_TRACKDEF") {
-		for _, track := range wce.TrackDefs {
-			if track.Tag == tag {
-				return track
+		for _, definition := range s.definitions {
+			def := wce.tagFirst(definition, tag)
+			if def != nil {
+				return def
 			}
 		}
 	}
 
-	if strings.HasSuffix(tag, "_HS_DEF") {
-		for _, sprite := range wce.HierarchicalSpriteDefs {
-			if sprite.Tag == tag {
-				return sprite
-			}
+	for _, definition := range []string{"SPRITE3DDEF", "REGION", "ACTORDEF", "TRACKINSTANCE", "SPRITE2DDEF", "SIMPLESPRITEDEF"} {
+		def := wce.tagFirst(definition, tag)
+		if def != nil {
+			return def
 		}
 	}
-
-	if strings.HasSuffix(tag, "_POLYHDEF") {
-		for _, polyhedron := range wce.PolyhedronDefs {
-			if polyhedron.Tag == tag {
-				return polyhedron
-			}
-		}
-	}
-
-	if strings.HasSuffix(tag, "_DMT") {
-		for _, track := range wce.RGBTrackDefs {
-			if track.Tag == tag {
-				return track
-			}
-		}
-	}
-
-	for _, sprite := range wce.Sprite3DDefs {
-		if sprite.Tag == tag {
-			return sprite
-		}
-	}
-	for _, region := range wce.Regions {
-		if region.Tag == tag {
-			return region
-		}
-	}
-
-	for _, actor := range wce.ActorDefs {
-		if actor.Tag == tag {
-			return actor
-		}
-	}
-
-	for _, track := range wce.TrackInstances {
-		if track.Tag == tag {
-			return track
-		}
-	}
-
-	for _, sprite := range wce.Sprite2DDefs {
-		if sprite.Tag == tag {
-			return sprite
-		}
-	}
-
-	for _, sprite := range wce.SimpleSpriteDefs {
-		if sprite.Tag == tag {
-			return sprite
-		}
-		if strings.HasSuffix(sprite.Tag, "_SPRITE") && !strings.HasSuffix(tag, "_SPRITE") {
-			if sprite.Tag == tag+"_SPRITE" {
-				return sprite
-			}
-		}
+	if !strings.HasSuffix(tag, "_SPRITE") {
+		return wce.tagFirst("SIMPLESPRITEDEF", tag+"_SPRITE")
 	}
 	return nil
 }
@@ -239,60 +158,17 @@ func (wce *Wce) ByTagWithIndex(tag string, index int) WldDefinitioner {
 	if tag == "" {
 		return nil
 	}
-
-	if strings.HasSuffix(tag, "_DMSPRITEDEF") {
-		for _, dmsprite := range wce.DMSpriteDef2s {
-			if dmsprite.Tag == tag && dmsprite.TagIndex == index {
-				return dmsprite
-			}
+	for _, s := range byTagWithIndexSuffixes {
+		if !strings.HasSuffix(tag, s.suffix) {
+			continue
 		}
-		for _, dmsprite := range wce.DMSpriteDefs {
-			if dmsprite.Tag == tag && dmsprite.TagIndex == index {
-				return dmsprite
+		for _, definition := range s.definitions {
+			def := wce.tagFirstWithIndex(definition, tag, index)
+			if def != nil {
+				return def
 			}
 		}
 	}
-
-	if strings.HasSuffix(tag, "_TRACK") {
-		for _, track := range wce.TrackInstances {
-			if track.Tag == tag && track.TagIndex == index {
-				return track
-			}
-		}
-	}
-
-	if strings.HasSuffix(tag, "_TRACKDEF") {
-		for _, track := range wce.TrackDefs {
-			if track.Tag == tag && track.TagIndex == index {
-				return track
-			}
-		}
-	}
-
-	if strings.HasSuffix(tag, "_MDF") {
-		for _, material := range wce.MaterialDefs {
-			if material.Tag == tag && material.TagIndex == index {
-				return material
-			}
-		}
-	}
-
-	if strings.HasSuffix(tag, "_SPRITE") {
-		for _, sprite := range wce.SimpleSpriteDefs {
-			if sprite.Tag == tag && sprite.TagIndex == index {
-				return sprite
-			}
-		}
-	}
-
-	if strings.HasSuffix(tag, "_PCD") {
-		for _, sprite := range wce.ParticleCloudDefs {
-			if sprite.Tag == tag && sprite.TagIndex == index {
-				return sprite
-			}
-		}
-	}
-
 	return nil
 }
 
@@ -316,6 +192,8 @@ func (wce *Wce) reset() {
 	wce.GlobalAmbientLightDef = nil
 	wce.lastReadFolder = ""
 	wce.tagIndexes = make(map[string]int)
+	wce.positions = make(map[interface{}]Position)
+	wce.tagLookupReset()
 	wce.SimpleSpriteDefs = []*SimpleSpriteDef{}
 	wce.MaterialDefs = []*MaterialDef{}
 	wce.variationMaterialDefs = make(map[string][]*MaterialDef)
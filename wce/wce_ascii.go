@@ -25,7 +25,7 @@ func (wce *Wce) ReadAscii(path string) error {
 	}
 	err = asciiReader.readDefinitions()
 	if err != nil {
-		return fmt.Errorf("%s:%d: %w", path, asciiReader.lineNumber, err)
+		return fmt.Errorf("%s:%d: %w", path, asciiReader.sourceLine(), err)
 	}
 
 	switch wce.FileSystem.(type) {
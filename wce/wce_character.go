@@ -0,0 +1,425 @@
+package wce
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/quail/helper"
+)
+
+// Character is a playable race model and the heads, faces and armor texture sets it can be drawn
+// with. An eqg character is read through its wld conversion, so both are assembled the same way
+type Character struct {
+	Code        string // race code, such as ELF
+	Skeleton    *Skeleton
+	Heads       []int // head models, such as 1 for ELFHE01_DMSPRITEDEF
+	Faces       []int // face textures, such as 1 for ELFHE0011_MDF. 0 is the base face
+	TextureSets []int // armor texture sets, the material number of worn armor such as 2 for ELFCH0201_MDF. 0 is the base texture
+	src         *Wce
+	hiSprite    *HierarchicalSpriteDef
+}
+
+// CharacterLook is a choice of head, face and armor texture set of a character
+type CharacterLook struct {
+	Head       int
+	Face       int
+	TextureSet int
+}
+
+// CharacterMesh is a character assembled in its rest pose, in model space
+type CharacterMesh struct {
+	Vertices [][3]float32
+	Normals  [][3]float32
+	UVs      [][2]float32
+	Groups   []*CharacterMeshGroup
+}
+
+// CharacterMeshGroup is the triangles of a character mesh drawn with the same material
+type CharacterMeshGroup struct {
+	Material string // material definition tag
+	Texture  string // texture file name, empty for an untextured material
+	Indices  []uint32
+}
+
+// Character returns the character with the race code code, such as ELF
+func (wce *Wce) Character(code string) (*Character, error) {
+	code = strings.ToUpper(code)
+	src, err := wce.convertEQGToWld()
+	if err != nil {
+		return nil, fmt.Errorf("convert eqg to wld: %w", err)
+	}
+
+	var hiSprite *HierarchicalSpriteDef
+	for _, def := range src.HierarchicalSpriteDefs {
+		if def.Tag == code+"_HS_DEF" {
+			hiSprite = def
+			break
+		}
+	}
+	if hiSprite == nil {
+		return nil, fmt.Errorf("character %s not found", code)
+	}
+	skel, err := src.skeletonFromHierarchicalSprite(hiSprite)
+	if err != nil {
+		return nil, fmt.Errorf("skeleton: %w", err)
+	}
+
+	char := &Character{
+		Code:     code,
+		Skeleton: skel,
+		src:      src,
+		hiSprite: hiSprite,
+	}
+
+	regexHead := characterHeadRegex(code)
+	heads := make(map[int]bool)
+	for _, sprite := range src.DMSpriteDef2s {
+		match := regexHead.FindStringSubmatch(sprite.Tag)
+		if match == nil {
+			continue
+		}
+		head, _ := strconv.Atoi(match[1])
+		heads[head] = true
+	}
+
+	faces := map[int]bool{0: true}
+	textureSets := map[int]bool{0: true}
+	for _, matDef := range src.MaterialDefs {
+		part, set, page, ok := characterMaterialParse(code, matDef.Tag)
+		if !ok {
+			continue
+		}
+		textureSets[set] = true
+		if part == "HE" {
+			faces[page/10] = true
+		}
+	}
+
+	char.Heads = characterKeys(heads)
+	char.Faces = characterKeys(faces)
+	char.TextureSets = characterKeys(textureSets)
+	return char, nil
+}
+
+// Characters returns the race code of every hierarchical sprite with a head model or a
+// character material, such as ELF
+func (wce *Wce) Characters() ([]string, error) {
+	src, err := wce.convertEQGToWld()
+	if err != nil {
+		return nil, fmt.Errorf("convert eqg to wld: %w", err)
+	}
+	codes := []string{}
+	for _, hiSprite := range src.HierarchicalSpriteDefs {
+		code := strings.TrimSuffix(hiSprite.Tag, "_HS_DEF")
+		isCharacter := false
+		regexHead := characterHeadRegex(code)
+		for _, sprite := range src.DMSpriteDef2s {
+			if regexHead.MatchString(sprite.Tag) {
+				isCharacter = true
+				break
+			}
+		}
+		for _, matDef := range src.MaterialDefs {
+			if isCharacter {
+				break
+			}
+			_, _, _, isCharacter = characterMaterialParse(code, matDef.Tag)
+		}
+		if isCharacter {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// Mesh returns the character assembled with look. Skins are moved from the space of their bones
+// to model space, heads other than the one chosen are left out, and materials are swapped for the
+// face and texture set chosen where the character has them
+func (e *Character) Mesh(look CharacterLook) (*CharacterMesh, error) {
+	if len(e.Heads) > 0 && !characterHas(e.Heads, look.Head) {
+		return nil, fmt.Errorf("head %d not found, heads are %v", look.Head, e.Heads)
+	}
+	if !characterHas(e.Faces, look.Face) {
+		return nil, fmt.Errorf("face %d not found, faces are %v", look.Face, e.Faces)
+	}
+	if !characterHas(e.TextureSets, look.TextureSet) {
+		return nil, fmt.Errorf("texture set %d not found, texture sets are %v", look.TextureSet, e.TextureSets)
+	}
+
+	poses, err := e.Skeleton.restPoses()
+	if err != nil {
+		return nil, err
+	}
+
+	mesh := &CharacterMesh{}
+	regexHead := characterHeadRegex(e.Code)
+	isAdded := make(map[string]bool)
+	for _, skin := range e.hiSprite.AttachedSkins {
+		sprite, ok := e.src.tagFirst("DMSPRITEDEF2", skin.DMSpriteTag).(*DMSpriteDef2)
+		if !ok {
+			return nil, fmt.Errorf("attached skin %s not found", skin.DMSpriteTag)
+		}
+		if match := regexHead.FindStringSubmatch(sprite.Tag); match != nil {
+			// heads are added below, so only the chosen one is
+			continue
+		}
+		bones, err := characterVertexBones(sprite, len(poses))
+		if err != nil {
+			return nil, err
+		}
+		err = e.meshAppend(mesh, sprite, bones, poses, look)
+		if err != nil {
+			return nil, err
+		}
+		isAdded[sprite.Tag] = true
+	}
+
+	for _, sprite := range e.src.DMSpriteDef2s {
+		match := regexHead.FindStringSubmatch(sprite.Tag)
+		if match == nil {
+			continue
+		}
+		head, _ := strconv.Atoi(match[1])
+		if head != look.Head {
+			continue
+		}
+		bones, err := characterVertexBones(sprite, len(poses))
+		if err != nil {
+			return nil, err
+		}
+		err = e.meshAppend(mesh, sprite, bones, poses, look)
+		if err != nil {
+			return nil, err
+		}
+		isAdded[sprite.Tag] = true
+	}
+
+	// meshes on a dag follow it rigidly
+	for i, dag := range e.hiSprite.Dags {
+		if dag.SpriteTag == "" || isAdded[dag.SpriteTag] {
+			continue
+		}
+		sprite, ok := e.src.tagFirst("DMSPRITEDEF2", dag.SpriteTag).(*DMSpriteDef2)
+		if !ok {
+			continue
+		}
+		bones := make([]int, len(sprite.Vertices))
+		for j := range bones {
+			bones[j] = i
+		}
+		err = e.meshAppend(mesh, sprite, bones, poses, look)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mesh, nil
+}
+
+// meshAppend appends a dmsprite to mesh, each vertex moved by the pose of its bone
+func (e *Character) meshAppend(mesh *CharacterMesh, sprite *DMSpriteDef2, bones []int, poses []eqgBonePose, look CharacterLook) error {
+	palette := []string{}
+	if sprite.MaterialPaletteTag != "" {
+		def, ok := e.src.tagFirst("MATERIALPALETTE", sprite.MaterialPaletteTag).(*MaterialPalette)
+		if !ok {
+			return fmt.Errorf("dmspritedef2 %s: material palette %s not found", sprite.Tag, sprite.MaterialPaletteTag)
+		}
+		palette = def.Materials
+	}
+
+	offset := uint32(len(mesh.Vertices))
+	for i, vert := range sprite.Vertices {
+		position := [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+		normal := [3]float32{}
+		if i < len(sprite.VertexNormals) {
+			normal = sprite.VertexNormals[i]
+		}
+		uv := [2]float32{}
+		if i < len(sprite.UVs) {
+			uv = sprite.UVs[i]
+		}
+		pose := poses[bones[i]]
+		mesh.Vertices = append(mesh.Vertices, pose.apply(position))
+		mesh.Normals = append(mesh.Normals, helper.QuatRotate(pose.rotation, normal))
+		mesh.UVs = append(mesh.UVs, uv)
+	}
+
+	faceIndex := 0
+	for _, group := range sprite.FaceMaterialGroups {
+		tag := ""
+		if int(group[1]) < len(palette) {
+			tag = e.materialTag(palette[group[1]], look)
+		}
+		matDef, _ := e.src.tagFirst("MATERIALDEFINITION", tag).(*MaterialDef)
+		// TRANSPARENT materials are not drawn
+		isDrawn := matDef == nil || matDef.RenderMethod != "TRANSPARENT"
+		meshGroup := mesh.group(tag, e.src.materialTexture(matDef))
+		for i := 0; i < int(group[0]) && faceIndex < len(sprite.Faces); i++ {
+			face := sprite.Faces[faceIndex]
+			faceIndex++
+			for _, index := range face.Triangle {
+				if int(index) >= len(sprite.Vertices) {
+					return fmt.Errorf("dmspritedef2 %s: face %d: vertex %d out of range", sprite.Tag, faceIndex-1, index)
+				}
+			}
+			if !isDrawn {
+				continue
+			}
+			meshGroup.Indices = append(meshGroup.Indices, uint32(face.Triangle[0])+offset, uint32(face.Triangle[1])+offset, uint32(face.Triangle[2])+offset)
+		}
+	}
+	if faceIndex < len(sprite.Faces) {
+		return fmt.Errorf("dmspritedef2 %s: face material groups cover %d of %d faces", sprite.Tag, faceIndex, len(sprite.Faces))
+	}
+	return nil
+}
+
+// group returns the group of mesh drawn with material, adding it if needed
+func (e *CharacterMesh) group(material string, texture string) *CharacterMeshGroup {
+	for _, group := range e.Groups {
+		if group.Material == material {
+			return group
+		}
+	}
+	group := &CharacterMeshGroup{Material: material, Texture: texture}
+	e.Groups = append(e.Groups, group)
+	return group
+}
+
+// materialTag returns the material look draws in place of the base material tag. A head material,
+// such as ELFHE0001_MDF, becomes the face of look, such as ELFHE0021_MDF for face 2. Other parts
+// become the texture set of look, such as ELFCH0201_MDF for set 2. Materials the character does not
+// have fall back to the texture set 0, then the base
+func (e *Character) materialTag(tag string, look CharacterLook) string {
+	part, _, page, ok := characterMaterialParse(e.Code, tag)
+	if !ok {
+		return tag
+	}
+	candidates := []string{fmt.Sprintf("%s%s%02d%02d_MDF", e.Code, part, look.TextureSet, page)}
+	if part == "HE" {
+		page = look.Face*10 + page%10
+		candidates = []string{
+			fmt.Sprintf("%s%s%02d%02d_MDF", e.Code, part, look.TextureSet, page),
+			fmt.Sprintf("%s%s%02d%02d_MDF", e.Code, part, 0, page),
+			fmt.Sprintf("%s%s%02d%02d_MDF", e.Code, part, look.TextureSet, page%10),
+		}
+	}
+	for _, candidate := range candidates {
+		if e.src.tagFirst("MATERIALDEFINITION", candidate) != nil {
+			return candidate
+		}
+	}
+	return tag
+}
+
+// materialTexture returns the file name of the first texture of a material, or empty
+func (wce *Wce) materialTexture(matDef *MaterialDef) string {
+	if matDef == nil || matDef.SimpleSpriteTag == "" {
+		return ""
+	}
+	sprite, ok := wce.tagFirst("SIMPLESPRITEDEF", matDef.SimpleSpriteTag).(*SimpleSpriteDef)
+	if !ok {
+		return ""
+	}
+	for _, frame := range sprite.SimpleSpriteFrames {
+		if len(frame.TextureFiles) > 0 {
+			return frame.TextureFiles[0]
+		}
+	}
+	return ""
+}
+
+// RestPoses returns where each bone rests in model space
+func (e *Skeleton) RestPoses() ([]TrackTransform, error) {
+	poses, err := e.restPoses()
+	if err != nil {
+		return nil, err
+	}
+	transforms := []TrackTransform{}
+	for _, pose := range poses {
+		transforms = append(transforms, TrackTransform{Translation: pose.translation, Rotation: pose.rotation})
+	}
+	return transforms, nil
+}
+
+func (e *Skeleton) restPoses() ([]eqgBonePose, error) {
+	bones := []*MdsBone{}
+	parents := []int{}
+	for _, bone := range e.Bones {
+		bones = append(bones, &MdsBone{Name: bone.Name, Pivot: bone.Rest.Translation, Quaternion: bone.Rest.Rotation})
+		parents = append(parents, bone.Parent)
+	}
+	poses, err := eqgBonePoses(bones, parents)
+	if err != nil {
+		return nil, fmt.Errorf("skeleton %s: %w", e.Tag, err)
+	}
+	if len(poses) == 0 {
+		// a mesh with no bones stays where it is
+		poses = []eqgBonePose{{rotation: helper.QuatIdentity}}
+	}
+	return poses, nil
+}
+
+// characterVertexBones returns the bone each vertex of a skin follows, from its skin assignment
+// groups. A skin without groups follows the first bone
+func characterVertexBones(sprite *DMSpriteDef2, boneCount int) ([]int, error) {
+	bones := make([]int, len(sprite.Vertices))
+	vertexIndex := 0
+	for _, group := range sprite.SkinAssignmentGroups {
+		if group[1] < 0 || int(group[1]) >= boneCount {
+			return nil, fmt.Errorf("dmspritedef2 %s: skin assignment to dag %d out of range", sprite.Tag, group[1])
+		}
+		for i := 0; i < int(group[0]) && vertexIndex < len(bones); i++ {
+			bones[vertexIndex] = int(group[1])
+			vertexIndex++
+		}
+	}
+	if len(sprite.SkinAssignmentGroups) > 0 && vertexIndex < len(bones) {
+		return nil, fmt.Errorf("dmspritedef2 %s: skin assignment groups cover %d of %d vertices", sprite.Tag, vertexIndex, len(bones))
+	}
+	return bones, nil
+}
+
+// characterHeadRegex matches the head dmsprites of a race code, such as ELFHE01_DMSPRITEDEF or,
+// for a head split into pieces, ELFHE01_2_DMSPRITEDEF
+func characterHeadRegex(code string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(code) + `HE(\d\d)(_\d+)?_DMSPRITEDEF$`)
+}
+
+// characterMaterialParse returns the body part, texture set and page of a character material of a
+// race code, such as CH, 2 and 1 for ELFCH0201_MDF
+func characterMaterialParse(code string, tag string) (string, int, int, bool) {
+	if len(code) != 3 || !strings.HasPrefix(tag, code) || !regexChrMaterial.MatchString(tag) {
+		return "", 0, 0, false
+	}
+	set, err := strconv.Atoi(tag[5:7])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	page, err := strconv.Atoi(tag[7:9])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return tag[3:5], set, page, true
+}
+
+func characterKeys(values map[int]bool) []int {
+	keys := []int{}
+	for value := range values {
+		keys = append(keys, value)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func characterHas(values []int, value int) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
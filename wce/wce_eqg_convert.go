@@ -0,0 +1,590 @@
+package wce
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/quail/helper"
+	"github.com/xackery/quail/raw"
+)
+
+// convertWldToEQG returns a copy of wce with its wld definitions converted to eqg ones.
+// Hierarchical sprites become skinned mds with an ani for each animation, region meshes
+// become the ter of the zone, other meshes become mod, and actor instances and point lights
+// become the zon. parts are the other wld of the same s3d, such as objects.wld and lights.wld,
+// whose actors and lights are added to the zon. wce is left as is, so it can be written again
+func (wce *Wce) convertWldToEQG(parts ...*Wce) (*Wce, error) {
+	dst := *wce
+	dst.lookup = nil
+	dst.AniDefs = append([]*EqgAniDef{}, wce.AniDefs...)
+	dst.MdsDefs = append([]*EqgMdsDef{}, wce.MdsDefs...)
+	dst.ModDefs = append([]*EqgModDef{}, wce.ModDefs...)
+	dst.TerDefs = append([]*EqgTerDef{}, wce.TerDefs...)
+	dst.PrtDefs = append([]*EqgParticleRenderDef{}, wce.PrtDefs...)
+	dst.ZonDefs = append([]*EqgZonDef{}, wce.ZonDefs...)
+
+	// Write spell blit particles? (SPB)
+	for _, blitSprite := range wce.BlitSpriteDefs {
+		prt := &EqgParticleRenderDef{
+			Tag: blitSprite.Tag,
+		}
+		dst.PrtDefs = append(dst.PrtDefs, prt)
+	}
+
+	models := make(map[string]string) // eqg file name by the tag of the sprite it was made from
+	isSkin := make(map[string]bool)
+	for _, hiSprite := range wce.HierarchicalSpriteDefs {
+		mds, anis, err := wce.eqgMdsFromHierarchicalSprite(hiSprite)
+		if err != nil {
+			return nil, fmt.Errorf("hierarchicalspritedef %s: %w", hiSprite.Tag, err)
+		}
+		dst.MdsDefs = append(dst.MdsDefs, mds)
+		dst.AniDefs = append(dst.AniDefs, anis...)
+		models[hiSprite.Tag] = mds.Tag + ".mds"
+		for _, skin := range hiSprite.AttachedSkins {
+			isSkin[skin.DMSpriteTag] = true
+		}
+		for _, dag := range hiSprite.Dags {
+			isSkin[dag.SpriteTag] = true
+		}
+	}
+
+	regionSprites := make(map[string]bool)
+	for _, region := range wce.Regions {
+		if region.SpriteTag != "" {
+			regionSprites[region.SpriteTag] = true
+		}
+	}
+
+	zoneName := strings.ToLower(strings.TrimSuffix(filepath.Base(wce.FileName), filepath.Ext(wce.FileName)))
+	var ter *eqgMesh
+	for _, dmSprite := range wce.DMSpriteDef2s {
+		if isSkin[dmSprite.Tag] {
+			continue
+		}
+		if regionSprites[dmSprite.Tag] || regexRegionSprite.MatchString(dmSprite.Tag) {
+			if ter == nil {
+				ter = &eqgMesh{}
+			}
+			err := wce.eqgMeshAppend(ter, dmSprite, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("dmspritedef2 %s: %w", dmSprite.Tag, err)
+			}
+			continue
+		}
+
+		mesh := &eqgMesh{}
+		err := wce.eqgMeshAppend(mesh, dmSprite, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("dmspritedef2 %s: %w", dmSprite.Tag, err)
+		}
+		mod := &EqgModDef{
+			Tag:       eqgTag(dmSprite.Tag, "_DMSPRITEDEF"),
+			Version:   1,
+			Materials: mesh.materials,
+			Vertices:  mesh.vertices,
+			Faces:     mesh.faces,
+		}
+		dst.ModDefs = append(dst.ModDefs, mod)
+		models[dmSprite.Tag] = mod.Tag + ".mod"
+	}
+	if ter != nil {
+		dst.TerDefs = append(dst.TerDefs, &EqgTerDef{
+			Tag:       zoneName,
+			Version:   1,
+			Materials: ter.materials,
+			Vertices:  ter.vertices,
+			Faces:     ter.faces,
+		})
+	}
+
+	wces := append([]*Wce{wce}, parts...)
+	actorInsts := []*ActorInst{}
+	pointLights := []*PointLight{}
+	for _, part := range wces {
+		actorInsts = append(actorInsts, part.ActorInsts...)
+		pointLights = append(pointLights, part.PointLights...)
+	}
+	if len(dst.ZonDefs) > 0 || (ter == nil && len(actorInsts) == 0 && len(pointLights) == 0) {
+		return &dst, nil
+	}
+
+	zon := &EqgZonDef{
+		Tag:     zoneName,
+		Version: 1,
+	}
+	isModel := make(map[string]bool)
+	if ter != nil {
+		zon.Models = append(zon.Models, zoneName+".ter")
+		isModel[zoneName+".ter"] = true
+		zon.Instances = append(zon.Instances, EqgZonInstance{
+			ModelTag:    zoneName + ".ter",
+			InstanceTag: zoneName,
+			Scale:       1,
+		})
+	}
+
+	for _, actor := range actorInsts {
+		if !actor.Location.Valid || actor.DefinitionTag == "" {
+			continue
+		}
+		model := eqgActorModel(wces, models, actor.DefinitionTag)
+		if !isModel[model] {
+			zon.Models = append(zon.Models, model)
+			isModel[model] = true
+		}
+		translation, rotation, scale := actor.Transform()
+		inst := EqgZonInstance{
+			ModelTag:    model,
+			InstanceTag: actor.Tag,
+			Translation: translation,
+			Rotation:    rotation,
+			Scale:       scale,
+		}
+		if inst.InstanceTag == "" {
+			inst.InstanceTag = strings.TrimSuffix(model, filepath.Ext(model))
+		}
+		zon.Instances = append(zon.Instances, inst)
+	}
+
+	for _, light := range pointLights {
+		zon.Lights = append(zon.Lights, EqgZonLight{
+			Name:     light.Tag,
+			Position: light.Location,
+			Color:    eqgLightColor(wces, light.LightDefTag),
+			Radius:   light.Radius,
+		})
+	}
+	dst.ZonDefs = append(dst.ZonDefs, zon)
+
+	return &dst, nil
+}
+
+// eqgMesh is the materials, vertices and faces of a mod, ter or mds model being built
+type eqgMesh struct {
+	materials []*EQMaterialDef
+	vertices  []*ModVertex
+	faces     []*ModFace
+}
+
+// eqgBonePose is where a bone is in model space
+type eqgBonePose struct {
+	translation [3]float32
+	rotation    [4]float32
+}
+
+func (p eqgBonePose) apply(v [3]float32) [3]float32 {
+	v = helper.QuatRotate(p.rotation, v)
+	return [3]float32{v[0] + p.translation[0], v[1] + p.translation[1], v[2] + p.translation[2]}
+}
+
+// unapply moves v from model space to the space of the bone
+func (p eqgBonePose) unapply(v [3]float32) [3]float32 {
+	v = [3]float32{v[0] - p.translation[0], v[1] - p.translation[1], v[2] - p.translation[2]}
+	return helper.QuatRotate(helper.QuatConjugate(p.rotation), v)
+}
+
+// eqgBonePoses returns where each bone is in model space. The pivot and quaternion of a bone are
+// relative to its parent, parents is the parent index of each bone, or -1 for a root
+func eqgBonePoses(bones []*MdsBone, parents []int) ([]eqgBonePose, error) {
+	poses := make([]eqgBonePose, len(bones))
+	isPosed := make([]bool, len(bones))
+	var pose func(i int, depth int) (eqgBonePose, error)
+	pose = func(i int, depth int) (eqgBonePose, error) {
+		if isPosed[i] {
+			return poses[i], nil
+		}
+		if depth > len(bones) {
+			return eqgBonePose{}, fmt.Errorf("bone %s is its own parent", bones[i].Name)
+		}
+		local := eqgBonePose{translation: bones[i].Pivot, rotation: helper.QuatNormalize(bones[i].Quaternion)}
+		if parents[i] >= 0 {
+			parent, err := pose(parents[i], depth+1)
+			if err != nil {
+				return eqgBonePose{}, err
+			}
+			local = eqgBonePose{
+				translation: parent.apply(local.translation),
+				rotation:    helper.QuatNormalize(helper.QuatMul(parent.rotation, local.rotation)),
+			}
+		}
+		poses[i] = local
+		isPosed[i] = true
+		return local, nil
+	}
+	for i := range bones {
+		_, err := pose(i, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return poses, nil
+}
+
+// eqgMeshAppend appends a dmsprite to mesh. For a skinned mesh, bones is the bone of each vertex
+// and poses is where each bone is, vertices are moved from bone space to model space and weighted
+func (wce *Wce) eqgMeshAppend(mesh *eqgMesh, sprite *DMSpriteDef2, bones []int, poses []eqgBonePose) error {
+	palette := []string{}
+	if sprite.MaterialPaletteTag != "" {
+		def := wce.tagFirst("MATERIALPALETTE", sprite.MaterialPaletteTag)
+		if def == nil {
+			return fmt.Errorf("material palette %s not found", sprite.MaterialPaletteTag)
+		}
+		palette = def.(*MaterialPalette).Materials
+	}
+
+	isTransparent := make(map[string]bool)
+	for _, tag := range palette {
+		// TRANSPARENT materials are not drawn
+		matDef, ok := wce.tagFirst("MATERIALDEFINITION", tag).(*MaterialDef)
+		isTransparent[tag] = ok && matDef.RenderMethod == "TRANSPARENT"
+
+		mat := wce.eqgMaterial(tag)
+		isFound := false
+		for _, existing := range mesh.materials {
+			if existing.Tag == mat.Tag {
+				isFound = true
+				break
+			}
+		}
+		if !isFound {
+			mesh.materials = append(mesh.materials, mat)
+		}
+	}
+
+	offset := uint32(len(mesh.vertices))
+	for i, vert := range sprite.Vertices {
+		position := [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+		v := &ModVertex{Position: position}
+		if i < len(sprite.VertexNormals) {
+			v.Normal = sprite.VertexNormals[i]
+		}
+		if i < len(sprite.UVs) {
+			v.Uv = sprite.UVs[i]
+		}
+		if i < len(sprite.VertexColors) {
+			v.Tint = sprite.VertexColors[i]
+		}
+		if bones != nil {
+			bone := bones[i]
+			v.Position = poses[bone].apply(position)
+			v.Normal = helper.QuatRotate(poses[bone].rotation, v.Normal)
+			v.Weights = []*ModBoneWeight{{BoneIndex: int32(bone), Value: 1}}
+		}
+		mesh.vertices = append(mesh.vertices, v)
+	}
+
+	faceIndex := 0
+	for _, group := range sprite.FaceMaterialGroups {
+		materialName := ""
+		if int(group[1]) < len(palette) {
+			materialName = palette[group[1]]
+		}
+		for i := 0; i < int(group[0]) && faceIndex < len(sprite.Faces); i++ {
+			face := sprite.Faces[faceIndex]
+			faceIndex++
+			for _, index := range face.Triangle {
+				if int(index) >= len(sprite.Vertices) {
+					return fmt.Errorf("face %d: vertex %d out of range", faceIndex-1, index)
+				}
+			}
+			modFace := &ModFace{
+				Index:        [3]uint32{uint32(face.Triangle[0]) + offset, uint32(face.Triangle[1]) + offset, uint32(face.Triangle[2]) + offset},
+				MaterialName: materialName,
+				Passable:     face.Passable,
+			}
+			if isTransparent[materialName] {
+				modFace.Transparent = 1
+			}
+			mesh.faces = append(mesh.faces, modFace)
+		}
+	}
+	if faceIndex < len(sprite.Faces) {
+		return fmt.Errorf("face material groups cover %d of %d faces", faceIndex, len(sprite.Faces))
+	}
+	return nil
+}
+
+// eqgMaterial returns the eqg material of a wld material, with the shader of its render method and
+// its texture
+func (wce *Wce) eqgMaterial(tag string) *EQMaterialDef {
+	mat := &EQMaterialDef{Tag: tag, ShaderTag: helper.RenderMethodShader("")}
+	matDef, ok := wce.tagFirst("MATERIALDEFINITION", tag).(*MaterialDef)
+	if !ok {
+		return mat
+	}
+	mat.ShaderTag = helper.RenderMethodShader(matDef.RenderMethod)
+
+	spriteDef := wce.tagFirst("SIMPLESPRITEDEF", matDef.SimpleSpriteTag)
+	if spriteDef == nil {
+		return mat
+	}
+	sprite := spriteDef.(*SimpleSpriteDef)
+	textures := []string{}
+	for _, frame := range sprite.SimpleSpriteFrames {
+		if len(frame.TextureFiles) > 0 {
+			textures = append(textures, strings.ToLower(frame.TextureFiles[0]))
+		}
+	}
+	if len(textures) == 0 {
+		return mat
+	}
+	mat.Properties = append(mat.Properties, &MaterialProperty{
+		Name:  "e_TextureDiffuse0",
+		Type:  raw.MaterialParamTypeTexture,
+		Value: textures[0],
+	})
+	if len(textures) > 1 {
+		mat.AnimationTextures = textures
+		if sprite.Sleep.Valid {
+			mat.AnimationSleep = sprite.Sleep.Uint32
+		}
+	}
+	return mat
+}
+
+// eqgMdsFromHierarchicalSprite converts a skeleton and its skins to an mds, and the animation
+// tracks of its dags to an ani for each animation
+func (wce *Wce) eqgMdsFromHierarchicalSprite(hiSprite *HierarchicalSpriteDef) (*EqgMdsDef, []*EqgAniDef, error) {
+	mds := &EqgMdsDef{
+		Tag:     eqgTag(hiSprite.Tag, "_HS_DEF"),
+		Version: 1,
+	}
+
+	parents := make([]int, len(hiSprite.Dags))
+	for i := range parents {
+		parents[i] = -1
+	}
+	trackDags := make(map[string]int)
+	for i, dag := range hiSprite.Dags {
+		// the first frame of the dag track is the bind pose
+		local := TrackTransform{Rotation: helper.QuatIdentity}
+		track := wce.trackDef(dag.Track)
+		if track != nil {
+			transforms := track.Transforms()
+			if len(transforms) > 0 {
+				local = transforms[0]
+			}
+		}
+		if dag.Track != "" {
+			trackDags[dag.Track] = i
+		}
+
+		bone := &MdsBone{
+			Name:          eqgTag(dag.Tag, "_DAG"),
+			Next:          -1,
+			ChildrenCount: uint32(len(dag.SubDags)),
+			ChildIndex:    -1,
+			Pivot:         local.Translation,
+			Quaternion:    local.Rotation,
+			Scale:         [3]float32{1, 1, 1},
+		}
+		for j, subDag := range dag.SubDags {
+			if int(subDag) >= len(hiSprite.Dags) {
+				return nil, nil, fmt.Errorf("dag %s: sub dag %d out of range", dag.Tag, subDag)
+			}
+			if parents[subDag] != -1 || int(subDag) == i {
+				return nil, nil, fmt.Errorf("dag %s: sub dag %d has more than one parent", dag.Tag, subDag)
+			}
+			parents[subDag] = i
+			if j == 0 {
+				bone.ChildIndex = int32(subDag)
+			}
+		}
+		mds.Bones = append(mds.Bones, bone)
+	}
+	for _, dag := range hiSprite.Dags {
+		for j := 1; j < len(dag.SubDags); j++ {
+			mds.Bones[dag.SubDags[j-1]].Next = int32(dag.SubDags[j])
+		}
+	}
+
+	poses, err := eqgBonePoses(mds.Bones, parents)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modelAdd := func(sprite *DMSpriteDef2, bones []int) error {
+		mesh := &eqgMesh{materials: mds.Materials}
+		err := wce.eqgMeshAppend(mesh, sprite, bones, poses)
+		if err != nil {
+			return fmt.Errorf("dmspritedef2 %s: %w", sprite.Tag, err)
+		}
+		mds.Materials = mesh.materials
+		model := &EqgMdsModel{
+			Name:      eqgTag(sprite.Tag, "_DMSPRITEDEF"),
+			Vertices:  mesh.vertices,
+			BoneCount: uint32(len(mds.Bones)),
+		}
+		if len(mds.Models) == 0 {
+			model.MainPiece = 1
+		}
+		for _, face := range mesh.faces {
+			model.Faces = append(model.Faces, &MdsFace{
+				Index:        face.Index,
+				MaterialName: face.MaterialName,
+				Passable:     face.Passable,
+				Transparent:  face.Transparent,
+			})
+		}
+		mds.Models = append(mds.Models, model)
+		return nil
+	}
+
+	isAttached := make(map[string]bool)
+	for _, skin := range hiSprite.AttachedSkins {
+		sprite, ok := wce.tagFirst("DMSPRITEDEF2", skin.DMSpriteTag).(*DMSpriteDef2)
+		if !ok {
+			return nil, nil, fmt.Errorf("attached skin %s not found", skin.DMSpriteTag)
+		}
+		isAttached[skin.DMSpriteTag] = true
+
+		bones := make([]int, len(sprite.Vertices))
+		vertexIndex := 0
+		for _, group := range sprite.SkinAssignmentGroups {
+			if group[1] < 0 || int(group[1]) >= len(mds.Bones) {
+				return nil, nil, fmt.Errorf("dmspritedef2 %s: skin assignment to dag %d out of range", sprite.Tag, group[1])
+			}
+			for i := 0; i < int(group[0]) && vertexIndex < len(bones); i++ {
+				bones[vertexIndex] = int(group[1])
+				vertexIndex++
+			}
+		}
+		if vertexIndex < len(bones) {
+			return nil, nil, fmt.Errorf("dmspritedef2 %s: skin assignment groups cover %d of %d vertices", sprite.Tag, vertexIndex, len(bones))
+		}
+		err := modelAdd(sprite, bones)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// meshes on a dag follow it rigidly
+	for i, dag := range hiSprite.Dags {
+		if dag.SpriteTag == "" || isAttached[dag.SpriteTag] {
+			continue
+		}
+		sprite, ok := wce.tagFirst("DMSPRITEDEF2", dag.SpriteTag).(*DMSpriteDef2)
+		if !ok {
+			continue
+		}
+		bones := make([]int, len(sprite.Vertices))
+		for j := range bones {
+			bones[j] = i
+		}
+		err := modelAdd(sprite, bones)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// an animation track is named as the dag track with an animation code before it, such as C01HUMPE_TRACK
+	codes := []string{}
+	animations := make(map[string][]*TrackInstance)
+	for _, track := range wce.TrackInstances {
+		if len(track.Tag) <= 3 || !regexAniPrefix.MatchString(track.Tag) {
+			continue
+		}
+		dagIndex, ok := trackDags[track.Tag[3:]]
+		if !ok {
+			continue
+		}
+		code := track.Tag[:3]
+		if animations[code] == nil {
+			codes = append(codes, code)
+			animations[code] = make([]*TrackInstance, len(hiSprite.Dags))
+		}
+		animations[code][dagIndex] = track
+	}
+
+	anis := []*EqgAniDef{}
+	for _, code := range codes {
+		ani := &EqgAniDef{
+			Tag:     mds.Tag + "_" + strings.ToLower(code),
+			Version: 1,
+		}
+		for i, track := range animations[code] {
+			if track == nil {
+				continue
+			}
+			trackDef := wce.trackDef(track.Tag)
+			if trackDef == nil {
+				return nil, nil, fmt.Errorf("track %s: trackdef %s not found", track.Tag, track.SpriteTag)
+			}
+			sleep := uint32(100)
+			if track.Sleep.Valid && track.Sleep.Uint32 > 0 {
+				sleep = track.Sleep.Uint32
+			}
+			bone := &AniBone{Name: mds.Bones[i].Name}
+			for j, transform := range trackDef.Transforms() {
+				bone.Frames = append(bone.Frames, &AniBoneFrame{
+					Milliseconds: uint32(j) * sleep,
+					Translation:  transform.Translation,
+					Rotation:     transform.Rotation,
+					Scale:        [3]float32{1, 1, 1},
+				})
+			}
+			ani.Bones = append(ani.Bones, bone)
+		}
+		anis = append(anis, ani)
+	}
+
+	return mds, anis, nil
+}
+
+// trackDef returns the trackdef of a track instance, or nil if either is missing
+func (wce *Wce) trackDef(trackTag string) *TrackDef {
+	track, ok := wce.tagFirst("TRACKINSTANCE", trackTag).(*TrackInstance)
+	if !ok {
+		return nil
+	}
+	trackDef, ok := wce.tagFirst("TRACKDEFINITION", track.SpriteTag).(*TrackDef)
+	if !ok {
+		return nil
+	}
+	return trackDef
+}
+
+// eqgActorModel returns the eqg file an actor is converted to. Actors that are not in wces, such
+// as objects of a zone kept in a separate _obj.s3d, are expected to be converted to a mod
+func eqgActorModel(wces []*Wce, models map[string]string, actorTag string) string {
+	for _, w := range wces {
+		actor, ok := w.tagFirst("ACTORDEF", actorTag).(*ActorDef)
+		if !ok {
+			continue
+		}
+		for _, action := range actor.Actions {
+			for _, lod := range action.LevelOfDetails {
+				model, ok := models[lod.SpriteTag]
+				if ok {
+					return model
+				}
+			}
+		}
+	}
+	return strings.ToLower(mapActorName(actorTag)) + ".mod"
+}
+
+// eqgLightColor returns the color of a light definition found in wces, or white
+func eqgLightColor(wces []*Wce, lightDefTag string) [3]float32 {
+	for _, w := range wces {
+		lightDef, ok := w.tagFirst("LIGHTDEFINITION", lightDefTag).(*LightDef)
+		if !ok {
+			continue
+		}
+		if len(lightDef.Colors) > 0 {
+			return lightDef.Colors[0]
+		}
+		if len(lightDef.LightLevels) > 0 {
+			level := lightDef.LightLevels[0]
+			return [3]float32{level, level, level}
+		}
+	}
+	return [3]float32{1, 1, 1}
+}
+
+// eqgTag returns the lower case eqg name of a wld tag
+func eqgTag(tag string, suffix string) string {
+	return strings.ToLower(strings.TrimSuffix(tag, suffix))
+}
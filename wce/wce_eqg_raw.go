@@ -208,25 +208,25 @@ func (wce *Wce) ReadRaw(rawEntry raw.Reader) error {
 	return nil
 }
 
-func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
+// WriteEqgRaw writes the eqg definitions of wce to archive, converting its wld definitions first.
+// parts are the other wld of the same s3d, such as objects.wld and lights.wld, see convertWldToEQG
+func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs, parts ...*Wce) error {
 	if archive == nil {
 		return fmt.Errorf("archive is nil")
 	}
-	var err error
-
-	err = wce.convertWldToEQG()
+	src, err := wce.convertWldToEQG(parts...)
 	if err != nil {
 		return fmt.Errorf("convert wld to eqg: %w", err)
 	}
 
-	for _, mds := range wce.MdsDefs {
+	for _, mds := range src.MdsDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Mds{
 			MetaFileName: mds.Tag,
 			Version:      mds.Version,
 		}
 
-		err = mds.ToRaw(wce, dst)
+		err = mds.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("mds to raw: %w", err)
 		}
@@ -241,14 +241,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, mod := range wce.ModDefs {
+	for _, mod := range src.ModDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Mod{
 			MetaFileName: mod.Tag,
 			Version:      mod.Version,
 		}
 
-		err = mod.ToRaw(wce, dst)
+		err = mod.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("mod to raw: %w", err)
 		}
@@ -263,14 +263,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, ter := range wce.TerDefs {
+	for _, ter := range src.TerDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Ter{
 			MetaFileName: ter.Tag,
 			Version:      ter.Version,
 		}
 
-		err = ter.ToRaw(wce, dst)
+		err = ter.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("ter to raw: %w", err)
 		}
@@ -286,10 +286,10 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 
 	}
 
-	if len(wce.ZonDefs) > 1 {
+	if len(src.ZonDefs) > 1 {
 		return fmt.Errorf("only one zon def is supported")
 	}
-	for _, zon := range wce.ZonDefs {
+	for _, zon := range src.ZonDefs {
 		if zon.Version == 2 {
 			continue // skip v2 zones, it's handled on WriteSingleFile
 		}
@@ -299,7 +299,7 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 			Version:      zon.Version,
 		}
 
-		err = zon.ToRaw(wce, dst)
+		err = zon.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("zon to raw: %w", err)
 		}
@@ -314,13 +314,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, ani := range wce.AniDefs {
+	for _, ani := range src.AniDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Ani{}
-		err = ani.ToRaw(wce, dst)
+		err = ani.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("ani to raw: %w", err)
 		}
+		dst.Process(wce.animationOptions)
 
 		err = dst.Write(buf)
 		if err != nil {
@@ -332,14 +333,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, lay := range wce.LayDefs {
+	for _, lay := range src.LayDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Lay{
 			MetaFileName: lay.Tag,
 			Version:      lay.Version,
 		}
 
-		err = lay.ToRaw(wce, dst)
+		err = lay.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("lay to raw: %w", err)
 		}
@@ -354,14 +355,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, pts := range wce.PtsDefs {
+	for _, pts := range src.PtsDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Pts{
 			MetaFileName: pts.Tag,
 			Version:      pts.Version,
 		}
 
-		err = pts.ToRaw(wce, dst)
+		err = pts.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("pts to raw: %w", err)
 		}
@@ -376,14 +377,14 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 		}
 	}
 
-	for _, prt := range wce.PrtDefs {
+	for _, prt := range src.PrtDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Prt{
 			MetaFileName: prt.Tag,
 			Version:      prt.Version,
 		}
 
-		err = prt.ToRaw(wce, dst)
+		err = prt.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("prt to raw: %w", err)
 		}
@@ -401,13 +402,13 @@ func (wce *Wce) WriteEqgRaw(archive *pfs.Pfs) error {
 
 	}
 
-	for _, lod := range wce.LodDefs {
+	for _, lod := range src.LodDefs {
 		buf := &bytes.Buffer{}
 		dst := &raw.Lod{
 			MetaFileName: lod.Tag,
 		}
 
-		err = lod.ToRaw(wce, dst)
+		err = lod.ToRaw(src, dst)
 		if err != nil {
 			return fmt.Errorf("lod to raw: %w", err)
 		}
@@ -470,8 +471,9 @@ func (wce *Wce) WriteSingleFile(sidefileType SideFileType, path string) (bool, e
 
 func writeEqgMaterials(srcMaterials []*EQMaterialDef) ([]*raw.ModMaterial, error) {
 	dstMaterials := []*raw.ModMaterial{}
-	for _, srcMat := range srcMaterials {
+	for i, srcMat := range srcMaterials {
 		mat := &raw.ModMaterial{
+			ID:         int32(i),
 			Name:       srcMat.Tag,
 			ShaderName: srcMat.ShaderTag,
 		}
@@ -491,74 +493,3 @@ func writeEqgMaterials(srcMaterials []*EQMaterialDef) ([]*raw.ModMaterial, error
 	}
 	return dstMaterials, nil
 }
-
-func (wce *Wce) convertWldToEQG() error {
-	//var err error
-
-	// Write spell blit particles? (SPB)
-	for _, blitSprite := range wce.BlitSpriteDefs {
-		prt := &EqgParticleRenderDef{
-			Tag: blitSprite.Tag,
-		}
-		wce.PrtDefs = append(wce.PrtDefs, prt)
-	}
-
-	// Write other particle cloud blits
-	//for _, blitSprite := range wce.BlitSpriteDefs {
-	//}
-
-	// Write spell effect actordefs
-	//for _, actorDef := range wce.ActorDefs {
-	//}
-
-	// Write particle clouds
-	//for _, cloudDef := range wce.ParticleCloudDefs {
-	//	}
-
-	// Write other blits (for 2D Sprites and stuff)
-	//for _, blitSprite := range wce.BlitSpriteDefs {
-	//}
-
-	// Write out CHR_EYE materials
-	//	for _, matDef := range wce.MaterialDefs {
-	//	}
-
-	//for _, dmSprite := range wce.DMSpriteDef2s {
-	//}
-
-	//for _, dmSprite := range wce.DMSpriteDefs {
-	//}
-
-	//for _, hiSprite := range wce.HierarchicalSpriteDefs {
-	//}
-
-	//for _, light := range wce.PointLights {
-	//}
-
-	//for _, sprite := range wce.Sprite3DDefs {
-	//}
-
-	//for _, tree := range wce.WorldTrees {
-	//}
-
-	//for _, region := range wce.Regions {
-	//}
-
-	//for _, alight := range wce.AmbientLights {
-	//}
-
-	//for _, actor := range wce.ActorInsts {
-	//}
-
-	//for _, track := range wce.TrackInstances {
-	//}
-
-	// Write non-spell effect actordefs
-	//for _, actorDef := range wce.ActorDefs {
-	//}
-
-	//for _, zone := range wce.Zones {
-	//}
-
-	return nil
-}
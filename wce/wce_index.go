@@ -0,0 +1,217 @@
+package wce
+
+import "fmt"
+
+// tagKey is a tag of a single kind of definition
+type tagKey struct {
+	definition string
+	tag        string
+}
+
+// tagLookup finds definitions by tag without scanning every slice. Definitions appended directly to
+// a slice are indexed on the next lookup, and a slice that shrinks is indexed again. A slice
+// replaced by another of the same length, or a tag changed in place, is not noticed, so use
+// Remove, Add and RenameTag for those
+type tagLookup struct {
+	defs   map[tagKey][]WldDefinitioner
+	sizes  map[string]int             // number of definitions indexed from each slice
+	lasts  map[string]WldDefinitioner // last definition indexed from each slice
+	counts [23]int                    // slice lengths when last synced
+}
+
+// Add appends def to the slice of its kind
+func (wce *Wce) Add(def WldDefinitioner) error {
+	switch e := def.(type) {
+	case *ActorDef:
+		wce.ActorDefs = append(wce.ActorDefs, e)
+	case *ActorInst:
+		wce.ActorInsts = append(wce.ActorInsts, e)
+	case *AmbientLight:
+		wce.AmbientLights = append(wce.AmbientLights, e)
+	case *BlitSpriteDef:
+		wce.BlitSpriteDefs = append(wce.BlitSpriteDefs, e)
+	case *DMSpriteDef2:
+		wce.DMSpriteDef2s = append(wce.DMSpriteDef2s, e)
+	case *DMSpriteDef:
+		wce.DMSpriteDefs = append(wce.DMSpriteDefs, e)
+	case *DMTrackDef2:
+		wce.DMTrackDef2s = append(wce.DMTrackDef2s, e)
+	case *HierarchicalSpriteDef:
+		wce.HierarchicalSpriteDefs = append(wce.HierarchicalSpriteDefs, e)
+	case *LightDef:
+		wce.LightDefs = append(wce.LightDefs, e)
+	case *MaterialDef:
+		wce.MaterialDefs = append(wce.MaterialDefs, e)
+	case *MaterialPalette:
+		wce.MaterialPalettes = append(wce.MaterialPalettes, e)
+	case *ParticleCloudDef:
+		wce.ParticleCloudDefs = append(wce.ParticleCloudDefs, e)
+	case *PointLight:
+		wce.PointLights = append(wce.PointLights, e)
+	case *PolyhedronDefinition:
+		wce.PolyhedronDefs = append(wce.PolyhedronDefs, e)
+	case *Region:
+		wce.Regions = append(wce.Regions, e)
+	case *RGBTrackDef:
+		wce.RGBTrackDefs = append(wce.RGBTrackDefs, e)
+	case *SimpleSpriteDef:
+		wce.SimpleSpriteDefs = append(wce.SimpleSpriteDefs, e)
+	case *Sprite2DDef:
+		wce.Sprite2DDefs = append(wce.Sprite2DDefs, e)
+	case *Sprite3DDef:
+		wce.Sprite3DDefs = append(wce.Sprite3DDefs, e)
+	case *TrackDef:
+		wce.TrackDefs = append(wce.TrackDefs, e)
+	case *TrackInstance:
+		wce.TrackInstances = append(wce.TrackInstances, e)
+	case *WorldTree:
+		wce.WorldTrees = append(wce.WorldTrees, e)
+	case *Zone:
+		wce.Zones = append(wce.Zones, e)
+	default:
+		return fmt.Errorf("unknown definition %T", def)
+	}
+	wce.tagLookupSync()
+	return nil
+}
+
+// Remove removes def, returning false if it was not found
+func (wce *Wce) Remove(def WldDefinitioner) bool {
+	isFound := false
+	for _, e := range wce.tagDefinitions(def.Definition(), definitionTag(def)) {
+		if e == def {
+			isFound = true
+			break
+		}
+	}
+	if !isFound {
+		return false
+	}
+	wce.removeDefinitions(map[WldDefinitioner]bool{def: true})
+	return true
+}
+
+// tagDefinitions returns the definitions of a kind with tag, in the order they were added
+func (wce *Wce) tagDefinitions(definition string, tag string) []WldDefinitioner {
+	wce.tagLookupSync()
+	return wce.lookup.defs[tagKey{definition: definition, tag: tag}]
+}
+
+// tagFirst returns the first definition of a kind with tag
+func (wce *Wce) tagFirst(definition string, tag string) WldDefinitioner {
+	defs := wce.tagDefinitions(definition, tag)
+	if len(defs) == 0 {
+		return nil
+	}
+	return defs[0]
+}
+
+// tagFirstWithIndex returns the first definition of a kind with tag and tag index
+func (wce *Wce) tagFirstWithIndex(definition string, tag string, index int) WldDefinitioner {
+	for _, def := range wce.tagDefinitions(definition, tag) {
+		if definitionTagIndex(def) == index {
+			return def
+		}
+	}
+	return nil
+}
+
+// tagLookupReset drops the tag lookup, it is rebuilt on the next lookup
+func (wce *Wce) tagLookupReset() {
+	wce.lookup = nil
+}
+
+// tagLookupCounts returns the length of every slice tagLookup indexes
+func (wce *Wce) tagLookupCounts() [23]int {
+	return [23]int{
+		len(wce.ActorDefs), len(wce.ActorInsts), len(wce.AmbientLights), len(wce.BlitSpriteDefs),
+		len(wce.DMSpriteDef2s), len(wce.DMSpriteDefs), len(wce.DMTrackDef2s), len(wce.HierarchicalSpriteDefs),
+		len(wce.LightDefs), len(wce.MaterialDefs), len(wce.MaterialPalettes), len(wce.ParticleCloudDefs),
+		len(wce.PointLights), len(wce.PolyhedronDefs), len(wce.Regions), len(wce.RGBTrackDefs),
+		len(wce.SimpleSpriteDefs), len(wce.Sprite2DDefs), len(wce.Sprite3DDefs), len(wce.TrackDefs),
+		len(wce.TrackInstances), len(wce.WorldTrees), len(wce.Zones),
+	}
+}
+
+// tagLookupSync indexes definitions appended since the last lookup
+func (wce *Wce) tagLookupSync() {
+	counts := wce.tagLookupCounts()
+	if wce.lookup != nil && wce.lookup.counts == counts {
+		return
+	}
+	if wce.lookup == nil {
+		wce.lookup = &tagLookup{
+			defs:  make(map[tagKey][]WldDefinitioner),
+			sizes: make(map[string]int),
+			lasts: make(map[string]WldDefinitioner),
+		}
+	}
+	isStale := false
+	sync := func(definition string, count int, def func(i int) WldDefinitioner) {
+		size := wce.lookup.sizes[definition]
+		if count < size || (size > 0 && def(size-1) != wce.lookup.lasts[definition]) {
+			isStale = true
+			return
+		}
+		for i := size; i < count; i++ {
+			e := def(i)
+			key := tagKey{definition: definition, tag: definitionTag(e)}
+			wce.lookup.defs[key] = append(wce.lookup.defs[key], e)
+		}
+		if count > size {
+			wce.lookup.sizes[definition] = count
+			wce.lookup.lasts[definition] = def(count - 1)
+		}
+	}
+	sync("ACTORDEF", len(wce.ActorDefs), func(i int) WldDefinitioner { return wce.ActorDefs[i] })
+	sync("ACTORINST", len(wce.ActorInsts), func(i int) WldDefinitioner { return wce.ActorInsts[i] })
+	sync("AMBIENTLIGHT", len(wce.AmbientLights), func(i int) WldDefinitioner { return wce.AmbientLights[i] })
+	sync("BLITSPRITEDEF", len(wce.BlitSpriteDefs), func(i int) WldDefinitioner { return wce.BlitSpriteDefs[i] })
+	sync("DMSPRITEDEF2", len(wce.DMSpriteDef2s), func(i int) WldDefinitioner { return wce.DMSpriteDef2s[i] })
+	sync("DMSPRITEDEFINITION", len(wce.DMSpriteDefs), func(i int) WldDefinitioner { return wce.DMSpriteDefs[i] })
+	sync("DMTRACKDEF2", len(wce.DMTrackDef2s), func(i int) WldDefinitioner { return wce.DMTrackDef2s[i] })
+	sync("HIERARCHICALSPRITEDEF", len(wce.HierarchicalSpriteDefs), func(i int) WldDefinitioner { return wce.HierarchicalSpriteDefs[i] })
+	sync("LIGHTDEFINITION", len(wce.LightDefs), func(i int) WldDefinitioner { return wce.LightDefs[i] })
+	sync("MATERIALDEFINITION", len(wce.MaterialDefs), func(i int) WldDefinitioner { return wce.MaterialDefs[i] })
+	sync("MATERIALPALETTE", len(wce.MaterialPalettes), func(i int) WldDefinitioner { return wce.MaterialPalettes[i] })
+	sync("PARTICLECLOUDDEF", len(wce.ParticleCloudDefs), func(i int) WldDefinitioner { return wce.ParticleCloudDefs[i] })
+	sync("POINTLIGHT", len(wce.PointLights), func(i int) WldDefinitioner { return wce.PointLights[i] })
+	sync("POLYHEDRONDEFINITION", len(wce.PolyhedronDefs), func(i int) WldDefinitioner { return wce.PolyhedronDefs[i] })
+	sync("REGION", len(wce.Regions), func(i int) WldDefinitioner { return wce.Regions[i] })
+	sync("RGBDEFORMATIONTRACKDEF", len(wce.RGBTrackDefs), func(i int) WldDefinitioner { return wce.RGBTrackDefs[i] })
+	sync("SIMPLESPRITEDEF", len(wce.SimpleSpriteDefs), func(i int) WldDefinitioner { return wce.SimpleSpriteDefs[i] })
+	sync("SPRITE2DDEF", len(wce.Sprite2DDefs), func(i int) WldDefinitioner { return wce.Sprite2DDefs[i] })
+	sync("SPRITE3DDEF", len(wce.Sprite3DDefs), func(i int) WldDefinitioner { return wce.Sprite3DDefs[i] })
+	sync("TRACKDEFINITION", len(wce.TrackDefs), func(i int) WldDefinitioner { return wce.TrackDefs[i] })
+	sync("TRACKINSTANCE", len(wce.TrackInstances), func(i int) WldDefinitioner { return wce.TrackInstances[i] })
+	sync("WORLDTREE", len(wce.WorldTrees), func(i int) WldDefinitioner { return wce.WorldTrees[i] })
+	sync("ZONE", len(wce.Zones), func(i int) WldDefinitioner { return wce.Zones[i] })
+	if isStale {
+		// a slice was replaced or shrunk, start over
+		wce.tagLookupReset()
+		wce.tagLookupSync()
+		return
+	}
+	wce.lookup.counts = counts
+}
+
+// definitionTagIndex returns the TagIndex of definitions that have one
+func definitionTagIndex(def WldDefinitioner) int {
+	switch e := def.(type) {
+	case *DMSpriteDef2:
+		return e.TagIndex
+	case *DMSpriteDef:
+		return e.TagIndex
+	case *MaterialDef:
+		return e.TagIndex
+	case *ParticleCloudDef:
+		return e.TagIndex
+	case *SimpleSpriteDef:
+		return e.TagIndex
+	case *TrackDef:
+		return e.TagIndex
+	case *TrackInstance:
+		return e.TagIndex
+	}
+	return 0
+}
@@ -3,6 +3,7 @@ package wce
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -11,13 +12,17 @@ import (
 func ReadJSON(name string, path string) (*Wce, error) {
 
 	wce := New(name)
-	r, err := wce.FileSystem.Open(path)
+	data, err := fs.ReadFile(wce.FileSystem, path)
 	if err != nil {
 		return nil, fmt.Errorf("open: %w, path: %s", err, path)
 	}
 
-	dec := json.NewDecoder(r)
-	err = dec.Decode(wce)
+	err = jsonValidate(data)
+	if err != nil {
+		return nil, fmt.Errorf("validate %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, wce)
 	if err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
@@ -0,0 +1,330 @@
+package wce
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// wce.schema.json is generated by TestWceGenJsonSchema in wce/def
+//
+//go:embed wce.schema.json
+var jsonSchemaData []byte
+
+// JSONSchema returns the json schema (draft 2020-12) of what WriteJSON writes and ReadJSON reads
+func JSONSchema() []byte {
+	return append([]byte{}, jsonSchemaData...)
+}
+
+// jsonSchema is the part of a json schema the generator writes
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 json.RawMessage        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Minimum              json.Number            `json:"minimum"`
+	Maximum              json.Number            `json:"maximum"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	ContentEncoding      string                 `json:"contentEncoding"`
+
+	types      []string
+	additional *jsonSchema // nil with isClosed when additionalProperties is false
+	isClosed   bool
+}
+
+// JSONError is a value that does not match the json schema
+type JSONError struct {
+	Path string // json pointer to the value, such as /DMSpriteDef2s/0/Vertices/2
+	Err  error
+}
+
+func (e *JSONError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Err)
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// JSONErrors is every value that does not match the json schema
+type JSONErrors []*JSONError
+
+func (e JSONErrors) Error() string {
+	lines := []string{}
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	jsonSchemaOnce sync.Once
+	jsonSchemaRoot *jsonSchema
+	jsonSchemaErr  error
+)
+
+// jsonSchemaLoad decodes the embedded schema once
+func jsonSchemaLoad() (*jsonSchema, error) {
+	jsonSchemaOnce.Do(func() {
+		jsonSchemaRoot, jsonSchemaErr = jsonSchemaDecode()
+	})
+	return jsonSchemaRoot, jsonSchemaErr
+}
+
+func jsonSchemaDecode() (*jsonSchema, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonSchemaData))
+	dec.UseNumber()
+	root := &jsonSchema{}
+	err := dec.Decode(root)
+	if err != nil {
+		return nil, fmt.Errorf("decode schema: %w", err)
+	}
+	err = root.prepare()
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	for name, def := range root.Defs {
+		err = def.prepare()
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	return root, nil
+}
+
+// prepare decodes the fields of s that can hold more than one kind of value
+func (s *jsonSchema) prepare() error {
+	if len(s.Type) > 0 {
+		if s.Type[0] == '[' {
+			err := json.Unmarshal(s.Type, &s.types)
+			if err != nil {
+				return fmt.Errorf("type: %w", err)
+			}
+		} else {
+			s.types = []string{""}
+			err := json.Unmarshal(s.Type, &s.types[0])
+			if err != nil {
+				return fmt.Errorf("type: %w", err)
+			}
+		}
+	}
+	if len(s.AdditionalProperties) > 0 {
+		s.isClosed = true
+		if string(s.AdditionalProperties) != "false" {
+			s.additional = &jsonSchema{}
+			err := json.Unmarshal(s.AdditionalProperties, s.additional)
+			if err != nil {
+				return fmt.Errorf("additionalProperties: %w", err)
+			}
+		}
+	}
+	children := []*jsonSchema{}
+	for _, prop := range s.Properties {
+		children = append(children, prop)
+	}
+	children = append(children, s.AnyOf...)
+	if s.Items != nil {
+		children = append(children, s.Items)
+	}
+	if s.additional != nil {
+		children = append(children, s.additional)
+	}
+	for _, child := range children {
+		err := child.prepare()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonValidate checks data against the wce json schema
+func jsonValidate(data []byte) error {
+	root, err := jsonSchemaLoad()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	err = dec.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	v := &jsonValidator{root: root}
+	v.validate(root, value, "")
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type jsonValidator struct {
+	root *jsonSchema
+	errs JSONErrors
+}
+
+func (v *jsonValidator) errorAdd(path string, format string, a ...interface{}) {
+	v.errs = append(v.errs, &JSONError{Path: path, Err: fmt.Errorf(format, a...)})
+}
+
+// validate checks value against s, adding an error for each value found at path that does not match
+func (v *jsonValidator) validate(s *jsonSchema, value interface{}, path string) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		def, ok := v.root.Defs[name]
+		if !ok {
+			v.errorAdd(path, "schema ref %s not found", s.Ref)
+			return
+		}
+		s = def
+	}
+
+	if len(s.AnyOf) > 0 {
+		// report the errors of the choice that got furthest, so a null-able definition reports its own errors
+		var best JSONErrors
+		for _, choice := range s.AnyOf {
+			choiceValidator := &jsonValidator{root: v.root}
+			choiceValidator.validate(choice, value, path)
+			if len(choiceValidator.errs) == 0 {
+				return
+			}
+			if best == nil || jsonErrorsDepth(choiceValidator.errs) > jsonErrorsDepth(best) {
+				best = choiceValidator.errs
+			}
+		}
+		v.errs = append(v.errs, best...)
+		return
+	}
+
+	if len(s.types) > 0 {
+		kind := jsonKind(value)
+		isMatch := false
+		for _, typ := range s.types {
+			if typ == kind || (typ == "number" && kind == "integer") {
+				isMatch = true
+				break
+			}
+		}
+		if !isMatch {
+			v.errorAdd(path, "expected %s, got %s", strings.Join(s.types, " or "), jsonKind(value))
+			return
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			_, ok := val[name]
+			if !ok {
+				v.errorAdd(path, "missing required property %s", name)
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propPath := path + "/" + jsonPointerEscape(name)
+			prop, ok := s.Properties[name]
+			if ok {
+				v.validate(prop, val[name], propPath)
+				continue
+			}
+			if s.additional != nil {
+				v.validate(s.additional, val[name], propPath)
+				continue
+			}
+			if s.isClosed {
+				v.errorAdd(propPath, "unknown property")
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			v.errorAdd(path, "expected at least %d items, got %d", *s.MinItems, len(val))
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			v.errorAdd(path, "expected at most %d items, got %d", *s.MaxItems, len(val))
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, item, path+"/"+strconv.Itoa(i))
+			}
+		}
+	case json.Number:
+		if s.Minimum == "" && s.Maximum == "" {
+			return
+		}
+		number, ok := new(big.Float).SetString(val.String())
+		if !ok {
+			v.errorAdd(path, "invalid number %s", val)
+			return
+		}
+		if s.Minimum != "" {
+			minimum, ok := new(big.Float).SetString(s.Minimum.String())
+			if ok && number.Cmp(minimum) < 0 {
+				v.errorAdd(path, "%s is less than the minimum %s", val, s.Minimum)
+			}
+		}
+		if s.Maximum != "" {
+			maximum, ok := new(big.Float).SetString(s.Maximum.String())
+			if ok && number.Cmp(maximum) > 0 {
+				v.errorAdd(path, "%s is greater than the maximum %s", val, s.Maximum)
+			}
+		}
+	}
+}
+
+// jsonKind returns the json schema type of a value decoded with UseNumber
+func jsonKind(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		// encoding/json only decodes plain integers into integer fields
+		if strings.ContainsAny(val.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonErrorsDepth returns how deep the deepest error path is
+func jsonErrorsDepth(errs JSONErrors) int {
+	depth := 0
+	for _, err := range errs {
+		depth = max(depth, strings.Count(err.Path, "/"))
+	}
+	return depth
+}
+
+// jsonPointerEscape escapes a property name for a json pointer
+func jsonPointerEscape(name string) string {
+	name = strings.ReplaceAll(name, "~", "~0")
+	return strings.ReplaceAll(name, "/", "~1")
+}
@@ -0,0 +1,235 @@
+package wce
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/quail/bake"
+	"github.com/xackery/quail/helper"
+)
+
+// LightMesh is a mesh placed in a zone that takes baked vertex lighting: a region sprite,
+// an actor instance (kept in an rgb track) or a zon instance (kept in its lits or a .lit file)
+type LightMesh struct {
+	Name      string       // tag of the region sprite, actor instance or zon instance
+	LitName   string       // .lit file that keeps the colors of a v1 zon instance, otherwise empty
+	Positions [][3]float32 // zone coordinates
+	Normals   [][3]float32 // zone coordinates, one per position
+	Indices   []uint32     // triangles, 3 per face
+	set       func(colors [][4]uint8)
+}
+
+// SetColors stores rgba colors baked for the mesh, one per position. Colors of a mesh with a
+// LitName are not kept by the wce and are expected to be written to that .lit file
+func (e *LightMesh) SetColors(colors [][4]uint8) error {
+	if len(colors) != len(e.Positions) {
+		return fmt.Errorf("%s: %d colors for %d vertices", e.Name, len(colors), len(e.Positions))
+	}
+	if e.set != nil {
+		e.set(colors)
+	}
+	return nil
+}
+
+// Lights returns point lights of the wce for baking, from lights.wld point lights or zon lights.
+// Point lights take the first color of their light definition, looked up in wce and then parts
+func (wce *Wce) Lights(parts ...*Wce) []bake.Light {
+	lights := []bake.Light{}
+	wces := append([]*Wce{wce}, parts...)
+	for _, light := range wce.PointLights {
+		lights = append(lights, bake.Light{
+			Position: light.Location,
+			Color:    eqgLightColor(wces, light.LightDefTag),
+			Radius:   light.Radius,
+		})
+	}
+	for _, zon := range wce.ZonDefs {
+		for _, light := range zon.Lights {
+			lights = append(lights, bake.Light{
+				Position: light.Position,
+				Color:    light.Color,
+				Radius:   light.Radius,
+			})
+		}
+	}
+	return lights
+}
+
+// LightAmbient returns the color of the global ambient light, 0 to 1 per channel, and false if
+// there is none. The global ambient light is stored blue, green, red, alpha
+func (wce *Wce) LightAmbient() ([3]float32, bool) {
+	if wce.GlobalAmbientLightDef == nil {
+		return [3]float32{}, false
+	}
+	color := wce.GlobalAmbientLightDef.Color
+	return [3]float32{float32(color[2]) / 255, float32(color[1]) / 255, float32(color[0]) / 255}, true
+}
+
+// LightMeshes returns the meshes of the wce that take baked lighting. Actor and zon instances
+// find their model in wce first and then in models, such as the wce of an _obj.s3d
+func (wce *Wce) LightMeshes(models ...*Wce) ([]*LightMesh, error) {
+	meshes := []*LightMesh{}
+	wces := append([]*Wce{wce}, models...)
+
+	regionSprites := make(map[string]bool)
+	for _, region := range wce.Regions {
+		if region.SpriteTag != "" {
+			regionSprites[region.SpriteTag] = true
+		}
+	}
+	for _, sprite := range wce.DMSpriteDef2s {
+		if !regionSprites[sprite.Tag] && !regexRegionSprite.MatchString(sprite.Tag) {
+			continue
+		}
+		sprite := sprite
+		mesh, err := lightSpriteMesh(sprite, [3]float32{}, [3]float32{}, 1)
+		if err != nil {
+			return nil, err
+		}
+		mesh.set = func(colors [][4]uint8) {
+			sprite.VertexColors = colors
+		}
+		meshes = append(meshes, mesh)
+	}
+
+	for i, inst := range wce.ActorInsts {
+		if !inst.Location.Valid || inst.DefinitionTag == "" {
+			continue
+		}
+		i, inst := i, inst
+		var sprite *DMSpriteDef2
+		for _, w := range wces {
+			actor, ok := w.tagFirst("ACTORDEF", inst.DefinitionTag).(*ActorDef)
+			if ok {
+				sprite = w.mapActorSprite(actor)
+				break
+			}
+		}
+		if sprite == nil {
+			continue
+		}
+		translation, rotation, scale := inst.Transform()
+		mesh, err := lightSpriteMesh(sprite, translation, rotation, scale)
+		if err != nil {
+			return nil, err
+		}
+		mesh.Name = inst.Tag
+		if mesh.Name == "" {
+			mesh.Name = fmt.Sprintf("%s %d", inst.DefinitionTag, i)
+		}
+		mesh.set = func(colors [][4]uint8) {
+			wce.lightTrackSet(inst, i, colors)
+		}
+		meshes = append(meshes, mesh)
+	}
+
+	for _, zon := range wce.ZonDefs {
+		for i := range zon.Instances {
+			inst := &zon.Instances[i]
+			model := strings.TrimSuffix(inst.ModelTag, filepath.Ext(inst.ModelTag))
+			var vertices []*ModVertex
+			var faces []*ModFace
+			for _, w := range wces {
+				for _, mod := range w.ModDefs {
+					if vertices == nil && strings.EqualFold(mod.Tag, model) {
+						vertices, faces = mod.Vertices, mod.Faces
+					}
+				}
+				for _, ter := range w.TerDefs {
+					if vertices == nil && strings.EqualFold(ter.Tag, model) {
+						vertices, faces = ter.Vertices, ter.Faces
+					}
+				}
+			}
+			if vertices == nil {
+				continue
+			}
+			scale := inst.Scale
+			if scale == 0 {
+				scale = 1
+			}
+			mesh := &LightMesh{Name: inst.InstanceTag}
+			for _, vert := range vertices {
+				mesh.Positions = append(mesh.Positions, helper.EulerTransform(vert.Position, inst.Rotation, [3]float32{scale, scale, scale}, inst.Translation))
+				mesh.Normals = append(mesh.Normals, helper.EulerTransform(vert.Normal, inst.Rotation, [3]float32{1, 1, 1}, [3]float32{}))
+			}
+			for j, face := range faces {
+				for _, index := range face.Index {
+					if int(index) >= len(vertices) {
+						return nil, fmt.Errorf("%s face %d: vertex %d out of range", inst.ModelTag, j, index)
+					}
+				}
+				mesh.Indices = append(mesh.Indices, face.Index[:]...)
+			}
+			if zon.Version < 2 {
+				// v1 zones name a .lit file per instance instead of keeping lits
+				mesh.LitName = strings.ToLower(inst.InstanceTag)
+				if !strings.HasSuffix(mesh.LitName, ".lit") {
+					mesh.LitName += ".lit"
+				}
+			} else {
+				mesh.set = func(colors [][4]uint8) {
+					inst.Lits = make([]uint32, len(colors))
+					for j, color := range colors {
+						inst.Lits[j] = LitPack(color)
+					}
+				}
+			}
+			meshes = append(meshes, mesh)
+		}
+	}
+	return meshes, nil
+}
+
+// LitPack packs an rgba color into a zon lit, which like .lit entries is stored blue, green, red, alpha
+func LitPack(color [4]uint8) uint32 {
+	return uint32(color[2]) | uint32(color[1])<<8 | uint32(color[0])<<16 | uint32(color[3])<<24
+}
+
+// LitEntry returns an rgba color as a .lit entry, stored blue, green, red, alpha
+func LitEntry(color [4]uint8) [4]uint8 {
+	return [4]uint8{color[2], color[1], color[0], color[3]}
+}
+
+// lightTrackSet keeps the colors of an actor instance in its rgb track, adding one if needed
+func (wce *Wce) lightTrackSet(inst *ActorInst, index int, colors [][4]uint8) {
+	if inst.DMRGBTrackTag.Valid {
+		track, ok := wce.tagFirst("RGBDEFORMATIONTRACKDEF", inst.DMRGBTrackTag.String).(*RGBTrackDef)
+		if ok {
+			track.RGBAs = colors
+			return
+		}
+	}
+	tag := fmt.Sprintf("%s%d_DMT", mapActorName(inst.DefinitionTag), index)
+	for i := 1; wce.tagFirst("RGBDEFORMATIONTRACKDEF", tag) != nil; i++ {
+		tag = fmt.Sprintf("%s%d_%d_DMT", mapActorName(inst.DefinitionTag), index, i)
+	}
+	track := &RGBTrackDef{Tag: tag, Data1: 1, Data2: 1, Sleep: 200, RGBAs: colors}
+	track.folders = append([]string{}, inst.folders...)
+	wce.RGBTrackDefs = append(wce.RGBTrackDefs, track)
+	inst.DMRGBTrackTag = NullString{String: tag, Valid: true}
+}
+
+// lightSpriteMesh returns a dmsprite placed in the zone
+func lightSpriteMesh(sprite *DMSpriteDef2, translation [3]float32, rotation [3]float32, scale float32) (*LightMesh, error) {
+	mesh := &LightMesh{Name: sprite.Tag}
+	for i, vert := range sprite.Vertices {
+		vert = [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+		mesh.Positions = append(mesh.Positions, helper.EulerTransform(vert, rotation, [3]float32{scale, scale, scale}, translation))
+		normal := [3]float32{}
+		if i < len(sprite.VertexNormals) {
+			normal = helper.EulerTransform(sprite.VertexNormals[i], rotation, [3]float32{1, 1, 1}, [3]float32{})
+		}
+		mesh.Normals = append(mesh.Normals, normal)
+	}
+	for i, face := range sprite.Faces {
+		for _, index := range face.Triangle {
+			if int(index) >= len(sprite.Vertices) {
+				return nil, fmt.Errorf("dmspritedef2 %s face %d: vertex %d out of range", sprite.Tag, i, index)
+			}
+			mesh.Indices = append(mesh.Indices, uint32(index))
+		}
+	}
+	return mesh, nil
+}
@@ -0,0 +1,269 @@
+package wce
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xackery/quail/raw"
+)
+
+var regexRegionSprite = regexp.MustCompile(`^R\d+_DMSPRITEDEF$`)
+
+// WriteMapRaw appends the collision geometry of the wce to an EQEmu map.
+// Zone meshes become world triangles, static actor and mod meshes become models,
+// and actor and zon instances become placeables. It can be called on several
+// wce (zone, objects, _obj) to build a single map.
+// Positions are converted to server coordinates, see mapServerVertex.
+// v4 terrain tiles and invisible walls are kept outside of the wce, see
+// WriteMapTilesRaw and WriteMapWallsRaw.
+func (wce *Wce) WriteMapRaw(dst *raw.Map) error {
+	if dst == nil {
+		return fmt.Errorf("map is nil")
+	}
+	if dst.UnitsPerVertex == 0 {
+		dst.UnitsPerVertex = 1
+	}
+
+	regionSprites := make(map[string]bool)
+	for _, region := range wce.Regions {
+		if region.SpriteTag != "" {
+			regionSprites[region.SpriteTag] = true
+		}
+	}
+
+	for _, sprite := range wce.DMSpriteDef2s {
+		if !regionSprites[sprite.Tag] && !regexRegionSprite.MatchString(sprite.Tag) {
+			continue
+		}
+		for i, face := range sprite.Faces {
+			var tri [3][3]float32
+			for j, index := range face.Triangle {
+				if int(index) >= len(sprite.Vertices) {
+					return fmt.Errorf("dmspritedef2 %s face %d: vertex %d out of range", sprite.Tag, i, index)
+				}
+				vert := sprite.Vertices[index]
+				tri[j] = [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+			}
+			mapFaceAdd(dst, tri, face.Passable == 0)
+		}
+	}
+
+	for _, ter := range wce.TerDefs {
+		for i, face := range ter.Faces {
+			var tri [3][3]float32
+			for j, index := range face.Index {
+				if int(index) >= len(ter.Vertices) {
+					return fmt.Errorf("ter %s face %d: vertex %d out of range", ter.Tag, i, index)
+				}
+				tri[j] = ter.Vertices[index].Position
+			}
+			mapFaceAdd(dst, tri, face.Passable == 0)
+		}
+	}
+
+	models := make(map[string]bool)
+	for _, model := range dst.Models {
+		models[model.Name] = true
+	}
+
+	for _, actor := range wce.ActorDefs {
+		name := mapActorName(actor.Tag)
+		if models[name] {
+			continue
+		}
+		sprite := wce.mapActorSprite(actor)
+		if sprite == nil {
+			continue
+		}
+		model := &raw.MapModel{Name: name}
+		for _, vert := range sprite.Vertices {
+			model.Vertices = append(model.Vertices, mapServerVertex([3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}))
+		}
+		for i, face := range sprite.Faces {
+			mapFace := raw.MapModelFace{Collidable: face.Passable == 0}
+			for j, index := range face.Triangle {
+				if int(index) >= len(sprite.Vertices) {
+					return fmt.Errorf("actordef %s face %d: vertex %d out of range", actor.Tag, i, index)
+				}
+				mapFace.Index[j] = uint32(index)
+			}
+			mapFace.Index[1], mapFace.Index[2] = mapFace.Index[2], mapFace.Index[1]
+			model.Faces = append(model.Faces, mapFace)
+		}
+		dst.Models = append(dst.Models, model)
+		models[name] = true
+	}
+
+	for _, mod := range wce.ModDefs {
+		name := strings.ToLower(mod.Tag)
+		if models[name] {
+			continue
+		}
+		model := &raw.MapModel{Name: name}
+		for _, vert := range mod.Vertices {
+			model.Vertices = append(model.Vertices, mapServerVertex(vert.Position))
+		}
+		for i, face := range mod.Faces {
+			for _, index := range face.Index {
+				if int(index) >= len(mod.Vertices) {
+					return fmt.Errorf("mod %s face %d: vertex %d out of range", mod.Tag, i, index)
+				}
+			}
+			model.Faces = append(model.Faces, raw.MapModelFace{Index: [3]uint32{face.Index[0], face.Index[2], face.Index[1]}, Collidable: face.Passable == 0})
+		}
+		dst.Models = append(dst.Models, model)
+		models[name] = true
+	}
+
+	for _, inst := range wce.ActorInsts {
+		if !inst.Location.Valid || inst.DefinitionTag == "" {
+			continue
+		}
+		translation, rotation, scale := inst.Transform()
+		placeable := &raw.MapPlaceable{
+			ModelName:   mapActorName(inst.DefinitionTag),
+			Translation: mapServerVertex(translation),
+			Rotation:    mapServerRotation(rotation),
+			Scale:       [3]float32{scale, scale, scale},
+		}
+		dst.Placeables = append(dst.Placeables, placeable)
+	}
+
+	terrains := make(map[string]bool)
+	for _, ter := range wce.TerDefs {
+		terrains[strings.ToLower(ter.Tag)] = true
+	}
+
+	for _, zon := range wce.ZonDefs {
+		for _, inst := range zon.Instances {
+			name := strings.ToLower(inst.ModelTag)
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+			if terrains[name] {
+				continue
+			}
+			scale := inst.Scale
+			if scale == 0 {
+				scale = 1
+			}
+			dst.Placeables = append(dst.Placeables, &raw.MapPlaceable{
+				ModelName:   name,
+				Translation: mapServerVertex(inst.Translation),
+				Rotation:    mapServerRotation(inst.Rotation),
+				Scale:       [3]float32{scale, scale, scale},
+			})
+		}
+	}
+
+	return nil
+}
+
+// WriteMapTilesRaw appends the terrain tiles of a v4 zone dat to an EQEmu map.
+// Tiles are placed by their latitude and longitude, which are already in server coordinates
+func WriteMapTilesRaw(dst *raw.Map, info *raw.V4Info, dat *raw.DatZon) error {
+	if dst == nil {
+		return fmt.Errorf("map is nil")
+	}
+	if info.QuadsPerTile < 1 || info.UnitsPerVert <= 0 {
+		return fmt.Errorf("quads per tile %d and units per vert %0.2f must be above 0", info.QuadsPerTile, info.UnitsPerVert)
+	}
+	if len(dst.Tiles) > 0 && (dst.QuadsPerTile != uint32(info.QuadsPerTile) || dst.UnitsPerVertex != info.UnitsPerVert) {
+		return fmt.Errorf("tiles of %d quads and %0.2f units do not match map tiles of %d quads and %0.2f units", info.QuadsPerTile, info.UnitsPerVert, dst.QuadsPerTile, dst.UnitsPerVertex)
+	}
+	dst.QuadsPerTile = uint32(info.QuadsPerTile)
+	dst.UnitsPerVertex = info.UnitsPerVert
+
+	tileSize := info.UnitsPerVert * float32(info.QuadsPerTile)
+	quadCount := info.QuadsPerTile * info.QuadsPerTile
+	vertCount := (info.QuadsPerTile + 1) * (info.QuadsPerTile + 1)
+	for i, tile := range dat.Tiles {
+		if len(tile.Floats) != vertCount || len(tile.Flags) != quadCount {
+			return fmt.Errorf("tile %d: %d heights and %d flags, want %d and %d", i, len(tile.Floats), len(tile.Flags), vertCount, quadCount)
+		}
+		// tiles are numbered from 100000
+		mapTile := &raw.MapTile{
+			Flat: true,
+			X:    float32(tile.Lat-100000) * tileSize,
+			Y:    float32(tile.Lng-100000) * tileSize,
+		}
+		for _, height := range tile.Floats {
+			if height != tile.Floats[0] {
+				mapTile.Flat = false
+			}
+		}
+		for _, flag := range tile.Flags {
+			// a hole keeps the tile from being flat
+			if flag&0x01 != 0 {
+				mapTile.Flat = false
+			}
+		}
+		if mapTile.Flat {
+			mapTile.Z = tile.Floats[0]
+		} else {
+			mapTile.Floats = append([]float32{}, tile.Floats...)
+			mapTile.Flags = append([]uint8{}, tile.Flags...)
+		}
+		dst.Tiles = append(dst.Tiles, mapTile)
+	}
+	return nil
+}
+
+// mapWallHeight is how far invisible walls reach above their vertices, as in the EQEmu map tools
+const mapWallHeight = 1000
+
+// WriteMapWallsRaw appends the invisible walls of an eqg zone (invw.dat) to an EQEmu map.
+// Each edge of a wall becomes a collidable quad facing both ways
+func WriteMapWallsRaw(dst *raw.Map, src *raw.DatIw) error {
+	if dst == nil {
+		return fmt.Errorf("map is nil")
+	}
+	for _, wall := range src.Walls {
+		for i := 0; i+1 < len(wall.Vertices); i++ {
+			a, b := wall.Vertices[i], wall.Vertices[i+1]
+			aTop := [3]float32{a[0], a[1], a[2] + mapWallHeight}
+			bTop := [3]float32{b[0], b[1], b[2] + mapWallHeight}
+			mapFaceAdd(dst, [3][3]float32{b, a, aTop}, true)
+			mapFaceAdd(dst, [3][3]float32{aTop, bTop, b}, true)
+			mapFaceAdd(dst, [3][3]float32{aTop, a, b}, true)
+			mapFaceAdd(dst, [3][3]float32{b, bTop, aTop}, true)
+		}
+	}
+	return nil
+}
+
+// mapActorSprite returns the static mesh of an actor, or nil if it has none
+func (wce *Wce) mapActorSprite(actor *ActorDef) *DMSpriteDef2 {
+	for _, action := range actor.Actions {
+		for _, lod := range action.LevelOfDetails {
+			def := wce.ByTag(lod.SpriteTag)
+			if def == nil {
+				continue
+			}
+			sprite, ok := def.(*DMSpriteDef2)
+			if ok {
+				return sprite
+			}
+		}
+	}
+	return nil
+}
+
+// mapFaceAdd adds a world triangle in server coordinates, keeping its winding
+func mapFaceAdd(dst *raw.Map, tri [3][3]float32, isCollidable bool) {
+	dst.FaceAdd(mapServerVertex(tri[0]), mapServerVertex(tri[2]), mapServerVertex(tri[1]), isCollidable)
+}
+
+// mapServerVertex converts a wld or eqg position to EQEmu server coordinates, which have x and y swapped
+func mapServerVertex(vert [3]float32) [3]float32 {
+	return [3]float32{vert[1], vert[0], vert[2]}
+}
+
+// mapServerRotation converts an x, y, z rotation in radians to match mapServerVertex
+func mapServerRotation(rot [3]float32) [3]float32 {
+	return [3]float32{-rot[1], -rot[0], -rot[2]}
+}
+
+func mapActorName(tag string) string {
+	return strings.TrimSuffix(tag, "_ACTORDEF")
+}
@@ -0,0 +1,195 @@
+package wce
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xackery/quail/helper"
+)
+
+// AttachPoint is a named place on a skeleton that weapons, shields and other items attach to, such
+// as R_POINT. Hierarchical sprites keep points as dags, mds keep them in the .pts with their tag
+type AttachPoint struct {
+	Name        string     // such as R_POINT
+	Bone        string     // bone the point follows, as named by the skeleton
+	Translation [3]float32 // relative to the bone
+	Rotation    [4]float32 // relative to the bone, as a unit quaternion
+	Scale       [3]float32 // kept by .pts only, dags do not scale
+}
+
+// AttachPoints returns the attach points of skel. Dags of a hierarchical sprite named with a
+// POINT suffix are points, following their parent dag
+func (wce *Wce) AttachPoints(skel *Skeleton) []*AttachPoint {
+	points := []*AttachPoint{}
+	if skel.IsEqg() {
+		pts := wce.attachPts(skel)
+		if pts == nil {
+			return points
+		}
+		for _, entry := range pts.Points {
+			points = append(points, &AttachPoint{
+				Name:        entry.Name,
+				Bone:        entry.BoneName,
+				Translation: entry.Translation,
+				Rotation:    helper.QuatFromEuler(entry.Rotation),
+				Scale:       entry.Scale,
+			})
+		}
+		return points
+	}
+
+	for _, bone := range skel.Bones {
+		if !strings.HasSuffix(bone.Name, "POINT") || bone.Parent < 0 {
+			continue
+		}
+		points = append(points, &AttachPoint{
+			Name:        bone.Name,
+			Bone:        skel.Bones[bone.Parent].Name,
+			Translation: bone.Rest.Translation,
+			Rotation:    bone.Rest.Rotation,
+			Scale:       [3]float32{1, 1, 1},
+		})
+	}
+	return points
+}
+
+// AttachPoint returns the attach point of skel named name, or nil
+func (wce *Wce) AttachPoint(skel *Skeleton, name string) *AttachPoint {
+	for _, point := range wce.AttachPoints(skel) {
+		if strings.EqualFold(point.Name, name) {
+			return point
+		}
+	}
+	return nil
+}
+
+// AttachPointSet adds point to skel, or moves the point with its name. The bone it follows must
+// be a bone of skel that is not a point. Hierarchical sprites get a dag and a track for a new
+// point, so skel should be read again with Skeleton after
+func (wce *Wce) AttachPointSet(skel *Skeleton, point *AttachPoint) error {
+	name := strings.ToUpper(point.Name)
+	if name == "" || strings.ContainsAny(name, " \"") {
+		return fmt.Errorf("point name %q is not valid", point.Name)
+	}
+	boneIndex := skel.Bone(point.Bone)
+	if boneIndex < 0 {
+		return fmt.Errorf("bone %s not found in %s", point.Bone, skel.Tag)
+	}
+	bone := skel.Bones[boneIndex]
+	if strings.EqualFold(bone.Name, name) {
+		return fmt.Errorf("point %s can not follow itself", name)
+	}
+	rotation := helper.QuatNormalize(point.Rotation)
+	if point.Rotation == [4]float32{} {
+		rotation = helper.QuatIdentity
+	}
+
+	if skel.IsEqg() {
+		pts := wce.attachPts(skel)
+		if pts == nil {
+			pts = &EqgParticlePointDef{Tag: skel.mds.Tag, Version: 1}
+			pts.folders = append([]string{}, skel.mds.folders...)
+			wce.PtsDefs = append(wce.PtsDefs, pts)
+		}
+		var entry *ParticlePointEntry
+		for _, existing := range pts.Points {
+			if strings.EqualFold(existing.Name, name) {
+				entry = existing
+				break
+			}
+		}
+		if entry == nil {
+			entry = &ParticlePointEntry{Name: name}
+			pts.Points = append(pts.Points, entry)
+		}
+		entry.BoneName = bone.Name
+		entry.Translation = point.Translation
+		entry.Rotation = helper.QuatToEuler(rotation)
+		entry.Scale = point.Scale
+		if entry.Scale == [3]float32{} {
+			entry.Scale = [3]float32{1, 1, 1}
+		}
+		return nil
+	}
+
+	hiSprite, ok := wce.tagFirst("HIERARCHICALSPRITEDEF", skel.Tag).(*HierarchicalSpriteDef)
+	if !ok {
+		return fmt.Errorf("hierarchical sprite %s not found", skel.Tag)
+	}
+	transform := TrackTransform{Translation: point.Translation, Rotation: rotation}
+
+	pointIndex := skel.Bone(name)
+	if pointIndex >= 0 {
+		if !strings.HasSuffix(skel.Bones[pointIndex].Name, "POINT") {
+			return fmt.Errorf("%s is a bone of %s, not a point", name, skel.Tag)
+		}
+		for parent, depth := boneIndex, 0; parent >= 0 && depth <= len(skel.Bones); parent, depth = skel.Bones[parent].Parent, depth+1 {
+			if parent == pointIndex {
+				return fmt.Errorf("point %s can not follow %s, which follows it", name, bone.Name)
+			}
+		}
+		trackDef := wce.trackDef(skel.Bones[pointIndex].track)
+		if trackDef == nil {
+			return fmt.Errorf("point %s: track %s not found", name, skel.Bones[pointIndex].track)
+		}
+		trackDef.SetTransforms([]TrackTransform{transform})
+		// move the dag to the sub dags of its new bone
+		for i := range hiSprite.Dags {
+			subDags := []uint32{}
+			for _, subDag := range hiSprite.Dags[i].SubDags {
+				if int(subDag) != pointIndex {
+					subDags = append(subDags, subDag)
+				}
+			}
+			hiSprite.Dags[i].SubDags = subDags
+		}
+		hiSprite.Dags[boneIndex].SubDags = append(hiSprite.Dags[boneIndex].SubDags, uint32(pointIndex))
+		return nil
+	}
+
+	pointTag := skel.Code + name
+	if wce.tagFirst("TRACKINSTANCE", pointTag+"_TRACK") != nil {
+		return fmt.Errorf("track %s_TRACK already exists", pointTag)
+	}
+	track := &TrackInstance{Tag: pointTag + "_TRACK", SpriteTag: pointTag + "_TRACKDEF"}
+	trackDef := &TrackDef{Tag: pointTag + "_TRACKDEF"}
+	trackDef.SetTransforms([]TrackTransform{transform})
+	rest, ok := wce.tagFirst("TRACKINSTANCE", bone.track).(*TrackInstance)
+	if ok {
+		track.folders = append([]string{}, rest.folders...)
+		trackDef.folders = append([]string{}, rest.folders...)
+	}
+	wce.TrackDefs = append(wce.TrackDefs, trackDef)
+	wce.TrackInstances = append(wce.TrackInstances, track)
+
+	hiSprite.Dags = append(hiSprite.Dags, Dag{Tag: pointTag + "_DAG", Track: track.Tag})
+	hiSprite.Dags[boneIndex].SubDags = append(hiSprite.Dags[boneIndex].SubDags, uint32(len(hiSprite.Dags)-1))
+	return nil
+}
+
+// AttachPointPose returns where point is in the rest pose of skel, in model space
+func AttachPointPose(skel *Skeleton, point *AttachPoint) (TrackTransform, error) {
+	boneIndex := skel.Bone(point.Bone)
+	if boneIndex < 0 {
+		return TrackTransform{}, fmt.Errorf("bone %s not found in %s", point.Bone, skel.Tag)
+	}
+	poses, err := skel.restPoses()
+	if err != nil {
+		return TrackTransform{}, err
+	}
+	pose := poses[boneIndex]
+	return TrackTransform{
+		Translation: pose.apply(point.Translation),
+		Rotation:    helper.QuatNormalize(helper.QuatMul(pose.rotation, point.Rotation)),
+	}, nil
+}
+
+// attachPts returns the .pts of an mds skeleton, or nil
+func (wce *Wce) attachPts(skel *Skeleton) *EqgParticlePointDef {
+	for _, pts := range wce.PtsDefs {
+		if strings.EqualFold(pts.Tag, skel.mds.Tag) {
+			return pts
+		}
+	}
+	return nil
+}
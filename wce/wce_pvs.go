@@ -0,0 +1,144 @@
+package wce
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/xackery/quail/bake"
+	"github.com/xackery/quail/pvs"
+)
+
+// Visibility returns the region indices visible from each region, decoded from their vis lists
+func (wce *Wce) Visibility() ([][]int, error) {
+	lists := make([][]int, len(wce.Regions))
+	for i, region := range wce.Regions {
+		regions, err := region.VisibleRegions()
+		if err != nil {
+			return nil, fmt.Errorf("region %d: %w", i, err)
+		}
+		lists[i] = regions
+	}
+	return lists, nil
+}
+
+// VisibilityBuild computes which regions can see each other with the bsp of the first world
+// tree, sampling the regions and casting rays against the solid faces of region meshes
+func (wce *Wce) VisibilityBuild(cfg *pvs.Config) ([][]int, error) {
+	if cfg == nil {
+		cfg = pvs.DefaultConfig()
+	}
+	if len(wce.WorldTrees) == 0 {
+		return nil, fmt.Errorf("no world tree found")
+	}
+	tree := wce.WorldTrees[0]
+	regionIndexes := make(map[string]int)
+	for i, region := range wce.Regions {
+		regionIndexes[region.Tag] = i
+	}
+
+	zone := &pvs.Zone{
+		RegionCount: len(wce.Regions),
+		RegionAt: func(point [3]float32) int {
+			index, ok := regionIndexes[tree.RegionAt(point[0], point[1], point[2])]
+			if !ok {
+				return -1
+			}
+			return index
+		},
+		Min: [3]float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32},
+		Max: [3]float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32},
+	}
+	isBounded := false
+	bound := func(vert [3]float32) {
+		isBounded = true
+		for i := 0; i < 3; i++ {
+			zone.Min[i] = min(zone.Min[i], vert[i])
+			zone.Max[i] = max(zone.Max[i], vert[i])
+		}
+	}
+
+	positions := [][3]float32{}
+	indices := []uint32{}
+	for i, region := range wce.Regions {
+		for _, vert := range wce.RegionVertices(i) {
+			bound(vert)
+		}
+		sprite, ok := wce.ByTag(region.SpriteTag).(*DMSpriteDef2)
+		if !ok {
+			continue
+		}
+		base := uint32(len(positions))
+		for _, vert := range sprite.Vertices {
+			vert = [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+			positions = append(positions, vert)
+			bound(vert)
+		}
+		for j, face := range sprite.Faces {
+			tri := [3][3]float32{}
+			for k, index := range face.Triangle {
+				if int(index) >= len(sprite.Vertices) {
+					return nil, fmt.Errorf("dmspritedef2 %s face %d: vertex %d out of range", sprite.Tag, j, index)
+				}
+				tri[k] = positions[base+uint32(index)]
+			}
+			// a sample just off each side of a face, where a camera could be
+			center, normal := visFaceCenter(tri)
+			for _, side := range []float32{2, -2} {
+				zone.Seeds = append(zone.Seeds, [3]float32{center[0] + normal[0]*side, center[1] + normal[1]*side, center[2] + normal[2]*side})
+			}
+			// passable faces, such as water and leaves, are seen through
+			if face.Passable != 0 {
+				continue
+			}
+			indices = append(indices, base+uint32(face.Triangle[0]), base+uint32(face.Triangle[1]), base+uint32(face.Triangle[2]))
+		}
+	}
+	if !isBounded {
+		return nil, fmt.Errorf("regions have no vertices to bound the zone with")
+	}
+	for i := 0; i < 3; i++ {
+		zone.Min[i] -= cfg.Spacing
+		zone.Max[i] += cfg.Spacing
+	}
+	zone.Occluders = bake.NewScene(positions, indices)
+
+	lists, err := pvs.Build(zone, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("pvs build: %w", err)
+	}
+	return lists, nil
+}
+
+// VisibilitySet replaces the vis list of each region with lists, one per region
+func (wce *Wce) VisibilitySet(lists [][]int) error {
+	if len(lists) != len(wce.Regions) {
+		return fmt.Errorf("%d vis lists for %d regions", len(lists), len(wce.Regions))
+	}
+	for i, region := range wce.Regions {
+		err := region.SetVisibleRegions(lists[i])
+		if err != nil {
+			return fmt.Errorf("region %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// visFaceCenter returns the center and unit normal of a triangle
+func visFaceCenter(tri [3][3]float32) ([3]float32, [3]float32) {
+	center := [3]float32{}
+	for _, vert := range tri {
+		for i := 0; i < 3; i++ {
+			center[i] += vert[i] / 3
+		}
+	}
+	a := [3]float32{tri[1][0] - tri[0][0], tri[1][1] - tri[0][1], tri[1][2] - tri[0][2]}
+	b := [3]float32{tri[2][0] - tri[0][0], tri[2][1] - tri[0][1], tri[2][2] - tri[0][2]}
+	normal := [3]float32{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+	length := float32(math.Sqrt(float64(normal[0]*normal[0] + normal[1]*normal[1] + normal[2]*normal[2])))
+	if length > 0 {
+		for i := range normal {
+			normal[i] /= length
+		}
+	}
+	return center, normal
+}
@@ -0,0 +1,606 @@
+package wce
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xackery/quail/tree"
+)
+
+// References returns the definitions that the definitions tagged tag reference
+func (wce *Wce) References(tag string) []WldDefinitioner {
+	defs, nodes := wce.referenceTree()
+	ids := make(map[int32]bool)
+	for _, node := range nodes {
+		if node.Tag != tag {
+			continue
+		}
+		for childID := range node.Children {
+			ids[childID] = true
+		}
+	}
+	return referenceDefinitions(defs, ids)
+}
+
+// ReferencedBy returns the definitions that reference a definition tagged tag
+func (wce *Wce) ReferencedBy(tag string) []WldDefinitioner {
+	defs, nodes := wce.referenceTree()
+	ids := make(map[int32]bool)
+	for id, node := range nodes {
+		for _, child := range node.Children {
+			if child.Tag == tag {
+				ids[id] = true
+				break
+			}
+		}
+	}
+	return referenceDefinitions(defs, ids)
+}
+
+// Prune removes every wld definition that is not reachable from the definitions tagged by roots,
+// and returns what was removed. With no roots, actor definitions and instances, world trees,
+// zones, ambient lights and point lights are the roots. Regions are addressed by index, so they
+// are never removed
+func (wce *Wce) Prune(roots ...string) ([]WldDefinitioner, error) {
+	defs, nodes := wce.referenceTree()
+
+	rootNodes := []*tree.Node{}
+	if len(roots) == 0 {
+		for id, def := range defs {
+			switch def.(type) {
+			case *ActorDef, *ActorInst, *WorldTree, *Zone, *AmbientLight, *PointLight:
+				rootNodes = append(rootNodes, nodes[int32(id)])
+			}
+		}
+	}
+	for _, root := range roots {
+		isFound := false
+		for _, node := range nodes {
+			if node.Tag != root {
+				continue
+			}
+			rootNodes = append(rootNodes, node)
+			isFound = true
+		}
+		if !isFound {
+			return nil, fmt.Errorf("root %s not found", root)
+		}
+	}
+	for id, def := range defs {
+		_, ok := def.(*Region)
+		if ok {
+			rootNodes = append(rootNodes, nodes[int32(id)])
+		}
+	}
+
+	reachable := tree.Reachable(rootNodes...)
+	removed := []WldDefinitioner{}
+	isRemoved := make(map[WldDefinitioner]bool)
+	for id, def := range defs {
+		if reachable[int32(id)] {
+			continue
+		}
+		removed = append(removed, def)
+		isRemoved[def] = true
+	}
+	wce.removeDefinitions(isRemoved)
+	return removed, nil
+}
+
+// HasTag returns true if a wld definition is tagged tag, so it can be a root of Prune
+func (wce *Wce) HasTag(tag string) bool {
+	for _, def := range wce.wldDefinitions() {
+		if definitionTag(def) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func referenceDefinitions(defs []WldDefinitioner, ids map[int32]bool) []WldDefinitioner {
+	sorted := make([]int, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, int(id))
+	}
+	sort.Ints(sorted)
+	refs := []WldDefinitioner{}
+	for _, id := range sorted {
+		refs = append(refs, defs[id])
+	}
+	return refs
+}
+
+// referenceTree returns every wld definition and a node for each, keyed by its index in the slice,
+// linked to the definitions it references. References resolve by tag, so every TagIndex of a tag
+// is linked. Links that only exist by naming are included: a hierarchical sprite uses the
+// animation tracks of its dag tracks and the CHR_EYE materials, and a palette uses the variation
+// materials of its model
+func (wce *Wce) referenceTree() ([]WldDefinitioner, map[int32]*tree.Node) {
+	defs := wce.wldDefinitions()
+	nodes := make(map[int32]*tree.Node)
+	byTag := make(map[string][]*tree.Node)
+	animations := make(map[string][]*tree.Node) // animation tracks by the tag of their default pose
+	eyes := []*tree.Node{}
+	variations := []*tree.Node{}
+	for i, def := range defs {
+		tag := definitionTag(def)
+		node := tree.NewNode(def.Definition(), int32(i), tag)
+		nodes[int32(i)] = node
+		if tag != "" {
+			byTag[tag] = append(byTag[tag], node)
+		}
+		switch e := def.(type) {
+		case *TrackInstance:
+			if regexAniPrefix.MatchString(e.Tag) && len(e.Tag) > 3 {
+				animations[e.Tag[3:]] = append(animations[e.Tag[3:]], node)
+			}
+		case *MaterialDef:
+			if strings.HasPrefix(e.Tag, "CHR_EYE") {
+				eyes = append(eyes, node)
+			}
+			if e.Variation != 0 {
+				variations = append(variations, node)
+			}
+		}
+	}
+
+	for i, def := range defs {
+		node := nodes[int32(i)]
+		for _, ref := range wce.definitionRefs(def) {
+			for _, child := range byTag[ref] {
+				node.Link(child)
+			}
+		}
+		switch e := def.(type) {
+		case *HierarchicalSpriteDef:
+			for _, dag := range e.Dags {
+				for _, child := range animations[dag.Track] {
+					node.Link(child)
+				}
+			}
+			for _, child := range eyes {
+				node.Link(child)
+			}
+		case *MaterialPalette:
+			model := strings.TrimSuffix(e.Tag, "_MP")
+			for _, child := range variations {
+				if model != "" && strings.HasPrefix(child.Tag, model) {
+					node.Link(child)
+				}
+			}
+		}
+	}
+	return defs, nodes
+}
+
+// wldDefinitions returns every wld definition that has a tag or references one
+func (wce *Wce) wldDefinitions() []WldDefinitioner {
+	defs := []WldDefinitioner{}
+	for _, e := range wce.ActorDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.ActorInsts {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.AmbientLights {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.BlitSpriteDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.DMSpriteDef2s {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.DMSpriteDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.DMTrackDef2s {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.HierarchicalSpriteDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.LightDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.MaterialDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.MaterialPalettes {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.ParticleCloudDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.PointLights {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.PolyhedronDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.Regions {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.RGBTrackDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.SimpleSpriteDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.Sprite2DDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.Sprite3DDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.TrackDefs {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.TrackInstances {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.WorldTrees {
+		defs = append(defs, e)
+	}
+	for _, e := range wce.Zones {
+		defs = append(defs, e)
+	}
+	return defs
+}
+
+func definitionTag(def WldDefinitioner) string {
+	tag := definitionTagRef(def)
+	if tag == nil {
+		return ""
+	}
+	return *tag
+}
+
+// definitionTagRef returns the tag field of a definition
+func definitionTagRef(def WldDefinitioner) *string {
+	switch e := def.(type) {
+	case *ActorDef:
+		return &e.Tag
+	case *ActorInst:
+		return &e.Tag
+	case *AmbientLight:
+		return &e.Tag
+	case *BlitSpriteDef:
+		return &e.Tag
+	case *DMSpriteDef2:
+		return &e.Tag
+	case *DMSpriteDef:
+		return &e.Tag
+	case *DMTrackDef2:
+		return &e.Tag
+	case *HierarchicalSpriteDef:
+		return &e.Tag
+	case *LightDef:
+		return &e.Tag
+	case *MaterialDef:
+		return &e.Tag
+	case *MaterialPalette:
+		return &e.Tag
+	case *ParticleCloudDef:
+		return &e.Tag
+	case *PointLight:
+		return &e.Tag
+	case *PolyhedronDefinition:
+		return &e.Tag
+	case *Region:
+		return &e.Tag
+	case *RGBTrackDef:
+		return &e.Tag
+	case *SimpleSpriteDef:
+		return &e.Tag
+	case *Sprite2DDef:
+		return &e.Tag
+	case *Sprite3DDef:
+		return &e.Tag
+	case *TrackDef:
+		return &e.Tag
+	case *TrackInstance:
+		return &e.Tag
+	case *WorldTree:
+		return &e.Tag
+	case *Zone:
+		return &e.Tag
+	}
+	return nil
+}
+
+// definitionRefs returns the tags a definition references
+func (wce *Wce) definitionRefs(def WldDefinitioner) []string {
+	refs := []string{}
+	definitionRefsVisit(def, func(ref *string) {
+		refs = append(refs, *ref)
+	})
+	switch e := def.(type) {
+	case *AmbientLight:
+		refs = append(refs, wce.regionTags(e.Regions)...)
+	case *Zone:
+		refs = append(refs, wce.regionTags(e.Regions)...)
+	}
+	return refs
+}
+
+// definitionRefsVisit calls fn with every field of a definition that holds the tag of another
+// definition
+func definitionRefsVisit(def WldDefinitioner, fn func(ref *string)) {
+	switch e := def.(type) {
+	case *ActorDef:
+		for i := range e.Actions {
+			for j := range e.Actions[i].LevelOfDetails {
+				fn(&e.Actions[i].LevelOfDetails[j].SpriteTag)
+			}
+		}
+	case *ActorInst:
+		fn(&e.DefinitionTag)
+		if e.DMRGBTrackTag.Valid {
+			fn(&e.DMRGBTrackTag.String)
+		}
+	case *AmbientLight:
+		fn(&e.LightTag)
+	case *BlitSpriteDef:
+		fn(&e.SpriteTag)
+	case *DMSpriteDef2:
+		fn(&e.MaterialPaletteTag)
+		fn(&e.DmTrackTag)
+		fn(&e.PolyhedronTag)
+	case *DMSpriteDef:
+		fn(&e.MaterialPaletteTag)
+	case *HierarchicalSpriteDef:
+		fn(&e.PolyhedronTag)
+		for i := range e.Dags {
+			fn(&e.Dags[i].Track)
+			fn(&e.Dags[i].SpriteTag)
+		}
+		for i := range e.AttachedSkins {
+			fn(&e.AttachedSkins[i].DMSpriteTag)
+		}
+	case *MaterialDef:
+		fn(&e.SimpleSpriteTag)
+	case *MaterialPalette:
+		for i := range e.Materials {
+			fn(&e.Materials[i])
+		}
+	case *ParticleCloudDef:
+		fn(&e.BlitSpriteDefTag)
+	case *PointLight:
+		fn(&e.LightDefTag)
+	case *Region:
+		fn(&e.AmbientLightTag)
+		fn(&e.SpriteTag)
+	case *Sprite2DDef:
+		if e.SpriteTag.Valid {
+			fn(&e.SpriteTag.String)
+		}
+	case *Sprite3DDef:
+		for _, node := range e.BSPNodes {
+			if node.SpriteTag.Valid {
+				fn(&node.SpriteTag.String)
+			}
+		}
+	case *TrackInstance:
+		fn(&e.SpriteTag)
+	case *WorldTree:
+		for _, node := range e.WorldNodes {
+			fn(&node.WorldRegionTag)
+		}
+	}
+}
+
+func (wce *Wce) regionTags(regions []uint32) []string {
+	tags := []string{}
+	for _, region := range regions {
+		if int(region) < len(wce.Regions) {
+			tags = append(tags, wce.Regions[region].Tag)
+		}
+	}
+	return tags
+}
+
+// removeDefinitions removes every definition in remove from the wce
+func (wce *Wce) removeDefinitions(remove map[WldDefinitioner]bool) {
+	if len(remove) == 0 {
+		return
+	}
+	for def := range remove {
+		delete(wce.positions, def)
+	}
+	defer wce.tagLookupReset()
+
+	actorDefs := []*ActorDef{}
+	for _, e := range wce.ActorDefs {
+		if !remove[e] {
+			actorDefs = append(actorDefs, e)
+		}
+	}
+	wce.ActorDefs = actorDefs
+
+	actorInsts := []*ActorInst{}
+	for _, e := range wce.ActorInsts {
+		if !remove[e] {
+			actorInsts = append(actorInsts, e)
+		}
+	}
+	wce.ActorInsts = actorInsts
+
+	ambientLights := []*AmbientLight{}
+	for _, e := range wce.AmbientLights {
+		if !remove[e] {
+			ambientLights = append(ambientLights, e)
+		}
+	}
+	wce.AmbientLights = ambientLights
+
+	blitSpriteDefs := []*BlitSpriteDef{}
+	for _, e := range wce.BlitSpriteDefs {
+		if !remove[e] {
+			blitSpriteDefs = append(blitSpriteDefs, e)
+		}
+	}
+	wce.BlitSpriteDefs = blitSpriteDefs
+
+	dmSpriteDef2s := []*DMSpriteDef2{}
+	for _, e := range wce.DMSpriteDef2s {
+		if !remove[e] {
+			dmSpriteDef2s = append(dmSpriteDef2s, e)
+		}
+	}
+	wce.DMSpriteDef2s = dmSpriteDef2s
+
+	dmSpriteDefs := []*DMSpriteDef{}
+	for _, e := range wce.DMSpriteDefs {
+		if !remove[e] {
+			dmSpriteDefs = append(dmSpriteDefs, e)
+		}
+	}
+	wce.DMSpriteDefs = dmSpriteDefs
+
+	dmTrackDef2s := []*DMTrackDef2{}
+	for _, e := range wce.DMTrackDef2s {
+		if !remove[e] {
+			dmTrackDef2s = append(dmTrackDef2s, e)
+		}
+	}
+	wce.DMTrackDef2s = dmTrackDef2s
+
+	hierarchicalSpriteDefs := []*HierarchicalSpriteDef{}
+	for _, e := range wce.HierarchicalSpriteDefs {
+		if !remove[e] {
+			hierarchicalSpriteDefs = append(hierarchicalSpriteDefs, e)
+		}
+	}
+	wce.HierarchicalSpriteDefs = hierarchicalSpriteDefs
+
+	lightDefs := []*LightDef{}
+	for _, e := range wce.LightDefs {
+		if !remove[e] {
+			lightDefs = append(lightDefs, e)
+		}
+	}
+	wce.LightDefs = lightDefs
+
+	materialDefs := []*MaterialDef{}
+	for _, e := range wce.MaterialDefs {
+		if !remove[e] {
+			materialDefs = append(materialDefs, e)
+		}
+	}
+	wce.MaterialDefs = materialDefs
+	for folder, variations := range wce.variationMaterialDefs {
+		kept := []*MaterialDef{}
+		for _, e := range variations {
+			if !remove[e] {
+				kept = append(kept, e)
+			}
+		}
+		wce.variationMaterialDefs[folder] = kept
+	}
+
+	materialPalettes := []*MaterialPalette{}
+	for _, e := range wce.MaterialPalettes {
+		if !remove[e] {
+			materialPalettes = append(materialPalettes, e)
+		}
+	}
+	wce.MaterialPalettes = materialPalettes
+
+	particleCloudDefs := []*ParticleCloudDef{}
+	for _, e := range wce.ParticleCloudDefs {
+		if !remove[e] {
+			particleCloudDefs = append(particleCloudDefs, e)
+		}
+	}
+	wce.ParticleCloudDefs = particleCloudDefs
+
+	pointLights := []*PointLight{}
+	for _, e := range wce.PointLights {
+		if !remove[e] {
+			pointLights = append(pointLights, e)
+		}
+	}
+	wce.PointLights = pointLights
+
+	polyhedronDefs := []*PolyhedronDefinition{}
+	for _, e := range wce.PolyhedronDefs {
+		if !remove[e] {
+			polyhedronDefs = append(polyhedronDefs, e)
+		}
+	}
+	wce.PolyhedronDefs = polyhedronDefs
+
+	regions := []*Region{}
+	for _, e := range wce.Regions {
+		if !remove[e] {
+			regions = append(regions, e)
+		}
+	}
+	wce.Regions = regions
+
+	rgbTrackDefs := []*RGBTrackDef{}
+	for _, e := range wce.RGBTrackDefs {
+		if !remove[e] {
+			rgbTrackDefs = append(rgbTrackDefs, e)
+		}
+	}
+	wce.RGBTrackDefs = rgbTrackDefs
+
+	simpleSpriteDefs := []*SimpleSpriteDef{}
+	for _, e := range wce.SimpleSpriteDefs {
+		if !remove[e] {
+			simpleSpriteDefs = append(simpleSpriteDefs, e)
+		}
+	}
+	wce.SimpleSpriteDefs = simpleSpriteDefs
+
+	sprite2DDefs := []*Sprite2DDef{}
+	for _, e := range wce.Sprite2DDefs {
+		if !remove[e] {
+			sprite2DDefs = append(sprite2DDefs, e)
+		}
+	}
+	wce.Sprite2DDefs = sprite2DDefs
+
+	sprite3DDefs := []*Sprite3DDef{}
+	for _, e := range wce.Sprite3DDefs {
+		if !remove[e] {
+			sprite3DDefs = append(sprite3DDefs, e)
+		}
+	}
+	wce.Sprite3DDefs = sprite3DDefs
+
+	trackDefs := []*TrackDef{}
+	for _, e := range wce.TrackDefs {
+		if !remove[e] {
+			trackDefs = append(trackDefs, e)
+		}
+	}
+	wce.TrackDefs = trackDefs
+
+	trackInstances := []*TrackInstance{}
+	for _, e := range wce.TrackInstances {
+		if !remove[e] {
+			trackInstances = append(trackInstances, e)
+		}
+	}
+	wce.TrackInstances = trackInstances
+
+	worldTrees := []*WorldTree{}
+	for _, e := range wce.WorldTrees {
+		if !remove[e] {
+			worldTrees = append(worldTrees, e)
+		}
+	}
+	wce.WorldTrees = worldTrees
+
+	zones := []*Zone{}
+	for _, e := range wce.Zones {
+		if !remove[e] {
+			zones = append(zones, e)
+		}
+	}
+	wce.Zones = zones
+}
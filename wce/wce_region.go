@@ -0,0 +1,467 @@
+package wce
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/quail/raw"
+)
+
+// RegionType is the behavior a ZONE definition gives a bsp region
+type RegionType struct {
+	Region   int // 0 based index into Regions
+	Type     raw.WtrRegionType
+	ZoneTag  string    // ZONE definition that sets the type, empty for normal regions
+	ZoneLine *ZoneLine // destination when the region teleports
+}
+
+// ZoneLine is the destination of a zoneline region, encoded in a DRNTP zone tag.
+// A ZoneID of 255 refers to the zone point at Index instead of a position
+type ZoneLine struct {
+	ZoneID  int
+	Index   int
+	X       int
+	Y       int
+	Z       int
+	Heading int
+}
+
+func (e *ZoneLine) String() string {
+	if e.ZoneID == 255 {
+		return fmt.Sprintf("zone point %d", e.Index)
+	}
+	return fmt.Sprintf("zone %d at %d %d %d heading %d", e.ZoneID, e.X, e.Y, e.Z, e.Heading)
+}
+
+// RegionTypes returns the type of every region, regions not in a ZONE are normal
+func (wce *Wce) RegionTypes() []*RegionType {
+	regionTypes := make([]*RegionType, len(wce.Regions))
+	for i, region := range wce.Regions {
+		regionTypes[i] = &RegionType{Region: i, Type: raw.WtrRegionTypeNormal}
+		// some zones mark the region itself instead of using a ZONE
+		regionType := zoneNameRegionType(region.UserData)
+		if regionType != raw.WtrRegionTypeUnsupported {
+			regionTypes[i].Type = regionType
+			regionTypes[i].ZoneLine = zoneLineDecode(region.UserData)
+		}
+	}
+
+	for _, zone := range wce.Zones {
+		regionType := zoneRegionType(zone)
+		if regionType == raw.WtrRegionTypeUnsupported {
+			continue
+		}
+		zoneLine := zoneLineDecode(zone.Tag)
+		if zoneLine == nil {
+			zoneLine = zoneLineDecode(zone.UserData)
+		}
+		for _, region := range zone.Regions {
+			if int(region) >= len(regionTypes) {
+				continue
+			}
+			regionTypes[region].Type = regionType
+			regionTypes[region].ZoneTag = zone.Tag
+			regionTypes[region].ZoneLine = zoneLine
+		}
+	}
+	return regionTypes
+}
+
+// SetRegionType moves a region into the ZONE for regionType, creating the ZONE if needed.
+// zoneLine is required for zonelines and ignored otherwise. ZONEs left without regions are removed
+func (wce *Wce) SetRegionType(regionIdx int, regionType raw.WtrRegionType, zoneLine *ZoneLine) error {
+	if regionIdx < 0 || regionIdx >= len(wce.Regions) {
+		return fmt.Errorf("region %d out of range, have %d regions", regionIdx, len(wce.Regions))
+	}
+
+	tag := ""
+	switch regionType {
+	case raw.WtrRegionTypeNormal:
+	case raw.WtrRegionTypeWater:
+		tag = "WT_ZONE"
+	case raw.WtrRegionTypeLava:
+		tag = "LA_ZONE"
+	case raw.WtrRegionTypeZoneLine:
+		if zoneLine == nil {
+			return fmt.Errorf("zoneline requires a destination")
+		}
+		tag = zoneLineEncode(zoneLine)
+	case raw.WtrRegionTypePVP:
+		tag = "DRP_ZONE"
+	case raw.WtrRegionTypeSlime:
+		tag = "SLN_ZONE"
+	case raw.WtrRegionTypeIce:
+		tag = "DRN_S_ZONE"
+	case raw.WtrRegionTypeVWater:
+		tag = "VWA_ZONE"
+	default:
+		return fmt.Errorf("region type %s is not supported by s3d zones", regionType)
+	}
+
+	region := wce.Regions[regionIdx]
+	if zoneNameRegionType(region.UserData) != raw.WtrRegionTypeUnsupported {
+		region.UserData = ""
+	}
+
+	folders := []string{"world"}
+	zones := []*Zone{}
+	for _, zone := range wce.Zones {
+		if len(zone.folders) > 0 {
+			folders = zone.folders
+		}
+		// zones without a region type group regions for other reasons, leave them be
+		if zoneRegionType(zone) == raw.WtrRegionTypeUnsupported {
+			zones = append(zones, zone)
+			continue
+		}
+		isChanged := false
+		for i := 0; i < len(zone.Regions); i++ {
+			if int(zone.Regions[i]) != regionIdx {
+				continue
+			}
+			zone.Regions = append(zone.Regions[:i], zone.Regions[i+1:]...)
+			isChanged = true
+			i--
+		}
+		if isChanged && len(zone.Regions) == 0 {
+			continue
+		}
+		zones = append(zones, zone)
+	}
+	wce.Zones = zones
+
+	if tag == "" {
+		return nil
+	}
+
+	var dst *Zone
+	for _, zone := range wce.Zones {
+		if zone.Tag == tag {
+			dst = zone
+			break
+		}
+		if regionType == raw.WtrRegionTypeZoneLine || zoneRegionType(zone) != regionType || zoneLineDecode(zone.Tag) != nil {
+			continue
+		}
+		dst = zone
+		break
+	}
+	if dst == nil {
+		dst = &Zone{folders: append([]string{}, folders...), Tag: tag}
+		wce.Zones = append(wce.Zones, dst)
+	}
+	dst.Regions = append(dst.Regions, uint32(regionIdx))
+	sort.Slice(dst.Regions, func(i, j int) bool { return dst.Regions[i] < dst.Regions[j] })
+	return nil
+}
+
+// zoneLineDecode parses a DRNTP, WTNTP or LANTP tag, laid out as a 5 digit zone id,
+// 6 digit x, y and z and a 3 digit heading. Returns nil if name is not a zoneline
+func zoneLineDecode(name string) *ZoneLine {
+	name = strings.ToUpper(name)
+	if len(name) < 5 {
+		return nil
+	}
+	switch name[:5] {
+	case "DRNTP", "WTNTP", "LANTP":
+	default:
+		return nil
+	}
+	if strings.HasPrefix(name[5:], "_") {
+		return &ZoneLine{ZoneID: 255}
+	}
+
+	field := func(start, length int) (int, bool) {
+		if len(name) < start+length {
+			return 0, false
+		}
+		val, err := strconv.Atoi(name[start : start+length])
+		if err != nil {
+			return 0, false
+		}
+		return val, true
+	}
+
+	zoneID, ok := field(5, 5)
+	if !ok {
+		return nil
+	}
+	if zoneID == 255 {
+		index, ok := field(10, 6)
+		if !ok {
+			return nil
+		}
+		return &ZoneLine{ZoneID: zoneID, Index: index}
+	}
+
+	zoneLine := &ZoneLine{ZoneID: zoneID}
+	for i, dst := range []*int{&zoneLine.X, &zoneLine.Y, &zoneLine.Z} {
+		*dst, ok = field(10+i*6, 6)
+		if !ok {
+			return nil
+		}
+	}
+	zoneLine.Heading, ok = field(28, 3)
+	if !ok {
+		return nil
+	}
+	return zoneLine
+}
+
+// zoneLineEncode returns the ZONE tag for a zoneline destination
+func zoneLineEncode(zoneLine *ZoneLine) string {
+	if zoneLine.ZoneID == 255 {
+		return fmt.Sprintf("DRNTP%05d%06d%06d%06d%03d_ZONE", 255, zoneLine.Index, 0, 0, 0)
+	}
+	return fmt.Sprintf("DRNTP%05d%06d%06d%06d%03d_ZONE", zoneLine.ZoneID, zoneLine.X, zoneLine.Y, zoneLine.Z, zoneLine.Heading)
+}
+
+// RegionAt walks the bsp from the root and returns the tag of the region containing a point in
+// zone coordinates, or an empty string if the point is outside the tree
+func (e *WorldTree) RegionAt(x, y, z float32) string {
+	nodeNumber := uint32(1)
+	for depth := 0; depth < len(e.WorldNodes); depth++ {
+		if nodeNumber < 1 || int(nodeNumber) > len(e.WorldNodes) {
+			return ""
+		}
+		node := e.WorldNodes[nodeNumber-1]
+		if node.WorldRegionTag != "" {
+			return node.WorldRegionTag
+		}
+		distance := x*node.Normals[0] + y*node.Normals[1] + z*node.Normals[2] + node.Normals[3]
+		if distance >= 0 {
+			nodeNumber = node.FrontTree
+		} else {
+			nodeNumber = node.BackTree
+		}
+		if nodeNumber == 0 {
+			return ""
+		}
+	}
+	return ""
+}
+
+// RegionIndex returns the index in Regions of a region tag, or -1
+func (wce *Wce) RegionIndex(tag string) int {
+	for i, region := range wce.Regions {
+		if region.Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// RegionVertices returns a region's vertices, falling back to its mesh when the region has none
+func (wce *Wce) RegionVertices(regionIdx int) [][3]float32 {
+	if regionIdx < 0 || regionIdx >= len(wce.Regions) {
+		return nil
+	}
+	region := wce.Regions[regionIdx]
+	if len(region.RegionVertices) > 0 {
+		return region.RegionVertices
+	}
+	if region.SpriteTag == "" {
+		return nil
+	}
+	sprite, ok := wce.ByTag(region.SpriteTag).(*DMSpriteDef2)
+	if !ok {
+		return nil
+	}
+	verts := make([][3]float32, len(sprite.Vertices))
+	for i, vert := range sprite.Vertices {
+		verts[i] = [3]float32{vert[0] + sprite.CenterOffset[0], vert[1] + sprite.CenterOffset[1], vert[2] + sprite.CenterOffset[2]}
+	}
+	return verts
+}
+
+// RegionAmbientLight returns the ambient light that lists a region, or nil
+func (wce *Wce) RegionAmbientLight(regionIdx int) *AmbientLight {
+	if regionIdx < 0 || regionIdx >= len(wce.Regions) {
+		return nil
+	}
+	region := wce.Regions[regionIdx]
+	for _, light := range wce.AmbientLights {
+		if region.AmbientLightTag != "" && light.Tag == region.AmbientLightTag {
+			return light
+		}
+		for _, lightRegion := range light.Regions {
+			if int(lightRegion) == regionIdx {
+				return light
+			}
+		}
+	}
+	return nil
+}
+
+// RegionZones returns every ZONE that lists a region
+func (wce *Wce) RegionZones(regionIdx int) []*Zone {
+	zones := []*Zone{}
+	for _, zone := range wce.Zones {
+		for _, region := range zone.Regions {
+			if int(region) == regionIdx {
+				zones = append(zones, zone)
+				break
+			}
+		}
+	}
+	return zones
+}
+
+// VisibleRegions returns the sorted indices of regions visible from this region, from all of its vis lists
+func (e *Region) VisibleRegions() ([]int, error) {
+	if e.VisTree == nil {
+		return nil, nil
+	}
+	visible := make(map[int]bool)
+	for i, list := range e.VisTree.VisLists {
+		regions, err := VisListDecode(list.Ranges, e.VisListBytes == 1)
+		if err != nil {
+			return nil, fmt.Errorf("vis list %d: %w", i, err)
+		}
+		for _, region := range regions {
+			visible[region] = true
+		}
+	}
+	regions := make([]int, 0, len(visible))
+	for region := range visible {
+		regions = append(regions, region)
+	}
+	sort.Ints(regions)
+	return regions, nil
+}
+
+// VisListDecode returns the region indices of a vis list. Run length encoded lists are
+// a sequence of skip and include counts, otherwise the list is uint16 region indices
+func VisListDecode(ranges []byte, isRLE bool) ([]int, error) {
+	regions := []int{}
+	if !isRLE {
+		if len(ranges)%2 != 0 {
+			return nil, fmt.Errorf("odd length %d for uint16 region list", len(ranges))
+		}
+		for i := 0; i < len(ranges); i += 2 {
+			regions = append(regions, int(ranges[i])|int(ranges[i+1])<<8)
+		}
+		return regions, nil
+	}
+
+	region := 0
+	include := func(count int) {
+		for i := 0; i < count; i++ {
+			regions = append(regions, region)
+			region++
+		}
+	}
+	for i := 0; i < len(ranges); i++ {
+		b := ranges[i]
+		switch {
+		case b < 0x3f:
+			region += int(b)
+		case b == 0x3f:
+			if i+2 >= len(ranges) {
+				return nil, fmt.Errorf("offset %d: skip word past end", i)
+			}
+			region += int(ranges[i+1]) | int(ranges[i+2])<<8
+			i += 2
+		case b < 0x80:
+			region += int(b&0x38) >> 3
+			include(int(b & 0x07))
+		case b < 0xc0:
+			include(int(b&0x38) >> 3)
+			region += int(b & 0x07)
+		case b < 0xff:
+			include(int(b - 0xc0))
+		default:
+			if i+2 >= len(ranges) {
+				return nil, fmt.Errorf("offset %d: include word past end", i)
+			}
+			include(int(ranges[i+1]) | int(ranges[i+2])<<8)
+			i += 2
+		}
+	}
+	return regions, nil
+}
+
+// VisListEncode returns region indices as a run length encoded vis list, see VisListDecode
+func VisListEncode(regions []int) ([]byte, error) {
+	sorted := append([]int{}, regions...)
+	sort.Ints(sorted)
+	if len(sorted) > 0 && sorted[0] < 0 {
+		return nil, fmt.Errorf("region %d is negative", sorted[0])
+	}
+
+	// runs of regions to skip, then include
+	type run struct{ skip, include int }
+	runs := []run{}
+	next := 0
+	for _, region := range sorted {
+		if region < next {
+			continue
+		}
+		if region == next && len(runs) > 0 {
+			runs[len(runs)-1].include++
+		} else {
+			runs = append(runs, run{skip: region - next, include: 1})
+		}
+		next = region + 1
+	}
+
+	ranges := []byte{}
+	word := func(code byte, count int) {
+		ranges = append(ranges, code, byte(count), byte(count>>8))
+	}
+	skip := func(count int) {
+		for count > 0xffff {
+			word(0x3f, 0xffff)
+			count -= 0xffff
+		}
+		if count >= 0x3f {
+			word(0x3f, count)
+		} else if count > 0 {
+			ranges = append(ranges, byte(count))
+		}
+	}
+	include := func(count int) {
+		for count > 0xffff {
+			word(0xff, 0xffff)
+			count -= 0xffff
+		}
+		if count > 0x3e {
+			word(0xff, count)
+		} else if count > 0 {
+			ranges = append(ranges, 0xc0+byte(count))
+		}
+	}
+	for i := 0; i < len(runs); i++ {
+		r := runs[i]
+		if r.skip > 0 && r.skip <= 7 && r.include <= 7 {
+			ranges = append(ranges, 0x40|byte(r.skip)<<3|byte(r.include))
+			continue
+		}
+		skip(r.skip)
+		// a short include and the short skip after it fit one byte
+		if r.include <= 7 && i+1 < len(runs) && runs[i+1].skip > 0 && runs[i+1].skip <= 7 {
+			ranges = append(ranges, 0x80|byte(r.include)<<3|byte(runs[i+1].skip))
+			runs[i+1].skip = 0
+			continue
+		}
+		include(r.include)
+	}
+	return ranges, nil
+}
+
+// SetVisibleRegions replaces the vis tree of a region with a single run length encoded list of
+// the region indices visible from it
+func (e *Region) SetVisibleRegions(regions []int) error {
+	ranges, err := VisListEncode(regions)
+	if err != nil {
+		return err
+	}
+	e.VisListBytes = 1
+	e.VisTree = &VisTree{
+		VisNodes: []*VisNode{{VisListIndex: 1}},
+		VisLists: []*VisList{{Ranges: ranges}},
+	}
+	return nil
+}
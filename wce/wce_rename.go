@@ -0,0 +1,95 @@
+package wce
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenameTag renames every definition tagged oldTag to newTag and updates every reference to it.
+// Nothing is changed if a definition is already tagged newTag
+func (wce *Wce) RenameTag(oldTag string, newTag string) (int, error) {
+	if oldTag == "" || newTag == "" {
+		return 0, fmt.Errorf("tags must not be empty")
+	}
+	return wce.rename(func(tag string) string {
+		if tag == oldTag {
+			return newTag
+		}
+		return tag
+	})
+}
+
+// RenamePrefix renames a model code, such as ELF to ZZZ, in every tag that starts with it and in
+// every animation track, such as C01ELFPE_TRACK, that starts with an animation code followed by it.
+// References and dag names are updated to match. Nothing is changed if a renamed tag is already
+// used by another definition
+func (wce *Wce) RenamePrefix(oldCode string, newCode string) (int, error) {
+	if oldCode == "" || newCode == "" {
+		return 0, fmt.Errorf("codes must not be empty")
+	}
+	return wce.rename(func(tag string) string {
+		return renamePrefix(tag, oldCode, newCode)
+	})
+}
+
+func renamePrefix(tag string, oldCode string, newCode string) string {
+	if strings.HasPrefix(tag, oldCode) {
+		return newCode + tag[len(oldCode):]
+	}
+	if regexAniPrefix.MatchString(tag) && strings.HasPrefix(tag[3:], oldCode) {
+		return tag[:3] + newCode + tag[3+len(oldCode):]
+	}
+	return tag
+}
+
+// rename applies fn to every tag and reference, returning how many definitions were renamed.
+// Tags are checked for collisions before anything is changed
+func (wce *Wce) rename(fn func(tag string) string) (int, error) {
+	defs := wce.wldDefinitions()
+
+	originals := make(map[string]string) // renamed tag to the tag it came from
+	for _, def := range defs {
+		tag := definitionTag(def)
+		if tag == "" {
+			continue
+		}
+		renamed := fn(tag)
+		original, ok := originals[renamed]
+		if ok && original != tag {
+			return 0, fmt.Errorf("%s %s: renamed to %s, which is already used by %s", def.Definition(), tag, renamed, original)
+		}
+		originals[renamed] = tag
+	}
+
+	count := 0
+	for _, def := range defs {
+		tag := definitionTagRef(def)
+		if tag != nil && *tag != "" {
+			renamed := fn(*tag)
+			if renamed != *tag {
+				*tag = renamed
+				count++
+			}
+		}
+		definitionRefsVisit(def, func(ref *string) {
+			if *ref != "" {
+				*ref = fn(*ref)
+			}
+		})
+		hs, ok := def.(*HierarchicalSpriteDef)
+		if ok {
+			for i := range hs.Dags {
+				hs.Dags[i].Tag = fn(hs.Dags[i].Tag)
+			}
+		}
+	}
+
+	wce.tagLookupReset()
+
+	tagIndexes := make(map[string]int)
+	for tag, index := range wce.tagIndexes {
+		tagIndexes[fn(tag)] = index
+	}
+	wce.tagIndexes = tagIndexes
+	return count, nil
+}
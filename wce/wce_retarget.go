@@ -0,0 +1,166 @@
+package wce
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/xackery/quail/helper"
+)
+
+// AnimationRetarget returns anim, made for the skeleton from, moved to the skeleton to.
+// boneMap names the bone of to that each bone of from moves, and bones it leaves out move the bone
+// of to with the same name. Rotations keep their change from the rest pose of from. Translations
+// keep their change from the rest pose too, scaled by how much longer the bone of to is. Root
+// bones stay at their rest position unless isRootMotion is set, which scales their movement by
+// how much larger to is. Bones of to that nothing moves stay at their rest pose
+func AnimationRetarget(anim *Animation, from *Skeleton, to *Skeleton, boneMap map[string]string, isRootMotion bool) (*Animation, error) {
+	sources, err := animationBoneSources(from, to, boneMap)
+	if err != nil {
+		return nil, err
+	}
+
+	fromBones := make(map[string]*AniBone)
+	for _, bone := range anim.Bones {
+		fromBones[strings.ToUpper(bone.Name)] = bone
+	}
+
+	// the size of a skeleton is the length of its bones
+	fromLength := float32(0)
+	toLength := float32(0)
+	for i, fromIndex := range sources {
+		if fromIndex < 0 {
+			continue
+		}
+		fromLength += vecLength(from.Bones[fromIndex].Rest.Translation)
+		toLength += vecLength(to.Bones[i].Rest.Translation)
+	}
+	rootScale := float32(1)
+	if fromLength > 0 {
+		rootScale = toLength / fromLength
+	}
+
+	dst := &Animation{Code: anim.Code}
+	duration := anim.Duration()
+	for i, toBone := range to.Bones {
+		fromIndex := sources[i]
+		var fromBone *AniBone
+		if fromIndex >= 0 {
+			fromBone = fromBones[strings.ToUpper(from.Bones[fromIndex].Name)]
+		}
+		if fromBone == nil || len(fromBone.Frames) == 0 {
+			dst.Bones = append(dst.Bones, &AniBone{
+				Name: toBone.Name,
+				Frames: []*AniBoneFrame{
+					{Milliseconds: 0, Translation: toBone.Rest.Translation, Rotation: toBone.Rest.Rotation, Scale: [3]float32{1, 1, 1}},
+					{Milliseconds: duration, Translation: toBone.Rest.Translation, Rotation: toBone.Rest.Rotation, Scale: [3]float32{1, 1, 1}},
+				},
+			})
+			continue
+		}
+
+		fromRest := from.Bones[fromIndex].Rest
+		scale := float32(1)
+		fromBoneLength := vecLength(fromRest.Translation)
+		if fromBoneLength > 1e-4 {
+			scale = vecLength(toBone.Rest.Translation) / fromBoneLength
+		}
+		isRoot := toBone.Parent < 0
+		if isRoot {
+			scale = rootScale
+		}
+		fromRestInverse := helper.QuatConjugate(fromRest.Rotation)
+
+		dstBone := &AniBone{Name: toBone.Name}
+		for _, frame := range fromBone.Frames {
+			dstFrame := &AniBoneFrame{
+				Milliseconds: frame.Milliseconds,
+				Translation:  toBone.Rest.Translation,
+				// the change from rest, in the space of the bone
+				Rotation: helper.QuatNormalize(helper.QuatMul(toBone.Rest.Rotation, helper.QuatMul(fromRestInverse, frame.Rotation))),
+				Scale:    frame.Scale,
+			}
+			if !isRoot || isRootMotion {
+				for j := 0; j < 3; j++ {
+					dstFrame.Translation[j] += (frame.Translation[j] - fromRest.Translation[j]) * scale
+				}
+			}
+			dstBone.Frames = append(dstBone.Frames, dstFrame)
+		}
+		dst.Bones = append(dst.Bones, dstBone)
+	}
+	return dst, nil
+}
+
+// AnimationCopy returns anim, made for the skeleton from, with its bones renamed to those of the
+// skeleton to. boneMap names the bone of to that each bone of from moves, and bones it leaves out
+// move the bone of to with the same name. Keyframes are copied as they are, and bones of to that
+// nothing moves are left out
+func AnimationCopy(anim *Animation, from *Skeleton, to *Skeleton, boneMap map[string]string) (*Animation, error) {
+	sources, err := animationBoneSources(from, to, boneMap)
+	if err != nil {
+		return nil, err
+	}
+	fromBones := make(map[string]*AniBone)
+	for _, bone := range anim.Bones {
+		fromBones[strings.ToUpper(bone.Name)] = bone
+	}
+
+	dst := &Animation{Code: anim.Code}
+	for i, toBone := range to.Bones {
+		if sources[i] < 0 {
+			continue
+		}
+		fromBone := fromBones[strings.ToUpper(from.Bones[sources[i]].Name)]
+		if fromBone == nil {
+			continue
+		}
+		dstBone := aniBoneCopy(fromBone)
+		dstBone.Name = toBone.Name
+		dst.Bones = append(dst.Bones, dstBone)
+	}
+	if len(dst.Bones) == 0 {
+		return nil, fmt.Errorf("no bones of %s move bones of %s", from.Tag, to.Tag)
+	}
+	return dst, nil
+}
+
+// animationBoneSources returns the bone of from that moves each bone of to, or -1 for none
+func animationBoneSources(from *Skeleton, to *Skeleton, boneMap map[string]string) ([]int, error) {
+	sources := make([]int, len(to.Bones)) // bone of from moving each bone of to
+	for i := range sources {
+		sources[i] = -1
+	}
+	for fromName, toName := range boneMap {
+		fromIndex := from.Bone(fromName)
+		if fromIndex < 0 {
+			return nil, fmt.Errorf("bone map: %s is not a bone of %s", fromName, from.Tag)
+		}
+		toIndex := to.Bone(toName)
+		if toIndex < 0 {
+			return nil, fmt.Errorf("bone map: %s is not a bone of %s", toName, to.Tag)
+		}
+		if sources[toIndex] >= 0 {
+			return nil, fmt.Errorf("bone map: %s is mapped from both %s and %s", toName, from.Bones[sources[toIndex]].Name, fromName)
+		}
+		sources[toIndex] = fromIndex
+	}
+	isMapped := make(map[int]bool)
+	for _, fromIndex := range sources {
+		isMapped[fromIndex] = true
+	}
+	for i, bone := range to.Bones {
+		if sources[i] >= 0 {
+			continue
+		}
+		fromIndex := from.Bone(bone.Name)
+		if fromIndex >= 0 && !isMapped[fromIndex] {
+			sources[i] = fromIndex
+		}
+	}
+	return sources, nil
+}
+
+func vecLength(v [3]float32) float32 {
+	return float32(math.Sqrt(float64(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])))
+}
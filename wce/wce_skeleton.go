@@ -0,0 +1,506 @@
+package wce
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/xackery/quail/helper"
+)
+
+// Skeleton is the bone hierarchy of a hierarchical sprite or an mds, so animations can be read
+// and written the same way for s3d tracks and eqg .ani files
+type Skeleton struct {
+	Tag   string
+	Code  string // model code, such as HUM
+	Bones []*SkeletonBone
+	mds   *EqgMdsDef
+}
+
+// SkeletonBone is a bone of a skeleton and where it rests relative to its parent
+type SkeletonBone struct {
+	Name   string // without the model code or _DAG, such as PE. The root of a hierarchical sprite is ROOT
+	Parent int    // -1 for a root bone
+	Rest   TrackTransform
+	track  string // dag track tag
+}
+
+// Animation is an action of a skeleton, such as C01, as the keyframes of each bone it moves.
+// Bones are named as the skeleton bones they move
+type Animation struct {
+	Code  string
+	Bones []*AniBone
+}
+
+// Skeleton returns the hierarchical sprite or mds tagged tag. The _HS_DEF suffix is optional,
+// and mds tags match without case
+func (wce *Wce) Skeleton(tag string) (*Skeleton, error) {
+	code := strings.TrimSuffix(strings.ToUpper(tag), "_HS_DEF")
+	for _, hiSprite := range wce.HierarchicalSpriteDefs {
+		if hiSprite.Tag == tag || hiSprite.Tag == code+"_HS_DEF" {
+			return wce.skeletonFromHierarchicalSprite(hiSprite)
+		}
+	}
+	for _, mds := range wce.MdsDefs {
+		if strings.EqualFold(mds.Tag, code) {
+			return skeletonFromMds(mds)
+		}
+	}
+	return nil, fmt.Errorf("skeleton %s not found", tag)
+}
+
+func (wce *Wce) skeletonFromHierarchicalSprite(hiSprite *HierarchicalSpriteDef) (*Skeleton, error) {
+	skel := &Skeleton{
+		Tag:  hiSprite.Tag,
+		Code: strings.TrimSuffix(hiSprite.Tag, "_HS_DEF"),
+	}
+	for _, dag := range hiSprite.Dags {
+		// the first frame of the dag track is the bind pose
+		bone := &SkeletonBone{
+			Name:   skeletonBoneName(skel.Code, dag.Tag),
+			Parent: -1,
+			Rest:   TrackTransform{Rotation: helper.QuatIdentity},
+			track:  dag.Track,
+		}
+		track := wce.trackDef(dag.Track)
+		if track != nil {
+			transforms := track.Transforms()
+			if len(transforms) > 0 {
+				bone.Rest = transforms[0]
+			}
+		}
+		skel.Bones = append(skel.Bones, bone)
+	}
+	for i, dag := range hiSprite.Dags {
+		for _, subDag := range dag.SubDags {
+			if int(subDag) >= len(skel.Bones) {
+				return nil, fmt.Errorf("dag %s: sub dag %d out of range", dag.Tag, subDag)
+			}
+			if skel.Bones[subDag].Parent != -1 || int(subDag) == i {
+				return nil, fmt.Errorf("dag %s: sub dag %d has more than one parent", dag.Tag, subDag)
+			}
+			skel.Bones[subDag].Parent = i
+		}
+	}
+	return skel, nil
+}
+
+func skeletonFromMds(mds *EqgMdsDef) (*Skeleton, error) {
+	parents, _, err := mdsBoneHierarchy(mds.Bones)
+	if err != nil {
+		return nil, fmt.Errorf("mds %s: %w", mds.Tag, err)
+	}
+	skel := &Skeleton{
+		Tag:  mds.Tag,
+		Code: strings.ToUpper(mds.Tag),
+		mds:  mds,
+	}
+	for i, bone := range mds.Bones {
+		skel.Bones = append(skel.Bones, &SkeletonBone{
+			Name:   bone.Name,
+			Parent: parents[i],
+			Rest:   TrackTransform{Translation: bone.Pivot, Rotation: helper.QuatNormalize(bone.Quaternion)},
+		})
+	}
+	return skel, nil
+}
+
+// skeletonBoneName returns a dag tag without its model code, such as PE for HUMPE_DAG
+func skeletonBoneName(code string, dagTag string) string {
+	name := strings.TrimPrefix(strings.TrimSuffix(dagTag, "_DAG"), code)
+	if name == "" {
+		return "ROOT"
+	}
+	return name
+}
+
+// Bone returns the index of the bone named name, ignoring case, or -1 if it is missing
+func (e *Skeleton) Bone(name string) int {
+	for i, bone := range e.Bones {
+		if strings.EqualFold(bone.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsEqg returns true if the skeleton is an mds, with animations in .ani files
+func (e *Skeleton) IsEqg() bool {
+	return e.mds != nil
+}
+
+// Animations returns a copy of every animation of skel, in the order they were added
+func (wce *Wce) Animations(skel *Skeleton) []*Animation {
+	if skel.IsEqg() {
+		animations := []*Animation{}
+		for _, ani := range wce.skeletonAnis(skel) {
+			anim := &Animation{Code: aniCode(ani.Tag)}
+			for _, bone := range ani.Bones {
+				anim.Bones = append(anim.Bones, aniBoneCopy(bone))
+			}
+			animations = append(animations, anim)
+		}
+		return animations
+	}
+
+	// an animation track is named as the dag track with an animation code before it, such as C01HUMPE_TRACK
+	trackBones := make(map[string]int)
+	for i, bone := range skel.Bones {
+		if bone.track != "" {
+			trackBones[bone.track] = i
+		}
+	}
+	animations := []*Animation{}
+	codes := make(map[string]*Animation)
+	for _, track := range wce.TrackInstances {
+		if len(track.Tag) <= 3 || !regexAniPrefix.MatchString(track.Tag) {
+			continue
+		}
+		boneIndex, ok := trackBones[track.Tag[3:]]
+		if !ok {
+			continue
+		}
+		trackDef := wce.trackDef(track.Tag)
+		if trackDef == nil {
+			continue
+		}
+		code := track.Tag[:3]
+		anim := codes[code]
+		if anim == nil {
+			anim = &Animation{Code: code}
+			codes[code] = anim
+			animations = append(animations, anim)
+		}
+		sleep := uint32(100)
+		if track.Sleep.Valid && track.Sleep.Uint32 > 0 {
+			sleep = track.Sleep.Uint32
+		}
+		bone := &AniBone{Name: skel.Bones[boneIndex].Name}
+		for j, transform := range trackDef.Transforms() {
+			bone.Frames = append(bone.Frames, &AniBoneFrame{
+				Milliseconds: uint32(j) * sleep,
+				Translation:  transform.Translation,
+				Rotation:     transform.Rotation,
+				Scale:        [3]float32{1, 1, 1},
+			})
+		}
+		anim.Bones = append(anim.Bones, bone)
+	}
+	return animations
+}
+
+// Animation returns a copy of the animation of skel with code, or nil if it is missing
+func (wce *Wce) Animation(skel *Skeleton, code string) *Animation {
+	for _, anim := range wce.Animations(skel) {
+		if strings.EqualFold(anim.Code, code) {
+			return anim
+		}
+	}
+	return nil
+}
+
+// AnimationSet adds anim to skel, replacing any animation with the same code. Bones of anim that
+// are not in skel are skipped. Hierarchical sprites get a track for each bone, sampled at the
+// shortest time between keyframes, and mds get an .ani
+func (wce *Wce) AnimationSet(skel *Skeleton, anim *Animation) error {
+	code := strings.ToUpper(anim.Code)
+	if len(code) != 3 || !regexAniPrefix.MatchString(code) {
+		return fmt.Errorf("%s is not an animation code, such as C01", anim.Code)
+	}
+	for _, bone := range anim.Bones {
+		for i := 1; i < len(bone.Frames); i++ {
+			if bone.Frames[i].Milliseconds < bone.Frames[i-1].Milliseconds {
+				return fmt.Errorf("bone %s: frame %d is before the frame ahead of it", bone.Name, i)
+			}
+		}
+	}
+	wce.AnimationRemove(skel, code)
+
+	if skel.IsEqg() {
+		ani := &EqgAniDef{
+			Tag:     skel.mds.Tag + "_" + strings.ToLower(code),
+			Version: 1,
+		}
+		folder := strings.TrimSuffix(strings.ToLower(wce.FileName), ".eqg")
+		ani.folders = []string{folder + "/" + folder + "_ani"}
+		for _, bone := range anim.Bones {
+			boneIndex := skel.Bone(bone.Name)
+			if boneIndex < 0 {
+				continue
+			}
+			aniBone := aniBoneCopy(bone)
+			aniBone.Name = skel.Bones[boneIndex].Name
+			ani.Bones = append(ani.Bones, aniBone)
+		}
+		wce.AniDefs = append(wce.AniDefs, ani)
+		return nil
+	}
+
+	bones := []*AniBone{}
+	for _, bone := range anim.Bones {
+		boneIndex := skel.Bone(bone.Name)
+		if boneIndex < 0 || skel.Bones[boneIndex].track == "" || len(bone.Frames) == 0 {
+			continue
+		}
+		bones = append(bones, bone)
+	}
+	wce.wldAnimationAdd(bones, func(bone *AniBone) (*TrackInstance, *TrackDef) {
+		boneTrack := skel.Bones[skel.Bone(bone.Name)].track
+		track := &TrackInstance{Tag: code + boneTrack, animation: code}
+		trackDef := &TrackDef{Tag: code + boneTrack + "DEF", animation: code}
+		rest, ok := wce.tagFirst("TRACKINSTANCE", boneTrack).(*TrackInstance)
+		if ok {
+			track.folders = append([]string{}, rest.folders...)
+			trackDef.folders = append([]string{}, rest.folders...)
+			if rest.SpriteTag != "" {
+				trackDef.Tag = code + rest.SpriteTag
+			}
+		}
+		return track, trackDef
+	})
+	return nil
+}
+
+// AnimationRemove removes the animation of skel with code, returning how many tracks or .ani
+// files were removed
+func (wce *Wce) AnimationRemove(skel *Skeleton, code string) int {
+	code = strings.ToUpper(code)
+	count := 0
+	if skel.IsEqg() {
+		isRemoved := make(map[*EqgAniDef]bool)
+		for _, ani := range wce.skeletonAnis(skel) {
+			isRemoved[ani] = aniCode(ani.Tag) == code
+		}
+		anis := []*EqgAniDef{}
+		for _, ani := range wce.AniDefs {
+			if isRemoved[ani] {
+				count++
+				continue
+			}
+			anis = append(anis, ani)
+		}
+		wce.AniDefs = anis
+		return count
+	}
+
+	remove := make(map[WldDefinitioner]bool)
+	for _, bone := range skel.Bones {
+		if bone.track == "" {
+			continue
+		}
+		for _, def := range wce.tagDefinitions("TRACKINSTANCE", code+bone.track) {
+			track := def.(*TrackInstance)
+			remove[track] = true
+			count++
+			// a trackdef shared by another track stays
+			trackDef, ok := wce.tagFirst("TRACKDEFINITION", track.SpriteTag).(*TrackDef)
+			if ok && len(wce.ReferencedBy(trackDef.Tag)) <= 1 {
+				remove[trackDef] = true
+			}
+		}
+	}
+	wce.removeDefinitions(remove)
+	return count
+}
+
+// skeletonAnis returns the .ani files of an mds skeleton, named as the mds with an animation code
+// after it, such as hum_c01. When the wce has a single mds, every .ani with an animation code is its
+func (wce *Wce) skeletonAnis(skel *Skeleton) []*EqgAniDef {
+	anis := []*EqgAniDef{}
+	for _, ani := range wce.AniDefs {
+		if aniCode(ani.Tag) == "" {
+			continue
+		}
+		if len(wce.MdsDefs) > 1 && !strings.EqualFold(ani.Tag[:len(ani.Tag)-4], skel.mds.Tag) {
+			continue
+		}
+		anis = append(anis, ani)
+	}
+	return anis
+}
+
+// aniCode returns the upper case animation code at the end of an .ani tag, such as C01 for
+// hum_c01, or an empty string if it has none
+func aniCode(tag string) string {
+	split := strings.LastIndex(tag, "_")
+	if split < 0 {
+		return ""
+	}
+	code := strings.ToUpper(tag[split+1:])
+	if len(code) != 3 || !regexAniPrefix.MatchString(code) {
+		return ""
+	}
+	return code
+}
+
+func aniBoneCopy(bone *AniBone) *AniBone {
+	dst := &AniBone{Name: bone.Name}
+	for _, frame := range bone.Frames {
+		copied := *frame
+		dst.Frames = append(dst.Frames, &copied)
+	}
+	return dst
+}
+
+// Skeletons returns every hierarchical sprite and mds
+func (wce *Wce) Skeletons() ([]*Skeleton, error) {
+	skels := []*Skeleton{}
+	for _, hiSprite := range wce.HierarchicalSpriteDefs {
+		skel, err := wce.skeletonFromHierarchicalSprite(hiSprite)
+		if err != nil {
+			return nil, fmt.Errorf("hierarchicalspritedef %s: %w", hiSprite.Tag, err)
+		}
+		skels = append(skels, skel)
+	}
+	for _, mds := range wce.MdsDefs {
+		skel, err := skeletonFromMds(mds)
+		if err != nil {
+			return nil, err
+		}
+		skels = append(skels, skel)
+	}
+	return skels, nil
+}
+
+// FrameCount returns the most keyframes any bone of the animation has
+func (e *Animation) FrameCount() int {
+	count := 0
+	for _, bone := range e.Bones {
+		count = max(count, len(bone.Frames))
+	}
+	return count
+}
+
+// Duration returns when the last keyframe of the animation is, in milliseconds
+func (e *Animation) Duration() uint32 {
+	duration := uint32(0)
+	for _, bone := range e.Bones {
+		if len(bone.Frames) > 0 {
+			duration = max(duration, bone.Frames[len(bone.Frames)-1].Milliseconds)
+		}
+	}
+	return duration
+}
+
+// AnimationRename changes the code of an animation of skel, such as C01 to C02, returning how many
+// tracks or .ani files were renamed. Nothing is changed if skel already has an animation with newCode
+func (wce *Wce) AnimationRename(skel *Skeleton, oldCode string, newCode string) (int, error) {
+	oldCode = strings.ToUpper(oldCode)
+	newCode = strings.ToUpper(newCode)
+	if len(newCode) != 3 || !regexAniPrefix.MatchString(newCode) {
+		return 0, fmt.Errorf("%s is not an animation code, such as C01", newCode)
+	}
+	if wce.Animation(skel, oldCode) == nil {
+		return 0, fmt.Errorf("animation %s not found", oldCode)
+	}
+	if wce.Animation(skel, newCode) != nil {
+		return 0, fmt.Errorf("animation %s already exists", newCode)
+	}
+
+	if skel.IsEqg() {
+		count := 0
+		for _, ani := range wce.skeletonAnis(skel) {
+			if aniCode(ani.Tag) == oldCode {
+				ani.Tag = ani.Tag[:len(ani.Tag)-3] + strings.ToLower(newCode)
+				count++
+			}
+		}
+		return count, nil
+	}
+
+	// tracks and trackdefs are named as those of the bind pose with the code before them
+	isBoneTrack := make(map[string]bool)
+	for _, bone := range skel.Bones {
+		if bone.track == "" {
+			continue
+		}
+		isBoneTrack[bone.track] = true
+		isBoneTrack[bone.track+"DEF"] = true
+		rest, ok := wce.tagFirst("TRACKINSTANCE", bone.track).(*TrackInstance)
+		if ok && rest.SpriteTag != "" {
+			isBoneTrack[rest.SpriteTag] = true
+		}
+	}
+	isRenamed := func(tag string) bool {
+		return len(tag) > 3 && tag[:3] == oldCode && isBoneTrack[tag[3:]]
+	}
+	tracks := []*TrackInstance{}
+	for _, track := range wce.TrackInstances {
+		if isRenamed(track.Tag) {
+			tracks = append(tracks, track)
+		}
+	}
+	trackDefs := []*TrackDef{}
+	for _, trackDef := range wce.TrackDefs {
+		if isRenamed(trackDef.Tag) {
+			trackDefs = append(trackDefs, trackDef)
+		}
+	}
+
+	count, err := wce.rename(func(tag string) string {
+		if isRenamed(tag) {
+			return newCode + tag[3:]
+		}
+		return tag
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, track := range tracks {
+		if track.animation != "" {
+			track.animation = newCode
+		}
+	}
+	for _, trackDef := range trackDefs {
+		if trackDef.animation != "" {
+			trackDef.animation = newCode
+		}
+	}
+	return count, nil
+}
+
+// AnimationSpeed retimes an animation of skel to play speed times as fast, returning how many
+// tracks or .ani files were changed. Tracks keep their frames and change how long each is shown
+func (wce *Wce) AnimationSpeed(skel *Skeleton, code string, speed float64) (int, error) {
+	if speed <= 0 {
+		return 0, fmt.Errorf("speed %g must be more than 0", speed)
+	}
+	code = strings.ToUpper(code)
+	retime := func(ms uint32) uint32 {
+		return uint32(math.Round(float64(ms) / speed))
+	}
+
+	count := 0
+	if skel.IsEqg() {
+		for _, ani := range wce.skeletonAnis(skel) {
+			if aniCode(ani.Tag) != code {
+				continue
+			}
+			for _, bone := range ani.Bones {
+				for _, frame := range bone.Frames {
+					frame.Milliseconds = retime(frame.Milliseconds)
+				}
+			}
+			count++
+		}
+	} else {
+		for _, bone := range skel.Bones {
+			if bone.track == "" {
+				continue
+			}
+			for _, def := range wce.tagDefinitions("TRACKINSTANCE", code+bone.track) {
+				track := def.(*TrackInstance)
+				sleep := uint32(100)
+				if track.Sleep.Valid && track.Sleep.Uint32 > 0 {
+					sleep = track.Sleep.Uint32
+				}
+				track.Sleep = NullUint32{Uint32: max(1, retime(sleep)), Valid: true}
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("animation %s not found", code)
+	}
+	return count, nil
+}
@@ -0,0 +1,81 @@
+package wce
+
+import (
+	"math"
+
+	"github.com/xackery/quail/helper"
+)
+
+// TrackTransform is the translation and rotation (x, y, z, w) of a bone for one frame of a track
+type TrackTransform struct {
+	Translation [3]float32
+	Rotation    [4]float32
+}
+
+// Transforms returns every frame of the track as a translation and a unit quaternion
+func (e *TrackDef) Transforms() []TrackTransform {
+	transforms := []TrackTransform{}
+	for _, frame := range e.Frames {
+		// the rotation denominator is the w of the quaternion
+		transforms = append(transforms, TrackTransform{
+			Translation: trackShift(frame.XYZ, frame.XYZScale),
+			Rotation:    helper.QuatNormalize([4]float32{float32(frame.Rotation[0]), float32(frame.Rotation[1]), float32(frame.Rotation[2]), float32(frame.RotScale)}),
+		})
+	}
+	for _, frame := range e.LegacyFrames {
+		transforms = append(transforms, TrackTransform{
+			Translation: trackShift(frame.XYZ, frame.XYZScale),
+			Rotation:    helper.QuatNormalize(frame.Rotation),
+		})
+	}
+	return transforms
+}
+
+func trackShift(xyz [3]int16, scale int16) [3]float32 {
+	if scale == 0 {
+		return [3]float32{}
+	}
+	return [3]float32{
+		float32(xyz[0]) / float32(scale),
+		float32(xyz[1]) / float32(scale),
+		float32(xyz[2]) / float32(scale),
+	}
+}
+
+// SetTransforms replaces the frames of the track with transforms. Translations are stored with the
+// finest shift denominator that fits, rotations with a denominator of 16384
+func (e *TrackDef) SetTransforms(transforms []TrackTransform) {
+	e.Frames = []*Frame{}
+	e.LegacyFrames = nil
+	for _, transform := range transforms {
+		scale := trackShiftScale(transform.Translation)
+		rotation := helper.QuatNormalize(transform.Rotation)
+		e.Frames = append(e.Frames, &Frame{
+			XYZScale: scale,
+			XYZ: [3]int16{
+				int16(math.Round(float64(transform.Translation[0] * float32(scale)))),
+				int16(math.Round(float64(transform.Translation[1] * float32(scale)))),
+				int16(math.Round(float64(transform.Translation[2] * float32(scale)))),
+			},
+			RotScale: int16(math.Round(float64(rotation[3] * 16384))),
+			Rotation: [3]int16{
+				int16(math.Round(float64(rotation[0] * 16384))),
+				int16(math.Round(float64(rotation[1] * 16384))),
+				int16(math.Round(float64(rotation[2] * 16384))),
+			},
+		})
+	}
+}
+
+// trackShiftScale returns the largest shift denominator, up to 256, that keeps translation in an int16
+func trackShiftScale(translation [3]float32) int16 {
+	largest := float32(0)
+	for _, v := range translation {
+		largest = max(largest, float32(math.Abs(float64(v))))
+	}
+	scale := int16(256)
+	for scale > 1 && largest*float32(scale) > math.MaxInt16 {
+		scale /= 2
+	}
+	return scale
+}
@@ -0,0 +1,431 @@
+package wce
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Position returns the source position def was parsed from, if it was read from ascii
+func (wce *Wce) Position(def interface{}) (Position, bool) {
+	pos, ok := wce.positions[def]
+	return pos, ok
+}
+
+func (wce *Wce) positionSet(def interface{}, pos Position) {
+	if wce.positions == nil {
+		wce.positions = make(map[interface{}]Position)
+	}
+	wce.positions[def] = pos
+}
+
+// Severity is how serious a validation finding is
+type Severity int
+
+const (
+	// SeverityError will fail or crash when converted or loaded by the client
+	SeverityError Severity = iota
+	// SeverityWarning is suspicious but may be intended, such as a reference to another archive
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Finding is a single problem found by Validate
+type Finding struct {
+	Position   Position
+	Definition string
+	Tag        string
+	Severity   Severity
+	Message    string
+}
+
+func (f *Finding) String() string {
+	name := f.Definition
+	if f.Tag != "" {
+		name += " " + f.Tag
+	}
+	msg := fmt.Sprintf("%s: %s: %s", f.Severity, name, f.Message)
+	if f.Position.File == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", f.Position, msg)
+}
+
+type validator struct {
+	wce      *Wce
+	findings []*Finding
+}
+
+func (v *validator) add(def interface{ Definition() string }, tag string, severity Severity, format string, args ...interface{}) {
+	pos, _ := v.wce.Position(def)
+	v.findings = append(v.findings, &Finding{
+		Position:   pos,
+		Definition: def.Definition(),
+		Tag:        tag,
+		Severity:   severity,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// ref reports an error when tag is set but does not resolve the same way ToRaw resolves it
+func (v *validator) ref(def interface{ Definition() string }, tag string, name string, refTag string) {
+	if refTag == "" {
+		return
+	}
+	if v.wce.ByTag(refTag) != nil {
+		return
+	}
+	v.add(def, tag, SeverityError, "%s %s not found", name, refTag)
+}
+
+func (v *validator) refIndex(def interface{ Definition() string }, tag string, name string, refTag string, refIndex int) {
+	if refTag == "" {
+		return
+	}
+	if v.wce.ByTagWithIndex(refTag, refIndex) != nil {
+		return
+	}
+	v.add(def, tag, SeverityError, "%s %s index %d not found", name, refTag, refIndex)
+}
+
+// Validate checks references, geometry and engine limits, returning every problem found.
+// Findings are sorted by source position when the wce was read from ascii
+func (wce *Wce) Validate() []*Finding {
+	v := &validator{wce: wce}
+
+	for _, e := range wce.DMSpriteDef2s {
+		v.dmSpriteDef2(e)
+	}
+	for _, e := range wce.DMSpriteDefs {
+		v.dmSpriteDef(e)
+	}
+	for _, e := range wce.MaterialPalettes {
+		for _, material := range e.Materials {
+			v.ref(e, e.Tag, "material", material)
+		}
+	}
+	for _, e := range wce.MaterialDefs {
+		v.refIndex(e, e.Tag, "simple sprite", e.SimpleSpriteTag, e.SimpleSpriteTagIndex)
+	}
+	for _, e := range wce.BlitSpriteDefs {
+		v.ref(e, e.Tag, "sprite", e.SpriteTag)
+	}
+	for _, e := range wce.ParticleCloudDefs {
+		v.ref(e, e.Tag, "blit sprite", e.BlitSpriteDefTag)
+	}
+	for _, e := range wce.Sprite2DDefs {
+		v.ref(e, e.Tag, "sphere list", e.SphereListTag)
+	}
+	for _, e := range wce.ActorDefs {
+		for i, action := range e.Actions {
+			for j, lod := range action.LevelOfDetails {
+				v.ref(e, e.Tag, fmt.Sprintf("action %d lod %d sprite", i, j), lod.SpriteTag)
+			}
+		}
+	}
+	for _, e := range wce.ActorInsts {
+		// actors commonly live in another archive, such as a zone's _obj.s3d
+		if e.DefinitionTag != "" && wce.ByTag(e.DefinitionTag) == nil {
+			v.add(e, e.Tag, SeverityWarning, "definition %s not found", e.DefinitionTag)
+		}
+		if e.DMRGBTrackTag.Valid {
+			v.ref(e, e.Tag, "dm rgb track", e.DMRGBTrackTag.String)
+		}
+	}
+	for _, e := range wce.PointLights {
+		v.ref(e, e.Tag, "light def", e.LightDefTag)
+	}
+	for _, e := range wce.AmbientLights {
+		v.ref(e, e.Tag, "light def", e.LightTag)
+		for _, region := range e.Regions {
+			if int(region) >= len(wce.Regions) {
+				v.add(e, e.Tag, SeverityError, "region %d out of range, %d regions", region, len(wce.Regions))
+			}
+		}
+	}
+	for _, e := range wce.TrackInstances {
+		v.refIndex(e, e.Tag, "track def", e.SpriteTag, e.SpriteTagIndex)
+	}
+	for _, e := range wce.HierarchicalSpriteDefs {
+		v.hierarchicalSpriteDef(e)
+	}
+	for _, e := range wce.Regions {
+		v.ref(e, e.Tag, "sprite", e.SpriteTag)
+		v.ref(e, e.Tag, "ambient light", e.AmbientLightTag)
+		if e.VisTree != nil {
+			for i, node := range e.VisTree.VisNodes {
+				if int(node.FrontTree) > len(e.VisTree.VisNodes) || int(node.BackTree) > len(e.VisTree.VisNodes) {
+					v.add(e, e.Tag, SeverityError, "vis node %d child out of range, %d nodes", i, len(e.VisTree.VisNodes))
+				}
+				if int(node.VisListIndex) > len(e.VisTree.VisLists) {
+					v.add(e, e.Tag, SeverityError, "vis node %d list %d out of range, %d lists", i, node.VisListIndex, len(e.VisTree.VisLists))
+				}
+			}
+			visible, err := e.VisibleRegions()
+			if err != nil {
+				v.add(e, e.Tag, SeverityError, "%s", err)
+			}
+			if len(visible) > 0 && visible[len(visible)-1] >= len(wce.Regions) {
+				v.add(e, e.Tag, SeverityError, "visible region %d out of range, %d regions", visible[len(visible)-1], len(wce.Regions))
+			}
+		}
+	}
+	for _, e := range wce.Zones {
+		for _, region := range e.Regions {
+			if int(region) >= len(wce.Regions) {
+				v.add(e, e.Tag, SeverityError, "region %d out of range, %d regions", region, len(wce.Regions))
+			}
+		}
+	}
+	for _, e := range wce.WorldTrees {
+		for i, node := range e.WorldNodes {
+			// children are 1 based, 0 means no child
+			if int(node.FrontTree) > len(e.WorldNodes) || int(node.BackTree) > len(e.WorldNodes) {
+				v.add(e, e.Tag, SeverityError, "world node %d child out of range, %d nodes", i, len(e.WorldNodes))
+			}
+			v.ref(e, e.Tag, fmt.Sprintf("world node %d region", i), node.WorldRegionTag)
+		}
+	}
+	for _, e := range wce.ModDefs {
+		v.eqgMesh(e, e.Tag, e.Vertices, len(e.Bones))
+		for i, face := range e.Faces {
+			v.eqgFace(e, e.Tag, i, face.Index, face.MaterialName, len(e.Vertices), e.Materials)
+		}
+	}
+	for _, e := range wce.TerDefs {
+		v.eqgMesh(e, e.Tag, e.Vertices, 0)
+		for i, face := range e.Faces {
+			v.eqgFace(e, e.Tag, i, face.Index, face.MaterialName, len(e.Vertices), e.Materials)
+		}
+	}
+	for _, e := range wce.MdsDefs {
+		for _, model := range e.Models {
+			v.eqgMesh(e, e.Tag, model.Vertices, len(e.Bones))
+			for i, face := range model.Faces {
+				v.eqgFace(e, e.Tag, i, face.Index, face.MaterialName, len(model.Vertices), e.Materials)
+			}
+		}
+	}
+	for _, e := range wce.ZonDefs {
+		models := make(map[string]bool)
+		for _, model := range e.Models {
+			models[model] = true
+		}
+		for _, instance := range e.Instances {
+			if !models[instance.ModelTag] {
+				v.add(e, e.Tag, SeverityError, "instance %s model %s not found", instance.InstanceTag, instance.ModelTag)
+			}
+		}
+	}
+
+	sort.SliceStable(v.findings, func(i, j int) bool {
+		a := v.findings[i].Position
+		b := v.findings[j].Position
+		if a.File != b.File {
+			// definitions that were not read from ascii go last
+			if a.File == "" || b.File == "" {
+				return b.File == ""
+			}
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return v.findings
+}
+
+func (v *validator) dmSpriteDef2(e *DMSpriteDef2) {
+	v.ref(e, e.Tag, "material palette", e.MaterialPaletteTag)
+	v.ref(e, e.Tag, "dm track", e.DmTrackTag)
+	if e.PolyhedronTag != "NEGATIVE_TWO" && e.PolyhedronTag != "SPECIAL_COLLISION" {
+		v.ref(e, e.Tag, "polyhedron", e.PolyhedronTag)
+	}
+
+	numVerts := len(e.Vertices)
+	if numVerts > math.MaxUint16 {
+		v.add(e, e.Tag, SeverityError, "%d vertices exceeds limit of %d", numVerts, math.MaxUint16)
+	}
+	if len(e.Faces) > math.MaxUint16 {
+		v.add(e, e.Tag, SeverityError, "%d faces exceeds limit of %d", len(e.Faces), math.MaxUint16)
+	}
+	v.arrayLen(e, e.Tag, "uvs", len(e.UVs), numVerts)
+	v.arrayLen(e, e.Tag, "vertex normals", len(e.VertexNormals), numVerts)
+	v.arrayLen(e, e.Tag, "vertex colors", len(e.VertexColors), numVerts)
+
+	// vertices are stored as int16 scaled by 1<<fpscale
+	scale := float64(int(1) << int(e.FPScale))
+	for i, vert := range e.Vertices {
+		if !fitsInt16(vert, scale) {
+			v.add(e, e.Tag, SeverityError, "vertex %d %0.2f %0.2f %0.2f exceeds fixed point range %0.2f of fpscale %d", i, vert[0], vert[1], vert[2], math.MaxInt16/scale, e.FPScale)
+			break
+		}
+	}
+	// normals are stored as int8 scaled by 128 and clamped to 127, which only loses a component outside -1 to 1
+	for i, normal := range e.VertexNormals {
+		if !isUnitRange(normal) {
+			v.add(e, e.Tag, SeverityWarning, "vertex normal %d %0.4f %0.4f %0.4f is outside -1 to 1", i, normal[0], normal[1], normal[2])
+			break
+		}
+	}
+
+	for i, face := range e.Faces {
+		for _, idx := range face.Triangle {
+			if int(idx) >= numVerts {
+				v.add(e, e.Tag, SeverityError, "face %d vertex %d out of range, %d vertices", i, idx, numVerts)
+				break
+			}
+		}
+	}
+
+	total := 0
+	for _, group := range e.SkinAssignmentGroups {
+		total += int(group[0])
+	}
+	if len(e.SkinAssignmentGroups) > 0 && total != numVerts {
+		v.add(e, e.Tag, SeverityError, "skin assignment groups total %d, %d vertices", total, numVerts)
+	}
+
+	total = 0
+	for _, group := range e.VertexMaterialGroups {
+		total += int(group[0])
+	}
+	if len(e.VertexMaterialGroups) > 0 && total != numVerts {
+		v.add(e, e.Tag, SeverityError, "vertex material groups total %d, %d vertices", total, numVerts)
+	}
+
+	numMaterials := -1
+	palette, ok := v.wce.ByTag(e.MaterialPaletteTag).(*MaterialPalette)
+	if ok {
+		numMaterials = len(palette.Materials)
+	}
+	total = 0
+	for i, group := range e.FaceMaterialGroups {
+		total += int(group[0])
+		if numMaterials >= 0 && int(group[1]) >= numMaterials {
+			v.add(e, e.Tag, SeverityError, "face material group %d material %d out of range, %d materials", i, group[1], numMaterials)
+		}
+	}
+	if len(e.FaceMaterialGroups) > 0 && total != len(e.Faces) {
+		v.add(e, e.Tag, SeverityError, "face material groups total %d, %d faces", total, len(e.Faces))
+	}
+}
+
+func (v *validator) dmSpriteDef(e *DMSpriteDef) {
+	v.ref(e, e.Tag, "material palette", e.MaterialPaletteTag)
+
+	numVerts := len(e.Vertices)
+	if numVerts > math.MaxUint16 {
+		v.add(e, e.Tag, SeverityError, "%d vertices exceeds limit of %d", numVerts, math.MaxUint16)
+	}
+	v.arrayLen(e, e.Tag, "tex coords", len(e.TexCoords), numVerts)
+	v.arrayLen(e, e.Tag, "normals", len(e.Normals), numVerts)
+	v.arrayLen(e, e.Tag, "colors", len(e.Colors), numVerts)
+
+	for i, face := range e.Faces {
+		for _, idx := range face.VertexIndexes {
+			if int(idx) >= numVerts {
+				v.add(e, e.Tag, SeverityError, "face %d vertex %d out of range, %d vertices", i, idx, numVerts)
+				break
+			}
+		}
+	}
+
+	total := 0
+	for _, group := range e.SkinAssignmentGroups {
+		total += int(group[0])
+	}
+	if len(e.SkinAssignmentGroups) > 0 && total != numVerts {
+		v.add(e, e.Tag, SeverityError, "skin assignment groups total %d, %d vertices", total, numVerts)
+	}
+}
+
+func (v *validator) hierarchicalSpriteDef(e *HierarchicalSpriteDef) {
+	for i, dag := range e.Dags {
+		if dag.Track == "" {
+			v.add(e, e.Tag, SeverityError, "dag %d %s has no track", i, dag.Tag)
+		}
+		v.refIndex(e, e.Tag, fmt.Sprintf("dag %d track", i), dag.Track, dag.TrackIndex)
+		v.refIndex(e, e.Tag, fmt.Sprintf("dag %d sprite", i), dag.SpriteTag, dag.SpriteTagIndex)
+		for _, sub := range dag.SubDags {
+			if int(sub) >= len(e.Dags) {
+				v.add(e, e.Tag, SeverityError, "dag %d sub dag %d out of range, %d dags", i, sub, len(e.Dags))
+			}
+		}
+	}
+	for i, skin := range e.AttachedSkins {
+		v.refIndex(e, e.Tag, fmt.Sprintf("attached skin %d", i), skin.DMSpriteTag, skin.DMSpriteTagIndex)
+		if int(skin.LinkSkinUpdatesToDagIndex) >= len(e.Dags) {
+			v.add(e, e.Tag, SeverityError, "attached skin %d dag %d out of range, %d dags", i, skin.LinkSkinUpdatesToDagIndex, len(e.Dags))
+		}
+	}
+	if e.PolyhedronTag != "SPECIAL_COLLISION" {
+		v.ref(e, e.Tag, "polyhedron", e.PolyhedronTag)
+	}
+}
+
+func (v *validator) eqgMesh(def interface{ Definition() string }, tag string, vertices []*ModVertex, numBones int) {
+	if numBones == 0 {
+		return
+	}
+	for i, vert := range vertices {
+		for _, weight := range vert.Weights {
+			if weight.BoneIndex < 0 || int(weight.BoneIndex) >= numBones {
+				v.add(def, tag, SeverityError, "vertex %d bone %d out of range, %d bones", i, weight.BoneIndex, numBones)
+				return
+			}
+		}
+	}
+}
+
+func (v *validator) eqgFace(def interface{ Definition() string }, tag string, faceIdx int, index [3]uint32, materialName string, numVerts int, materials []*EQMaterialDef) {
+	for _, idx := range index {
+		if int(idx) >= numVerts {
+			v.add(def, tag, SeverityError, "face %d vertex %d out of range, %d vertices", faceIdx, idx, numVerts)
+			break
+		}
+	}
+	if materialName == "" {
+		return
+	}
+	for _, material := range materials {
+		if material.Tag == materialName {
+			return
+		}
+	}
+	v.add(def, tag, SeverityError, "face %d material %s not found", faceIdx, materialName)
+}
+
+// arrayLen reports per vertex arrays that are neither empty nor one entry per vertex
+func (v *validator) arrayLen(def interface{ Definition() string }, tag string, name string, count int, numVerts int) {
+	if count == 0 || count == numVerts {
+		return
+	}
+	v.add(def, tag, SeverityError, "%d %s, %d vertices", count, name, numVerts)
+}
+
+func fitsInt16(vert [3]float32, scale float64) bool {
+	for _, val := range vert {
+		scaled := float64(val) * scale
+		if scaled > math.MaxInt16 || scaled < math.MinInt16 {
+			return false
+		}
+	}
+	return true
+}
+
+func isUnitRange(vert [3]float32) bool {
+	for _, val := range vert {
+		if val > 1 || val < -1 {
+			return false
+		}
+	}
+	return true
+}
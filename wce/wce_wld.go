@@ -3,6 +3,7 @@ package wce
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -872,10 +873,11 @@ func (e *DMSpriteDef2) ToRaw(wce *Wce, rawWld *raw.Wld) (int32, error) {
 	}
 
 	for _, normal := range e.VertexNormals {
+		// a unit normal of 1 is one past what an int8 holds
 		dmSpriteDef.VertexNormals = append(dmSpriteDef.VertexNormals, [3]int8{
-			int8(normal[0] * 128),
-			int8(normal[1] * 128),
-			int8(normal[2] * 128),
+			int8(min(max(normal[0]*128, -128), 127)),
+			int8(min(max(normal[1]*128, -128), 127)),
+			int8(min(max(normal[2]*128, -128), 127)),
 		})
 	}
 
@@ -3545,6 +3547,37 @@ func (e *ActorInst) FromRaw(wce *Wce, rawWld *raw.Wld, frag *rawfrag.WldFragActo
 	return nil
 }
 
+// Transform returns where an actor instance is placed, as a translation, a rotation about x, y and
+// z in radians and a scale of 1 when none is set. wld rotations are stored z, y, x with 512 units
+// per full turn
+func (e *ActorInst) Transform() ([3]float32, [3]float32, float32) {
+	loc := e.Location.Float32Slice6
+	rotation := [3]float32{loc[5], loc[4], loc[3]}
+	for i := range rotation {
+		rotation[i] = rotation[i] / 512 * 2 * math.Pi
+	}
+	scale := float32(1)
+	if e.Scale.Valid && e.Scale.Float32 != 0 {
+		scale = e.Scale.Float32
+	}
+	return [3]float32{loc[0], loc[1], loc[2]}, rotation, scale
+}
+
+// SetTransform places an actor instance at translation, turned by rotation about x, y and z in
+// radians. A scale of 0 leaves the scale unset
+func (e *ActorInst) SetTransform(translation [3]float32, rotation [3]float32, scale float32) {
+	e.Location = NullFloat32Slice6{Valid: true, Float32Slice6: [6]float32{
+		translation[0], translation[1], translation[2],
+		rotation[2] / (2 * math.Pi) * 512,
+		rotation[1] / (2 * math.Pi) * 512,
+		rotation[0] / (2 * math.Pi) * 512,
+	}}
+	e.Scale = NullFloat32{}
+	if scale != 0 {
+		e.Scale = NullFloat32{Float32: scale, Valid: true}
+	}
+}
+
 // LightDef is a declaration of LIGHTDEF
 type LightDef struct {
 	folders      []string // when writing, this is the folder the file is in
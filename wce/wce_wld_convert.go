@@ -0,0 +1,986 @@
+package wce
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/quail/anim"
+	"github.com/xackery/quail/helper"
+)
+
+const (
+	// wldRegionSize is the width of the square cells a ter is split into region meshes by
+	wldRegionSize = 512
+	// wldTransparentTag is the material of eqg faces that are not drawn
+	wldTransparentTag = "TRANSPARENT_MDF"
+)
+
+var regexChrMaterial = regexp.MustCompile(`^[A-Z]{3}(CH|FA|FT|HE|HN|LG|TA|UA)\d{4}_MDF$`)
+
+// convertEQGToWld returns a copy of wce with its eqg definitions converted to wld ones.
+// Mds and mod with bones become a hierarchical sprite with a skin for each model and a dag for
+// each pts point, other mod become a dmsprite, a ter becomes region meshes and the world tree
+// finding them, ani become animation tracks, lay become texture variations and the zon becomes
+// actor instances and point lights. Meshes are split into pieces a dmsprite can index. Particle
+// renders have no wld equivalent. wce is left as is, so it can be written again
+func (wce *Wce) convertEQGToWld() (*Wce, error) {
+	if len(wce.MdsDefs) == 0 && len(wce.ModDefs) == 0 && len(wce.TerDefs) == 0 && len(wce.AniDefs) == 0 && len(wce.LayDefs) == 0 && len(wce.ZonDefs) == 0 && len(wce.PtsDefs) == 0 && len(wce.PrtDefs) == 0 {
+		return wce, nil
+	}
+
+	dst := *wce
+	dst.lookup = nil
+	dst.ActorDefs = append([]*ActorDef{}, wce.ActorDefs...)
+	dst.ActorInsts = append([]*ActorInst{}, wce.ActorInsts...)
+	dst.DMSpriteDef2s = append([]*DMSpriteDef2{}, wce.DMSpriteDef2s...)
+	dst.HierarchicalSpriteDefs = append([]*HierarchicalSpriteDef{}, wce.HierarchicalSpriteDefs...)
+	dst.LightDefs = append([]*LightDef{}, wce.LightDefs...)
+	dst.MaterialDefs = append([]*MaterialDef{}, wce.MaterialDefs...)
+	dst.MaterialPalettes = append([]*MaterialPalette{}, wce.MaterialPalettes...)
+	dst.PointLights = append([]*PointLight{}, wce.PointLights...)
+	dst.Regions = append([]*Region{}, wce.Regions...)
+	dst.SimpleSpriteDefs = append([]*SimpleSpriteDef{}, wce.SimpleSpriteDefs...)
+	dst.TrackDefs = append([]*TrackDef{}, wce.TrackDefs...)
+	dst.TrackInstances = append([]*TrackInstance{}, wce.TrackInstances...)
+	dst.WorldTrees = append([]*WorldTree{}, wce.WorldTrees...)
+	worldDef := &WorldDef{folders: []string{"world"}}
+	if wce.WorldDef != nil {
+		copied := *wce.WorldDef
+		worldDef = &copied
+	}
+	dst.WorldDef = worldDef
+
+	for _, mds := range wce.MdsDefs {
+		err := dst.wldFromEqgSkeleton(mds.Tag, mds.Materials, mds.Bones, mds.Models)
+		if err != nil {
+			return nil, fmt.Errorf("mds %s: %w", mds.Tag, err)
+		}
+	}
+
+	for _, mod := range wce.ModDefs {
+		if len(mod.Bones) > 0 {
+			bones := []*MdsBone{}
+			for _, bone := range mod.Bones {
+				bones = append(bones, &MdsBone{
+					Name:          bone.Name,
+					Next:          bone.Next,
+					ChildrenCount: bone.ChildrenCount,
+					ChildIndex:    bone.ChildIndex,
+					Pivot:         bone.Pivot,
+					Quaternion:    bone.Quaternion,
+					Scale:         bone.Scale,
+				})
+			}
+			model := &EqgMdsModel{Name: mod.Tag, Vertices: mod.Vertices, MainPiece: 1}
+			for _, face := range mod.Faces {
+				model.Faces = append(model.Faces, &MdsFace{
+					Index:        face.Index,
+					MaterialName: face.MaterialName,
+					Passable:     face.Passable,
+					Transparent:  face.Transparent,
+				})
+			}
+			err := dst.wldFromEqgSkeleton(mod.Tag, mod.Materials, bones, []*EqgMdsModel{model})
+			if err != nil {
+				return nil, fmt.Errorf("mod %s: %w", mod.Tag, err)
+			}
+			continue
+		}
+
+		err := dst.wldFromEqgMod(mod)
+		if err != nil {
+			return nil, fmt.Errorf("mod %s: %w", mod.Tag, err)
+		}
+	}
+
+	for _, pts := range wce.PtsDefs {
+		err := dst.wldFromEqgPts(pts)
+		if err != nil {
+			return nil, fmt.Errorf("pts %s: %w", pts.Tag, err)
+		}
+	}
+
+	for _, ter := range wce.TerDefs {
+		err := dst.wldFromEqgTer(ter)
+		if err != nil {
+			return nil, fmt.Errorf("ter %s: %w", ter.Tag, err)
+		}
+	}
+
+	for _, ani := range wce.AniDefs {
+		err := dst.wldFromEqgAni(ani)
+		if err != nil {
+			return nil, fmt.Errorf("ani %s: %w", ani.Tag, err)
+		}
+	}
+
+	for i, lay := range wce.LayDefs {
+		err := dst.wldFromEqgLay(lay, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("lay %s: %w", lay.Tag, err)
+		}
+	}
+
+	for _, zon := range wce.ZonDefs {
+		dst.wldFromEqgZon(zon)
+	}
+
+	return &dst, nil
+}
+
+// wldFace is an eqg face with the wld material it is drawn with
+type wldFace struct {
+	index    [3]uint32
+	material string
+	passable int
+}
+
+// wldFaces returns the faces of an eqg mesh with their materials converted
+func (dst *Wce) wldFaces(materials []*EQMaterialDef, faces []*MdsFace) ([]wldFace, error) {
+	tags := make(map[string]string)
+	for _, mat := range materials {
+		tags[mat.Tag] = dst.wldMaterial(mat)
+	}
+
+	out := []wldFace{}
+	for i, face := range faces {
+		tag, ok := tags[face.MaterialName]
+		if !ok && face.MaterialName != "" {
+			return nil, fmt.Errorf("face %d: material %s not found", i, face.MaterialName)
+		}
+		// faces without a material are collision only
+		if face.Transparent != 0 || face.MaterialName == "" {
+			tag = dst.wldTransparentMaterial()
+		}
+		out = append(out, wldFace{index: face.Index, material: tag, passable: face.Passable})
+	}
+	return out, nil
+}
+
+// modFaces returns mod faces as mds faces
+func modFaces(faces []*ModFace) []*MdsFace {
+	out := []*MdsFace{}
+	for _, face := range faces {
+		out = append(out, &MdsFace{
+			Index:        face.Index,
+			MaterialName: face.MaterialName,
+			Passable:     face.Passable,
+			Transparent:  face.Transparent,
+		})
+	}
+	return out
+}
+
+// wldPalette adds a material palette of every material faces use, returning its materials
+func (dst *Wce) wldPalette(tag string, faces []wldFace) []string {
+	palette := &MaterialPalette{Tag: tag}
+	isAdded := make(map[string]bool)
+	for _, face := range faces {
+		if isAdded[face.material] {
+			continue
+		}
+		isAdded[face.material] = true
+		palette.Materials = append(palette.Materials, face.material)
+	}
+	dst.MaterialPalettes = append(dst.MaterialPalettes, palette)
+	return palette.Materials
+}
+
+// wldMaterial adds the wld material of an eqg material if it is missing, returning its tag.
+// The shader map picks the render method from the shader and the properties the material sets
+func (dst *Wce) wldMaterial(mat *EQMaterialDef) string {
+	tag := wldMaterialTag(mat.Tag)
+	if dst.tagFirst("MATERIALDEFINITION", tag) != nil {
+		return tag
+	}
+
+	textures := append([]string{}, mat.AnimationTextures...)
+	properties := []string{}
+	for _, property := range mat.Properties {
+		if property.Value == "" {
+			continue
+		}
+		properties = append(properties, property.Name)
+		if len(mat.AnimationTextures) == 0 && property.Name == "e_TextureDiffuse0" {
+			textures = append(textures, property.Value)
+		}
+	}
+	if len(mat.AnimationTextures) > 0 {
+		properties = append(properties, "e_TextureDiffuse0")
+	}
+
+	matDef := &MaterialDef{
+		Tag:           tag,
+		RenderMethod:  helper.ShaderRenderMethod(mat.ShaderTag, properties...),
+		RGBPen:        [4]uint8{178, 178, 178, 0},
+		Brightness:    0,
+		ScaledAmbient: 0.75,
+	}
+	if len(textures) == 0 {
+		dst.MaterialDefs = append(dst.MaterialDefs, matDef)
+		return tag
+	}
+
+	sprite := &SimpleSpriteDef{Tag: strings.TrimSuffix(tag, "_MDF") + "_SPRITE"}
+	for _, texture := range textures {
+		sprite.SimpleSpriteFrames = append(sprite.SimpleSpriteFrames, wldSpriteFrame(texture))
+	}
+	if len(textures) > 1 {
+		sprite.Animated = NullUint32{Uint32: 1, Valid: true}
+		sprite.Sleep = NullUint32{Uint32: mat.AnimationSleep, Valid: true}
+	}
+	matDef.SimpleSpriteTag = sprite.Tag
+	if dst.tagFirst("SIMPLESPRITEDEF", sprite.Tag) == nil {
+		dst.SimpleSpriteDefs = append(dst.SimpleSpriteDefs, sprite)
+	}
+	dst.MaterialDefs = append(dst.MaterialDefs, matDef)
+	return tag
+}
+
+// wldTransparentMaterial adds the material of faces that are not drawn if it is missing
+func (dst *Wce) wldTransparentMaterial() string {
+	if dst.tagFirst("MATERIALDEFINITION", wldTransparentTag) == nil {
+		dst.MaterialDefs = append(dst.MaterialDefs, &MaterialDef{
+			Tag:          wldTransparentTag,
+			RenderMethod: "TRANSPARENT",
+		})
+	}
+	return wldTransparentTag
+}
+
+// wldSpriteFrame returns the frame of a simple sprite showing texture
+func wldSpriteFrame(texture string) SimpleSpriteFrame {
+	texture = strings.ToUpper(texture)
+	return SimpleSpriteFrame{
+		TextureTag:   strings.TrimSuffix(texture, filepath.Ext(texture)),
+		TextureFiles: []string{texture},
+	}
+}
+
+// wldMaterialTag returns the wld tag of an eqg material
+func wldMaterialTag(name string) string {
+	tag := strings.ToUpper(name)
+	if !strings.HasSuffix(tag, "_MDF") {
+		tag += "_MDF"
+	}
+	return tag
+}
+
+// wldBoneTag returns the dag and track name of a bone, prefixed with the model code so
+// animation tracks of different models do not collide
+func wldBoneTag(code string, name string) string {
+	tag := strings.TrimSuffix(strings.ToUpper(name), "_DAG")
+	if strings.HasPrefix(tag, code) {
+		return tag
+	}
+	return code + tag
+}
+
+// wldActorDef adds an actor showing spriteTag
+func (dst *Wce) wldActorDef(code string, spriteTag string) {
+	dst.ActorDefs = append(dst.ActorDefs, &ActorDef{
+		Tag:      code + "_ACTORDEF",
+		Callback: "SPRITECALLBACK",
+		Actions:  []ActorAction{{LevelOfDetails: []ActorLevelOfDetail{{SpriteTag: spriteTag, MinDistance: 1e30}}}},
+	})
+}
+
+// wldFromEqgMod converts a mod without bones to a dmsprite, or a hierarchical sprite with one dag
+// when it needs more than one piece
+func (dst *Wce) wldFromEqgMod(mod *EqgModDef) error {
+	code := strings.ToUpper(mod.Tag)
+	faces, err := dst.wldFaces(mod.Materials, modFaces(mod.Faces))
+	if err != nil {
+		return err
+	}
+	paletteTag := code + "_MP"
+	palette := dst.wldPalette(paletteTag, faces)
+	pieces, err := wldPieces(mod.Vertices, faces, palette, nil, nil)
+	if err != nil {
+		return err
+	}
+	if len(pieces) == 1 {
+		pieces[0].Tag = code + "_DMSPRITEDEF"
+		pieces[0].MaterialPaletteTag = paletteTag
+		dst.DMSpriteDef2s = append(dst.DMSpriteDef2s, pieces[0])
+		dst.wldActorDef(code, pieces[0].Tag)
+		return nil
+	}
+
+	hiSprite := &HierarchicalSpriteDef{
+		Tag:               code + "_HS_DEF",
+		Dags:              []Dag{{Tag: code + "_DAG", Track: code + "_TRACK"}},
+		HexTwoHundredFlag: 1,
+	}
+	dst.wldBindTrack(code, TrackTransform{Rotation: helper.QuatIdentity})
+	for i, piece := range pieces {
+		piece.Tag = fmt.Sprintf("%s_%d_DMSPRITEDEF", code, i+1)
+		piece.MaterialPaletteTag = paletteTag
+		piece.SkinAssignmentGroups = [][2]int16{{int16(len(piece.Vertices)), 0}}
+		dst.DMSpriteDef2s = append(dst.DMSpriteDef2s, piece)
+		hiSprite.AttachedSkins = append(hiSprite.AttachedSkins, AttachedSkin{DMSpriteTag: piece.Tag})
+	}
+	dst.HierarchicalSpriteDefs = append(dst.HierarchicalSpriteDefs, hiSprite)
+	dst.wldActorDef(code, hiSprite.Tag)
+	return nil
+}
+
+// wldBindTrack adds the track and single frame trackdef of a dag at rest
+func (dst *Wce) wldBindTrack(boneTag string, transform TrackTransform) {
+	trackDef := &TrackDef{Tag: boneTag + "_TRACKDEF"}
+	trackDef.SetTransforms([]TrackTransform{transform})
+	dst.TrackDefs = append(dst.TrackDefs, trackDef)
+	dst.TrackInstances = append(dst.TrackInstances, &TrackInstance{Tag: boneTag + "_TRACK", SpriteTag: trackDef.Tag})
+}
+
+// wldFromEqgSkeleton converts bones to the dags of a hierarchical sprite and each model to skins
+// attached to it. A vertex follows the bone it is weighted to the most
+func (dst *Wce) wldFromEqgSkeleton(tag string, materials []*EQMaterialDef, bones []*MdsBone, models []*EqgMdsModel) error {
+	code := strings.ToUpper(tag)
+	parents, children, err := mdsBoneHierarchy(bones)
+	if err != nil {
+		return err
+	}
+	poses, err := eqgBonePoses(bones, parents)
+	if err != nil {
+		return err
+	}
+
+	// 0x200 marks a sprite with attached skins
+	hiSprite := &HierarchicalSpriteDef{Tag: code + "_HS_DEF", HexTwoHundredFlag: 1}
+	for i, bone := range bones {
+		boneTag := wldBoneTag(code, bone.Name)
+		dag := Dag{Tag: boneTag + "_DAG", Track: boneTag + "_TRACK"}
+		for _, child := range children[i] {
+			dag.SubDags = append(dag.SubDags, uint32(child))
+		}
+		hiSprite.Dags = append(hiSprite.Dags, dag)
+		dst.wldBindTrack(boneTag, TrackTransform{Translation: bone.Pivot, Rotation: bone.Quaternion})
+	}
+	if len(hiSprite.Dags) == 0 {
+		// a skin needs a dag to follow
+		hiSprite.Dags = append(hiSprite.Dags, Dag{Tag: code + "_DAG", Track: code + "_TRACK"})
+		dst.wldBindTrack(code, TrackTransform{Rotation: helper.QuatIdentity})
+		poses = []eqgBonePose{{rotation: helper.QuatIdentity}}
+	}
+
+	radius := float32(0)
+	for _, model := range models {
+		name := strings.ToUpper(model.Name)
+		if name == "" {
+			name = code
+		}
+		faces, err := dst.wldFaces(materials, model.Faces)
+		if err != nil {
+			return fmt.Errorf("model %s: %w", model.Name, err)
+		}
+		paletteTag := name + "_MP"
+		palette := dst.wldPalette(paletteTag, faces)
+
+		vertexBones := make([]int, len(model.Vertices))
+		for i, vert := range model.Vertices {
+			weight := float32(0)
+			for _, boneWeight := range vert.Weights {
+				if boneWeight.Value > weight && int(boneWeight.BoneIndex) < len(poses) && boneWeight.BoneIndex >= 0 {
+					weight = boneWeight.Value
+					vertexBones[i] = int(boneWeight.BoneIndex)
+				}
+			}
+			radius = max(radius, wldLength(vert.Position))
+		}
+
+		pieces, err := wldPieces(model.Vertices, faces, palette, vertexBones, poses)
+		if err != nil {
+			return fmt.Errorf("model %s: %w", model.Name, err)
+		}
+		for i, piece := range pieces {
+			piece.Tag = name + "_DMSPRITEDEF"
+			if len(pieces) > 1 {
+				piece.Tag = fmt.Sprintf("%s_%d_DMSPRITEDEF", name, i+1)
+			}
+			piece.MaterialPaletteTag = paletteTag
+			dst.DMSpriteDef2s = append(dst.DMSpriteDef2s, piece)
+			hiSprite.AttachedSkins = append(hiSprite.AttachedSkins, AttachedSkin{DMSpriteTag: piece.Tag})
+		}
+	}
+	hiSprite.BoundingRadius = NullFloat32{Float32: radius, Valid: true}
+	dst.HierarchicalSpriteDefs = append(dst.HierarchicalSpriteDefs, hiSprite)
+	dst.wldActorDef(code, hiSprite.Tag)
+	return nil
+}
+
+// wldFromEqgPts adds the points of a pts as dags of the hierarchical sprite converted from the mds
+// or mod with its tag. Dags do not scale, so the scale of a point is dropped, and models without
+// bones have no dags for points to follow
+func (dst *Wce) wldFromEqgPts(pts *EqgParticlePointDef) error {
+	code := strings.ToUpper(pts.Tag)
+	if dst.tagFirst("HIERARCHICALSPRITEDEF", code+"_HS_DEF") == nil {
+		return nil
+	}
+	for _, entry := range pts.Points {
+		// a new point adds a dag, so the skeleton is read again for each
+		skel, err := dst.Skeleton(code + "_HS_DEF")
+		if err != nil {
+			return err
+		}
+		err = dst.AttachPointSet(skel, &AttachPoint{
+			Name:        entry.Name,
+			Bone:        skeletonBoneName(code, wldBoneTag(code, entry.BoneName)+"_DAG"),
+			Translation: entry.Translation,
+			Rotation:    helper.QuatFromEuler(entry.Rotation),
+		})
+		if err != nil {
+			return fmt.Errorf("point %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// mdsBoneHierarchy returns the parent of each bone, or -1 for a root, and the children of each
+// bone, following the first child and next sibling links
+func mdsBoneHierarchy(bones []*MdsBone) ([]int, [][]int, error) {
+	parents := make([]int, len(bones))
+	for i := range parents {
+		parents[i] = -1
+	}
+	children := make([][]int, len(bones))
+	for i, bone := range bones {
+		child := int(bone.ChildIndex)
+		for child >= 0 {
+			if child >= len(bones) {
+				return nil, nil, fmt.Errorf("bone %s: child %d out of range", bone.Name, child)
+			}
+			if parents[child] != -1 || child == i {
+				return nil, nil, fmt.Errorf("bone %s: child %d has more than one parent", bone.Name, child)
+			}
+			parents[child] = i
+			children[i] = append(children[i], child)
+			child = int(bones[child].Next)
+		}
+	}
+	return parents, children, nil
+}
+
+// wldFromEqgTer splits a ter into a region mesh for each cell of a grid, by the center of each face.
+// Cells that need more than one mesh are split in four until each fits in one. A world tree of
+// the cell edges finds the region of a point, and each region sees every other until a pvs is built
+func (dst *Wce) wldFromEqgTer(ter *EqgTerDef) error {
+	if len(dst.WorldTrees) > 0 {
+		return fmt.Errorf("zone already has a world tree")
+	}
+	dst.WorldDef.Zone = 1
+	faces, err := dst.wldFaces(ter.Materials, modFaces(ter.Faces))
+	if err != nil {
+		return err
+	}
+	paletteTag := strings.ToUpper(ter.Tag) + "_MP"
+	palette := dst.wldPalette(paletteTag, faces)
+
+	type cellKey struct{ x, y int }
+	keys := []cellKey{}
+	cellFaces := make(map[cellKey][]int)
+	centers := make([][2]float32, len(faces))
+	for i, face := range faces {
+		center := [3]float32{}
+		for _, index := range face.index {
+			if int(index) >= len(ter.Vertices) {
+				return fmt.Errorf("face %d: vertex %d out of range", i, index)
+			}
+			for j := 0; j < 3; j++ {
+				center[j] += ter.Vertices[index].Position[j] / 3
+			}
+		}
+		centers[i] = [2]float32{center[0], center[1]}
+		key := cellKey{x: int(math.Floor(float64(center[0] / wldRegionSize))), y: int(math.Floor(float64(center[1] / wldRegionSize)))}
+		if cellFaces[key] == nil {
+			keys = append(keys, key)
+		}
+		cellFaces[key] = append(cellFaces[key], i)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].y != keys[j].y {
+			return keys[i].y < keys[j].y
+		}
+		return keys[i].x < keys[j].x
+	})
+
+	cells := []*wldCell{}
+	var split func(cell *wldCell) error
+	split = func(cell *wldCell) error {
+		pieceFaces := make([]wldFace, len(cell.faces))
+		for i, face := range cell.faces {
+			pieceFaces[i] = faces[face]
+		}
+		pieces, err := wldPieces(ter.Vertices, pieceFaces, palette, nil, nil)
+		if err != nil {
+			return err
+		}
+		if len(pieces) == 1 {
+			cell.sprite = pieces[0]
+			cells = append(cells, cell)
+			return nil
+		}
+		if cell.size <= 1 {
+			return fmt.Errorf("cell at %0.0f %0.0f has too many vertices for a region", cell.min[0], cell.min[1])
+		}
+		half := cell.size / 2
+		quads := [4]*wldCell{}
+		for i := range quads {
+			quads[i] = &wldCell{min: [2]float32{cell.min[0] + half*float32(i%2), cell.min[1] + half*float32(i/2)}, size: half}
+		}
+		for _, face := range cell.faces {
+			i := 0
+			if centers[face][0] >= cell.min[0]+half {
+				i++
+			}
+			if centers[face][1] >= cell.min[1]+half {
+				i += 2
+			}
+			quads[i].faces = append(quads[i].faces, face)
+		}
+		for _, quad := range quads {
+			if len(quad.faces) == 0 {
+				continue
+			}
+			err = split(quad)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, key := range keys {
+		err = split(&wldCell{min: [2]float32{float32(key.x) * wldRegionSize, float32(key.y) * wldRegionSize}, size: wldRegionSize, faces: cellFaces[key]})
+		if err != nil {
+			return err
+		}
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+
+	first := len(dst.Regions)
+	visible := []int{}
+	for i, cell := range cells {
+		number := first + i + 1
+		cell.sprite.Tag = fmt.Sprintf("R%d_DMSPRITEDEF", number)
+		cell.sprite.MaterialPaletteTag = paletteTag
+		dst.DMSpriteDef2s = append(dst.DMSpriteDef2s, cell.sprite)
+		cell.region = fmt.Sprintf("R%06d", number)
+		center := cell.sprite.CenterOffset
+		dst.Regions = append(dst.Regions, &Region{
+			Tag:       cell.region,
+			SpriteTag: cell.sprite.Tag,
+			Sphere:    [4]float32{center[0], center[1], center[2], cell.sprite.BoundingRadius},
+		})
+		visible = append(visible, first+i)
+	}
+	for _, region := range dst.Regions[first:] {
+		err = region.SetVisibleRegions(visible)
+		if err != nil {
+			return fmt.Errorf("region %s: %w", region.Tag, err)
+		}
+	}
+
+	tree := &WorldTree{}
+	_, err = wldWorldNodes(tree, cells)
+	if err != nil {
+		return err
+	}
+	dst.WorldTrees = append(dst.WorldTrees, tree)
+	return nil
+}
+
+// wldCell is a square of a ter that becomes a region, holding the faces centered in it
+type wldCell struct {
+	min    [2]float32
+	size   float32
+	faces  []int
+	sprite *DMSpriteDef2
+	region string
+}
+
+// wldWorldNodes adds the nodes of a bsp finding which cell a point is in to tree, splitting on
+// cell edges, and returns the node number of its root. Points outside every cell find the nearest
+// cell the tree was split from
+func wldWorldNodes(tree *WorldTree, cells []*wldCell) (uint32, error) {
+	node := &WorldNode{}
+	tree.WorldNodes = append(tree.WorldNodes, node)
+	number := uint32(len(tree.WorldNodes))
+	if len(cells) == 1 {
+		node.WorldRegionTag = cells[0].region
+		return number, nil
+	}
+
+	// split on the edge closest to the middle that no cell crosses
+	var sides []*wldCell
+	axis, split, best := 0, 0, len(cells)
+	for i := 0; i < 2; i++ {
+		sorted := append([]*wldCell{}, cells...)
+		sort.SliceStable(sorted, func(a, b int) bool {
+			return sorted[a].min[i] < sorted[b].min[i]
+		})
+		end := sorted[0].min[i] + sorted[0].size
+		for j := 1; j < len(sorted); j++ {
+			balance := len(sorted) - 2*j
+			if balance < 0 {
+				balance = -balance
+			}
+			if end <= sorted[j].min[i] && balance < best {
+				sides, axis, split, best = sorted, i, j, balance
+			}
+			end = max(end, sorted[j].min[i]+sorted[j].size)
+		}
+	}
+	if sides == nil {
+		return 0, fmt.Errorf("cells overlap")
+	}
+
+	node.Normals[axis] = 1
+	node.Normals[3] = -sides[split].min[axis]
+	front, err := wldWorldNodes(tree, sides[split:])
+	if err != nil {
+		return 0, err
+	}
+	back, err := wldWorldNodes(tree, sides[:split])
+	if err != nil {
+		return 0, err
+	}
+	node.FrontTree = front
+	node.BackTree = back
+	return number, nil
+}
+
+// wldPieces splits faces into dmsprites of no more vertices than a face can index. For a skinned
+// mesh, bones is the bone of each vertex and poses is where each bone is, vertices are moved to the
+// space of their bone and grouped by it. Other meshes are centered. Faces are grouped by material
+func wldPieces(vertices []*ModVertex, faces []wldFace, palette []string, bones []int, poses []eqgBonePose) ([]*DMSpriteDef2, error) {
+	paletteIndex := make(map[string]int)
+	for i, tag := range palette {
+		paletteIndex[tag] = i
+	}
+
+	pieces := []*DMSpriteDef2{}
+	start := 0
+	for start < len(faces) {
+		isUsed := make(map[uint32]bool)
+		end := start
+		for end < len(faces) {
+			added := 0
+			for _, index := range faces[end].index {
+				if int(index) >= len(vertices) {
+					return nil, fmt.Errorf("face %d: vertex %d out of range", end, index)
+				}
+				if !isUsed[index] {
+					added++
+				}
+			}
+			if len(isUsed)+added > math.MaxUint16 {
+				break
+			}
+			for _, index := range faces[end].index {
+				isUsed[index] = true
+			}
+			end++
+		}
+
+		piece, err := wldPiece(vertices, faces[start:end], paletteIndex, bones, poses)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+	if len(pieces) == 0 {
+		piece, err := wldPiece(vertices, nil, paletteIndex, bones, poses)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces, nil
+}
+
+// wldPiece returns a dmsprite of faces and the vertices they use
+func wldPiece(vertices []*ModVertex, faces []wldFace, paletteIndex map[string]int, bones []int, poses []eqgBonePose) (*DMSpriteDef2, error) {
+	used := []int{}
+	isUsed := make(map[uint32]bool)
+	for _, face := range faces {
+		for _, index := range face.index {
+			if !isUsed[index] {
+				isUsed[index] = true
+				used = append(used, int(index))
+			}
+		}
+	}
+	if len(faces) == 0 {
+		for i := range vertices {
+			used = append(used, i)
+		}
+	}
+	if bones != nil {
+		sort.SliceStable(used, func(i, j int) bool {
+			return bones[used[i]] < bones[used[j]]
+		})
+	}
+
+	sprite := &DMSpriteDef2{}
+	remap := make(map[uint32]uint16)
+	isTinted := false
+	for i, index := range used {
+		vert := vertices[index]
+		remap[uint32(index)] = uint16(i)
+		position := vert.Position
+		normal := vert.Normal
+		if bones != nil {
+			position = poses[bones[index]].unapply(position)
+			normal = helper.QuatRotate(helper.QuatConjugate(poses[bones[index]].rotation), normal)
+		}
+		sprite.Vertices = append(sprite.Vertices, position)
+		sprite.VertexNormals = append(sprite.VertexNormals, normal)
+		sprite.UVs = append(sprite.UVs, vert.Uv)
+		sprite.VertexColors = append(sprite.VertexColors, vert.Tint)
+		if vert.Tint != [4]uint8{} {
+			isTinted = true
+		}
+		if bones == nil {
+			continue
+		}
+		bone := int16(bones[index])
+		groups := len(sprite.SkinAssignmentGroups)
+		if groups > 0 && sprite.SkinAssignmentGroups[groups-1][1] == bone {
+			sprite.SkinAssignmentGroups[groups-1][0]++
+			continue
+		}
+		sprite.SkinAssignmentGroups = append(sprite.SkinAssignmentGroups, [2]int16{1, bone})
+	}
+	if !isTinted {
+		sprite.VertexColors = nil
+	}
+
+	sorted := append([]wldFace{}, faces...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return paletteIndex[sorted[i].material] < paletteIndex[sorted[j].material]
+	})
+	for _, face := range sorted {
+		sprite.Faces = append(sprite.Faces, &Face{
+			Passable: face.passable,
+			Triangle: [3]uint16{remap[face.index[0]], remap[face.index[1]], remap[face.index[2]]},
+		})
+		index := uint16(paletteIndex[face.material])
+		groups := len(sprite.FaceMaterialGroups)
+		if groups > 0 && sprite.FaceMaterialGroups[groups-1][1] == index {
+			sprite.FaceMaterialGroups[groups-1][0]++
+			continue
+		}
+		sprite.FaceMaterialGroups = append(sprite.FaceMaterialGroups, [2]uint16{1, index})
+	}
+
+	if bones == nil && len(sprite.Vertices) > 0 {
+		boxMin := sprite.Vertices[0]
+		boxMax := sprite.Vertices[0]
+		for _, vert := range sprite.Vertices {
+			for j := 0; j < 3; j++ {
+				boxMin[j] = min(boxMin[j], vert[j])
+				boxMax[j] = max(boxMax[j], vert[j])
+			}
+		}
+		for j := 0; j < 3; j++ {
+			sprite.CenterOffset[j] = (boxMin[j] + boxMax[j]) / 2
+		}
+		for i := range sprite.Vertices {
+			for j := 0; j < 3; j++ {
+				sprite.Vertices[i][j] -= sprite.CenterOffset[j]
+			}
+		}
+	}
+
+	largest := float32(0)
+	for _, vert := range sprite.Vertices {
+		for _, v := range vert {
+			largest = max(largest, float32(math.Abs(float64(v))))
+		}
+		sprite.BoundingRadius = max(sprite.BoundingRadius, wldLength(vert))
+	}
+	if largest > math.MaxInt16 {
+		return nil, fmt.Errorf("piece is %0.0f units across, too large for a dmsprite", largest*2)
+	}
+	// the finest fixed point scale the vertices fit in
+	for sprite.FPScale < 15 && largest*float32(int(1)<<(sprite.FPScale+1)) <= math.MaxInt16 {
+		sprite.FPScale++
+	}
+	return sprite, nil
+}
+
+func wldLength(v [3]float32) float32 {
+	return float32(math.Sqrt(float64(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])))
+}
+
+// wldFromEqgAni converts an ani to a track for each bone, named by its animation code and the track
+// of the bone, such as C01ELFHE_TRACK for bone he of elf_c01.ani. Frames are sampled at the shortest
+// time between two keyframes
+func (dst *Wce) wldFromEqgAni(ani *EqgAniDef) error {
+	split := strings.LastIndex(ani.Tag, "_")
+	if split < 0 {
+		return fmt.Errorf("name has no animation code, such as %s_c01", ani.Tag)
+	}
+	code := strings.ToUpper(ani.Tag[split+1:])
+	if len(code) != 3 || !regexAniPrefix.MatchString(code) {
+		return fmt.Errorf("%s is not an animation code, such as c01", ani.Tag[split+1:])
+	}
+	model := strings.ToUpper(ani.Tag[:split])
+
+	dst.wldAnimationAdd(ani.Bones, func(bone *AniBone) (*TrackInstance, *TrackDef) {
+		boneTag := code + wldBoneTag(model, bone.Name)
+		trackDef := &TrackDef{Tag: boneTag + "_TRACKDEF"}
+		return &TrackInstance{Tag: boneTag + "_TRACK", SpriteTag: trackDef.Tag}, trackDef
+	})
+	return nil
+}
+
+// wldAnimationAdd adds a track for each bone of an animation that has keyframes, after the
+// animation options process them. Every track is sampled at the shortest time between keyframes,
+// so they play in step. newTrack names the track and trackdef of a bone
+func (dst *Wce) wldAnimationAdd(bones []*AniBone, newTrack func(bone *AniBone) (*TrackInstance, *TrackDef)) {
+	boneKeys := make([][]anim.Key, len(bones))
+	sleep := uint32(0)
+	duration := uint32(0)
+	for i, bone := range bones {
+		keys := []anim.Key{}
+		for _, frame := range bone.Frames {
+			keys = append(keys, anim.Key(*frame))
+		}
+		keys = dst.animationOptions.Process(keys)
+		boneKeys[i] = keys
+		for j, key := range keys {
+			duration = max(duration, key.Milliseconds)
+			if j == 0 || key.Milliseconds <= keys[j-1].Milliseconds {
+				continue
+			}
+			delta := key.Milliseconds - keys[j-1].Milliseconds
+			if sleep == 0 || delta < sleep {
+				sleep = delta
+			}
+		}
+	}
+	if sleep == 0 {
+		sleep = 100
+	}
+
+	for i, bone := range bones {
+		if len(boneKeys[i]) == 0 {
+			continue
+		}
+		transforms := []TrackTransform{}
+		// one frame past the end when the duration is not a multiple of sleep, so the last key plays
+		for ms := uint32(0); ms < duration+sleep; ms += sleep {
+			key := anim.Sample(boneKeys[i], ms)
+			transforms = append(transforms, TrackTransform{Translation: key.Translation, Rotation: key.Rotation})
+		}
+		track, trackDef := newTrack(bone)
+		trackDef.SetTransforms(transforms)
+		track.SpriteTag = trackDef.Tag
+		track.Sleep = NullUint32{Uint32: sleep, Valid: true}
+		dst.TrackDefs = append(dst.TrackDefs, trackDef)
+		dst.TrackInstances = append(dst.TrackInstances, track)
+	}
+}
+
+// wldFromEqgLay converts the layers of a lay to variation materials showing another texture.
+// The variation is the number at the end of the lay name, such as 2 for elf_02.lay, or its
+// position. Character materials keep the texture number in their tag, such as ELFCH0201_MDF
+func (dst *Wce) wldFromEqgLay(lay *EqgLayDef, position int) error {
+	variation := position
+	split := strings.LastIndex(lay.Tag, "_")
+	if split >= 0 {
+		val, err := strconv.Atoi(lay.Tag[split+1:])
+		if err == nil {
+			variation = val
+		}
+	}
+	if variation < 1 || variation > 99 {
+		return fmt.Errorf("variation %d is not between 1 and 99", variation)
+	}
+
+	for _, layer := range lay.Layers {
+		if layer.Material == "" || layer.Diffuse == "" {
+			continue
+		}
+		baseTag := wldMaterialTag(layer.Material)
+		base, ok := dst.tagFirst("MATERIALDEFINITION", baseTag).(*MaterialDef)
+		if !ok {
+			return fmt.Errorf("layer material %s not found", baseTag)
+		}
+
+		tag := fmt.Sprintf("%s_%02d_MDF", strings.TrimSuffix(baseTag, "_MDF"), variation)
+		if regexChrMaterial.MatchString(baseTag) {
+			tag = fmt.Sprintf("%s%02d%s", baseTag[:5], variation, baseTag[7:])
+		}
+		if dst.tagFirst("MATERIALDEFINITION", tag) != nil {
+			continue
+		}
+
+		sprite := &SimpleSpriteDef{
+			Tag:                strings.TrimSuffix(tag, "_MDF") + "_SPRITE",
+			Variation:          1,
+			SimpleSpriteFrames: []SimpleSpriteFrame{wldSpriteFrame(layer.Diffuse)},
+		}
+		dst.SimpleSpriteDefs = append(dst.SimpleSpriteDefs, sprite)
+
+		matDef := *base
+		matDef.folders = nil
+		matDef.fragID = 0
+		matDef.Tag = tag
+		matDef.TagIndex = 0
+		matDef.Variation = 1
+		matDef.SimpleSpriteTag = sprite.Tag
+		matDef.SimpleSpriteTagIndex = 0
+		if base.SimpleSpriteTag == "" {
+			// the layer gives the material a texture, so map it again as if it always had one
+			matDef.RenderMethod = helper.ShaderRenderMethod(helper.RenderMethodShader(base.RenderMethod), "e_TextureDiffuse0")
+		}
+		dst.MaterialDefs = append(dst.MaterialDefs, &matDef)
+	}
+	return nil
+}
+
+// wldFromEqgZon converts the model instances of a zon, other than its ter, to actor instances and
+// its lights to point lights
+func (dst *Wce) wldFromEqgZon(zon *EqgZonDef) {
+	for _, inst := range zon.Instances {
+		ext := filepath.Ext(inst.ModelTag)
+		if strings.EqualFold(ext, ".ter") {
+			continue
+		}
+		actor := &ActorInst{
+			Tag:           inst.InstanceTag,
+			DefinitionTag: strings.ToUpper(strings.TrimSuffix(inst.ModelTag, ext)) + "_ACTORDEF",
+		}
+		actor.SetTransform(inst.Translation, inst.Rotation, inst.Scale)
+		dst.ActorInsts = append(dst.ActorInsts, actor)
+	}
+
+	for i, light := range zon.Lights {
+		lightDef := &LightDef{
+			Tag:         fmt.Sprintf("L%d_LDEF", len(dst.LightDefs)+1),
+			LightLevels: []float32{1},
+			Colors:      [][3]float32{light.Color},
+		}
+		dst.LightDefs = append(dst.LightDefs, lightDef)
+		tag := light.Name
+		if tag == "" {
+			tag = fmt.Sprintf("L%d", i+1)
+		}
+		dst.PointLights = append(dst.PointLights, &PointLight{
+			Tag:         tag,
+			LightDefTag: lightDef.Tag,
+			Location:    light.Position,
+			Radius:      light.Radius,
+		})
+	}
+}
@@ -293,12 +293,18 @@ func readRawFrag(e *Wce, rawWld *raw.Wld, fragment helper.FragmentReadWriter, fo
 }
 
 func (wce *Wce) WriteWldRaw(w io.Writer) error {
-	var err error
+	wce.fragIDReset()
 
-	err = wce.convertEQGToWld()
+	src, err := wce.convertEQGToWld()
 	if err != nil {
 		return fmt.Errorf("convert eqg to wld: %w", err)
 	}
+	return src.writeWldRaw(w)
+}
+
+// writeWldRaw writes the wld definitions of wce
+func (wce *Wce) writeWldRaw(w io.Writer) error {
+	var err error
 
 	dst := &raw.Wld{
 		IsNewWorld: false,
@@ -529,6 +535,64 @@ func (wce *Wce) WriteWldRaw(w io.Writer) error {
 	return dst.Write(w)
 }
 
+// fragIDReset forgets the fragment each definition was written to by ToRaw, so the wce can be
+// written again
+func (wce *Wce) fragIDReset() {
+	if wce.GlobalAmbientLightDef != nil {
+		wce.GlobalAmbientLightDef.fragID = 0
+	}
+	for _, def := range wce.wldDefinitions() {
+		switch e := def.(type) {
+		case *ActorDef:
+			e.fragID = 0
+		case *ActorInst:
+			e.fragID = 0
+		case *AmbientLight:
+			e.fragID = 0
+		case *BlitSpriteDef:
+			e.fragID = 0
+		case *DMSpriteDef2:
+			e.fragID = 0
+		case *DMSpriteDef:
+			e.fragID = 0
+		case *DMTrackDef2:
+			e.fragID = 0
+		case *HierarchicalSpriteDef:
+			e.fragID = 0
+		case *LightDef:
+			e.fragID = 0
+		case *MaterialDef:
+			e.fragID = 0
+		case *MaterialPalette:
+			e.fragID = 0
+		case *ParticleCloudDef:
+			e.fragID = 0
+		case *PointLight:
+			e.fragID = 0
+		case *PolyhedronDefinition:
+			e.fragID = 0
+		case *Region:
+			e.fragID = 0
+		case *RGBTrackDef:
+			e.fragID = 0
+		case *SimpleSpriteDef:
+			e.fragID = 0
+		case *Sprite2DDef:
+			e.fragID = 0
+		case *Sprite3DDef:
+			e.fragID = 0
+		case *TrackDef:
+			e.fragID = 0
+		case *TrackInstance:
+			e.fragID = 0
+		case *WorldTree:
+			e.fragID = 0
+		case *Zone:
+			e.fragID = 0
+		}
+	}
+}
+
 var (
 	regexAniPrefix = regexp.MustCompile(`^[CDLOPST](0[1-9]|[1-9][0-9])`)
 )
@@ -658,29 +722,3 @@ func appendUnique(slice []string, value string) []string {
 	}
 	return append(slice, value)
 }
-
-func (wce *Wce) convertEQGToWld() error {
-
-	//for _, mds := range wce.MdsDefs {
-	//}
-
-	//for _, mod := range wce.ModDefs {
-	//}
-
-	//for _, ter := range wce.TerDefs {
-	//}
-
-	//for _, ani := range wce.AniDefs {
-	//}
-
-	//for _, lay := range wce.LayDefs {
-	//}
-
-	//for _, pts := range wce.PtsDefs {
-
-	//}
-
-	//for _, prt := range wce.PrtDefs {
-	//}
-	return nil
-}
@@ -0,0 +1,127 @@
+package wce
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xackery/quail/raw"
+)
+
+// WriteWtrRaw writes the water, lava, pvp and zoneline regions of the wce to an EQEmu water map.
+// A bsp world tree produces a version 1 map, eqg zon areas produce a version 2 map
+func (wce *Wce) WriteWtrRaw(dst *raw.Wtr) error {
+	if dst == nil {
+		return fmt.Errorf("wtr is nil")
+	}
+
+	if len(wce.WorldTrees) > 0 {
+		return wce.writeWtrTree(dst)
+	}
+
+	dst.Version = 2
+	for _, zon := range wce.ZonDefs {
+		for _, area := range zon.Areas {
+			regionType := areaRegionType(area.Name)
+			if regionType == raw.WtrRegionTypeUnsupported {
+				continue
+			}
+			dst.Regions = append(dst.Regions, &raw.WtrRegion{
+				Type:     regionType,
+				Position: area.Position,
+				// Color holds the zon area orientation in degrees, see EqgZonDef.ToRaw
+				Rotation: area.Color,
+				Scale:    [3]float32{1, 1, 1},
+				Extents:  area.Extents,
+			})
+		}
+	}
+	return nil
+}
+
+func (wce *Wce) writeWtrTree(dst *raw.Wtr) error {
+	if len(wce.WorldTrees) > 1 {
+		return fmt.Errorf("expected 1 world tree, got %d", len(wce.WorldTrees))
+	}
+	dst.Version = 1
+
+	// region numbers are 1 based, zone region lists are 0 based
+	regionTypes := make(map[int]raw.WtrRegionType)
+	for _, zone := range wce.Zones {
+		regionType := zoneRegionType(zone)
+		for _, region := range zone.Regions {
+			regionTypes[int(region)+1] = regionType
+		}
+	}
+
+	tree := wce.WorldTrees[0]
+	for i, node := range tree.WorldNodes {
+		wtrNode := &raw.WtrNode{
+			NodeNumber:    int32(i + 1),
+			Normal:        [3]float32{node.Normals[0], node.Normals[1], node.Normals[2]},
+			SplitDistance: node.Normals[3],
+			Left:          int32(node.FrontTree),
+			Right:         int32(node.BackTree),
+		}
+		if node.WorldRegionTag != "" {
+			regionNumber := 0
+			_, err := fmt.Sscanf(node.WorldRegionTag, "R%d", &regionNumber)
+			if err != nil {
+				return fmt.Errorf("node %d region %s: %w", i, node.WorldRegionTag, err)
+			}
+			wtrNode.Region = int32(regionNumber)
+			wtrNode.Special = regionTypes[regionNumber]
+		}
+		dst.Nodes = append(dst.Nodes, wtrNode)
+	}
+	return nil
+}
+
+// zoneRegionType returns the region type a ZONE applies, based on its tag or, for generic tags, its user data
+func zoneRegionType(zone *Zone) raw.WtrRegionType {
+	regionType := zoneNameRegionType(zone.Tag)
+	if regionType != raw.WtrRegionTypeUnsupported {
+		return regionType
+	}
+	return zoneNameRegionType(zone.UserData)
+}
+
+func zoneNameRegionType(name string) raw.WtrRegionType {
+	name = strings.ToUpper(name)
+	switch {
+	case strings.HasPrefix(name, "WT"):
+		return raw.WtrRegionTypeWater
+	case strings.HasPrefix(name, "LA"):
+		return raw.WtrRegionTypeLava
+	case strings.HasPrefix(name, "DRNTP"):
+		return raw.WtrRegionTypeZoneLine
+	case strings.HasPrefix(name, "DRP_"):
+		return raw.WtrRegionTypePVP
+	case strings.HasPrefix(name, "SL"):
+		return raw.WtrRegionTypeSlime
+	case strings.HasPrefix(name, "DRN") && strings.Contains(name, "_S_"):
+		return raw.WtrRegionTypeIce
+	case strings.HasPrefix(name, "VWA"):
+		return raw.WtrRegionTypeVWater
+	}
+	return raw.WtrRegionTypeUnsupported
+}
+
+// areaRegionType returns the region type of an eqg zon area
+func areaRegionType(name string) raw.WtrRegionType {
+	name = strings.ToUpper(name)
+	switch {
+	case strings.HasPrefix(name, "AWT"):
+		return raw.WtrRegionTypeWater
+	case strings.HasPrefix(name, "ALV"):
+		return raw.WtrRegionTypeLava
+	case strings.HasPrefix(name, "ATP"):
+		return raw.WtrRegionTypeZoneLine
+	case strings.HasPrefix(name, "APK"):
+		return raw.WtrRegionTypePVP
+	case strings.HasPrefix(name, "ASL"):
+		return raw.WtrRegionTypeIce
+	case strings.HasPrefix(name, "AVW"):
+		return raw.WtrRegionTypeVWater
+	}
+	return raw.WtrRegionTypeUnsupported
+}
@@ -0,0 +1,365 @@
+package wce_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/xackery/quail/anim"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+// animTrack adds a track and trackdef moving a bone through transforms, a frame every sleep ms
+func animTrack(w *wce.Wce, tag string, sleep uint32, transforms ...wce.TrackTransform) {
+	trackDef := &wce.TrackDef{Tag: tag + "DEF"}
+	trackDef.SetTransforms(transforms)
+	w.TrackDefs = append(w.TrackDefs, trackDef)
+	track := &wce.TrackInstance{Tag: tag, SpriteTag: trackDef.Tag}
+	if sleep > 0 {
+		track.Sleep = wce.NullUint32{Uint32: sleep, Valid: true}
+	}
+	w.TrackInstances = append(w.TrackInstances, track)
+}
+
+// animWce returns a wce with a human skeleton walking and a twice as tall skeleton standing still
+func animWce() *wce.Wce {
+	w := wce.New("test_chr.s3d")
+	identity := [4]float32{0, 0, 0, 1}
+	w.HierarchicalSpriteDefs = append(w.HierarchicalSpriteDefs,
+		&wce.HierarchicalSpriteDef{
+			Tag: "HUM_HS_DEF",
+			Dags: []wce.Dag{
+				{Tag: "HUM_DAG", Track: "HUM_TRACK", SubDags: []uint32{1}},
+				{Tag: "HUMPE_DAG", Track: "HUMPE_TRACK"},
+			},
+		},
+		&wce.HierarchicalSpriteDef{
+			Tag: "ZZZ_HS_DEF",
+			Dags: []wce.Dag{
+				{Tag: "ZZZ_DAG", Track: "ZZZ_TRACK", SubDags: []uint32{1, 2}},
+				{Tag: "ZZZPELVIS_DAG", Track: "ZZZPELVIS_TRACK"},
+				{Tag: "ZZZTA_DAG", Track: "ZZZTA_TRACK"},
+			},
+		},
+	)
+	animTrack(w, "HUM_TRACK", 0, wce.TrackTransform{Rotation: identity})
+	animTrack(w, "HUMPE_TRACK", 0, wce.TrackTransform{Translation: [3]float32{0, 0, 2}, Rotation: identity})
+	animTrack(w, "ZZZ_TRACK", 0, wce.TrackTransform{Rotation: identity})
+	animTrack(w, "ZZZPELVIS_TRACK", 0, wce.TrackTransform{Translation: [3]float32{0, 0, 4}, Rotation: identity})
+	animTrack(w, "ZZZTA_TRACK", 0, wce.TrackTransform{Translation: [3]float32{1, 0, 0}, Rotation: identity})
+
+	// the human walks forward, bobbing its pelvis and turning it 90 degrees around z
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	animTrack(w, "C01HUM_TRACK", 100,
+		wce.TrackTransform{Rotation: identity},
+		wce.TrackTransform{Translation: [3]float32{1, 0, 0}, Rotation: identity},
+	)
+	animTrack(w, "C01HUMPE_TRACK", 100,
+		wce.TrackTransform{Translation: [3]float32{0, 0, 2}, Rotation: identity},
+		wce.TrackTransform{Translation: [3]float32{0, 0, 2.5}, Rotation: turn},
+	)
+	return w
+}
+
+func nearTransform(t *testing.T, name string, got wce.TrackTransform, translation [3]float32, rotation [4]float32) {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		if math.Abs(float64(got.Translation[i]-translation[i])) > 0.01 {
+			t.Fatalf("%s translation %v, want %v", name, got.Translation, translation)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if math.Abs(float64(got.Rotation[i]-rotation[i])) > 0.001 {
+			t.Fatalf("%s rotation %v, want %v", name, got.Rotation, rotation)
+		}
+	}
+}
+
+func TestAnimationRetarget(t *testing.T) {
+	w := animWce()
+	from, err := w.Skeleton("HUM_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	to, err := w.Skeleton("zzz")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	if len(to.Bones) != 3 || to.Bones[0].Name != "ROOT" || to.Bones[1].Name != "PELVIS" || to.Bones[1].Parent != 0 {
+		t.Fatalf("skeleton bones %+v", to.Bones)
+	}
+
+	anims := w.Animations(from)
+	if len(anims) != 1 || anims[0].Code != "C01" || len(anims[0].Bones) != 2 {
+		t.Fatalf("animations %+v", anims)
+	}
+
+	_, err = wce.AnimationRetarget(anims[0], from, to, map[string]string{"PE": "HEAD"}, false)
+	if err == nil {
+		t.Fatalf("bone map to a missing bone should fail")
+	}
+
+	for _, isRootMotion := range []bool{false, true} {
+		anim, err := wce.AnimationRetarget(anims[0], from, to, map[string]string{"PE": "PELVIS"}, isRootMotion)
+		if err != nil {
+			t.Fatalf("retarget: %s", err)
+		}
+		err = w.AnimationSet(to, anim)
+		if err != nil {
+			t.Fatalf("set: %s", err)
+		}
+	}
+
+	back, err := wldRoundTrip(w)
+	if err != nil {
+		t.Fatalf("round trip: %s", err)
+	}
+	to, err = back.Skeleton("ZZZ_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	anim := back.Animation(to, "c01")
+	if anim == nil || len(anim.Bones) != 3 {
+		t.Fatalf("retargeted animation %+v", anim)
+	}
+
+	track := back.ByTag("C01ZZZPELVIS_TRACK")
+	if track == nil || track.(*wce.TrackInstance).Sleep.Uint32 != 100 {
+		t.Fatalf("track C01ZZZPELVIS_TRACK %+v", track)
+	}
+	frames := back.ByTag("C01ZZZPELVIS_TRACKDEF").(*wce.TrackDef).Transforms()
+	if len(frames) != 2 {
+		t.Fatalf("pelvis frames %d, want 2", len(frames))
+	}
+	// the pelvis is twice as long, so it bobs twice as far
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	nearTransform(t, "pelvis", frames[1], [3]float32{0, 0, 5}, turn)
+
+	frames = back.ByTag("C01ZZZ_TRACKDEF").(*wce.TrackDef).Transforms()
+	nearTransform(t, "root", frames[1], [3]float32{2, 0, 0}, [4]float32{0, 0, 0, 1})
+
+	frames = back.ByTag("C01ZZZTA_TRACKDEF").(*wce.TrackDef).Transforms()
+	if len(frames) != 2 {
+		t.Fatalf("unmoved bone frames %d, want 2", len(frames))
+	}
+	nearTransform(t, "unmoved", frames[1], [3]float32{1, 0, 0}, [4]float32{0, 0, 0, 1})
+	if len(back.TrackInstances) != 10 {
+		t.Fatalf("tracks %d, want 10 after setting C01 twice", len(back.TrackInstances))
+	}
+}
+
+func TestAnimationRetargetEqg(t *testing.T) {
+	w := animWce()
+	eqg := wce.New("zzz.eqg")
+	eqg.MdsDefs = append(eqg.MdsDefs, &wce.EqgMdsDef{
+		Tag: "zzz",
+		Bones: []*wce.MdsBone{
+			{Name: "root", ChildIndex: 1, Next: -1, Quaternion: [4]float32{0, 0, 0, 1}},
+			{Name: "pelvis", ChildIndex: -1, Next: -1, Pivot: [3]float32{0, 0, 1}, Quaternion: [4]float32{0, 0, 0, 1}},
+		},
+	})
+	from, err := w.Skeleton("HUM_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	to, err := eqg.Skeleton("ZZZ_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	if !to.IsEqg() || to.Bones[1].Parent != 0 {
+		t.Fatalf("mds skeleton %+v", to)
+	}
+	anim, err := wce.AnimationRetarget(w.Animation(from, "C01"), from, to, map[string]string{"ROOT": "root", "PE": "pelvis"}, true)
+	if err != nil {
+		t.Fatalf("retarget: %s", err)
+	}
+	err = eqg.AnimationSet(to, anim)
+	if err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	if len(eqg.AniDefs) != 1 || eqg.AniDefs[0].Tag != "zzz_c01" || len(eqg.AniDefs[0].Bones) != 2 {
+		t.Fatalf("ani defs %+v", eqg.AniDefs)
+	}
+	pelvis := eqg.AniDefs[0].Bones[1]
+	if pelvis.Name != "pelvis" || pelvis.Frames[1].Milliseconds != 100 {
+		t.Fatalf("pelvis %+v", pelvis)
+	}
+	// the pelvis is half as long, so it bobs half as far
+	if math.Abs(float64(pelvis.Frames[1].Translation[2]-1.25)) > 0.001 {
+		t.Fatalf("pelvis translation %v, want z 1.25", pelvis.Frames[1].Translation)
+	}
+
+	dst := &raw.Ani{}
+	err = eqg.AniDefs[0].ToRaw(eqg, dst)
+	if err != nil {
+		t.Fatalf("to raw: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	err = dst.Write(buf)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	if eqg.AnimationRemove(to, "C01") != 1 || len(eqg.AniDefs) != 0 {
+		t.Fatalf("remove left %d ani defs", len(eqg.AniDefs))
+	}
+}
+
+func wldRoundTrip(w *wce.Wce) (*wce.Wce, error) {
+	buf := &bytes.Buffer{}
+	err := w.WriteWldRaw(buf)
+	if err != nil {
+		return nil, err
+	}
+	rawWld := &raw.Wld{}
+	err = rawWld.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	back := wce.New(w.FileName)
+	err = back.ReadWldRaw(rawWld)
+	if err != nil {
+		return nil, err
+	}
+	return back, nil
+}
+
+func TestAnimationTools(t *testing.T) {
+	w := animWce()
+	hum, err := w.Skeleton("HUM")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	skels, err := w.Skeletons()
+	if err != nil || len(skels) != 2 {
+		t.Fatalf("skeletons %d: %v", len(skels), err)
+	}
+
+	anim := w.Animation(hum, "C01")
+	if anim.FrameCount() != 2 || anim.Duration() != 100 {
+		t.Fatalf("frames %d duration %d, want 2 and 100", anim.FrameCount(), anim.Duration())
+	}
+
+	count, err := w.AnimationSpeed(hum, "C01", 2)
+	if err != nil || count != 2 {
+		t.Fatalf("speed %d: %v", count, err)
+	}
+	if w.Animation(hum, "C01").Duration() != 50 {
+		t.Fatalf("duration %d after speed, want 50", w.Animation(hum, "C01").Duration())
+	}
+
+	count, err = w.AnimationRename(hum, "C01", "c02")
+	if err != nil || count != 4 {
+		t.Fatalf("rename %d: %v", count, err)
+	}
+	if w.ByTag("C02HUMPE_TRACK") == nil || w.ByTag("C02HUMPE_TRACKDEF") == nil || w.Animation(hum, "C01") != nil {
+		t.Fatalf("tracks not renamed")
+	}
+
+	// the elf has the same bones, so the walk copies as it is
+	w.HierarchicalSpriteDefs = append(w.HierarchicalSpriteDefs, &wce.HierarchicalSpriteDef{
+		Tag:  "ELF_HS_DEF",
+		Dags: []wce.Dag{{Tag: "ELF_DAG", Track: "ELF_TRACK", SubDags: []uint32{1}}, {Tag: "ELFPE_DAG", Track: "ELFPE_TRACK"}},
+	})
+	animTrack(w, "ELF_TRACK", 0, wce.TrackTransform{Rotation: [4]float32{0, 0, 0, 1}})
+	animTrack(w, "ELFPE_TRACK", 0, wce.TrackTransform{Rotation: [4]float32{0, 0, 0, 1}})
+	elf, err := w.Skeleton("ELF_HS_DEF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	copied, err := wce.AnimationCopy(w.Animation(hum, "C02"), hum, elf, nil)
+	if err != nil {
+		t.Fatalf("copy: %s", err)
+	}
+	err = w.AnimationSet(elf, copied)
+	if err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	frames := w.ByTag("C02ELFPE_TRACKDEF").(*wce.TrackDef).Transforms()
+	if len(frames) != 2 || frames[1].Translation[2] != 2.5 {
+		t.Fatalf("copied pelvis frames %+v", frames)
+	}
+	if w.ByTag("C02ELFPE_TRACK").(*wce.TrackInstance).Sleep.Uint32 != 50 {
+		t.Fatalf("copied sleep %d, want 50", w.ByTag("C02ELFPE_TRACK").(*wce.TrackInstance).Sleep.Uint32)
+	}
+
+	if w.AnimationRemove(hum, "C02") != 2 {
+		t.Fatalf("remove did not find 2 tracks")
+	}
+	if w.ByTag("C02HUMPE_TRACKDEF") != nil || w.ByTag("C02ELFPE_TRACK") == nil {
+		t.Fatalf("remove took the wrong tracks")
+	}
+}
+
+func TestAnimationToolsEqg(t *testing.T) {
+	w := wce.New("elf.eqg")
+	w.MdsDefs = append(w.MdsDefs, &wce.EqgMdsDef{
+		Tag:   "elf",
+		Bones: []*wce.MdsBone{{Name: "root", ChildIndex: -1, Next: -1, Quaternion: [4]float32{0, 0, 0, 1}}},
+	})
+	w.AniDefs = append(w.AniDefs, &wce.EqgAniDef{
+		Tag: "elf_l01",
+		Bones: []*wce.AniBone{{Name: "root", Frames: []*wce.AniBoneFrame{
+			{Milliseconds: 0, Rotation: [4]float32{0, 0, 0, 1}},
+			{Milliseconds: 300, Rotation: [4]float32{0, 0, 0, 1}},
+		}}},
+	})
+	elf, err := w.Skeleton("ELF")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+
+	count, err := w.AnimationSpeed(elf, "l01", 1.5)
+	if err != nil || count != 1 || w.AniDefs[0].Bones[0].Frames[1].Milliseconds != 200 {
+		t.Fatalf("speed %d: %v", count, err)
+	}
+	count, err = w.AnimationRename(elf, "L01", "L06")
+	if err != nil || count != 1 || w.AniDefs[0].Tag != "elf_l06" {
+		t.Fatalf("rename %d %s: %v", count, w.AniDefs[0].Tag, err)
+	}
+	_, err = w.AnimationRename(elf, "L01", "L02")
+	if err == nil {
+		t.Fatalf("renaming a missing animation should fail")
+	}
+	if w.AnimationRemove(elf, "L06") != 1 || len(w.AniDefs) != 0 {
+		t.Fatalf("remove left %d ani defs", len(w.AniDefs))
+	}
+}
+
+func TestAnimationOptions(t *testing.T) {
+	w := animWce()
+	w.SetAnimationOptions(anim.Options{Rate: 20})
+	hum, err := w.Skeleton("HUM")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+
+	// a quarter turn of the pelvis over a second
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	err = w.AnimationSet(hum, &wce.Animation{Code: "C02", Bones: []*wce.AniBone{
+		{Name: "PE", Frames: []*wce.AniBoneFrame{
+			{Milliseconds: 0, Translation: [3]float32{0, 0, 2}, Rotation: [4]float32{0, 0, 0, 1}, Scale: [3]float32{1, 1, 1}},
+			{Milliseconds: 1000, Translation: [3]float32{0, 0, 2}, Rotation: turn, Scale: [3]float32{1, 1, 1}},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("animation set: %s", err)
+	}
+
+	track, ok := w.ByTag("C02HUMPE_TRACK").(*wce.TrackInstance)
+	if !ok {
+		t.Fatalf("track not added")
+	}
+	if track.Sleep.Uint32 != 50 {
+		t.Fatalf("sleep: got %d, want 50", track.Sleep.Uint32)
+	}
+	trackDef := w.ByTag(track.SpriteTag).(*wce.TrackDef)
+	transforms := trackDef.Transforms()
+	if len(transforms) != 21 {
+		t.Fatalf("frames: got %d, want 21", len(transforms))
+	}
+	// slerp turns at a steady speed, so a quarter of the way is 22.5 degrees
+	angle := math.Pi / 8
+	nearTransform(t, "quarter", transforms[5], [3]float32{0, 0, 2}, [4]float32{0, 0, float32(math.Sin(angle / 2)), float32(math.Cos(angle / 2))})
+}
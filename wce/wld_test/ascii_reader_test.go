@@ -0,0 +1,210 @@
+package wce_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+// regexSegments is how lines were split before the tokenizer, kept to compare against
+func regexSegments(line string) []string {
+	matches := regexp.MustCompile(`"([^"]*)"|(\S+)`).FindAllStringSubmatch(line, -1)
+	args := []string{}
+	for _, match := range matches {
+		if strings.Contains(match[2], "//") {
+			match[2] = match[2][:strings.Index(match[2], "//")]
+			if match[2] == "" {
+				break
+			}
+		}
+		if match[1] != "" {
+			args = append(args, match[1])
+		} else {
+			args = append(args, match[2])
+		}
+	}
+	return args
+}
+
+func TestReadSegmentedLine(t *testing.T) {
+	lines := []string{
+		`TAG "FOO_DMSPRITEDEF"`,
+		`	XYZ 1.00000000e+00 -2.50000000e-01 0.00000000e+00`,
+		`FLAGS "" 0`,
+		`NAME "has space" "" "x//y" // trailing comment`,
+		`a//b c`,
+		`a //b c`,
+		`"a""b"c`,
+		`abc"def ghi"`,
+		`"unterminated quote here`,
+		"tab\tseparated\r",
+		"vertical\vtab",
+		`   `,
+		`//`,
+		`x "//" y`,
+		`"é" ünïcode`,
+		"LONG " + strings.Repeat(`1.00000000e+00 "a b" `, 1000),
+	}
+	for _, line := range lines {
+		token := wce.AsciiReadTokenNew(bytes.NewBufferString(line+"\n"), nil)
+		got, err := token.ReadSegmentedLine()
+		want := regexSegments(line)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "//") {
+			// skipped lines leave an empty last line
+			if err != io.EOF || got != nil {
+				t.Fatalf("%q: got %q %v, want eof", line, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: got %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	token := wce.AsciiReadTokenNew(bytes.NewBufferString("// header\n\nFIRST 1\r\n  // comment\n\t\nSECOND\nlast"), nil)
+	want := []string{"FIRST 1\r", "SECOND", "last"}
+	for i, w := range want {
+		line, err := token.ReadLine()
+		if i < len(want)-1 && err != nil {
+			t.Fatalf("line %d: %s", i, err)
+		}
+		if i == len(want)-1 && err != io.EOF {
+			t.Fatalf("line %d: got %v, want eof", i, err)
+		}
+		if line != w {
+			t.Fatalf("line %d: got %q, want %q", i, line, w)
+		}
+	}
+	if token.TotalLineCountRead() != 7 {
+		t.Fatalf("line number: got %d, want 7", token.TotalLineCountRead())
+	}
+}
+
+// asciiFixture returns a _root.wce with count materials and sprites
+func asciiFixture(count int) string {
+	sb := strings.Builder{}
+	sb.WriteString("// wcemu v0.0.1\nWORLDDEF\n\tNEWWORLD 0\n\tZONE 1 // parse as a zone\n\tEQGVERSION? NULL\n\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&sb, `MATERIALDEFINITION "S%05d_MDF"
+	TAGINDEX 0
+	VARIATION 0
+	RENDERMETHOD "TRANSPARENT"
+	RGBPEN 255 255 255 0
+	BRIGHTNESS 5.00000000e-01
+	SCALEDAMBIENT 1.00000000e+00
+	SIMPLESPRITEINST
+		// sprite this material draws
+		SIMPLESPRITETAG "S%05d_SPRITE"
+		SIMPLESPRITETAGINDEX 0
+		SIMPLESPRITEHEXFIFTYFLAG 0
+	PAIRS? 0 0.00000000e+00
+	DOUBLESIDED 0
+
+SIMPLESPRITEDEF "S%05d_SPRITE"
+	TAGINDEX 0
+	VARIATION 0
+	SKIPFRAMES? NULL
+	ANIMATED? NULL
+	SLEEP? NULL
+	CURRENTFRAME? NULL
+	NUMFRAMES 1
+		FRAME "S%05d"
+			NUMFILES 1
+				FILE "S%05d.BMP"
+
+`, i, i, i, i, i)
+	}
+	return sb.String()
+}
+
+// asciiRewrite reads the ascii file at path and writes it to dir, returning what was written
+func asciiRewrite(path string, dir string) (map[string]string, error) {
+	w := wce.New("synthetic.s3d")
+	err := w.ReadAscii(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	err = w.WriteAscii(dir)
+	if err != nil {
+		return nil, fmt.Errorf("write %s: %w", dir, err)
+	}
+	files := make(map[string]string)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func TestReadAsciiRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_root.wce")
+	err := os.WriteFile(path, []byte(asciiFixture(20)), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	first, err := asciiRewrite(path, filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("rewrite: %s", err)
+	}
+	isFound := false
+	for _, data := range first {
+		if strings.Contains(data, `SIMPLESPRITEDEF "S00019_SPRITE"`) {
+			isFound = true
+		}
+	}
+	if !isFound {
+		t.Fatalf("rewrite is missing sprites")
+	}
+	second, err := asciiRewrite(filepath.Join(dir, "a", "_root.wce"), filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("rewrite again: %s", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("round trip changed output")
+	}
+}
+
+func BenchmarkReadAscii(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "_root.wce")
+	err := os.WriteFile(path, []byte(asciiFixture(4000)), 0644)
+	if err != nil {
+		b.Fatalf("write: %s", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := wce.New("synthetic.s3d")
+		err = w.ReadAscii(path)
+		if err != nil {
+			b.Fatalf("read ascii: %s", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package wce_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+// charWce returns an elf with a body, two heads, a face texture and an armor texture set
+func charWce() *wce.Wce {
+	w := wce.New("test_chr.s3d")
+	identity := [4]float32{0, 0, 0, 1}
+	w.HierarchicalSpriteDefs = append(w.HierarchicalSpriteDefs, &wce.HierarchicalSpriteDef{
+		Tag: "ELF_HS_DEF",
+		Dags: []wce.Dag{
+			{Tag: "ELF_DAG", Track: "ELF_TRACK", SubDags: []uint32{1}},
+			{Tag: "ELFHE_DAG", Track: "ELFHE_TRACK"},
+		},
+		AttachedSkins: []wce.AttachedSkin{
+			{DMSpriteTag: "ELF_DMSPRITEDEF"},
+			{DMSpriteTag: "ELFHE00_DMSPRITEDEF", LinkSkinUpdatesToDagIndex: 1},
+			{DMSpriteTag: "ELFHE01_DMSPRITEDEF", LinkSkinUpdatesToDagIndex: 1},
+		},
+	})
+	animTrack(w, "ELF_TRACK", 0, wce.TrackTransform{Rotation: identity})
+	// the head bone is 5 above the root, turned a quarter around z
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	animTrack(w, "ELFHE_TRACK", 0, wce.TrackTransform{Translation: [3]float32{0, 0, 5}, Rotation: turn})
+
+	triangle := func(tag string, palette string, dag int16) *wce.DMSpriteDef2 {
+		return &wce.DMSpriteDef2{
+			Tag:                  tag,
+			Vertices:             [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+			UVs:                  [][2]float32{{0, 0}, {1, 0}, {0, 1}},
+			VertexNormals:        [][3]float32{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+			SkinAssignmentGroups: [][2]int16{{3, dag}},
+			MaterialPaletteTag:   palette,
+			Faces:                []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+			FaceMaterialGroups:   [][2]uint16{{1, 0}},
+		}
+	}
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s,
+		triangle("ELF_DMSPRITEDEF", "ELF_MP", 0),
+		triangle("ELFHE00_DMSPRITEDEF", "ELFHE_MP", 1),
+		triangle("ELFHE01_DMSPRITEDEF", "ELFHE_MP", 1),
+	)
+	w.MaterialPalettes = append(w.MaterialPalettes,
+		&wce.MaterialPalette{Tag: "ELF_MP", Materials: []string{"ELFCH0001_MDF"}},
+		&wce.MaterialPalette{Tag: "ELFHE_MP", Materials: []string{"ELFHE0001_MDF"}},
+	)
+	for _, tag := range []string{"ELFCH0001", "ELFCH0201", "ELFHE0001", "ELFHE0011"} {
+		w.SimpleSpriteDefs = append(w.SimpleSpriteDefs, &wce.SimpleSpriteDef{
+			Tag:                tag + "_SPRITE",
+			SimpleSpriteFrames: []wce.SimpleSpriteFrame{{TextureFiles: []string{strings.ToLower(tag) + ".bmp"}}},
+		})
+		w.MaterialDefs = append(w.MaterialDefs, &wce.MaterialDef{Tag: tag + "_MDF", RenderMethod: "USERDEFINED_2", SimpleSpriteTag: tag + "_SPRITE"})
+	}
+	return w
+}
+
+func TestCharacter(t *testing.T) {
+	w := charWce()
+	codes, err := w.Characters()
+	if err != nil || len(codes) != 1 || codes[0] != "ELF" {
+		t.Fatalf("characters %v: %v", codes, err)
+	}
+	char, err := w.Character("elf")
+	if err != nil {
+		t.Fatalf("character: %s", err)
+	}
+	if len(char.Heads) != 2 || len(char.Faces) != 2 || char.Faces[1] != 1 || len(char.TextureSets) != 2 || char.TextureSets[1] != 2 {
+		t.Fatalf("heads %v faces %v texture sets %v", char.Heads, char.Faces, char.TextureSets)
+	}
+
+	mesh, err := char.Mesh(wce.CharacterLook{Head: 1, Face: 1, TextureSet: 2})
+	if err != nil {
+		t.Fatalf("mesh: %s", err)
+	}
+	if len(mesh.Vertices) != 6 {
+		t.Fatalf("vertices: got %d, want a body and one head, 6", len(mesh.Vertices))
+	}
+	textures := []string{}
+	for _, group := range mesh.Groups {
+		textures = append(textures, group.Texture)
+	}
+	if strings.Join(textures, ",") != "elfch0201.bmp,elfhe0011.bmp" {
+		t.Fatalf("textures: got %v", textures)
+	}
+	// the head follows its bone, turned and raised
+	nearTransform(t, "head vertex", wce.TrackTransform{Translation: mesh.Vertices[4], Rotation: [4]float32{0, 0, 0, 1}}, [3]float32{0, 1, 5}, [4]float32{0, 0, 0, 1})
+
+	// materials the look has no texture for keep the base
+	mesh, err = char.Mesh(wce.CharacterLook{})
+	if err != nil {
+		t.Fatalf("base mesh: %s", err)
+	}
+	if mesh.Groups[0].Material != "ELFCH0001_MDF" || mesh.Groups[1].Material != "ELFHE0001_MDF" {
+		t.Fatalf("base materials: %s %s", mesh.Groups[0].Material, mesh.Groups[1].Material)
+	}
+
+	_, err = char.Mesh(wce.CharacterLook{Head: 3})
+	if err == nil {
+		t.Fatalf("head 3: want error")
+	}
+}
@@ -0,0 +1,164 @@
+package wce_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+const formatMessy = `// wcemu v0.0.1
+WORLDDEF
+  NEWWORLD 0   // in wld files this signifies a version flag
+  ZONE 1 // when 1 this parses things as if a zone
+  EQGVERSION? NULL // used in eqg parsing for version rebuilding
+
+// the only palette
+materialpalette TEST_MP
+    NUMMATERIALS 1
+    MATERIAL TEST_MDF
+
+MATERIALDEFINITION "TEST_MDF"
+ TAGINDEX 0
+ VARIATION 0
+ RENDERMETHOD TRANSPARENT
+ RGBPEN 255 255 255 0
+ BRIGHTNESS 0.5
+ SCALEDAMBIENT 1
+ SIMPLESPRITEINST
+   // sprite this material draws
+   SIMPLESPRITETAG TEST_SPRITE
+   SIMPLESPRITETAGINDEX 0
+   SIMPLESPRITEHEXFIFTYFLAG 0
+ PAIRS? 0 0.0
+ DOUBLESIDED 0
+
+SIMPLESPRITEDEF "TEST_SPRITE"
+	TAGINDEX 0
+	VARIATION 0
+	SKIPFRAMES? NULL
+	ANIMATED? NULL
+	SLEEP? NULL
+	CURRENTFRAME? NULL
+	NUMFRAMES 1
+		FRAME "TEST"
+			NUMFILES 1
+				FILE "TEST.BMP"
+`
+
+const formatWant = `// wcemu v0.0.1
+WORLDDEF
+	NEWWORLD 0 // in wld files this signifies a version flag
+	ZONE 1 // when 1 this parses things as if a zone
+	EQGVERSION? NULL // used in eqg parsing for version rebuilding
+
+// the only palette
+MATERIALPALETTE "TEST_MP"
+	NUMMATERIALS 1
+	MATERIAL "TEST_MDF"
+
+MATERIALDEFINITION "TEST_MDF"
+	TAGINDEX 0
+	VARIATION 0
+	RENDERMETHOD "TRANSPARENT"
+	RGBPEN 255 255 255 0
+	BRIGHTNESS 5.00000000e-01
+	SCALEDAMBIENT 1.00000000e+00
+	SIMPLESPRITEINST
+		// sprite this material draws
+		SIMPLESPRITETAG "TEST_SPRITE"
+		SIMPLESPRITETAGINDEX 0
+		SIMPLESPRITEHEXFIFTYFLAG 0
+	PAIRS? 0 0.0
+	DOUBLESIDED 0
+
+SIMPLESPRITEDEF "TEST_SPRITE"
+	TAGINDEX 0
+	VARIATION 0
+	SKIPFRAMES? NULL
+	ANIMATED? NULL
+	SLEEP? NULL
+	CURRENTFRAME? NULL
+	NUMFRAMES 1
+		FRAME "TEST"
+			NUMFILES 1
+				FILE "TEST.BMP"
+`
+
+func TestFormat(t *testing.T) {
+	out, err := wce.AsciiFormat([]byte(formatMessy))
+	if err != nil {
+		t.Fatalf("format: %s", err)
+	}
+	if string(out) != formatWant {
+		t.Fatalf("format:\n%s\nwant:\n%s", out, formatWant)
+	}
+
+	again, err := wce.AsciiFormat(out)
+	if err != nil {
+		t.Fatalf("format again: %s", err)
+	}
+	if string(again) != string(out) {
+		t.Fatalf("format is not stable:\n%s", again)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_root.wce")
+	err = os.WriteFile(path, out, 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	w := wce.New("test")
+	err = w.ReadAscii(path)
+	if err != nil {
+		t.Fatalf("read formatted: %s", err)
+	}
+}
+
+// TestFormatWriter checks what WriteAscii writes is already formatted
+func TestFormatWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_root.wce")
+	err := os.WriteFile(path, []byte(formatWant), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	w := wce.New("test")
+	err = w.ReadAscii(path)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	outDir := filepath.Join(dir, "out")
+	err = w.WriteAscii(outDir)
+	if err != nil {
+		t.Fatalf("write ascii: %s", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("read dir: %s", err)
+	}
+	count := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".wce") {
+			continue
+		}
+		count++
+		data, err := os.ReadFile(filepath.Join(outDir, entry.Name()))
+		if err != nil {
+			t.Fatalf("read %s: %s", entry.Name(), err)
+		}
+		out, err := wce.AsciiFormat(data)
+		if err != nil {
+			t.Fatalf("format %s: %s", entry.Name(), err)
+		}
+		if string(out) != string(data) {
+			t.Fatalf("format %s changed writer output:\n%s\nwant:\n%s", entry.Name(), out, data)
+		}
+	}
+	if count == 0 {
+		t.Fatalf("no wce files written")
+	}
+}
@@ -0,0 +1,113 @@
+package wce_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestByTagIndex(t *testing.T) {
+	w := wce.New("test.s3d")
+
+	first := &wce.SimpleSpriteDef{Tag: "A_SPRITE"}
+	err := w.Add(first)
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	if w.ByTag("A_SPRITE") != first {
+		t.Fatalf("bytag A_SPRITE after add")
+	}
+	if w.ByTag("A") != first {
+		t.Fatalf("bytag A without _SPRITE suffix")
+	}
+
+	// appending directly to a slice is picked up on the next lookup
+	second := &wce.SimpleSpriteDef{Tag: "A_SPRITE", TagIndex: 1}
+	w.SimpleSpriteDefs = append(w.SimpleSpriteDefs, second)
+	if w.ByTagWithIndex("A_SPRITE", 1) != second {
+		t.Fatalf("bytagwithindex A_SPRITE 1 after append")
+	}
+	if w.ByTag("A_SPRITE") != first {
+		t.Fatalf("bytag A_SPRITE should return the first added")
+	}
+
+	if !w.Remove(first) {
+		t.Fatalf("remove: not found")
+	}
+	if w.Remove(first) {
+		t.Fatalf("remove twice: found")
+	}
+	if w.ByTag("A_SPRITE") != second {
+		t.Fatalf("bytag A_SPRITE after remove")
+	}
+	if w.ByTagWithIndex("A_SPRITE", 0) != nil {
+		t.Fatalf("bytagwithindex A_SPRITE 0 after remove")
+	}
+
+	// shrinking a slice rebuilds the index
+	w.SimpleSpriteDefs = append(w.SimpleSpriteDefs, first)
+	if w.ByTag("A_SPRITE") != second {
+		t.Fatalf("bytag A_SPRITE after append")
+	}
+	w.SimpleSpriteDefs = []*wce.SimpleSpriteDef{first}
+	if w.ByTagWithIndex("A_SPRITE", 1) != nil || w.ByTag("A_SPRITE") != first {
+		t.Fatalf("bytag after slice shrunk")
+	}
+
+	_, err = w.RenameTag("A_SPRITE", "B_SPRITE")
+	if err != nil {
+		t.Fatalf("rename: %s", err)
+	}
+	if w.ByTag("A_SPRITE") != nil || w.ByTag("B_SPRITE") != first {
+		t.Fatalf("bytag after rename")
+	}
+}
+
+// syntheticZone returns a zone with count materials in one palette and count meshes using it
+func syntheticZone(count int) *wce.Wce {
+	w := wce.New("synthetic.s3d")
+	w.WorldDef.NewWorld = 1
+	w.WorldDef.Zone = 1
+	palette := &wce.MaterialPalette{Tag: "SYNTHETIC_MP"}
+	for i := 0; i < count; i++ {
+		spriteTag := fmt.Sprintf("S%05d_SPRITE", i)
+		w.SimpleSpriteDefs = append(w.SimpleSpriteDefs, &wce.SimpleSpriteDef{
+			Tag:                spriteTag,
+			SimpleSpriteFrames: []wce.SimpleSpriteFrame{{TextureTag: fmt.Sprintf("S%05d", i), TextureFiles: []string{fmt.Sprintf("S%05d.BMP", i)}}},
+		})
+		materialTag := fmt.Sprintf("S%05d_MDF", i)
+		w.MaterialDefs = append(w.MaterialDefs, &wce.MaterialDef{
+			Tag:             materialTag,
+			RenderMethod:    "TRANSPARENT",
+			SimpleSpriteTag: spriteTag,
+		})
+		palette.Materials = append(palette.Materials, materialTag)
+	}
+	w.MaterialPalettes = append(w.MaterialPalettes, palette)
+	for i := 0; i < count; i++ {
+		w.DMSpriteDef2s = append(w.DMSpriteDef2s, &wce.DMSpriteDef2{
+			Tag:                fmt.Sprintf("R%05d_DMSPRITEDEF", i),
+			MaterialPaletteTag: palette.Tag,
+			Vertices:           [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+			UVs:                [][2]float32{{0, 0}, {1, 0}, {0, 1}},
+			VertexNormals:      [][3]float32{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+			Faces:              []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+			FaceMaterialGroups: [][2]uint16{{1, uint16(i)}},
+			FPScale:            1,
+		})
+	}
+	return w
+}
+
+func BenchmarkWriteWldRawSyntheticZone(b *testing.B) {
+	w := syntheticZone(4000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := w.WriteWldRaw(io.Discard)
+		if err != nil {
+			b.Fatalf("write wld: %s", err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package wce_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		wce  *wce.Wce
+	}{
+		{name: "chr", wce: referenceWce()},
+		{name: "zone", wce: syntheticZone(10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &bytes.Buffer{}
+			err := tt.wce.WriteWldRaw(want)
+			if err != nil {
+				t.Fatalf("write wld: %s", err)
+			}
+
+			path := filepath.Join(t.TempDir(), tt.name+".json")
+			err = tt.wce.WriteJSON(path)
+			if err != nil {
+				t.Fatalf("write json: %s", err)
+			}
+			dst, err := wce.ReadJSON(tt.wce.FileName, path)
+			if err != nil {
+				t.Fatalf("read json: %s", err)
+			}
+
+			got := &bytes.Buffer{}
+			err = dst.WriteWldRaw(got)
+			if err != nil {
+				t.Fatalf("write wld from json: %s", err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Fatalf("wld from json is %d bytes, want %d bytes", got.Len(), want.Len())
+			}
+		})
+	}
+}
+
+func TestReadJSONSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{
+			name: "wrong types",
+			json: `{"FileName": "a.wld", "WorldDef": {"NewWorld": 0, "Zone": 0, "EqgVersion": {"Int8": 0, "Valid": false}},
+				"MaterialDefs": [{"Tag": "A_MDF", "RGBPen": [255, 255, 256, 0]}, {"Tag": 5}],
+				"DMSpriteDef2s": [{"Tag": "A_DMSPRITEDEF", "Vertices": [[0, 0, 0], [0, "1", 0], [0, 0]]}]}`,
+			want: []string{
+				"/DMSpriteDef2s/0/Vertices/1/1: expected number, got string",
+				"/DMSpriteDef2s/0/Vertices/2: expected at least 3 items, got 2",
+				"/MaterialDefs/0/RGBPen/2: 256 is greater than the maximum 255",
+				"/MaterialDefs/1/Tag: expected string, got integer",
+			},
+		},
+		{
+			name: "missing and unknown",
+			json: `{"FileName": "a.wld", "SimpleSpriteDefs": [{"TagIndex": 1.5, "Unknown": 1}], "GlobalAmbientLightDef": {"Color": "red"}}`,
+			want: []string{
+				"/: missing required property WorldDef",
+				"/GlobalAmbientLightDef/Color: expected array, got string",
+				"/SimpleSpriteDefs/0: missing required property Tag",
+				"/SimpleSpriteDefs/0/TagIndex: expected integer, got number",
+				"/SimpleSpriteDefs/0/Unknown: unknown property",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.json")
+			err := os.WriteFile(path, []byte(tt.json), 0644)
+			if err != nil {
+				t.Fatalf("write: %s", err)
+			}
+			_, err = wce.ReadJSON("test.wld", path)
+			var jsonErrs wce.JSONErrors
+			if !errors.As(err, &jsonErrs) {
+				t.Fatalf("read: got %v, want json errors", err)
+			}
+			got := []string{}
+			for _, jsonErr := range jsonErrs {
+				got = append(got, jsonErr.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package wce_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xackery/quail/bake"
+	"github.com/xackery/quail/wce"
+)
+
+func TestLightMeshes(t *testing.T) {
+	zone := wce.New("test.wld")
+	zone.GlobalAmbientLightDef = &wce.GlobalAmbientLightDef{Color: [4]uint8{0, 0, 255, 255}}
+	zone.DMSpriteDef2s = append(zone.DMSpriteDef2s, &wce.DMSpriteDef2{
+		Tag:           "R1_DMSPRITEDEF",
+		CenterOffset:  [3]float32{0, 0, 1},
+		Vertices:      [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		VertexNormals: [][3]float32{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		Faces:         []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+	})
+
+	models := wce.New("test_obj.wld")
+	models.DMSpriteDef2s = append(models.DMSpriteDef2s, &wce.DMSpriteDef2{
+		Tag:           "BOX_DMSPRITEDEF",
+		Vertices:      [][3]float32{{1, 0, 0}, {0, 0, 0}},
+		VertexNormals: [][3]float32{{1, 0, 0}, {0, 0, 1}},
+	})
+	models.ActorDefs = append(models.ActorDefs, &wce.ActorDef{
+		Tag:     "BOX_ACTORDEF",
+		Actions: []wce.ActorAction{{LevelOfDetails: []wce.ActorLevelOfDetail{{SpriteTag: "BOX_DMSPRITEDEF"}}}},
+	})
+
+	objects := wce.New("objects.wld")
+	inst := &wce.ActorInst{DefinitionTag: "BOX_ACTORDEF"}
+	inst.Location.Valid = true
+	// a quarter turn about z, 2 times the size
+	inst.Location.Float32Slice6 = [6]float32{10, 0, 0, 128, 0, 0}
+	inst.Scale.Valid = true
+	inst.Scale.Float32 = 2
+	objects.ActorInsts = append(objects.ActorInsts, inst)
+
+	lights := wce.New("lights.wld")
+	lights.LightDefs = append(lights.LightDefs, &wce.LightDef{Tag: "L1_LDEF", Colors: [][3]float32{{0, 1, 0}}})
+	lights.PointLights = append(lights.PointLights, &wce.PointLight{LightDefTag: "L1_LDEF", Location: [3]float32{0, 0, 5}, Radius: 8})
+
+	ambient, ok := zone.LightAmbient()
+	if !ok || ambient != [3]float32{1, 0, 0} {
+		t.Fatalf("ambient: got %v %t, want red", ambient, ok)
+	}
+	baker := &bake.Baker{Lights: lights.Lights(), Ambient: ambient}
+	if len(baker.Lights) != 1 || baker.Lights[0].Color != [3]float32{0, 1, 0} {
+		t.Fatalf("lights: got %+v", baker.Lights)
+	}
+
+	meshes, err := zone.LightMeshes(models)
+	if err != nil {
+		t.Fatalf("zone meshes: %s", err)
+	}
+	if len(meshes) != 1 || meshes[0].Positions[1] != [3]float32{1, 0, 1} || len(meshes[0].Indices) != 3 {
+		t.Fatalf("zone meshes: got %+v", meshes)
+	}
+	objectMeshes, err := objects.LightMeshes(models)
+	if err != nil {
+		t.Fatalf("object meshes: %s", err)
+	}
+	if len(objectMeshes) != 1 {
+		t.Fatalf("object meshes: got %d, want 1", len(objectMeshes))
+	}
+	position := objectMeshes[0].Positions[0]
+	if math.Abs(float64(position[0]-10)) > 1e-4 || math.Abs(float64(position[1]-2)) > 1e-4 {
+		t.Fatalf("object position: got %v, want 10 2 0", position)
+	}
+	normal := objectMeshes[0].Normals[0]
+	if math.Abs(float64(normal[1]-1)) > 1e-4 {
+		t.Fatalf("object normal: got %v, want 0 1 0", normal)
+	}
+
+	for _, mesh := range append(meshes, objectMeshes...) {
+		err = mesh.SetColors(baker.Bake(mesh.Positions, mesh.Normals))
+		if err != nil {
+			t.Fatalf("set colors: %s", err)
+		}
+	}
+	// 4 below a light that reaches 8, straight on
+	sprite := zone.DMSpriteDef2s[0]
+	if len(sprite.VertexColors) != 3 || sprite.VertexColors[0] != [4]uint8{255, 128, 0, 255} {
+		t.Fatalf("region colors: got %v", sprite.VertexColors)
+	}
+	if !inst.DMRGBTrackTag.Valid || len(objects.RGBTrackDefs) != 1 || objects.RGBTrackDefs[0].Tag != inst.DMRGBTrackTag.String {
+		t.Fatalf("rgb track: got %+v, tracks %d", inst.DMRGBTrackTag, len(objects.RGBTrackDefs))
+	}
+	if objects.RGBTrackDefs[0].RGBAs[0] != [4]uint8{255, 0, 0, 255} {
+		t.Fatalf("rgb track colors: got %v, want ambient only", objects.RGBTrackDefs[0].RGBAs)
+	}
+
+	// baking again keeps the track
+	err = objectMeshes[0].SetColors(make([][4]uint8, 2))
+	if err != nil {
+		t.Fatalf("set colors again: %s", err)
+	}
+	if len(objects.RGBTrackDefs) != 1 || objects.RGBTrackDefs[0].RGBAs[0] != [4]uint8{} {
+		t.Fatalf("rgb track after bake again: %d tracks", len(objects.RGBTrackDefs))
+	}
+	if objectMeshes[0].SetColors(nil) == nil {
+		t.Fatalf("wrong color count: want error")
+	}
+}
+
+func TestLightMeshesZon(t *testing.T) {
+	w := wce.New("test.eqg")
+	w.ModDefs = append(w.ModDefs, &wce.EqgModDef{
+		Tag:      "crate",
+		Vertices: []*wce.ModVertex{{Position: [3]float32{1, 0, 0}}, {Position: [3]float32{0, 1, 0}}, {Position: [3]float32{0, 0, 1}}},
+		Faces:    []*wce.ModFace{{Index: [3]uint32{0, 1, 2}}},
+	})
+	zon := &wce.EqgZonDef{
+		Tag:       "test",
+		Version:   2,
+		Instances: []wce.EqgZonInstance{{ModelTag: "crate.mod", InstanceTag: "crate01", Translation: [3]float32{0, 0, 3}, Scale: 1}},
+		Lights:    []wce.EqgZonLight{{Position: [3]float32{0, 0, 3}, Color: [3]float32{1, 1, 1}, Radius: 10}},
+	}
+	w.ZonDefs = append(w.ZonDefs, zon)
+
+	meshes, err := w.LightMeshes()
+	if err != nil {
+		t.Fatalf("meshes: %s", err)
+	}
+	if len(meshes) != 1 || meshes[0].LitName != "" || meshes[0].Positions[2] != [3]float32{0, 0, 4} {
+		t.Fatalf("meshes: got %+v", meshes)
+	}
+	err = meshes[0].SetColors([][4]uint8{{1, 2, 3, 4}, {}, {}})
+	if err != nil {
+		t.Fatalf("set colors: %s", err)
+	}
+	if len(zon.Instances[0].Lits) != 3 || zon.Instances[0].Lits[0] != 0x04010203 {
+		t.Fatalf("lits: got %x", zon.Instances[0].Lits)
+	}
+	if len(w.Lights()) != 1 {
+		t.Fatalf("lights: got %d, want 1", len(w.Lights()))
+	}
+
+	// v1 zones keep lits in a .lit file named by the instance
+	zon.Version = 1
+	meshes, err = w.LightMeshes()
+	if err != nil {
+		t.Fatalf("v1 meshes: %s", err)
+	}
+	if meshes[0].LitName != "crate01.lit" {
+		t.Fatalf("v1 lit name: got %s", meshes[0].LitName)
+	}
+}
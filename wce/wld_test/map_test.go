@@ -0,0 +1,80 @@
+package wce_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+func TestWriteMapTilesRaw(t *testing.T) {
+	info := &raw.V4Info{QuadsPerTile: 2, UnitsPerVert: 4}
+	dat := &raw.DatZon{QuadsPerTile: 2}
+	// a flat tile, and a sloped tile with a hole in its last quad
+	dat.Tiles = append(dat.Tiles, &raw.DatZonTile{Lat: 100001, Lng: 100000, Floats: make([]float32, 9), Flags: make([]uint8, 4)})
+	dat.Tiles = append(dat.Tiles, &raw.DatZonTile{Lat: 100000, Lng: 99999, Floats: []float32{0, 1, 2, 0, 1, 2, 0, 1, 2}, Flags: []uint8{0, 0, 0, 1}})
+
+	dst := &raw.Map{}
+	err := wce.WriteMapTilesRaw(dst, info, dat)
+	if err != nil {
+		t.Fatalf("write tiles: %s", err)
+	}
+	if len(dst.Tiles) != 2 || dst.QuadsPerTile != 2 || dst.UnitsPerVertex != 4 {
+		t.Fatalf("tiles: got %d, %d quads, %0.2f units", len(dst.Tiles), dst.QuadsPerTile, dst.UnitsPerVertex)
+	}
+	flat := dst.Tiles[0]
+	if !flat.Flat || flat.X != 8 || flat.Y != 0 || flat.Floats != nil {
+		t.Fatalf("flat tile: got %+v", flat)
+	}
+	sloped := dst.Tiles[1]
+	if sloped.Flat || sloped.X != 0 || sloped.Y != -8 || len(sloped.Floats) != 9 {
+		t.Fatalf("sloped tile: got %+v", sloped)
+	}
+
+	// 2 triangles for the flat tile, 2 for each of the 3 sloped quads that are not holes
+	verts, indices := dst.CollidableTriangles()
+	if len(indices) != 8*3 {
+		t.Fatalf("tile triangles: got %d, want 8", len(indices)/3)
+	}
+	// the first sloped quad rises along y
+	if verts[4] != [3]float32{0, -8, 0} || verts[6] != [3]float32{4, -4, 1} {
+		t.Fatalf("sloped quad: got %v", verts[4:8])
+	}
+
+	err = wce.WriteMapTilesRaw(dst, &raw.V4Info{QuadsPerTile: 4, UnitsPerVert: 4}, &raw.DatZon{})
+	if err == nil {
+		t.Fatalf("mismatched tiles: want error")
+	}
+}
+
+func TestWriteMapWallsRaw(t *testing.T) {
+	src := &raw.DatIw{Walls: []*raw.DatIwWall{{Name: "wall", Vertices: [][3]float32{{0, 0, 0}, {10, 0, 0}, {10, 10, 0}}}}}
+	buf := &bytes.Buffer{}
+	err := src.Write(buf)
+	if err != nil {
+		t.Fatalf("write invw: %s", err)
+	}
+	walls := &raw.DatIw{}
+	err = walls.Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("read invw: %s", err)
+	}
+	if len(walls.Walls) != 1 || walls.Walls[0].Name != "wall" || len(walls.Walls[0].Vertices) != 3 {
+		t.Fatalf("invw round trip: got %+v", walls.Walls)
+	}
+
+	dst := &raw.Map{}
+	err = wce.WriteMapWallsRaw(dst, walls)
+	if err != nil {
+		t.Fatalf("write walls: %s", err)
+	}
+	// 2 edges, each a quad facing both ways
+	if len(dst.Indices) != 8*3 || len(dst.Vertices) != 6 {
+		t.Fatalf("walls: got %d faces, %d vertices", len(dst.Indices)/3, len(dst.Vertices))
+	}
+	// x and y are swapped to server coordinates
+	if dst.Vertices[0] != [3]float32{0, 10, 0} {
+		t.Fatalf("wall vertex: got %v, want 0 10 0", dst.Vertices[0])
+	}
+}
@@ -0,0 +1,180 @@
+package wce_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+// migrations has a golden file for each migration, written by the version it upgrades from
+var migrations = []struct {
+	from string
+	in   string
+	want string
+}{
+	{
+		from: "v0.0.0",
+		in: `WORLDDEF
+	NEWWORLD 0
+	ZONE 1
+	EQGVERSION NULL
+
+MATERIALDEFINITION "TEST_MDF"
+	TAGINDEX 0
+	VARIATION 0
+	RENDERMETHOD "TRANSPARENT"
+	RGBPEN 255 255 255 0
+	BRIGHTNESS 5.00000000e-01
+	SCALEDAMBIENT 1.00000000e+00
+	SIMPLESPRITEINST
+		// sprite this material draws
+		SIMPLESPRITETAG "TEST_SPRITE"
+		SIMPLESPRITETAGINDEX 0
+		SIMPLESPRITEHEXFIFTYFLAG 0
+	PAIRS 0 0.00000000e+00
+	DOUBLESIDED 0
+
+SIMPLESPRITEDEF "TEST_SPRITE"
+	TAGINDEX 0
+	VARIATION 0
+	SKIPFRAMES NULL
+	ANIMATED NULL
+	SLEEP NULL
+	CURRENTFRAME? NULL
+	NUMFRAMES 1
+		FRAME "TEST"
+			NUMFILES 1
+				FILE "TEST.BMP"
+`,
+		want: `// wcemu ` + wce.AsciiVersion + `
+WORLDDEF
+	NEWWORLD 0
+	ZONE 1
+	EQGVERSION? NULL
+
+MATERIALDEFINITION "TEST_MDF"
+	TAGINDEX 0
+	VARIATION 0
+	RENDERMETHOD "TRANSPARENT"
+	RGBPEN 255 255 255 0
+	BRIGHTNESS 5.00000000e-01
+	SCALEDAMBIENT 1.00000000e+00
+	SIMPLESPRITEINST
+		// sprite this material draws
+		SIMPLESPRITETAG "TEST_SPRITE"
+		SIMPLESPRITETAGINDEX 0
+		SIMPLESPRITEHEXFIFTYFLAG 0
+	PAIRS? 0 0.00000000e+00
+	DOUBLESIDED 0
+
+SIMPLESPRITEDEF "TEST_SPRITE"
+	TAGINDEX 0
+	VARIATION 0
+	SKIPFRAMES? NULL
+	ANIMATED? NULL
+	SLEEP? NULL
+	CURRENTFRAME? NULL
+	NUMFRAMES 1
+		FRAME "TEST"
+			NUMFILES 1
+				FILE "TEST.BMP"
+`,
+	},
+}
+
+func TestAsciiUpgrade(t *testing.T) {
+	for _, tt := range migrations {
+		t.Run(tt.from, func(t *testing.T) {
+			version, err := wce.AsciiFileVersion([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("version: %s", err)
+			}
+			if version != tt.from {
+				t.Fatalf("version: got %s, want %s", version, tt.from)
+			}
+
+			out, from, err := wce.AsciiUpgrade([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("upgrade: %s", err)
+			}
+			if from != tt.from {
+				t.Fatalf("upgrade from: got %s, want %s", from, tt.from)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("upgrade:\n%s\nwant:\n%s", out, tt.want)
+			}
+
+			again, _, err := wce.AsciiUpgrade(out)
+			if err != nil {
+				t.Fatalf("upgrade again: %s", err)
+			}
+			if string(again) != string(out) {
+				t.Fatalf("upgrade again changed the file:\n%s", again)
+			}
+
+			// reading the old file migrates it the same way
+			dir := t.TempDir()
+			old := readAsciiJSON(t, filepath.Join(dir, "old"), tt.in)
+			upgraded := readAsciiJSON(t, filepath.Join(dir, "upgraded"), string(out))
+			if old != upgraded {
+				t.Fatalf("reading the old file differs from reading the upgraded file")
+			}
+		})
+	}
+}
+
+// readAsciiJSON reads data as a _root.wce in dir, returning the json of what was read
+func readAsciiJSON(t *testing.T, dir string, data string) string {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	path := filepath.Join(dir, "_root.wce")
+	err = os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	w := wce.New("test.s3d")
+	err = w.ReadAscii(path)
+	if err != nil {
+		t.Fatalf("read %s: %s", dir, err)
+	}
+	out, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("json: %s", err)
+	}
+	return string(out)
+}
+
+func TestAsciiUpgradeErrors(t *testing.T) {
+	_, _, err := wce.AsciiUpgrade([]byte("// wcemu v99.0.0\nWORLDDEF\n"))
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("newer version: got %v", err)
+	}
+	_, err = wce.AsciiFileVersion([]byte("// wcemu 1\n"))
+	if err == nil {
+		t.Fatalf("invalid version: expected error")
+	}
+
+	// errors in migrated files point at the line in the file as written
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_root.wce")
+	err = os.WriteFile(path, []byte("\nSIMPLESPRITEDEF \"A_SPRITE\"\n\tTAGINDEX 0\n\tVARIATION 0\n\tSKIPFRAMES x\n"), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	w := wce.New("test.s3d")
+	err = w.ReadAscii(path)
+	var parseErrs wce.ParseErrors
+	if !errors.As(err, &parseErrs) || len(parseErrs) != 1 {
+		t.Fatalf("read: got %v, want one parse error", err)
+	}
+	if parseErrs[0].Position.Line != 5 {
+		t.Fatalf("error line: got %d, want 5", parseErrs[0].Position.Line)
+	}
+}
@@ -0,0 +1,73 @@
+package wce_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	root := `// wcemu v0.0.1
+
+MATERIALPALETTE "BROKEN_MP"
+	NUMMATERIALS 2
+	MATERIAL "A_MDF"
+MATERIALPALETTE "GOOD_MP"
+	NUMMATERIALS 1
+	MATERIAL "B_MDF"
+
+FOOBAR "X"
+	JUNK 1
+INCLUDE "sub.wce"
+MATERIALPALETTE "LAST_MP"
+	NUMMATERIALS 0
+`
+	sub := `MATERIALPALETTE "SUB_MP"
+	NUMMATERIALS x
+`
+	rootPath := filepath.Join(dir, "_root.wce")
+	subPath := filepath.Join(dir, "sub.wce")
+	err := os.WriteFile(rootPath, []byte(root), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	err = os.WriteFile(subPath, []byte(sub), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	w := wce.New("test.wce")
+	err = w.ReadAscii(rootPath)
+	var parseErrs wce.ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Fatalf("read: got %v, want parse errors", err)
+	}
+	want := []wce.Position{
+		{File: rootPath, Line: 6, Col: 1},
+		{File: rootPath, Line: 10, Col: 1},
+		{File: subPath, Line: 2, Col: 2},
+	}
+	if len(parseErrs) != len(want) {
+		t.Fatalf("errors: got %d, want %d\n%s", len(parseErrs), len(want), err)
+	}
+	for i, parseErr := range parseErrs {
+		if parseErr.Position != want[i] {
+			t.Fatalf("error %d: got %s, want %s", i, parseErr.Position, want[i])
+		}
+	}
+	if parseErrs[0].Tag != "BROKEN_MP" {
+		t.Fatalf("error 0: got tag %s, want BROKEN_MP", parseErrs[0].Tag)
+	}
+
+	if len(w.MaterialPalettes) != 2 || w.MaterialPalettes[0].Tag != "GOOD_MP" || w.MaterialPalettes[1].Tag != "LAST_MP" {
+		t.Fatalf("palettes: got %d, want GOOD_MP and LAST_MP", len(w.MaterialPalettes))
+	}
+	pos, ok := w.Position(w.MaterialPalettes[0])
+	if !ok || pos != (wce.Position{File: rootPath, Line: 6, Col: 1}) {
+		t.Fatalf("GOOD_MP position: got %s", pos)
+	}
+}
@@ -0,0 +1,104 @@
+package wce_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestAttachPoints(t *testing.T) {
+	w := animWce()
+	hum, err := w.Skeleton("HUM")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	if len(w.AttachPoints(hum)) != 0 {
+		t.Fatalf("points before add: %d", len(w.AttachPoints(hum)))
+	}
+
+	err = w.AttachPointSet(hum, &wce.AttachPoint{Name: "r_point", Bone: "NOPE"})
+	if err == nil {
+		t.Fatalf("missing bone: want error")
+	}
+	err = w.AttachPointSet(hum, &wce.AttachPoint{Name: "r_point", Bone: "PE", Translation: [3]float32{1, 0, 0}})
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	hum, err = w.Skeleton("HUM")
+	if err != nil {
+		t.Fatalf("skeleton after add: %s", err)
+	}
+	point := w.AttachPoint(hum, "R_POINT")
+	if point == nil || point.Bone != "PE" || point.Translation != [3]float32{1, 0, 0} {
+		t.Fatalf("point after add: %+v", point)
+	}
+	// the pelvis rests 2 above the root
+	pose, err := wce.AttachPointPose(hum, point)
+	if err != nil {
+		t.Fatalf("pose: %s", err)
+	}
+	nearTransform(t, "pose", pose, [3]float32{1, 0, 2}, [4]float32{0, 0, 0, 1})
+
+	// move it to the root, turned a quarter around z
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	err = w.AttachPointSet(hum, &wce.AttachPoint{Name: "R_POINT", Bone: "ROOT", Translation: [3]float32{0, 1, 0}, Rotation: turn})
+	if err != nil {
+		t.Fatalf("move: %s", err)
+	}
+	back, err := wldRoundTrip(w)
+	if err != nil {
+		t.Fatalf("round trip: %s", err)
+	}
+	hum, err = back.Skeleton("HUM")
+	if err != nil {
+		t.Fatalf("skeleton after round trip: %s", err)
+	}
+	points := back.AttachPoints(hum)
+	if len(points) != 1 || points[0].Bone != "ROOT" {
+		t.Fatalf("points after move: %+v", points)
+	}
+	nearTransform(t, "moved", wce.TrackTransform{Translation: points[0].Translation, Rotation: points[0].Rotation}, [3]float32{0, 1, 0}, turn)
+
+	err = w.AttachPointSet(hum, &wce.AttachPoint{Name: "PE", Bone: "ROOT"})
+	if err == nil {
+		t.Fatalf("bone as point: want error")
+	}
+}
+
+func TestAttachPointsEqg(t *testing.T) {
+	eqg := wce.New("zzz.eqg")
+	eqg.MdsDefs = append(eqg.MdsDefs, &wce.EqgMdsDef{
+		Tag: "zzz",
+		Bones: []*wce.MdsBone{
+			{Name: "root", ChildIndex: 1, Next: -1, Quaternion: [4]float32{0, 0, 0, 1}},
+			{Name: "r_hand", ChildIndex: -1, Next: -1, Pivot: [3]float32{0, 0, 1}, Quaternion: [4]float32{0, 0, 0, 1}},
+		},
+	})
+	skel, err := eqg.Skeleton("zzz")
+	if err != nil {
+		t.Fatalf("skeleton: %s", err)
+	}
+	turn := [4]float32{0, 0, float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}
+	err = eqg.AttachPointSet(skel, &wce.AttachPoint{Name: "R_POINT", Bone: "R_HAND", Translation: [3]float32{0, 0, 0.5}, Rotation: turn})
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	if len(eqg.PtsDefs) != 1 || eqg.PtsDefs[0].Tag != "zzz" || eqg.PtsDefs[0].Points[0].BoneName != "r_hand" {
+		t.Fatalf("pts: %+v", eqg.PtsDefs)
+	}
+	// the .pts keeps the turn as euler radians
+	if math.Abs(float64(eqg.PtsDefs[0].Points[0].Rotation[2])-math.Pi/2) > 1e-4 {
+		t.Fatalf("pts rotation: %v", eqg.PtsDefs[0].Points[0].Rotation)
+	}
+	point := eqg.AttachPoint(skel, "r_point")
+	if point == nil {
+		t.Fatalf("point not found")
+	}
+	nearTransform(t, "point", wce.TrackTransform{Translation: point.Translation, Rotation: point.Rotation}, [3]float32{0, 0, 0.5}, turn)
+	pose, err := wce.AttachPointPose(skel, point)
+	if err != nil {
+		t.Fatalf("pose: %s", err)
+	}
+	nearTransform(t, "pose", pose, [3]float32{0, 0, 1.5}, turn)
+}
@@ -0,0 +1,106 @@
+package wce_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func referenceWce() *wce.Wce {
+	w := wce.New("test_chr.s3d")
+	w.ActorDefs = append(w.ActorDefs, &wce.ActorDef{
+		Tag:     "ELF_ACTORDEF",
+		Actions: []wce.ActorAction{{LevelOfDetails: []wce.ActorLevelOfDetail{{SpriteTag: "ELF_HS_DEF"}}}},
+	})
+	w.HierarchicalSpriteDefs = append(w.HierarchicalSpriteDefs, &wce.HierarchicalSpriteDef{
+		Tag:           "ELF_HS_DEF",
+		Dags:          []wce.Dag{{Tag: "ELF_DAG", Track: "ELF_TRACK"}},
+		AttachedSkins: []wce.AttachedSkin{{DMSpriteTag: "ELF_DMSPRITEDEF"}},
+	})
+	w.TrackInstances = append(w.TrackInstances,
+		&wce.TrackInstance{Tag: "ELF_TRACK", SpriteTag: "ELF_TRACKDEF"},
+		&wce.TrackInstance{Tag: "C01ELF_TRACK", SpriteTag: "C01ELF_TRACKDEF"},
+		&wce.TrackInstance{Tag: "C01DWF_TRACK", SpriteTag: "C01DWF_TRACKDEF"},
+	)
+	w.TrackDefs = append(w.TrackDefs,
+		&wce.TrackDef{Tag: "ELF_TRACKDEF"},
+		&wce.TrackDef{Tag: "C01ELF_TRACKDEF"},
+		&wce.TrackDef{Tag: "C01DWF_TRACKDEF"},
+	)
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s, &wce.DMSpriteDef2{Tag: "ELF_DMSPRITEDEF", MaterialPaletteTag: "ELF_MP"})
+	w.MaterialPalettes = append(w.MaterialPalettes, &wce.MaterialPalette{Tag: "ELF_MP", Materials: []string{"ELFCH0001_MDF"}})
+	w.MaterialDefs = append(w.MaterialDefs,
+		&wce.MaterialDef{Tag: "ELFCH0001_MDF", SimpleSpriteTag: "ELFCH0001_SPRITE"},
+		&wce.MaterialDef{Tag: "ELFCH0101_MDF", Variation: 1, SimpleSpriteTag: "ELFCH0101_SPRITE"},
+		&wce.MaterialDef{Tag: "UNUSED_MDF", SimpleSpriteTag: "UNUSED_SPRITE"},
+	)
+	w.SimpleSpriteDefs = append(w.SimpleSpriteDefs,
+		&wce.SimpleSpriteDef{Tag: "ELFCH0001_SPRITE"},
+		&wce.SimpleSpriteDef{Tag: "ELFCH0101_SPRITE"},
+		&wce.SimpleSpriteDef{Tag: "UNUSED_SPRITE"},
+		&wce.SimpleSpriteDef{Tag: "ORPHAN_SPRITE"},
+	)
+	return w
+}
+
+func definitionTags(defs []wce.WldDefinitioner) string {
+	tags := []string{}
+	for _, def := range defs {
+		switch e := def.(type) {
+		case *wce.TrackInstance:
+			tags = append(tags, e.Tag)
+		case *wce.TrackDef:
+			tags = append(tags, e.Tag)
+		case *wce.MaterialDef:
+			tags = append(tags, e.Tag)
+		case *wce.SimpleSpriteDef:
+			tags = append(tags, e.Tag)
+		case *wce.DMSpriteDef2:
+			tags = append(tags, e.Tag)
+		case *wce.ActorDef:
+			tags = append(tags, e.Tag)
+		default:
+			tags = append(tags, def.Definition())
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+func TestReferences(t *testing.T) {
+	w := referenceWce()
+
+	got := definitionTags(w.References("ELF_HS_DEF"))
+	want := "ELF_DMSPRITEDEF,ELF_TRACK,C01ELF_TRACK"
+	if got != want {
+		t.Fatalf("references: %s, want %s", got, want)
+	}
+
+	got = definitionTags(w.ReferencedBy("ELFCH0001_SPRITE"))
+	want = "ELFCH0001_MDF"
+	if got != want {
+		t.Fatalf("referenced by: %s, want %s", got, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	w := referenceWce()
+
+	removed, err := w.Prune()
+	if err != nil {
+		t.Fatalf("prune: %s", err)
+	}
+	got := definitionTags(removed)
+	want := "UNUSED_MDF,UNUSED_SPRITE,ORPHAN_SPRITE,C01DWF_TRACKDEF,C01DWF_TRACK"
+	if got != want {
+		t.Fatalf("removed: %s, want %s", got, want)
+	}
+	if len(w.MaterialDefs) != 2 || len(w.SimpleSpriteDefs) != 2 || len(w.TrackInstances) != 2 || len(w.TrackDefs) != 2 {
+		t.Fatalf("kept %d materials, %d sprites, %d tracks, %d trackdefs", len(w.MaterialDefs), len(w.SimpleSpriteDefs), len(w.TrackInstances), len(w.TrackDefs))
+	}
+
+	_, err = w.Prune("MISSING_ACTORDEF")
+	if err == nil {
+		t.Fatalf("prune missing root: expected error")
+	}
+}
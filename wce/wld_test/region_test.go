@@ -0,0 +1,235 @@
+package wce_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/xackery/quail/pvs"
+	"github.com/xackery/quail/raw"
+	"github.com/xackery/quail/wce"
+)
+
+func TestRegionTypes(t *testing.T) {
+	w := wce.New("test.wce")
+	for i := 0; i < 4; i++ {
+		w.Regions = append(w.Regions, &wce.Region{})
+	}
+	w.Zones = []*wce.Zone{
+		{Tag: "WT_ZONE", Regions: []uint32{0, 1}},
+		{Tag: "DRNTP00025000100-00200000005128_ZONE", Regions: []uint32{2}},
+	}
+
+	regionTypes := w.RegionTypes()
+	if regionTypes[1].Type != raw.WtrRegionTypeWater {
+		t.Fatalf("region 1: got %s, want water", regionTypes[1].Type)
+	}
+	zoneLine := regionTypes[2].ZoneLine
+	if regionTypes[2].Type != raw.WtrRegionTypeZoneLine || zoneLine == nil {
+		t.Fatalf("region 2: got %s, want zoneline", regionTypes[2].Type)
+	}
+	if *zoneLine != (wce.ZoneLine{ZoneID: 25, X: 100, Y: -200, Z: 5, Heading: 128}) {
+		t.Fatalf("region 2: got zoneline %s", zoneLine)
+	}
+
+	err := w.SetRegionType(2, raw.WtrRegionTypeLava, nil)
+	if err != nil {
+		t.Fatalf("set lava: %s", err)
+	}
+	err = w.SetRegionType(1, raw.WtrRegionTypeZoneLine, &wce.ZoneLine{ZoneID: 255, Index: 3})
+	if err != nil {
+		t.Fatalf("set zoneline: %s", err)
+	}
+	err = w.SetRegionType(3, raw.WtrRegionTypeWater, nil)
+	if err != nil {
+		t.Fatalf("set water: %s", err)
+	}
+
+	want := []raw.WtrRegionType{raw.WtrRegionTypeWater, raw.WtrRegionTypeZoneLine, raw.WtrRegionTypeLava, raw.WtrRegionTypeWater}
+	regionTypes = w.RegionTypes()
+	for i, regionType := range regionTypes {
+		if regionType.Type != want[i] {
+			t.Fatalf("region %d: got %s, want %s", i, regionType.Type, want[i])
+		}
+	}
+	if regionTypes[1].ZoneLine == nil || regionTypes[1].ZoneLine.Index != 3 {
+		t.Fatalf("region 1: got zoneline %v, want zone point 3", regionTypes[1].ZoneLine)
+	}
+	// the old zoneline zone is empty and removed, water now holds 0 and 3
+	if len(w.Zones) != 3 {
+		t.Fatalf("zones: got %d, want 3", len(w.Zones))
+	}
+	if len(w.Zones[0].Regions) != 2 || w.Zones[0].Regions[1] != 3 {
+		t.Fatalf("water zone regions: got %v, want [0 3]", w.Zones[0].Regions)
+	}
+}
+
+func TestRegionAt(t *testing.T) {
+	tree := &wce.WorldTree{WorldNodes: []*wce.WorldNode{
+		{Normals: [4]float32{0, 0, 1, -10}, FrontTree: 2, BackTree: 3},
+		{WorldRegionTag: "R000001"},
+		{WorldRegionTag: "R000002"},
+	}}
+	if got := tree.RegionAt(0, 0, 20); got != "R000001" {
+		t.Fatalf("above split: got %q, want R000001", got)
+	}
+	if got := tree.RegionAt(0, 0, 0); got != "R000002" {
+		t.Fatalf("below split: got %q, want R000002", got)
+	}
+}
+
+func TestVisListDecode(t *testing.T) {
+	// skip 2, include 3, skip 1 include 2, include 1 skip 4, skip 0x102, include 2
+	regions, err := wce.VisListDecode([]byte{0x02, 0xc3, 0x4a, 0x8c, 0x3f, 0x02, 0x01, 0xff, 0x02, 0x00}, true)
+	if err != nil {
+		t.Fatalf("decode: %s", err)
+	}
+	want := []int{2, 3, 4, 6, 7, 8, 271, 272}
+	if !reflect.DeepEqual(regions, want) {
+		t.Fatalf("decode: got %v, want %v", regions, want)
+	}
+
+	regions, err = wce.VisListDecode([]byte{0x05, 0x00, 0x01, 0x01}, false)
+	if err != nil {
+		t.Fatalf("decode words: %s", err)
+	}
+	if !reflect.DeepEqual(regions, []int{5, 257}) {
+		t.Fatalf("decode words: got %v, want [5 257]", regions)
+	}
+}
+
+func TestVisListEncode(t *testing.T) {
+	regions := []int{0, 1, 2, 5}
+	for i := 100; i < 200; i++ {
+		regions = append(regions, i)
+	}
+	ranges, err := wce.VisListEncode(regions)
+	if err != nil {
+		t.Fatalf("encode: %s", err)
+	}
+	want := []byte{0x9a, 0xc1, 0x3f, 94, 0, 0xff, 100, 0}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("encode: got % x, want % x", ranges, want)
+	}
+
+	for _, regions := range [][]int{{}, {3}, {7, 8, 20}, {1, 9, 10, 11, 12, 13, 14, 15, 16, 17, 30, 70000}, {40000, 0, 40000}} {
+		ranges, err := wce.VisListEncode(regions)
+		if err != nil {
+			t.Fatalf("encode %v: %s", regions, err)
+		}
+		decoded, err := wce.VisListDecode(ranges, true)
+		if err != nil {
+			t.Fatalf("decode %v: %s", regions, err)
+		}
+		want := map[int]bool{}
+		for _, region := range regions {
+			want[region] = true
+		}
+		if len(decoded) != len(want) {
+			t.Fatalf("round trip %v: got %v", regions, decoded)
+		}
+		for _, region := range decoded {
+			if !want[region] {
+				t.Fatalf("round trip %v: got %v", regions, decoded)
+			}
+		}
+	}
+	_, err = wce.VisListEncode([]int{-1})
+	if err == nil {
+		t.Fatalf("negative: want error")
+	}
+
+	region := &wce.Region{}
+	err = region.SetVisibleRegions([]int{4, 2})
+	if err != nil {
+		t.Fatalf("set visible: %s", err)
+	}
+	visible, err := region.VisibleRegions()
+	if err != nil || !reflect.DeepEqual(visible, []int{2, 4}) {
+		t.Fatalf("visible: got %v %v", visible, err)
+	}
+}
+
+func TestVisibilityBuild(t *testing.T) {
+	w := wce.New("test.wld")
+	// three regions along x, split at -10 and 10, with a wall across the middle one
+	w.WorldTrees = append(w.WorldTrees, &wce.WorldTree{WorldNodes: []*wce.WorldNode{
+		{Normals: [4]float32{1, 0, 0, -10}, FrontTree: 2, BackTree: 3},
+		{WorldRegionTag: "R3"},
+		{Normals: [4]float32{1, 0, 0, 10}, FrontTree: 4, BackTree: 5},
+		{WorldRegionTag: "R2"},
+		{WorldRegionTag: "R1"},
+	}})
+	floor := func(tag string, x float32) *wce.DMSpriteDef2 {
+		return &wce.DMSpriteDef2{
+			Tag:      tag,
+			Vertices: [][3]float32{{x - 10, -10, 0}, {x + 10, -10, 0}, {x, 10, 0}},
+			Faces:    []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}},
+		}
+	}
+	wall := &wce.DMSpriteDef2{
+		Tag:      "R2_DMSPRITEDEF",
+		Vertices: [][3]float32{{0, -200, -200}, {0, 200, -200}, {0, 200, 200}, {0, -200, 200}},
+		Faces:    []*wce.Face{{Triangle: [3]uint16{0, 1, 2}}, {Triangle: [3]uint16{0, 2, 3}}},
+	}
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s, floor("R1_DMSPRITEDEF", -20), wall, floor("R3_DMSPRITEDEF", 20))
+	for i := 1; i <= 3; i++ {
+		w.Regions = append(w.Regions, &wce.Region{Tag: fmt.Sprintf("R%d", i), SpriteTag: fmt.Sprintf("R%d_DMSPRITEDEF", i)})
+	}
+
+	cfg := pvs.DefaultConfig()
+	cfg.Spacing = 16
+	lists, err := w.VisibilityBuild(cfg)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+	want := [][]int{{0, 1}, {0, 1, 2}, {1, 2}}
+	if !reflect.DeepEqual(lists, want) {
+		t.Fatalf("build: got %v, want %v", lists, want)
+	}
+
+	// a passable wall is seen through
+	wall.Faces[0].Passable = 1
+	wall.Faces[1].Passable = 1
+	lists, err = w.VisibilityBuild(cfg)
+	if err != nil {
+		t.Fatalf("build passable: %s", err)
+	}
+	if len(lists[0]) != 3 {
+		t.Fatalf("build passable: got %v, want all visible", lists)
+	}
+
+	err = w.VisibilitySet(want)
+	if err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	visibility, err := w.Visibility()
+	if err != nil || !reflect.DeepEqual(visibility, want) {
+		t.Fatalf("visibility: got %v %v", visibility, err)
+	}
+	if w.VisibilitySet(want[:1]) == nil {
+		t.Fatalf("set too few: want error")
+	}
+}
+
+func TestWriteWtrRawAreas(t *testing.T) {
+	w := wce.New("test.wce")
+	// a 90 degree turn lays the long x extent of the area along y
+	w.ZonDefs = []*wce.EqgZonDef{{Areas: []wce.EqgZonRegion{
+		{Name: "AWT_1", Color: [3]float32{0, 0, 90}, Extents: [3]float32{10, 1, 1}},
+	}}}
+	dst := &raw.Wtr{}
+	err := w.WriteWtrRaw(dst)
+	if err != nil {
+		t.Fatalf("write wtr: %s", err)
+	}
+	if dst.Version != 2 || len(dst.Regions) != 1 {
+		t.Fatalf("wtr: got version %d with %d regions, want version 2 with 1 region", dst.Version, len(dst.Regions))
+	}
+	if got := dst.RegionType(0, 8, 0); got != raw.WtrRegionTypeWater {
+		t.Fatalf("along y: got %s, want water", got)
+	}
+	if got := dst.RegionType(8, 0, 0); got != raw.WtrRegionTypeNormal {
+		t.Fatalf("along x: got %s, want normal", got)
+	}
+}
@@ -0,0 +1,59 @@
+package wce_test
+
+import (
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestRenamePrefix(t *testing.T) {
+	w := referenceWce()
+
+	count, err := w.RenamePrefix("ELF", "ZZZ")
+	if err != nil {
+		t.Fatalf("rename: %s", err)
+	}
+	if count != 12 {
+		t.Fatalf("renamed %d definitions, want 12", count)
+	}
+	if w.ActorDefs[0].Tag != "ZZZ_ACTORDEF" || w.ActorDefs[0].Actions[0].LevelOfDetails[0].SpriteTag != "ZZZ_HS_DEF" {
+		t.Fatalf("actordef %s lod %s", w.ActorDefs[0].Tag, w.ActorDefs[0].Actions[0].LevelOfDetails[0].SpriteTag)
+	}
+	hs := w.HierarchicalSpriteDefs[0]
+	if hs.Dags[0].Tag != "ZZZ_DAG" || hs.Dags[0].Track != "ZZZ_TRACK" || hs.AttachedSkins[0].DMSpriteTag != "ZZZ_DMSPRITEDEF" {
+		t.Fatalf("hs dag %s track %s skin %s", hs.Dags[0].Tag, hs.Dags[0].Track, hs.AttachedSkins[0].DMSpriteTag)
+	}
+	if w.TrackInstances[1].Tag != "C01ZZZ_TRACK" || w.TrackInstances[1].SpriteTag != "C01ZZZ_TRACKDEF" {
+		t.Fatalf("animation track %s sprite %s", w.TrackInstances[1].Tag, w.TrackInstances[1].SpriteTag)
+	}
+	if w.TrackInstances[2].Tag != "C01DWF_TRACK" {
+		t.Fatalf("other model track renamed to %s", w.TrackInstances[2].Tag)
+	}
+	if w.MaterialPalettes[0].Materials[0] != "ZZZCH0001_MDF" || w.MaterialDefs[0].SimpleSpriteTag != "ZZZCH0001_SPRITE" {
+		t.Fatalf("palette material %s sprite %s", w.MaterialPalettes[0].Materials[0], w.MaterialDefs[0].SimpleSpriteTag)
+	}
+	if len(w.References("ZZZ_HS_DEF")) != 3 {
+		t.Fatalf("references lost after rename")
+	}
+}
+
+func TestRenameCollision(t *testing.T) {
+	w := referenceWce()
+
+	_, err := w.RenameTag("UNUSED_MDF", "ELFCH0001_MDF")
+	if err == nil {
+		t.Fatalf("rename onto existing tag: expected error")
+	}
+	if w.MaterialDefs[2].Tag != "UNUSED_MDF" {
+		t.Fatalf("rename changed %s after collision", w.MaterialDefs[2].Tag)
+	}
+
+	w.SimpleSpriteDefs = append(w.SimpleSpriteDefs, &wce.SimpleSpriteDef{Tag: "ZZZCH0001_SPRITE"})
+	_, err = w.RenamePrefix("ELF", "ZZZ")
+	if err == nil {
+		t.Fatalf("rename prefix onto existing tag: expected error")
+	}
+	if w.ActorDefs[0].Tag != "ELF_ACTORDEF" {
+		t.Fatalf("rename changed %s after collision", w.ActorDefs[0].Tag)
+	}
+}
@@ -0,0 +1,59 @@
+package wce_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xackery/quail/wce"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_root.wce")
+	data := `// wcemu v0.0.1
+
+MATERIALPALETTE "TEST_MP"
+	NUMMATERIALS 1
+	MATERIAL "MISSING_MDF"
+`
+	err := os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	w := wce.New("test.wce")
+	err = w.ReadAscii(path)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+
+	w.DMSpriteDef2s = append(w.DMSpriteDef2s, &wce.DMSpriteDef2{
+		Tag:                  "TEST_DMSPRITEDEF",
+		MaterialPaletteTag:   "TEST_MP",
+		Vertices:             [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		UVs:                  [][2]float32{{0, 0}},
+		VertexNormals:        [][3]float32{{0, 0, 1}, {0, -1, 0}, {0, 0, 1.5}},
+		Faces:                []*wce.Face{{Triangle: [3]uint16{0, 1, 3}}},
+		SkinAssignmentGroups: [][2]int16{{2, 0}},
+		FaceMaterialGroups:   [][2]uint16{{1, 0}},
+	})
+	w.ActorInsts = append(w.ActorInsts, &wce.ActorInst{DefinitionTag: "OTHER_ACTORDEF"})
+
+	findings := w.Validate()
+	want := []string{
+		path + ":3:1: error: MATERIALPALETTE TEST_MP: material MISSING_MDF not found",
+		"error: DMSPRITEDEF2 TEST_DMSPRITEDEF: 1 uvs, 3 vertices",
+		"warning: DMSPRITEDEF2 TEST_DMSPRITEDEF: vertex normal 2 0.0000 0.0000 1.5000 is outside -1 to 1",
+		"error: DMSPRITEDEF2 TEST_DMSPRITEDEF: face 0 vertex 3 out of range, 3 vertices",
+		"error: DMSPRITEDEF2 TEST_DMSPRITEDEF: skin assignment groups total 2, 3 vertices",
+		"warning: ACTORINST: definition OTHER_ACTORDEF not found",
+	}
+	got := []string{}
+	for _, finding := range findings {
+		got = append(got, finding.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}